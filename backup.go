@@ -0,0 +1,416 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"room-mapping-cache/internal/buildinfo"
+	"room-mapping-cache/internal/config"
+	"room-mapping-cache/internal/keys"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// backupFormat is the version of the backup file layout
+const backupFormat = 1
+
+// backupEntry is one hash in a backup file, stored as a line of JSON. Keys are
+// unprefixed and values are kept as stored, so still encrypted when
+// encryption is on.
+type backupEntry struct {
+	Key string `json:"key"`
+	// TTLMillis is the remaining lifetime at backup time; 0 means no expiry
+	TTLMillis int64             `json:"ttl_ms,omitempty"`
+	Fields    map[string]string `json:"fields"`
+}
+
+// backupManifest is written next to the backup file as <file>.manifest.json
+type backupManifest struct {
+	Format      int       `json:"format"`
+	CreatedAt   time.Time `json:"created_at"`
+	File        string    `json:"file"`
+	SHA256      string    `json:"sha256"`
+	Bytes       int64     `json:"bytes"`
+	Hashes      int       `json:"hashes"`
+	Fields      int       `json:"fields"`
+	KeyTemplate string    `json:"key_template"`
+	KeyPrefix   string    `json:"key_prefix,omitempty"`
+	Patterns    []string  `json:"patterns"`
+	Encrypted   bool      `json:"encrypted"`
+	Commit      string    `json:"commit"`
+}
+
+func manifestPath(file string) string {
+	return file + ".manifest.json"
+}
+
+// runBackup implements the "backup" subcommand: it scans every room hash,
+// snapshot and version key and streams them to a zstd-compressed JSON lines
+// file, then writes a manifest with the file's checksum. Keys written during
+// the scan may or may not be included. Exits non-zero if any key failed.
+func runBackup(args []string) int {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s backup [flags] <file>\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	configPath := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML config file; environment variables and flags override its values")
+	batchSize := fs.Int("batch", 500, "keys per SCAN page and Redis pipeline")
+	progressEvery := fs.Duration("progress", 5*time.Second, "interval between progress lines")
+	config.RegisterFlags(fs)
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 || *batchSize < 1 {
+		fs.Usage()
+		return 2
+	}
+	path := fs.Arg(0)
+
+	cfg := loadConfig(*configPath)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	redisClient := connectRedis(ctx, cfg)
+	defer redisClient.Close()
+	if redisClient.FailedOver() {
+		slog.Warn("Reads are failed over to the secondary Redis; the backup may lag the primary")
+	}
+
+	// Write to a temporary file so a failed backup never replaces a good one
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		fatal("Failed to create backup file", err, "path", path)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	counter := &countingWriter{w: io.MultiWriter(tmp, hash)}
+	zw, err := zstd.NewWriter(counter)
+	if err != nil {
+		fatal("Failed to create compressor", err)
+	}
+	enc := json.NewEncoder(zw)
+
+	manifest := backupManifest{
+		Format:      backupFormat,
+		CreatedAt:   time.Now().UTC(),
+		File:        filepath.Base(path),
+		KeyTemplate: cfg.RoomKeyTemplate,
+		KeyPrefix:   cfg.RedisKeyPrefix,
+		Patterns:    []string{keys.RoomScanPattern(), keys.VersionScanPattern()},
+		Encrypted:   cfg.EncryptionKeys != "",
+		Commit:      buildinfo.Get().Commit,
+	}
+	slog.Info("Backing up room mappings", "path", path, "patterns", manifest.Patterns, "batch", *batchSize)
+
+	var (
+		failed    int
+		started   = time.Now()
+		lastPrint = started
+	)
+	backupPage := func(found []string) error {
+		hashes, err := redisClient.HGetAllMulti(ctx, found)
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		ttls, _ := redisClient.PTTLMulti(ctx, found)
+		for i, key := range found {
+			fields, err := hashes[i].Result()
+			if err != nil {
+				failed++
+				slog.Error("Failed to read key", "key", key, "error", err)
+				continue
+			}
+			// Deleted or expired since the SCAN
+			if len(fields) == 0 {
+				continue
+			}
+			entry := backupEntry{Key: key, Fields: fields}
+			if ttls[i] != nil && ttls[i].Val() > 0 {
+				entry.TTLMillis = ttls[i].Val().Milliseconds()
+			}
+			if err := enc.Encode(entry); err != nil {
+				return err
+			}
+			manifest.Hashes++
+			manifest.Fields += len(fields)
+		}
+		return nil
+	}
+
+	for _, pattern := range manifest.Patterns {
+		cursor := ""
+		for err == nil {
+			var found []string
+			found, cursor, err = redisClient.ScanKeys(ctx, cursor, pattern, int64(*batchSize))
+			if err == nil && len(found) > 0 {
+				err = backupPage(found)
+			}
+			if time.Since(lastPrint) >= *progressEvery {
+				lastPrint = time.Now()
+				slog.Info("Backup progress", "hashes", manifest.Hashes, "fields", manifest.Fields,
+					"failed", failed, "bytes", counter.n)
+			}
+			if cursor == "" {
+				break
+			}
+		}
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+
+	summary := []any{
+		"path", path, "hashes", manifest.Hashes, "fields", manifest.Fields, "failed", failed,
+		"duration", time.Since(started).Round(time.Millisecond).String(),
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		slog.Warn("Backup interrupted", summary...)
+		return 1
+	case err != nil:
+		slog.Error("Backup failed", append([]any{"error", err}, summary...)...)
+		return 1
+	}
+
+	manifest.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	manifest.Bytes = counter.n
+	if err := tmp.Close(); err != nil {
+		fatal("Failed to write backup file", err, "path", path)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		fatal("Failed to write backup file", err, "path", path)
+	}
+	data, _ := json.MarshalIndent(manifest, "", "  ")
+	if err := os.WriteFile(manifestPath(path), append(data, '\n'), 0o644); err != nil {
+		fatal("Failed to write backup manifest", err, "path", manifestPath(path))
+	}
+
+	summary = append(summary, "bytes", manifest.Bytes, "sha256", manifest.SHA256)
+	if failed > 0 {
+		slog.Warn("Backup finished with errors", summary...)
+		return 1
+	}
+	slog.Info("Backup finished", summary...)
+	return 0
+}
+
+// runRestore implements the "restore" subcommand: it checks a backup against
+// its manifest and writes every hash back, with its TTL reduced by the time
+// since the backup. Hashes that have expired since are skipped. Existing
+// fields are overwritten; with -replace the keys are cleared first.
+func runRestore(args []string) int {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s restore [flags] <file>\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	configPath := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML config file; environment variables and flags override its values")
+	batchSize := fs.Int("batch", 500, "hashes per Redis pipeline")
+	replace := fs.Bool("replace", false, "delete each key before writing it instead of merging fields")
+	dryRun := fs.Bool("dry-run", false, "verify and read the backup without writing to Redis")
+	progressEvery := fs.Duration("progress", 5*time.Second, "interval between progress lines")
+	config.RegisterFlags(fs)
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 || *batchSize < 1 {
+		fs.Usage()
+		return 2
+	}
+	path := fs.Arg(0)
+
+	cfg := loadConfig(*configPath)
+
+	var manifest backupManifest
+	data, err := os.ReadFile(manifestPath(path))
+	if err == nil {
+		err = json.Unmarshal(data, &manifest)
+	}
+	if err != nil {
+		fatal("Failed to read backup manifest", err, "path", manifestPath(path))
+	}
+	if manifest.Format != backupFormat {
+		fatal("Unsupported backup format", fmt.Errorf("format %d, want %d", manifest.Format, backupFormat))
+	}
+	if err := verifyBackup(path, manifest); err != nil {
+		fatal("Backup does not match its manifest", err, "path", path)
+	}
+	if manifest.KeyTemplate != cfg.RoomKeyTemplate {
+		slog.Warn("Backup was taken with a different room key template", "backup", manifest.KeyTemplate, "configured", cfg.RoomKeyTemplate)
+	}
+	if manifest.Encrypted && cfg.EncryptionKeys == "" {
+		slog.Warn("Backup values are encrypted; the service needs the same ENCRYPTION_KEYS to read them")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	f, err := os.Open(path)
+	if err != nil {
+		fatal("Failed to open backup file", err, "path", path)
+	}
+	defer f.Close()
+	zr, err := zstd.NewReader(bufio.NewReader(f))
+	if err != nil {
+		fatal("Failed to read backup file", err, "path", path)
+	}
+	defer zr.Close()
+
+	var restore func(keys []string, values []map[string]interface{}, ttls []time.Duration) ([]error, error)
+	if !*dryRun {
+		redisClient := connectRedis(ctx, cfg)
+		defer redisClient.Close()
+		restore = func(keys []string, values []map[string]interface{}, ttls []time.Duration) ([]error, error) {
+			cmds, err := redisClient.RestoreHashes(ctx, keys, values, ttls, *replace)
+			errs := make([]error, len(cmds))
+			for i, cmd := range cmds {
+				if cmd == nil {
+					errs[i] = err
+				} else {
+					errs[i] = cmd.Err()
+				}
+			}
+			return errs, ctx.Err()
+		}
+	}
+	slog.Info("Restoring room mappings", "path", path, "created_at", manifest.CreatedAt,
+		"hashes", manifest.Hashes, "replace", *replace, "dry_run", *dryRun)
+
+	var (
+		restored, expired, failed int
+		batchKeys                 = make([]string, 0, *batchSize)
+		batchValues               = make([]map[string]interface{}, 0, *batchSize)
+		batchTTLs                 = make([]time.Duration, 0, *batchSize)
+		started                   = time.Now()
+		lastPrint                 = started
+	)
+	flush := func() error {
+		if len(batchKeys) == 0 {
+			return nil
+		}
+		if restore == nil {
+			restored += len(batchKeys)
+		} else {
+			results, err := restore(batchKeys, batchValues, batchTTLs)
+			if err != nil {
+				return err
+			}
+			for i, err := range results {
+				if err != nil {
+					failed++
+					slog.Error("Failed to restore key", "key", batchKeys[i], "error", err)
+					continue
+				}
+				restored++
+			}
+		}
+		batchKeys, batchValues, batchTTLs = batchKeys[:0], batchValues[:0], batchTTLs[:0]
+
+		if time.Since(lastPrint) >= *progressEvery {
+			lastPrint = time.Now()
+			slog.Info("Restore progress", "restored", restored, "expired", expired, "failed", failed)
+		}
+		return nil
+	}
+
+	dec := json.NewDecoder(zr)
+	for err == nil {
+		var entry backupEntry
+		if err = dec.Decode(&entry); err != nil {
+			break
+		}
+		if entry.Key == "" || len(entry.Fields) == 0 {
+			failed++
+			slog.Error("Skipping malformed backup entry", "key", entry.Key)
+			continue
+		}
+		var ttl time.Duration
+		if entry.TTLMillis > 0 {
+			ttl = time.Duration(entry.TTLMillis)*time.Millisecond - time.Since(manifest.CreatedAt)
+			if ttl <= 0 {
+				expired++
+				continue
+			}
+		}
+		values := make(map[string]interface{}, len(entry.Fields))
+		for field, value := range entry.Fields {
+			values[field] = value
+		}
+		batchKeys = append(batchKeys, entry.Key)
+		batchValues = append(batchValues, values)
+		batchTTLs = append(batchTTLs, ttl)
+		if len(batchKeys) == *batchSize {
+			err = flush()
+		}
+	}
+	if err == io.EOF {
+		err = flush()
+	}
+
+	summary := []any{
+		"restored", restored, "expired", expired, "failed", failed,
+		"duration", time.Since(started).Round(time.Millisecond).String(), "dry_run", *dryRun,
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		slog.Warn("Restore interrupted", summary...)
+		return 1
+	case err != nil:
+		slog.Error("Restore stopped early", append([]any{"error", err}, summary...)...)
+		return 1
+	case failed > 0:
+		slog.Warn("Restore finished with errors", summary...)
+		return 1
+	}
+	slog.Info("Restore finished", summary...)
+	return 0
+}
+
+// verifyBackup checks the backup file's size and checksum against its manifest
+func verifyBackup(path string, manifest backupManifest) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	n, err := io.Copy(hash, f)
+	if err != nil {
+		return err
+	}
+	if n != manifest.Bytes {
+		return fmt.Errorf("file is %d bytes, manifest says %d", n, manifest.Bytes)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != manifest.SHA256 {
+		return fmt.Errorf("sha256 is %s, manifest says %s", sum, manifest.SHA256)
+	}
+	return nil
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
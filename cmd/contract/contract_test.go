@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+
+	"room-mapping-cache/testutil"
+)
+
+// TestGolden replays the recorded cases in order against an in-process
+// server, as the command does against a running instance
+func TestGolden(t *testing.T) {
+	raw, err := os.ReadFile("golden.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cases []contractCase
+	if err := json.Unmarshal(raw, &cases); err != nil {
+		t.Fatalf("golden.json: %v", err)
+	}
+
+	srv := testutil.NewServer(t)
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	for i := range cases {
+		tc := &cases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			got, err := send(client, srv.URL, "", tc.Request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, d := range compare(tc, got) {
+				t.Error(d)
+			}
+		})
+	}
+}
@@ -0,0 +1,330 @@
+// Command contract replays recorded request/response pairs against a running
+// instance and reports every response whose status, headers or JSON body
+// drifted from the recording, so shape changes are caught before consumers
+// see them. Cases run in order and write their own hotels, so an empty
+// instance will do:
+//
+//	go run . -dev &
+//	go run ./cmd/contract -url http://localhost:8080
+//
+// go test ./cmd/contract replays the same cases against an in-process
+// server. After an intended change, re-record with -update and review the
+// diff of golden.json like any other change.
+//
+// Only the headers present in a recorded response are compared. Fields that
+// differ on every run (updated_at, request_id, version) are only checked for
+// presence; a case can list more under "ignore".
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// volatileFields differ between runs by design
+var volatileFields = []string{"updated_at", "request_id", "version"}
+
+// recordedHeaders are recorded with -update when the server sends them
+var recordedHeaders = []string{"Content-Type", "Content-Encoding", "Retry-After"}
+
+// contractCase is one recorded request/response pair
+type contractCase struct {
+	Name     string   `json:"name"`
+	Request  request  `json:"request"`
+	Response response `json:"response"`
+	// Ignore lists further body fields, at any depth, compared for presence only
+	Ignore []string `json:"ignore,omitempty"`
+}
+
+type request struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body is sent as JSON; RawBody is sent verbatim, for malformed payloads
+	Body    json.RawMessage `json:"body,omitempty"`
+	RawBody string          `json:"raw_body,omitempty"`
+}
+
+type response struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body holds JSON responses and Text anything else
+	Body json.RawMessage `json:"body,omitempty"`
+	Text string          `json:"text,omitempty"`
+}
+
+func main() {
+	var (
+		baseURL = flag.String("url", "http://localhost:8080", "base URL of the instance under test")
+		golden  = flag.String("golden", "cmd/contract/golden.json", "recorded cases")
+		update  = flag.Bool("update", false, "re-record the responses into -golden instead of comparing")
+		apiKey  = flag.String("api-key", "", "X-API-Key header to send")
+		timeout = flag.Duration("timeout", 10*time.Second, "per-request timeout")
+	)
+	flag.Parse()
+
+	raw, err := os.ReadFile(*golden)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "contract:", err)
+		os.Exit(2)
+	}
+	var cases []contractCase
+	if err := json.Unmarshal(raw, &cases); err != nil {
+		fmt.Fprintf(os.Stderr, "contract: %s: %v\n", *golden, err)
+		os.Exit(2)
+	}
+
+	// Responses are decoded here, so Content-Encoding reaches the comparison
+	client := &http.Client{Timeout: *timeout, Transport: &http.Transport{DisableCompression: true}}
+	base := strings.TrimRight(*baseURL, "/")
+	failed := 0
+	for i := range cases {
+		tc := &cases[i]
+		got, err := send(client, base, *apiKey, tc.Request)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "contract: %s: %v\n", tc.Name, err)
+			os.Exit(1)
+		}
+		if *update {
+			tc.Response = record(got, tc.Response, ignored(tc))
+			continue
+		}
+		if diffs := compare(tc, got); len(diffs) > 0 {
+			failed++
+			fmt.Printf("FAIL %s\n", tc.Name)
+			for _, d := range diffs {
+				fmt.Printf("    %s\n", d)
+			}
+			continue
+		}
+		fmt.Printf("ok   %s\n", tc.Name)
+	}
+
+	if *update {
+		out, err := json.MarshalIndent(cases, "", "  ")
+		if err == nil {
+			err = os.WriteFile(*golden, append(out, '\n'), 0o644)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "contract:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("recorded %d cases into %s\n", len(cases), *golden)
+		return
+	}
+	fmt.Printf("%d cases, %d failed\n", len(cases), failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// result is a response as received, with its body decompressed
+type result struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+func send(client *http.Client, base, apiKey string, r request) (*result, error) {
+	var body io.Reader
+	switch {
+	case r.RawBody != "":
+		body = strings.NewReader(r.RawBody)
+	case len(r.Body) > 0:
+		body = bytes.NewReader(r.Body)
+	}
+	req, err := http.NewRequest(r.Method, base+r.Path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	for name, value := range r.Headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var reader io.Reader = resp.Body
+	switch resp.Header.Get("Content-Encoding") {
+	case "":
+	case "gzip":
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("decode gzip body: %w", err)
+		}
+		reader = zr
+	default:
+		return nil, fmt.Errorf("cannot decode Content-Encoding %q; send Accept-Encoding: gzip", resp.Header.Get("Content-Encoding"))
+	}
+	raw, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	return &result{status: resp.StatusCode, header: resp.Header, body: raw}, nil
+}
+
+// ignored lists the body fields of a case compared for presence only
+func ignored(tc *contractCase) map[string]bool {
+	ignore := map[string]bool{}
+	for _, f := range append(volatileFields, tc.Ignore...) {
+		ignore[f] = true
+	}
+	return ignore
+}
+
+// record turns a result into a recording, keeping the headers the previous
+// recording checked. Ignored fields are recorded as "*", so re-recording
+// does not churn them.
+func record(got *result, prev response, ignore map[string]bool) response {
+	rec := response{Status: got.status, Headers: map[string]string{}}
+	names := append([]string(nil), recordedHeaders...)
+	for name := range prev.Headers {
+		names = append(names, name)
+	}
+	for _, name := range names {
+		if v := got.header.Get(name); v != "" {
+			rec.Headers[http.CanonicalHeaderKey(name)] = v
+		}
+	}
+	var body any
+	if err := json.Unmarshal(got.body, &body); err == nil {
+		rec.Body, _ = json.Marshal(mask(body, ignore))
+	} else {
+		rec.Text = string(got.body)
+	}
+	return rec
+}
+
+// compare lists the differences between a case's recording and got
+func compare(tc *contractCase, got *result) []string {
+	var diffs []string
+	want := tc.Response
+	if got.status != want.Status {
+		diffs = append(diffs, fmt.Sprintf("status %d, want %d", got.status, want.Status))
+	}
+	names := make([]string, 0, len(want.Headers))
+	for name := range want.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if v := got.header.Get(name); v != want.Headers[name] {
+			diffs = append(diffs, fmt.Sprintf("header %s: %q, want %q", name, v, want.Headers[name]))
+		}
+	}
+
+	if len(want.Body) == 0 {
+		if string(got.body) != want.Text {
+			diffs = append(diffs, fmt.Sprintf("body %q, want %q", got.body, want.Text))
+		}
+		return diffs
+	}
+	var gotBody, wantBody any
+	if err := json.Unmarshal(got.body, &gotBody); err != nil {
+		return append(diffs, fmt.Sprintf("body is not JSON: %q", got.body))
+	}
+	if err := json.Unmarshal(want.Body, &wantBody); err != nil {
+		return append(diffs, fmt.Sprintf("recorded body is not JSON: %v", err))
+	}
+	return append(diffs, diffJSON("$", gotBody, wantBody, ignored(tc))...)
+}
+
+// mask replaces the ignored fields of a decoded JSON value with "*"
+func mask(v any, ignore map[string]bool) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, fv := range v {
+			if ignore[k] {
+				v[k] = "*"
+			} else {
+				v[k] = mask(fv, ignore)
+			}
+		}
+	case []any:
+		for i := range v {
+			v[i] = mask(v[i], ignore)
+		}
+	}
+	return v
+}
+
+// diffJSON walks two decoded JSON values, reporting each path that differs
+func diffJSON(path string, got, want any, ignore map[string]bool) []string {
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: got %s, want an object", path, brief(got))}
+		}
+		keys := make([]string, 0, len(w)+len(g))
+		for k := range w {
+			keys = append(keys, k)
+		}
+		for k := range g {
+			if _, ok := w[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		var diffs []string
+		for _, k := range keys {
+			gv, inGot := g[k]
+			wv, inWant := w[k]
+			switch {
+			case !inGot:
+				diffs = append(diffs, fmt.Sprintf("%s.%s: missing", path, k))
+			case !inWant:
+				diffs = append(diffs, fmt.Sprintf("%s.%s: unexpected field %s", path, k, brief(gv)))
+			case ignore[k]:
+			default:
+				diffs = append(diffs, diffJSON(path+"."+k, gv, wv, ignore)...)
+			}
+		}
+		return diffs
+	case []any:
+		g, ok := got.([]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: got %s, want an array", path, brief(got))}
+		}
+		if len(g) != len(w) {
+			return []string{fmt.Sprintf("%s: %d elements, want %d", path, len(g), len(w))}
+		}
+		var diffs []string
+		for i := range w {
+			diffs = append(diffs, diffJSON(fmt.Sprintf("%s[%d]", path, i), g[i], w[i], ignore)...)
+		}
+		return diffs
+	default:
+		if !reflect.DeepEqual(got, want) {
+			return []string{fmt.Sprintf("%s: got %s, want %s", path, brief(got), brief(want))}
+		}
+		return nil
+	}
+}
+
+// brief renders a value for a diff line, shortening long ones
+func brief(v any) string {
+	raw, _ := json.Marshal(v)
+	if len(raw) > 80 {
+		return string(raw[:77]) + "..."
+	}
+	return string(raw)
+}
@@ -0,0 +1,385 @@
+// Command loadgen replays a recorded hotel-ID distribution against a running
+// instance and reports latency percentiles and error rates per route.
+//
+// Requests come from a request journal export (GET /admin/journal), which
+// keeps each recorded request's route and hotel IDs, or are sampled from a
+// list of hotel IDs with optional weights ("id" or "id weight" per line, e.g.
+// built from GET /admin/analytics/top-hotels).
+//
+//	go run ./cmd/loadgen -url http://localhost:8080 -journal journal.jsonl -rate 1000 -duration 1m
+//
+// Room parsing and the response encoders have Go benchmarks to compare
+// before a deploy:
+//
+//	go test -run '^$' -bench . -benchmem ./internal/handler
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"room-mapping-cache/internal/journal"
+)
+
+// request is one replayable lookup
+type request struct {
+	route    string // "single" or "batch"
+	hotelIDs []string
+}
+
+func main() {
+	var (
+		baseURL     = flag.String("url", "http://localhost:8080", "base URL of the instance under test")
+		journalPath = flag.String("journal", "", "request journal export (JSON lines) to replay")
+		idsPath     = flag.String("ids", "", `hotel IDs to sample from, one "id [weight]" per line`)
+		batchRatio  = flag.Float64("batch-ratio", 0.2, "share of batch requests when sampling from -ids")
+		batchSize   = flag.Int("batch-size", 50, "hotels per batch request when sampling from -ids")
+		rate        = flag.Float64("rate", 100, "requests per second (0 = as fast as -concurrency allows)")
+		concurrency = flag.Int("concurrency", 64, "maximum requests in flight")
+		duration    = flag.Duration("duration", 30*time.Second, "how long to run")
+		timeout     = flag.Duration("timeout", 5*time.Second, "per-request timeout")
+		apiKey      = flag.String("api-key", "", "X-API-Key header to send")
+		encoding    = flag.String("accept-encoding", "gzip", "Accept-Encoding header to send (empty for none)")
+		jsonOut     = flag.Bool("json", false, "print the report as JSON")
+	)
+	flag.Parse()
+
+	var (
+		next func(*rand.Rand) request
+		err  error
+	)
+	switch {
+	case *journalPath != "":
+		next, err = loadJournal(*journalPath)
+	case *idsPath != "":
+		next, err = loadIDs(*idsPath, *batchRatio, *batchSize)
+	default:
+		err = errors.New("one of -journal or -ids is required")
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "loadgen:", err)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, *duration)
+	defer cancel()
+
+	client := &http.Client{
+		Timeout: *timeout,
+		Transport: &http.Transport{
+			MaxIdleConns:        *concurrency,
+			MaxIdleConnsPerHost: *concurrency,
+			// Report the server's compression cost without decoding responses
+			DisableCompression: true,
+		},
+	}
+	r := &runner{
+		client:   client,
+		baseURL:  strings.TrimRight(*baseURL, "/"),
+		apiKey:   *apiKey,
+		encoding: *encoding,
+		results:  make(map[string]*routeResult),
+	}
+
+	start := time.Now()
+	r.run(ctx, next, *rate, *concurrency)
+	rep := r.report(time.Since(start))
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(rep)
+		return
+	}
+	rep.print(os.Stdout)
+}
+
+// loadJournal returns a sampler over the recorded requests of a journal export
+func loadJournal(path string) (func(*rand.Rand) request, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var reqs []request
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64<<10), 16<<20)
+	for scanner.Scan() {
+		var e journal.Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil || len(e.HotelIDs) == 0 {
+			continue
+		}
+		if e.Route != "single" && e.Route != "batch" {
+			continue
+		}
+		reqs = append(reqs, request{route: e.Route, hotelIDs: e.HotelIDs})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(reqs) == 0 {
+		return nil, fmt.Errorf("%s has no replayable requests", path)
+	}
+	return func(rng *rand.Rand) request { return reqs[rng.Intn(len(reqs))] }, nil
+}
+
+// loadIDs returns a sampler drawing hotel IDs in proportion to their weights
+func loadIDs(path string, batchRatio float64, batchSize int) (func(*rand.Rand) request, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var (
+		ids        []string
+		cumulative []float64
+		total      float64
+	)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.FieldsFunc(scanner.Text(), func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		weight := 1.0
+		if len(fields) > 1 {
+			if weight, err = strconv.ParseFloat(fields[1], 64); err != nil || weight <= 0 {
+				return nil, fmt.Errorf("invalid weight %q for hotel %s", fields[1], fields[0])
+			}
+		}
+		total += weight
+		ids = append(ids, fields[0])
+		cumulative = append(cumulative, total)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("%s has no hotel IDs", path)
+	}
+
+	pick := func(rng *rand.Rand) string {
+		return ids[sort.SearchFloat64s(cumulative, rng.Float64()*total)]
+	}
+	return func(rng *rand.Rand) request {
+		if rng.Float64() >= batchRatio {
+			return request{route: "single", hotelIDs: []string{pick(rng)}}
+		}
+		batch := make([]string, batchSize)
+		for i := range batch {
+			batch[i] = pick(rng)
+		}
+		return request{route: "batch", hotelIDs: batch}
+	}, nil
+}
+
+type runner struct {
+	client   *http.Client
+	baseURL  string
+	apiKey   string
+	encoding string
+
+	mu      sync.Mutex
+	results map[string]*routeResult
+}
+
+type routeResult struct {
+	latencies []time.Duration
+	statuses  map[string]int
+	errors    int
+}
+
+// run issues requests at rate (open loop) until ctx is done, never exceeding
+// concurrency in flight. With rate 0 every worker loops back to back.
+func (r *runner) run(ctx context.Context, next func(*rand.Rand) request, rate float64, concurrency int) {
+	tickets := make(chan struct{}, concurrency)
+	if rate > 0 {
+		go func() {
+			ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					select {
+					case tickets <- struct{}{}:
+					default:
+						// All workers busy: the target can't keep up with the rate
+						r.record("dropped", 0, "dropped", false)
+					}
+				}
+			}
+		}()
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed))
+			for {
+				if rate > 0 {
+					select {
+					case <-ctx.Done():
+						return
+					case <-tickets:
+					}
+				} else if ctx.Err() != nil {
+					return
+				}
+				r.do(ctx, next(rng))
+			}
+		}(time.Now().UnixNano() + int64(w))
+	}
+	wg.Wait()
+}
+
+func (r *runner) do(ctx context.Context, req request) {
+	var (
+		httpReq *http.Request
+		err     error
+	)
+	if req.route == "batch" {
+		body, _ := json.Marshal(map[string][]string{"hotel_ids": req.hotelIDs})
+		httpReq, err = http.NewRequestWithContext(ctx, http.MethodPost, r.baseURL+"/room-mappings/batch", bytes.NewReader(body))
+		if err == nil {
+			httpReq.Header.Set("Content-Type", "application/json")
+		}
+	} else {
+		httpReq, err = http.NewRequestWithContext(ctx, http.MethodGet, r.baseURL+"/room-mappings/"+url.PathEscape(req.hotelIDs[0]), nil)
+	}
+	if err != nil {
+		r.record(req.route, 0, "invalid_request", false)
+		return
+	}
+	if r.apiKey != "" {
+		httpReq.Header.Set("X-API-Key", r.apiKey)
+	}
+	if r.encoding != "" {
+		httpReq.Header.Set("Accept-Encoding", r.encoding)
+	}
+
+	start := time.Now()
+	resp, err := r.client.Do(httpReq)
+	if err != nil {
+		// Requests cut off by the end of the run aren't failures
+		if ctx.Err() == nil {
+			r.record(req.route, time.Since(start), "transport_error", false)
+		}
+		return
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	elapsed := time.Since(start)
+	r.record(req.route, elapsed, strconv.Itoa(resp.StatusCode), resp.StatusCode < 400)
+}
+
+func (r *runner) record(route string, latency time.Duration, status string, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := r.results[route]
+	if res == nil {
+		res = &routeResult{statuses: make(map[string]int)}
+		r.results[route] = res
+	}
+	res.statuses[status]++
+	if latency > 0 {
+		res.latencies = append(res.latencies, latency)
+	}
+	if !ok {
+		res.errors++
+	}
+}
+
+// Report summarizes a run per route
+type Report struct {
+	Duration string        `json:"duration"`
+	Routes   []RouteReport `json:"routes"`
+}
+
+type RouteReport struct {
+	Route     string         `json:"route"`
+	Requests  int            `json:"requests"`
+	RPS       float64        `json:"rps"`
+	ErrorRate float64        `json:"error_rate"`
+	Statuses  map[string]int `json:"statuses"`
+	P50Ms     float64        `json:"p50_ms"`
+	P95Ms     float64        `json:"p95_ms"`
+	P99Ms     float64        `json:"p99_ms"`
+	MaxMs     float64        `json:"max_ms"`
+}
+
+func (r *runner) report(elapsed time.Duration) Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rep := Report{Duration: elapsed.Round(time.Millisecond).String()}
+	for route, res := range r.results {
+		n := 0
+		for _, count := range res.statuses {
+			n += count
+		}
+		lat := res.latencies
+		sort.Slice(lat, func(i, j int) bool { return lat[i] < lat[j] })
+		rep.Routes = append(rep.Routes, RouteReport{
+			Route:     route,
+			Requests:  n,
+			RPS:       float64(n) / elapsed.Seconds(),
+			ErrorRate: float64(res.errors) / float64(n),
+			Statuses:  res.statuses,
+			P50Ms:     percentileMs(lat, 0.50),
+			P95Ms:     percentileMs(lat, 0.95),
+			P99Ms:     percentileMs(lat, 0.99),
+			MaxMs:     percentileMs(lat, 1),
+		})
+	}
+	sort.Slice(rep.Routes, func(i, j int) bool { return rep.Routes[i].Route < rep.Routes[j].Route })
+	return rep
+}
+
+// percentileMs returns the q-th quantile of sorted latencies in milliseconds
+func percentileMs(sorted []time.Duration, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(q*float64(len(sorted))+0.5) - 1
+	i = max(0, min(i, len(sorted)-1))
+	return float64(sorted[i].Microseconds()) / 1000
+}
+
+func (rep Report) print(w io.Writer) {
+	fmt.Fprintf(w, "duration %s\n\n", rep.Duration)
+	fmt.Fprintf(w, "%-8s %9s %9s %7s %9s %9s %9s %9s  %s\n", "route", "requests", "rps", "errors", "p50", "p95", "p99", "max", "statuses")
+	for _, rr := range rep.Routes {
+		statuses := make([]string, 0, len(rr.Statuses))
+		for status, n := range rr.Statuses {
+			statuses = append(statuses, fmt.Sprintf("%s=%d", status, n))
+		}
+		sort.Strings(statuses)
+		fmt.Fprintf(w, "%-8s %9d %9.1f %6.2f%% %7.2fms %7.2fms %7.2fms %7.2fms  %s\n",
+			rr.Route, rr.Requests, rr.RPS, 100*rr.ErrorRate, rr.P50Ms, rr.P95Ms, rr.P99Ms, rr.MaxMs, strings.Join(statuses, " "))
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"room-mapping-cache/internal/config"
+	"room-mapping-cache/internal/handler"
+	"room-mapping-cache/internal/loader"
+)
+
+// devSeedSample selects the built-in sample hotels for -dev-seed
+const devSeedSample = "sample"
+
+// sampleHotels are a few hotels covering the common shapes: several
+// suppliers, names that normalize alike and a room without an id
+var sampleHotels = []handler.HotelRooms{
+	{HotelID: "1001", Supplier: "expedia", Rooms: map[string]map[string]interface{}{
+		"Deluxe King Room":     {"id": 1},
+		"Superior Double Room": {"id": 2},
+		"Junior Suite":         {"id": 3},
+	}},
+	{HotelID: "1001", Supplier: "booking", Rooms: map[string]map[string]interface{}{
+		"DELUXE KING ROOM":   {"id": 1},
+		"Twin Room - Garden": {"id": 4},
+	}},
+	{HotelID: "1002", Supplier: "expedia", Rooms: map[string]map[string]interface{}{
+		"Standard Queen Room":    {"id": 10},
+		"Family Room, 2 Bedroom": {"id": 11},
+		"Penthouse":              {},
+	}},
+	{HotelID: "1003", Supplier: "hotelbeds", Rooms: map[string]map[string]interface{}{
+		"Chambre Double Supérieure": {"id": 20},
+		"Suite Junior":              {"id": 21},
+	}},
+}
+
+// startDevMode switches cfg to the in-memory store for -dev, so the service
+// runs with no external dependencies
+func startDevMode(cfg *config.Config) {
+	if cfg.Environment == "production" {
+		fatal("Refusing -dev", fmt.Errorf("ENVIRONMENT is production"))
+	}
+	cfg.StoreBackend = config.StoreBackendMemory
+	cfg.RedisSecondaryAddrs, cfg.RedisShadowAddrs = nil, nil
+}
+
+// seedStore writes the sample hotels, a fixtures directory (as the seed
+// subcommand reads it) or the hotels of a load dump (file, HTTPS or S3 URL)
+// through the bulk write path
+func seedStore(ctx context.Context, cfg *config.Config, roomHandler *handler.RoomHandler, seed string) error {
+	hotels := sampleHotels
+	if seed != devSeedSample {
+		hotels = nil
+		add := func(rec loader.Record) error {
+			hotels = append(hotels, handler.HotelRooms(rec))
+			return nil
+		}
+		onBad := func(bad *loader.RecordError) {
+			slog.Warn("Skipping invalid seed record", "position", bad.Pos, "error", bad.Err)
+		}
+		if info, err := os.Stat(seed); err == nil && info.IsDir() {
+			if err := loader.ReadFixtures(seed, "fixtures", add, onBad); err != nil {
+				return err
+			}
+		} else {
+			body, err := loader.OpenSource(ctx, seed, cfg.LoaderHTTPHeaders)
+			if err != nil {
+				return err
+			}
+			defer body.Close()
+			if err := loader.ReadDump(body, loader.IsCSV(seed), add, onBad); err != nil {
+				return err
+			}
+		}
+	}
+	for i, err := range roomHandler.WriteHotels(ctx, hotels) {
+		if err != nil {
+			return fmt.Errorf("hotel %s: %w", hotels[i].HotelID, err)
+		}
+	}
+	slog.Info("Seeded the in-memory store", "source", seed, "records", len(hotels))
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"room-mapping-cache/internal/config"
+	"room-mapping-cache/internal/redis"
+)
+
+// runHealthcheck implements the "healthcheck" subcommand for exec-based
+// container probes, so images need no curl: it asks the local instance's
+// /ready, or with -redis pings the configured Redis directly. Exits 0 when
+// healthy and 1 otherwise.
+func runHealthcheck(args []string) int {
+	fs := flag.NewFlagSet("healthcheck", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s healthcheck [flags]\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	configPath := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML config file; environment variables and flags override its values")
+	url := fs.String("url", "", "readiness URL to check (default: /ready on ADDR)")
+	checkRedis := fs.Bool("redis", false, "ping Redis directly instead of the HTTP server")
+	timeout := fs.Duration("timeout", 3*time.Second, "how long to wait for an answer")
+	config.RegisterFlags(fs)
+	_ = fs.Parse(args)
+	if fs.NArg() != 0 || *timeout <= 0 {
+		fs.Usage()
+		return 2
+	}
+
+	cfg := loadConfig(*configPath)
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	if *checkRedis {
+		redisClient, err := redis.NewClient(redisOptions(cfg))
+		if err == nil {
+			defer redisClient.Close()
+			err = redisClient.ActiveHealthCheck(ctx)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "unhealthy: redis:", err)
+			return 1
+		}
+		fmt.Println("healthy")
+		return 0
+	}
+
+	target := *url
+	if target == "" {
+		target = "http://" + localAddr(cfg.Addr) + "/ready"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "healthcheck:", err)
+		return 2
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "unhealthy:", err)
+		return 1
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "unhealthy: %s: %s\n", resp.Status, strings.TrimSpace(string(body)))
+		return 1
+	}
+	fmt.Println("healthy")
+	return 0
+}
+
+// localAddr turns a listen address into one to dial on this host, e.g.
+// ":8080" into "127.0.0.1:8080"
+func localAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	switch host {
+	case "", "0.0.0.0":
+		host = "127.0.0.1"
+	case "::":
+		host = "::1"
+	}
+	return net.JoinHostPort(host, port)
+}
@@ -0,0 +1,172 @@
+// Package analytics tracks per-hotel request outcomes over a rolling window.
+package analytics
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Outcome of serving one hotel
+type Outcome int
+
+const (
+	Hit   Outcome = iota // served from the local cache
+	Miss                 // fetched from Redis
+	Stale                // served stale after a Redis error
+	Error                // not served
+)
+
+type counts struct {
+	hits, misses, stale, errors int64
+}
+
+type bucket struct {
+	start  time.Time
+	hotels map[string]*counts
+}
+
+// Tracker counts outcomes per hotel in fixed-size time buckets covering the
+// window. Each bucket holds at most maxHotels distinct hotels; requests for
+// further hotels in that bucket are only counted as dropped. All methods are
+// safe on a nil Tracker.
+type Tracker struct {
+	bucketSize time.Duration
+	maxHotels  int
+
+	mu      sync.Mutex
+	buckets []bucket
+	dropped int64
+}
+
+// HotelStats summarizes one hotel over the window
+type HotelStats struct {
+	HotelID  string  `json:"hotel_id"`
+	Requests int64   `json:"requests"`
+	Hits     int64   `json:"hits"`
+	Misses   int64   `json:"misses"`
+	Stale    int64   `json:"stale"`
+	Errors   int64   `json:"errors"`
+	HitRate  float64 `json:"hit_rate"`
+}
+
+// NewTracker creates a tracker over window split into buckets of bucketSize
+func NewTracker(window, bucketSize time.Duration, maxHotels int) *Tracker {
+	n := int(window / bucketSize)
+	if n < 1 {
+		n = 1
+	}
+	return &Tracker{
+		bucketSize: bucketSize,
+		maxHotels:  maxHotels,
+		buckets:    make([]bucket, n),
+	}
+}
+
+// Record counts one outcome for a hotel
+func (t *Tracker) Record(hotelID string, outcome Outcome) {
+	if t == nil {
+		return
+	}
+	now := time.Now().Truncate(t.bucketSize)
+	idx := int(now.UnixNano()/int64(t.bucketSize)) % len(t.buckets)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b := &t.buckets[idx]
+	if !b.start.Equal(now) {
+		*b = bucket{start: now, hotels: make(map[string]*counts)}
+	}
+	c := b.hotels[hotelID]
+	if c == nil {
+		if len(b.hotels) >= t.maxHotels {
+			t.dropped++
+			return
+		}
+		c = &counts{}
+		b.hotels[hotelID] = c
+	}
+	switch outcome {
+	case Hit:
+		c.hits++
+	case Miss:
+		c.misses++
+	case Stale:
+		c.stale++
+	case Error:
+		c.errors++
+	}
+}
+
+// Top returns the n most requested hotels in the window, busiest first
+func (t *Tracker) Top(n int) []HotelStats {
+	if t == nil {
+		return nil
+	}
+	cutoff := time.Now().Add(-t.bucketSize * time.Duration(len(t.buckets)))
+
+	t.mu.Lock()
+	totals := make(map[string]*counts)
+	for _, b := range t.buckets {
+		if b.hotels == nil || !b.start.After(cutoff) {
+			continue
+		}
+		for id, c := range b.hotels {
+			sum := totals[id]
+			if sum == nil {
+				sum = &counts{}
+				totals[id] = sum
+			}
+			sum.hits += c.hits
+			sum.misses += c.misses
+			sum.stale += c.stale
+			sum.errors += c.errors
+		}
+	}
+	t.mu.Unlock()
+
+	stats := make([]HotelStats, 0, len(totals))
+	for id, c := range totals {
+		s := HotelStats{
+			HotelID:  id,
+			Requests: c.hits + c.misses + c.stale + c.errors,
+			Hits:     c.hits,
+			Misses:   c.misses,
+			Stale:    c.stale,
+			Errors:   c.errors,
+		}
+		if s.Requests > 0 {
+			s.HitRate = float64(s.Hits) / float64(s.Requests)
+		}
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Requests != stats[j].Requests {
+			return stats[i].Requests > stats[j].Requests
+		}
+		return stats[i].HotelID < stats[j].HotelID
+	})
+	if n > 0 && len(stats) > n {
+		stats = stats[:n]
+	}
+	return stats
+}
+
+// Window returns the span covered by Top
+func (t *Tracker) Window() time.Duration {
+	if t == nil {
+		return 0
+	}
+	return t.bucketSize * time.Duration(len(t.buckets))
+}
+
+// Dropped returns how many requests were not tracked because a bucket was full
+func (t *Tracker) Dropped() int64 {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.dropped
+}
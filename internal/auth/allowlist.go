@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	"room-mapping-cache/internal/errs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IPAllowlist rejects requests whose client IP is outside cidrs with 403.
+// Plain addresses are accepted as single-host prefixes. The client IP honors
+// X-Forwarded-For only from the router's trusted proxies.
+func IPAllowlist(cidrs []string) (gin.HandlerFunc, error) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, raw := range cidrs {
+		raw = strings.TrimSpace(raw)
+		if !strings.Contains(raw, "/") {
+			addr, err := netip.ParseAddr(raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid allowlist entry %q: %w", raw, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowlist entry %q: %w", raw, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return func(c *gin.Context) {
+		if ip := net.ParseIP(c.ClientIP()); ip != nil {
+			if addr, ok := netip.AddrFromSlice(ip); ok {
+				addr = addr.Unmap()
+				for _, p := range prefixes {
+					if p.Contains(addr) {
+						c.Next()
+						return
+					}
+				}
+			}
+		}
+		reject(c, errs.Forbidden, "client address not allowed")
+	}, nil
+}
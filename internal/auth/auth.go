@@ -0,0 +1,159 @@
+// Package auth authenticates callers by API key or JWT bearer token. Keys come
+// from the environment, an optional file and an optional Redis hash, and each
+// key has a name used to identify the caller in logs, metrics and the audit
+// trail. Tokens are verified against an OIDC issuer's JWKS.
+package auth
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"room-mapping-cache/internal/errs"
+	"room-mapping-cache/internal/redis"
+)
+
+// Keys maps API key SHA-256 digests to key names. Raw keys are not kept after
+// loading, and lookup timing depends only on the digest.
+type Keys struct {
+	static   map[string]string // digest -> name from the environment
+	file     string
+	redis    redis.HashReader
+	redisKey string
+
+	digests atomic.Pointer[map[string]string]
+}
+
+// New builds the key set from static name -> key pairs, plus the optional
+// file (one "name:key" per line) and Redis hash (field name -> hex SHA-256 of
+// the key, so raw keys never live in Redis). Call Reload before serving.
+func New(static map[string]string, file string, redisClient redis.HashReader, redisKey string) *Keys {
+	k := &Keys{static: make(map[string]string, len(static)), file: file, redis: redisClient, redisKey: redisKey}
+	for name, key := range static {
+		k.static[digest(key)] = name
+	}
+	empty := map[string]string{}
+	k.digests.Store(&empty)
+	return k
+}
+
+// ParseKeys parses "name:key,name2:key2" as used in configuration
+func ParseKeys(raw string) (map[string]string, error) {
+	keys := make(map[string]string)
+	for _, pair := range strings.Split(raw, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		name, key, ok := strings.Cut(pair, ":")
+		if !ok || name == "" || key == "" {
+			return nil, fmt.Errorf("invalid API key entry, expected name:key")
+		}
+		keys[name] = key
+	}
+	return keys, nil
+}
+
+// Reload re-reads the file and Redis sources. On error the previous keys
+// stay in effect.
+func (k *Keys) Reload(ctx context.Context) error {
+	digests := make(map[string]string, len(k.static))
+	for d, name := range k.static {
+		digests[d] = name
+	}
+
+	if k.file != "" {
+		fromFile, err := readKeyFile(k.file)
+		if err != nil {
+			return err
+		}
+		for name, key := range fromFile {
+			digests[digest(key)] = name
+		}
+	}
+
+	if k.redis != nil && k.redisKey != "" {
+		fromRedis, err := k.redis.HGetAll(ctx, k.redisKey)
+		if err != nil {
+			return errs.Classify("failed to load API keys from Redis", err)
+		}
+		for name, d := range fromRedis {
+			digests[strings.ToLower(strings.TrimSpace(d))] = name
+		}
+	}
+
+	k.digests.Store(&digests)
+	return nil
+}
+
+// Len returns the number of keys currently accepted
+func (k *Keys) Len() int {
+	return len(*k.digests.Load())
+}
+
+// Refresh reloads the keys every interval until ctx is cancelled, so keys can
+// be added or revoked without a restart.
+func (k *Keys) Refresh(ctx context.Context, interval time.Duration) {
+	if interval <= 0 || (k.file == "" && k.redisKey == "") {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloadCtx, cancel := context.WithTimeout(ctx, interval)
+			if err := k.Reload(reloadCtx); err != nil {
+				slog.Error("Failed to reload API keys", "error", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// Name returns the name of key, if it is accepted
+func (k *Keys) Name(key string) (string, bool) {
+	name, ok := (*k.digests.Load())[digest(key)]
+	return name, ok
+}
+
+func digest(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// readKeyFile reads "name:key" lines, skipping blanks and # comments
+func readKeyFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open API key file: %w", err)
+	}
+	defer f.Close()
+
+	keys := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		name, key, ok := strings.Cut(text, ":")
+		if !ok || name == "" || key == "" {
+			return nil, fmt.Errorf("API key file line %d: expected name:key", line)
+		}
+		keys[strings.TrimSpace(name)] = strings.TrimSpace(key)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read API key file: %w", err)
+	}
+	return keys, nil
+}
@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"strings"
+
+	"room-mapping-cache/internal/audit"
+	"room-mapping-cache/internal/errs"
+	"room-mapping-cache/internal/logging"
+	"room-mapping-cache/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Header carries the caller's API key
+const Header = "X-API-Key"
+
+// Middleware authenticates each request with a bearer token, when tokens is
+// set and the request carries one, or otherwise with an API key. Tokens must
+// grant scope; API keys grant every scope. Either of keys and tokens may be
+// nil. The caller's identity is recorded for the access log, metrics and
+// audit trail.
+func Middleware(keys *Keys, tokens *JWTValidator, scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if bearer, ok := bearerToken(c); ok && tokens != nil {
+			claims, err := tokens.Validate(c.Request.Context(), bearer)
+			if err != nil {
+				metrics.AuthRequests.WithLabelValues("", "invalid").Inc()
+				reject(c, errs.Unauthorized, "invalid bearer token")
+				return
+			}
+			name := "jwt:" + claims.Subject
+			if !claims.HasScope(scope) {
+				metrics.AuthRequests.WithLabelValues(name, "forbidden").Inc()
+				reject(c, errs.Forbidden, "token lacks scope "+scope)
+				return
+			}
+			accept(c, name, name)
+			return
+		}
+
+		if keys == nil {
+			metrics.AuthRequests.WithLabelValues("", "missing").Inc()
+			reject(c, errs.Unauthorized, "missing bearer token")
+			return
+		}
+		key := c.GetHeader(Header)
+		if key == "" {
+			metrics.AuthRequests.WithLabelValues("", "missing").Inc()
+			reject(c, errs.Unauthorized, "missing API key")
+			return
+		}
+		name, ok := keys.Name(key)
+		if !ok {
+			metrics.AuthRequests.WithLabelValues("", "invalid").Inc()
+			reject(c, errs.Unauthorized, "invalid API key")
+			return
+		}
+		accept(c, name, "key:"+name)
+	}
+}
+
+func accept(c *gin.Context, name, subject string) {
+	metrics.AuthRequests.WithLabelValues(name, "ok").Inc()
+	c.Set(logging.APIKeyNameKey, name)
+	c.Set(audit.SubjectKey, subject)
+	c.Next()
+}
+
+func reject(c *gin.Context, kind errs.Kind, msg string) {
+	logging.AbortWithError(c, errs.New(kind, msg))
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer" header
+func bearerToken(c *gin.Context) (string, bool) {
+	scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "bearer") || strings.TrimSpace(token) == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireSignatureBindsRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequireSignature([]string{"secret"}, time.Minute, 1<<20))
+	r.Any("/*path", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	body := `{"rooms":{"Twin":{"id":1}}}`
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	signed := httptest.NewRequest(http.MethodPut, "/hotels/1001/rooms?b=2&a=1", nil)
+	sig := Sign("secret", ts, signed, []byte(body))
+
+	send := func(method, target string) int {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(SignatureHeader, sig)
+		req.Header.Set(SignatureTimestampHeader, ts)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		want   int
+	}{
+		{"signed request", http.MethodPut, "/hotels/1001/rooms?b=2&a=1", http.StatusNoContent},
+		{"query reordered", http.MethodPut, "/hotels/1001/rooms?a=1&b=2", http.StatusNoContent},
+		{"other hotel", http.MethodPut, "/hotels/1002/rooms?b=2&a=1", http.StatusUnauthorized},
+		{"other endpoint", http.MethodPut, "/hotels/1001/rooms/Twin?b=2&a=1", http.StatusUnauthorized},
+		{"other method", http.MethodPost, "/hotels/1001/rooms?b=2&a=1", http.StatusUnauthorized},
+		{"other query", http.MethodPut, "/hotels/1001/rooms?b=3&a=1", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := send(tt.method, tt.target); got != tt.want {
+				t.Errorf("status %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LRU is a size-bounded, TTL-based least-recently-used cache safe for
+// concurrent use. Expired entries are retained for an extra stale window so
+// callers can fall back to them (GetStale) when the source is unavailable.
+type LRU[K comparable, V any] struct {
+	maxEntries int
+	defaultTTL time.Duration
+	staleTTL   time.Duration
+
+	// sizer estimates an entry's memory footprint in bytes; optional
+	sizer func(K, V) int
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[K]*list.Element
+	bytes int64
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+type entry[K comparable, V any] struct {
+	key        K
+	value      V
+	expiresAt  time.Time
+	staleUntil time.Time
+	size       int
+}
+
+// Stats is a point-in-time view of cache counters
+type Stats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Entries   int    `json:"entries"`
+	// Bytes is an estimate, only tracked when a sizer is set
+	Bytes int64 `json:"bytes_estimate"`
+}
+
+// NewLRU creates a cache. staleTTL is how long entries remain available to
+// GetStale after expiring; zero drops them as soon as they expire.
+func NewLRU[K comparable, V any](maxEntries int, defaultTTL, staleTTL time.Duration) *LRU[K, V] {
+	return &LRU[K, V]{
+		maxEntries: maxEntries,
+		defaultTTL: defaultTTL,
+		staleTTL:   staleTTL,
+		ll:         list.New(),
+		items:      make(map[K]*list.Element, maxEntries),
+	}
+}
+
+// SetSizer installs a memory estimator used for Stats().Bytes. It must be
+// called before the cache is used.
+func (c *LRU[K, V]) SetSizer(sizer func(K, V) int) {
+	c.sizer = sizer
+}
+
+// Get returns the cached value if present and not expired
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	value, _, ok := c.GetWithExpiry(key)
+	return value, ok
+}
+
+// GetWithExpiry is Get that also returns when the value expires
+func (c *LRU[K, V]) GetWithExpiry(key K) (V, time.Time, bool) {
+	c.mu.Lock()
+	el, ok := c.items[key]
+	if !ok {
+		c.mu.Unlock()
+		c.misses.Add(1)
+		var zero V
+		return zero, time.Time{}, false
+	}
+
+	e := el.Value.(*entry[K, V])
+	if now := time.Now(); now.After(e.expiresAt) {
+		if now.After(e.staleUntil) {
+			c.removeElement(el)
+		}
+		c.mu.Unlock()
+		c.misses.Add(1)
+		var zero V
+		return zero, time.Time{}, false
+	}
+
+	c.ll.MoveToFront(el)
+	value, expiresAt := e.value, e.expiresAt
+	c.mu.Unlock()
+	c.hits.Add(1)
+	return value, expiresAt, true
+}
+
+// Peek returns the cached value if present and not expired, without
+// affecting hit/miss counters or recency
+func (c *LRU[K, V]) Peek(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok || time.Now().After(el.Value.(*entry[K, V]).expiresAt) {
+		var zero V
+		return zero, false
+	}
+	return el.Value.(*entry[K, V]).value, true
+}
+
+// GetStale returns the value even if it has expired, as long as it is still
+// within the stale window. It does not affect hit/miss counters or recency.
+func (c *LRU[K, V]) GetStale(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	e := el.Value.(*entry[K, V])
+	if time.Now().After(e.staleUntil) {
+		c.removeElement(el)
+		var zero V
+		return zero, false
+	}
+	return e.value, true
+}
+
+// Set stores value with the default TTL
+func (c *LRU[K, V]) Set(key K, value V) {
+	c.SetWithTTL(key, value, c.defaultTTL)
+}
+
+// SetWithTTL stores value, evicting the least recently used entry when full
+func (c *LRU[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	expiresAt := time.Now().Add(ttl)
+	staleUntil := expiresAt.Add(c.staleTTL)
+	size := 0
+	if c.sizer != nil {
+		size = c.sizer(key, value)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry[K, V])
+		c.bytes += int64(size - e.size)
+		e.value = value
+		e.expiresAt = expiresAt
+		e.staleUntil = staleUntil
+		e.size = size
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&entry[K, V]{key: key, value: value, expiresAt: expiresAt, staleUntil: staleUntil, size: size})
+	c.bytes += int64(size)
+	for c.maxEntries > 0 && c.ll.Len() > c.maxEntries {
+		c.removeElement(c.ll.Back())
+		c.evictions.Add(1)
+	}
+}
+
+// Delete removes key from the cache
+func (c *LRU[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+// Len returns the number of entries, including expired ones not yet reclaimed
+func (c *LRU[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+func (c *LRU[K, V]) Stats() Stats {
+	c.mu.Lock()
+	entries, bytes := c.ll.Len(), c.bytes
+	c.mu.Unlock()
+
+	return Stats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+		Entries:   entries,
+		Bytes:     bytes,
+	}
+}
+
+func (c *LRU[K, V]) removeElement(el *list.Element) {
+	e := el.Value.(*entry[K, V])
+	c.ll.Remove(el)
+	delete(c.items, e.key)
+	c.bytes -= int64(e.size)
+}
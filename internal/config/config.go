@@ -1,19 +1,483 @@
 package config
 
 import (
-	"log"
+	"fmt"
+	"log/slog"
 	"os"
+	"runtime"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// STORE_BACKEND values
+const (
+	StoreBackendRedis  = "redis"
+	StoreBackendMemory = "memory"
+)
+
+// RESPONSE_OVER_LIMIT values
+const (
+	ResponseOverLimitTruncate = "truncate"
+	ResponseOverLimitReject   = "reject"
+)
+
 type Config struct {
-	Addr          string
-	Environment   string
+	Addr        string
+	Environment string
+	// GinMode is debug, release or test; the environment profile sets it
+	GinMode string
+	// StoreBackend is redis, or memory to keep the data in this process, for
+	// single-replica environments without Redis. StoreSeed names the hotels
+	// the memory store starts with: "sample", a fixtures directory or a load
+	// dump (file, https:// or s3:// URL).
+	StoreBackend  string
+	StoreSeed     string
 	RedisAddrs    []string
 	RedisPassword string
 	UseCluster    bool
+	// UseClusterSource says how UseCluster was decided, for startup logs
+	UseClusterSource string
+
+	// Logical DB (single instance only) and a prefix for every key, to
+	// partition a shared Redis between environments
+	RedisDB        int
+	RedisKeyPrefix string
+
+	// RedisNetwork is "tcp" or "unix"; with "unix", REDIS_ADDR is the socket path
+	RedisNetwork string
+
+	// Cluster replica read routing
+	RedisReadOnly       bool
+	RedisRouteByLatency bool
+	RedisRouteRandomly  bool
+
+	// Redis pool and timeouts
+	RedisPoolSize     int
+	RedisMinIdleConns int
+	RedisDialTimeout  time.Duration
+	RedisReadTimeout  time.Duration
+	RedisWriteTimeout time.Duration
+	RedisPoolTimeout  time.Duration
+	RedisMaxRetries   int
+
+	// Application-level retry of transient Redis errors
+	RedisRetryAttempts  int
+	RedisRetryBaseDelay time.Duration
+	RedisRetryMaxDelay  time.Duration
+
+	// RedisHedgeDelay sends a second read for a single-hotel lookup when the
+	// first hasn't answered in time, taking whichever returns first (0 = off)
+	RedisHedgeDelay time.Duration
+
+	// RedisCoalesceWindow holds single-hotel reads up to this long so reads
+	// arriving together share one pipeline, flushed early once
+	// RedisCoalesceMaxKeys keys are queued (0 = off)
+	RedisCoalesceWindow  time.Duration
+	RedisCoalesceMaxKeys int
+
+	// Optional DR endpoint that serves reads while the primary is unhealthy.
+	// It uses the primary's cluster mode and pool settings.
+	RedisSecondaryAddrs        []string
+	RedisSecondaryPassword     string
+	RedisFailoverCheckInterval time.Duration
+	// Consecutive failed primary checks before reads fail over, and good
+	// ones before they switch back
+	RedisFailoverAfter int
+	RedisFailbackAfter int
+
+	// Optional shadow endpoint, e.g. a cluster being migrated to: a sample of
+	// room lookups (ShadowReadSampleRate) is re-read from it off the request
+	// path and compared with what was served. It uses the primary's pool
+	// settings; RedisShadowUseCluster defaults to more than one address.
+	RedisShadowAddrs      []string
+	RedisShadowPassword   string
+	RedisShadowUseCluster bool
+	ShadowReadSampleRate  float64
+
+	// How often the background monitor checks Redis to enter/leave degraded mode
+	RedisHealthInterval time.Duration
+
+	// LoaderHTTPHeaders are sent when the bulk loader fetches an HTTPS source
+	LoaderHTTPHeaders map[string]string
+
+	// SupplierTTLs maps supplier name to how long its room data stays fresh.
+	// Zero means never expire. Suppliers not listed use DefaultSupplierTTL.
+	SupplierTTLs          map[string]time.Duration
+	DefaultSupplierTTL    time.Duration
+	SupplierSweepInterval time.Duration
+	// HotelTTL expires hotels not written for this long, capping the supplier
+	// windows (0 = no cap). The sweep gives hashes without an expiry one,
+	// counted from their newest room write.
+	HotelTTL time.Duration
+
+	// Background scan of room hashes for malformed, zero-ID and duplicate
+	// rooms (0 runs it only on demand from /admin/validate). Reports list at
+	// most ConsistencyMaxIssues issues.
+	ConsistencyInterval  time.Duration
+	ConsistencyMaxIssues int
+
+	// Every CacheSampleInterval (0 disables), up to CacheSampleSize hotels
+	// recently served from the local cache are re-read from Redis and compared
+	// with the cached copy
+	CacheSampleInterval time.Duration
+	CacheSampleSize     int
+
+	// Room entries skipped while reading (undecryptable, malformed or zero ID)
+	// are kept per hotel for /admin/dead-letters, up to DeadLetterMaxPerHotel
+	// (0 disables) and for DeadLetterTTL after the last one was recorded
+	DeadLetterMaxPerHotel int
+	DeadLetterTTL         time.Duration
+
+	// Rooms removed through the write API or ingestion leave a tombstone,
+	// served with ?include=deleted, for TombstoneRetention (0 disables)
+	TombstoneRetention time.Duration
+
+	// Request journal for replay debugging (opt-in)
+	JournalEnabled    bool
+	JournalSize       int
+	JournalSampleRate float64
+	JournalFile       string
+
+	// Encryption at rest for room values: "id:base64key,..." plus the key ID
+	// used for new writes. Older keys stay listed for reads during rotation.
+	EncryptionKeys        string
+	EncryptionActiveKeyID string
+
+	// SnapshotVersions is how many past versions of each hotel's mapping to
+	// keep as <room key>:v{n} (0 disables snapshots)
+	SnapshotVersions int
+
+	// Soft quota: batch requests per caller per minute before the caller's
+	// maximum batch size starts shrinking (0 disables), and the floor it shrinks to
+	SoftQuotaPerMinute int
+	DegradedBatchMin   int
+
+	// In-process LRU cache of hot hotels in front of Redis. Off by default:
+	// once on, reads may lag writes on other instances by up to CacheTTL,
+	// and by up to CacheStaleTTL more while Redis is failing.
+	CacheEnabled bool
+	CacheSize    int
+	CacheTTL     time.Duration
+	// CacheStaleTTL is how long past expiry an entry may still be served
+	// when Redis is failing (stale-while-revalidate)
+	CacheStaleTTL time.Duration
+	// NegativeCacheTTL caches "hotel has no mappings" results (0 disables)
+	NegativeCacheTTL time.Duration
+	// Refresh-ahead: entries served at least RefreshAheadMinRate times a
+	// second are re-read from Redis in the background once they are within
+	// RefreshAheadWindow of expiring (0 = off)
+	RefreshAheadWindow  time.Duration
+	RefreshAheadMinRate float64
+
+	// Cache warm-up sources, combined: comma-separated IDs, a newline-separated
+	// file, and a Redis set of hot hotel IDs
+	WarmupHotelIDs []string
+	WarmupFile     string
+	WarmupRedisSet string
+	// ReadinessSelfTestLookups is how many lookups must succeed after the
+	// warm-up before /ready reports ready (0 skips the self-test)
+	ReadinessSelfTestLookups int
+
+	// Hashes with more fields than LargeHashThreshold are read with HSCAN,
+	// stopping after LargeHashScanLimit fields (threshold 0 disables the HLEN check)
+	LargeHashThreshold int
+	LargeHashScanLimit int
+
+	// NormalizedRooms stores each hotel's parsed, sorted rooms as one value
+	// next to the hash and reads it first. Copies are rebuilt on writes and
+	// read misses and live at most NormalizedRoomsTTL, which bounds how long
+	// changes made outside the service go unseen.
+	NormalizedRooms    bool
+	NormalizedRoomsTTL time.Duration
+
+	// TokenIndex keeps a per-hotel index of normalized name tokens to room
+	// IDs, rebuilt on writes (and by the reindex command), which serves the
+	// whole-word ?q= search of the filter and count endpoints
+	TokenIndex bool
+
+	// Room hash key template; {hotel} is required and {supplier} is replaced
+	// with RoomKeySupplier for deployments that keep one hash per supplier
+	RoomKeyTemplate string
+	RoomKeySupplier string
+	// RoomKeyFallback also reads the legacy non-hashtagged key of hotels whose
+	// hashtagged key is empty. Turn it off once /admin/keys/migrate has
+	// consolidated the legacy keys.
+	RoomKeyFallback bool
+
+	// Hotel ID rules applied wherever a hotel ID enters the service: IDs are
+	// trimmed, optionally stripped of a leading "#" and case-folded
+	// (HotelIDCase: preserve, lower or upper), then checked against the
+	// length limit (0 disables), HotelIDNumeric and HotelIDAllowedChars, a
+	// regexp character class body such as "A-Za-z0-9_-"
+	HotelIDNumeric      bool
+	HotelIDMaxLength    int
+	HotelIDAllowedChars string
+	HotelIDStripHash    bool
+	HotelIDCase         string
+
+	// RoomNameUnicodeFolding makes room name normalization fold accents and
+	// full-width forms (NFKD), so "Habitación" and "Habitacion" match
+	RoomNameUnicodeFolding bool
+	// RoomNameTransliteration romanizes room names in these scripts
+	// (cyrillic, greek, cjk) before normalizing them
+	RoomNameTransliteration []string
+	// RoomNameRulesFile is an optional YAML file of stopwords, synonyms and
+	// punctuation for room name normalization, re-read when it changes
+	RoomNameRulesFile           string
+	RoomNameRulesReloadInterval time.Duration
+	// RoomNameConflictPolicy handles rooms whose names normalize alike but
+	// whose IDs differ: keep-first, keep-lowest-id or return-all-with-flag
+	RoomNameConflictPolicy string
+
+	// Redis Stream of room mapping deltas applied by a consumer group (opt-in).
+	// Entries pending longer than UpdatesStreamClaimIdle on any consumer are
+	// claimed and retried; after UpdatesStreamMaxDeliveries they go to the
+	// dead-letter stream, or are dropped without one.
+	UpdatesStreamEnabled       bool
+	UpdatesStream              string
+	UpdatesStreamGroup         string
+	UpdatesStreamClaimIdle     time.Duration
+	UpdatesStreamMaxDeliveries int
+	UpdatesStreamDeadLetter    string
+
+	// Kafka topic of room mapping changes (JSON, same ops as the stream)
+	// applied by a consumer group (opt-in)
+	KafkaEnabled bool
+	KafkaBrokers []string
+	KafkaTopic   string
+	KafkaGroupID string
+
+	// SQS queue of room mapping changes (JSON, as for Kafka) long-polled in
+	// batches; messages received SQSMaxReceives times go to the dead-letter
+	// queue, or are dropped without one
+	SQSEnabled           bool
+	SQSQueueURL          string
+	SQSBatchSize         int
+	SQSVisibilityTimeout time.Duration
+	SQSWaitTime          time.Duration
+	SQSMaxReceives       int
+	SQSDeadLetterURL     string
+
+	// Periodic pull of room mappings from an upstream API. UpstreamURL contains
+	// {hotel_id} and returns records in the bulk load JSON format; the hotels
+	// come from a list, file and Redis set, as for warm-up.
+	UpstreamRefresh         bool
+	UpstreamURL             string
+	UpstreamToken           string
+	UpstreamHeaders         map[string]string
+	UpstreamRefreshInterval time.Duration
+	UpstreamTimeout         time.Duration
+	UpstreamConcurrency     int
+	UpstreamHotelIDs        []string
+	UpstreamHotelIDsFile    string
+	UpstreamHotelIDsSet     string
+	// UpstreamReadThrough fetches hotels Redis has no mappings for from
+	// UpstreamURL on lookup, writes them and serves them. Written hashes
+	// expire within UpstreamReadThroughTTL; zero keeps the usual hash TTL.
+	UpstreamReadThrough    bool
+	UpstreamReadThroughTTL time.Duration
+
+	// Evict cached hotels on Redis keyspace notifications for room keys, for
+	// writers that don't publish invalidations (needs notify-keyspace-events)
+	KeyspaceEventsEnabled bool
+
+	// Batch Redis reads are split into chunks of this many hotels, each with
+	// its own timeout inside the request budget (size 0 disables chunking)
+	BatchChunkSize    int
+	BatchChunkTimeout time.Duration
+	// H2CEnabled serves HTTP/2 without TLS alongside HTTP/1.1
+	H2CEnabled                bool
+	HTTP2MaxConcurrentStreams int
+
+	// BatchParseWorkers parses a batch's room hashes concurrently (1 = serially)
+	BatchParseWorkers int
+
+	// OpenTelemetry tracing; the OTLP endpoint comes from the standard
+	// OTEL_EXPORTER_OTLP_* variables
+	TracingEnabled     bool
+	TracingServiceName string
+	TracingSampleRatio float64
+
+	// Structured logging: level (debug, info, warn, error) and format (json, text)
+	LogLevel  string
+	LogFormat string
+	// Fraction of non-error requests written to the access log
+	AccessLogSampleRate float64
+
+	// pprof is served on a separate listener, localhost-only by default
+	PprofEnabled bool
+	DebugAddr    string
+
+	// FaultInjection lets callers and admins inject Redis latency, Redis
+	// errors and partial batch failures; refused in production
+	FaultInjection bool
+
+	// Requests slower or larger than these are logged with their top Redis
+	// commands and counted (0 disables either check)
+	SlowRequestThreshold time.Duration
+	LargeResponseBytes   int
+
+	// SLI latency threshold: requests answered within it count as good for
+	// the latency SLI. SLORouteLatencyThresholds overrides it per route
+	// template, e.g. "/room-mappings/batch=500ms".
+	SLOLatencyThreshold       time.Duration
+	SLORouteLatencyThresholds map[string]time.Duration
+
+	// Per-hotel request analytics over a rolling window, capped at
+	// AnalyticsMaxHotels distinct hotels per minute
+	AnalyticsEnabled   bool
+	AnalyticsWindow    time.Duration
+	AnalyticsMaxHotels int
+
+	// AuditLogFile receives JSON-lines audit records for write and admin
+	// calls; empty writes them to the application log instead
+	AuditLogFile string
+
+	// API key authentication. Keys are "name:key" pairs from API_KEYS, an
+	// optional file of name:key lines and an optional Redis hash of name to
+	// hex SHA-256 of the key; file and Redis are reloaded every refresh interval.
+	AuthEnabled            bool
+	APIKeys                string
+	APIKeysFile            string
+	APIKeysRedisKey        string
+	APIKeysRefreshInterval time.Duration
+
+	// JWT bearer tokens from an OIDC issuer, accepted alongside API keys. The
+	// scopes gate read, write and admin routes; API keys grant all three.
+	JWTEnabled             bool
+	JWTIssuer              string
+	JWTAudience            string
+	JWTJWKSURL             string
+	JWTJWKSRefreshInterval time.Duration
+	JWTScopeRead           string
+	JWTScopeWrite          string
+	JWTScopeAdmin          string
+
+	// Per-caller rate limits in requests per second for the read, write and
+	// admin route groups (0 disables), shared across replicas via Redis.
+	// Bursts of up to rate * RateLimitBurstSeconds requests are allowed.
+	RateLimitRead         float64
+	RateLimitWrite        float64
+	RateLimitAdmin        float64
+	RateLimitBurstSeconds float64
+
+	// Daily and monthly request quotas per API key and per tenant on room
+	// and meta routes, over UTC calendar periods and counted in Redis (0
+	// disables). QuotaOverrides replaces both limits for a subject, keyed
+	// "key:<name>" or "tenant:<name>".
+	QuotaDaily     int
+	QuotaMonthly   int
+	QuotaOverrides map[string]QuotaLimits
+
+	// MaxInFlightRequests caps concurrent room and admin requests; excess is
+	// shed with 503 instead of queueing (0 disables)
+	MaxInFlightRequests int
+
+	// CORS for browser-based tools; disabled unless origins are set ("*"
+	// allows any origin, which cannot be combined with credentials)
+	CORSAllowedOrigins   []string
+	CORSAllowedMethods   []string
+	CORSAllowedHeaders   []string
+	CORSAllowCredentials bool
+	CORSMaxAge           time.Duration
+
+	// AdminAddr, when set, moves /admin, /metrics, /health/detail and pprof
+	// off the public address onto this listener. AdminAllowedCIDRs restricts
+	// those routes to the given networks wherever they are served.
+	AdminAddr         string
+	AdminAllowedCIDRs []string
+
+	// TrustedProxies may set the client IP headers used for client IP
+	// detection (rate limits, quotas, allowlists, logs). Unset trusts every
+	// proxy, unless AdminAllowedCIDRs is set; "none" trusts none, so the peer
+	// address is used.
+	TrustedProxies []string
+	// ClientIPHeaders are the headers a trusted proxy puts the client IP in,
+	// tried in order
+	ClientIPHeaders []string
+	// TrustedPlatform names a header an edge platform sets to the client IP,
+	// trusted from any peer: cloudflare, google, or a header name
+	TrustedPlatform string
+
+	// Per-endpoint latency budgets, applied as request context deadlines
+	LookupTimeout time.Duration
+	BatchTimeout  time.Duration
+	WriteTimeout  time.Duration
+	AdminTimeout  time.Duration
+
+	// HTTP server timeouts for the public listener
+	ServerReadTimeout  time.Duration
+	ServerWriteTimeout time.Duration
+	ServerIdleTimeout  time.Duration
+
+	// On SIGTERM /ready turns 503 and the server keeps serving for
+	// ShutdownDrainDelay, so the load balancer stops routing to it first.
+	// In-flight requests then get up to ShutdownTimeout to finish.
+	ShutdownDrainDelay time.Duration
+	ShutdownTimeout    time.Duration
+
+	// MaintenanceMode is the mode instances start in: off, read_only (writes
+	// and update consumers paused) or full (room routes answer 503 too).
+	// Rejected requests carry MaintenanceRetryAfter.
+	MaintenanceMode       string
+	MaintenanceRetryAfter time.Duration
+
+	// MaxBatchSize caps hotel IDs per batch request; MaxRoomsPerHotel caps
+	// the rooms decoded per hotel, larger hotels are served truncated
+	MaxBatchSize     int
+	MaxRoomsPerHotel int
+
+	// MaxResponseBytes caps room lookup bodies (0 disables). Over the cap,
+	// ResponseOverLimit "truncate" cuts the rooms and returns a next_cursor
+	// (batches drop their largest hotels' rooms); "reject" answers 413.
+	MaxResponseBytes  int
+	ResponseOverLimit string
+
+	// POST /match/batch takes up to MatchBatchMaxHotels hotels with up to
+	// MatchBatchMaxNames room names each
+	MatchBatchMaxHotels int
+	MatchBatchMaxNames  int
+
+	// MissingHotelNotFound answers single lookups of hotels without mappings
+	// with 404 HOTEL_NOT_FOUND instead of 200 and "exists": false
+	MissingHotelNotFound bool
+	// RetryAfter is sent with retryable errors; while the health monitor
+	// reports Redis down, RedisHealthInterval is sent instead
+	RetryAfter time.Duration
+
+	// Response compression: encodings offered in order of preference, the
+	// smallest body worth compressing and the gzip level (1 fastest, 9 best)
+	CompressionEncodings []string
+	CompressionMinBytes  int
+	CompressionGzipLevel int
+
+	// MaxRequestBodyBytes caps JSON bodies on batch and write endpoints
+	MaxRequestBodyBytes int64
+
+	// WriteSigningSecrets, when set, require write requests to carry an HMAC
+	// X-Signature made with one of them (several allow rotation) and a
+	// timestamp within SignatureMaxSkew
+	WriteSigningSecrets []string
+	SignatureMaxSkew    time.Duration
+
+	// WebhookSecrets enable POST /webhooks/mapping-updates; deliveries must be
+	// signed like writes with one of them
+	WebhookSecrets []string
+	// WebhookDedupeTTL is how long event IDs are remembered to drop redeliveries
+	WebhookDedupeTTL time.Duration
+
+	// IdempotencyTTL is how long responses to writes with an Idempotency-Key
+	// are kept for replay; 0 ignores the header
+	IdempotencyTTL time.Duration
+
+	// Tenants are the values accepted in X-Tenant on room and meta routes;
+	// each keeps its hotels under its own keys. Requests without the header
+	// use the untenanted keys unless TenantRequired is set.
+	Tenants        []string
+	TenantRequired bool
 }
 
 func Load() *Config {
@@ -22,16 +486,25 @@ func Load() *Config {
 	if err := godotenv.Load(); err != nil {
 		// .env file is optional, only log if it's a different error
 		if !os.IsNotExist(err) {
-			log.Printf("Warning: Error loading .env file: %v", err)
+			slog.Warn("Error loading .env file", "error", err)
 		}
 	}
 
+	environment := getEnv("ENVIRONMENT", "development")
+	profile, ok := profiles[environment]
+	if !ok {
+		slog.Warn("No settings profile for environment, using built-in defaults", "environment", environment)
+	}
+	profileValues = profile
+
 	var addrs []string
 
 	// Support REDIS_HOST and REDIS_PORT (for production)
+	// unless an address was given on the command line
 	redisHost := getEnv("REDIS_HOST", "")
 	redisPort := getEnv("REDIS_PORT", "")
-	if redisHost != "" && redisPort != "" {
+	_, addrFlag := flagValues["REDIS_ADDR"]
+	if redisHost != "" && redisPort != "" && !addrFlag {
 		// Support comma-separated hosts for cluster
 		hosts := strings.Split(redisHost, ",")
 		for _, host := range hosts {
@@ -52,21 +525,445 @@ func Load() *Config {
 		}
 	}
 
-	// Check if cluster mode is enabled (default: true for production, false for local)
-	useCluster := getEnv("REDIS_CLUSTER_MODE", "false")
-	useClusterBool := strings.ToLower(useCluster) == "true" || useCluster == "1"
+	useCluster, useClusterSource := resolveClusterMode(addrs)
+	shadowAddrs := splitList(getEnv("REDIS_SHADOW_ADDR", ""))
 
-	return &Config{
+	cfg := &Config{
 		Addr:          getEnv("ADDR", ":8080"),
-		Environment:   getEnv("ENVIRONMENT", "development"),
+		Environment:   environment,
+		GinMode:       getEnv("GIN_MODE", "debug"),
+		StoreBackend:  strings.ToLower(getEnv("STORE_BACKEND", StoreBackendRedis)),
+		StoreSeed:     getEnv("STORE_SEED", ""),
 		RedisAddrs:    addrs,
-		RedisPassword: getEnv("REDIS_PASSWORD", ""),
-		UseCluster:    useClusterBool,
+		RedisPassword: getSecret("REDIS_PASSWORD"),
+		UseCluster:    useCluster,
+
+		UseClusterSource: useClusterSource,
+
+		RedisDB:        getInt("REDIS_DB", 0),
+		RedisKeyPrefix: getEnv("REDIS_KEY_PREFIX", ""),
+		RedisNetwork:   strings.ToLower(getEnv("REDIS_NETWORK", "tcp")),
+
+		RedisReadOnly:       getBool("REDIS_READ_ONLY", false),
+		RedisRouteByLatency: getBool("REDIS_ROUTE_BY_LATENCY", false),
+		RedisRouteRandomly:  getBool("REDIS_ROUTE_RANDOMLY", false),
+
+		RedisPoolSize:     getInt("REDIS_POOL_SIZE", 100),
+		RedisMinIdleConns: getInt("REDIS_MIN_IDLE_CONNS", 10),
+		RedisDialTimeout:  getDuration("REDIS_DIAL_TIMEOUT", 5*time.Second),
+		RedisReadTimeout:  getDuration("REDIS_READ_TIMEOUT", 3*time.Second),
+		RedisWriteTimeout: getDuration("REDIS_WRITE_TIMEOUT", 3*time.Second),
+		RedisPoolTimeout:  getDuration("REDIS_POOL_TIMEOUT", 4*time.Second),
+		RedisMaxRetries:   getInt("REDIS_MAX_RETRIES", 3),
+
+		RedisRetryAttempts:   getInt("REDIS_RETRY_ATTEMPTS", 2),
+		RedisRetryBaseDelay:  getDuration("REDIS_RETRY_BASE_DELAY", 10*time.Millisecond),
+		RedisRetryMaxDelay:   getDuration("REDIS_RETRY_MAX_DELAY", 100*time.Millisecond),
+		RedisHedgeDelay:      getDuration("REDIS_HEDGE_DELAY", 0),
+		RedisCoalesceWindow:  getDuration("REDIS_COALESCE_WINDOW", 0),
+		RedisCoalesceMaxKeys: getInt("REDIS_COALESCE_MAX_KEYS", 256),
+
+		RedisSecondaryAddrs:        splitList(getEnv("REDIS_SECONDARY_ADDR", "")),
+		RedisSecondaryPassword:     getSecret("REDIS_SECONDARY_PASSWORD"),
+		RedisFailoverCheckInterval: getDuration("REDIS_FAILOVER_CHECK_INTERVAL", 5*time.Second),
+		RedisFailoverAfter:         getInt("REDIS_FAILOVER_AFTER", 3),
+		RedisFailbackAfter:         getInt("REDIS_FAILBACK_AFTER", 3),
+
+		RedisShadowAddrs:      shadowAddrs,
+		RedisShadowPassword:   getSecret("REDIS_SHADOW_PASSWORD"),
+		RedisShadowUseCluster: getBool("REDIS_SHADOW_USE_CLUSTER", len(shadowAddrs) > 1),
+		ShadowReadSampleRate:  getFloat("SHADOW_READ_SAMPLE_RATE", 0.01),
+
+		RedisHealthInterval: getDuration("REDIS_HEALTH_INTERVAL", 10*time.Second),
+
+		LoaderHTTPHeaders: parseHeaders(getEnv("LOADER_HTTP_HEADERS", "")),
+
+		SupplierTTLs:          parseDurationMap(getEnv("SUPPLIER_TTL_POLICIES", "")),
+		DefaultSupplierTTL:    getDuration("SUPPLIER_TTL_DEFAULT", 0),
+		SupplierSweepInterval: getDuration("SUPPLIER_TTL_SWEEP_INTERVAL", time.Hour),
+		HotelTTL:              getDuration("HOTEL_TTL", 0),
+
+		ConsistencyInterval:  getDuration("CONSISTENCY_CHECK_INTERVAL", 0),
+		ConsistencyMaxIssues: getInt("CONSISTENCY_MAX_ISSUES", 1000),
+
+		CacheSampleInterval: getDuration("CACHE_SAMPLE_INTERVAL", 0),
+		CacheSampleSize:     getInt("CACHE_SAMPLE_SIZE", 20),
+
+		DeadLetterMaxPerHotel: getInt("DEAD_LETTER_MAX_PER_HOTEL", 100),
+		DeadLetterTTL:         getDuration("DEAD_LETTER_TTL", 7*24*time.Hour),
+
+		TombstoneRetention: getDuration("TOMBSTONE_RETENTION", 0),
+
+		JournalEnabled:    getBool("JOURNAL_ENABLED", false),
+		JournalSize:       getInt("JOURNAL_SIZE", 1000),
+		JournalSampleRate: getFloat("JOURNAL_SAMPLE_RATE", 0.01),
+		JournalFile:       getEnv("JOURNAL_FILE", ""),
+
+		EncryptionKeys:        getSecret("ENCRYPTION_KEYS"),
+		EncryptionActiveKeyID: getEnv("ENCRYPTION_ACTIVE_KEY_ID", ""),
+
+		SnapshotVersions: getInt("SNAPSHOT_VERSIONS", 0),
+
+		SoftQuotaPerMinute: getInt("SOFT_QUOTA_PER_MINUTE", 0),
+		DegradedBatchMin:   getInt("DEGRADED_BATCH_MIN", 10),
+
+		CacheEnabled: getBool("CACHE_ENABLED", false),
+		CacheSize:    getInt("CACHE_SIZE", 10000),
+		CacheTTL:     getDuration("CACHE_TTL", 30*time.Second),
+
+		CacheStaleTTL:    getDuration("CACHE_STALE_TTL", 10*time.Minute),
+		NegativeCacheTTL: getDuration("NEGATIVE_CACHE_TTL", 5*time.Second),
+
+		RefreshAheadWindow:  getDuration("REFRESH_AHEAD_WINDOW", 0),
+		RefreshAheadMinRate: getFloat("REFRESH_AHEAD_MIN_RATE", 1),
+
+		WarmupHotelIDs: splitList(getEnv("WARMUP_HOTEL_IDS", "")),
+		WarmupFile:     getEnv("WARMUP_FILE", ""),
+		WarmupRedisSet: getEnv("WARMUP_REDIS_SET", ""),
+
+		ReadinessSelfTestLookups: getInt("READINESS_SELF_TEST_LOOKUPS", 5),
+
+		LargeHashThreshold: getInt("LARGE_HASH_THRESHOLD", 0),
+		LargeHashScanLimit: getInt("LARGE_HASH_SCAN_LIMIT", 2000),
+
+		NormalizedRooms:    getBool("NORMALIZED_ROOMS_ENABLED", false),
+		NormalizedRoomsTTL: getDuration("NORMALIZED_ROOMS_TTL", 10*time.Minute),
+
+		TokenIndex: getBool("TOKEN_INDEX_ENABLED", false),
+
+		RoomKeyTemplate: getEnv("ROOM_KEY_TEMPLATE", "room_map:{hotel}"),
+		RoomKeySupplier: getEnv("ROOM_KEY_SUPPLIER", ""),
+		RoomKeyFallback: getBool("ROOM_KEY_FALLBACK_ENABLED", true),
+
+		HotelIDNumeric:      getBool("HOTEL_ID_NUMERIC", false),
+		HotelIDMaxLength:    getInt("HOTEL_ID_MAX_LENGTH", 128),
+		HotelIDAllowedChars: getEnv("HOTEL_ID_ALLOWED_CHARS", ""),
+		HotelIDStripHash:    getBool("HOTEL_ID_STRIP_HASH", false),
+		HotelIDCase:         getEnv("HOTEL_ID_CASE", "preserve"),
+
+		RoomNameUnicodeFolding:      getBool("ROOM_NAME_UNICODE_FOLDING", false),
+		RoomNameTransliteration:     splitList(getEnv("ROOM_NAME_TRANSLITERATION", "")),
+		RoomNameRulesFile:           getEnv("ROOM_NAME_RULES_FILE", ""),
+		RoomNameRulesReloadInterval: getDuration("ROOM_NAME_RULES_RELOAD_INTERVAL", 30*time.Second),
+		RoomNameConflictPolicy:      getEnv("ROOM_NAME_CONFLICT_POLICY", "return-all-with-flag"),
+
+		UpdatesStreamEnabled:       getBool("UPDATES_STREAM_ENABLED", false),
+		UpdatesStream:              getEnv("UPDATES_STREAM", "room_map_updates"),
+		UpdatesStreamGroup:         getEnv("UPDATES_STREAM_GROUP", "room-mapping-cache"),
+		UpdatesStreamClaimIdle:     getDuration("UPDATES_STREAM_CLAIM_IDLE", time.Minute),
+		UpdatesStreamMaxDeliveries: getInt("UPDATES_STREAM_MAX_DELIVERIES", 5),
+		UpdatesStreamDeadLetter:    getEnv("UPDATES_STREAM_DEAD_LETTER", ""),
+
+		KafkaEnabled: getBool("KAFKA_ENABLED", false),
+		KafkaBrokers: splitList(getEnv("KAFKA_BROKERS", "")),
+		KafkaTopic:   getEnv("KAFKA_TOPIC", "room_map_updates"),
+		KafkaGroupID: getEnv("KAFKA_GROUP_ID", "room-mapping-cache"),
+
+		SQSEnabled:           getBool("SQS_ENABLED", false),
+		SQSQueueURL:          getEnv("SQS_QUEUE_URL", ""),
+		SQSBatchSize:         getInt("SQS_BATCH_SIZE", 10),
+		SQSVisibilityTimeout: getDuration("SQS_VISIBILITY_TIMEOUT", 30*time.Second),
+		SQSWaitTime:          getDuration("SQS_WAIT_TIME", 20*time.Second),
+		SQSMaxReceives:       getInt("SQS_MAX_RECEIVES", 5),
+		SQSDeadLetterURL:     getEnv("SQS_DEAD_LETTER_QUEUE_URL", ""),
+
+		UpstreamRefresh:         getBool("UPSTREAM_REFRESH_ENABLED", false),
+		UpstreamURL:             getEnv("UPSTREAM_API_URL", ""),
+		UpstreamToken:           getSecret("UPSTREAM_API_TOKEN"),
+		UpstreamHeaders:         parseHeaders(getEnv("UPSTREAM_API_HEADERS", "")),
+		UpstreamRefreshInterval: getDuration("UPSTREAM_REFRESH_INTERVAL", 15*time.Minute),
+		UpstreamTimeout:         getDuration("UPSTREAM_TIMEOUT", 10*time.Second),
+		UpstreamConcurrency:     getInt("UPSTREAM_CONCURRENCY", 8),
+		UpstreamHotelIDs:        splitList(getEnv("UPSTREAM_HOTEL_IDS", "")),
+		UpstreamHotelIDsFile:    getEnv("UPSTREAM_HOTEL_IDS_FILE", ""),
+		UpstreamHotelIDsSet:     getEnv("UPSTREAM_HOTEL_IDS_SET", ""),
+		UpstreamReadThrough:     getBool("UPSTREAM_READ_THROUGH_ENABLED", false),
+		UpstreamReadThroughTTL:  getDuration("UPSTREAM_READ_THROUGH_TTL", time.Hour),
+
+		KeyspaceEventsEnabled: getBool("KEYSPACE_EVENTS_ENABLED", false),
+
+		BatchChunkSize:            getInt("BATCH_CHUNK_SIZE", 25),
+		BatchChunkTimeout:         getDuration("BATCH_CHUNK_TIMEOUT", time.Second),
+		H2CEnabled:                getBool("H2C_ENABLED", true),
+		HTTP2MaxConcurrentStreams: getInt("HTTP2_MAX_CONCURRENT_STREAMS", 250),
+
+		BatchParseWorkers: getInt("BATCH_PARSE_WORKERS", runtime.GOMAXPROCS(0)),
+
+		TracingEnabled:     getBool("TRACING_ENABLED", false),
+		TracingServiceName: getEnv("OTEL_SERVICE_NAME", "room-mapping-cache"),
+		TracingSampleRatio: getFloat("TRACING_SAMPLE_RATIO", 0.1),
+
+		LogLevel:  getEnv("LOG_LEVEL", "info"),
+		LogFormat: getEnv("LOG_FORMAT", "json"),
+
+		AccessLogSampleRate: getFloat("ACCESS_LOG_SAMPLE_RATE", 1),
+
+		PprofEnabled: getBool("PPROF_ENABLED", false),
+		DebugAddr:    getEnv("DEBUG_ADDR", "localhost:6060"),
+
+		FaultInjection: getBool("FAULT_INJECTION_ENABLED", false),
+
+		SlowRequestThreshold: getDuration("SLOW_REQUEST_THRESHOLD", 500*time.Millisecond),
+		LargeResponseBytes:   getInt("LARGE_RESPONSE_BYTES", 1<<20),
+
+		SLOLatencyThreshold:       getDuration("SLO_LATENCY_THRESHOLD", 250*time.Millisecond),
+		SLORouteLatencyThresholds: parseDurationMap(getEnv("SLO_ROUTE_LATENCY_THRESHOLDS", "")),
+
+		AnalyticsEnabled:   getBool("ANALYTICS_ENABLED", true),
+		AnalyticsWindow:    getDuration("ANALYTICS_WINDOW", 15*time.Minute),
+		AnalyticsMaxHotels: getInt("ANALYTICS_MAX_HOTELS", 50000),
+
+		AuditLogFile: getEnv("AUDIT_LOG_FILE", ""),
+
+		AuthEnabled:            getBool("AUTH_ENABLED", false),
+		APIKeys:                getEnv("API_KEYS", ""),
+		APIKeysFile:            getEnv("API_KEYS_FILE", ""),
+		APIKeysRedisKey:        getEnv("API_KEYS_REDIS_KEY", ""),
+		APIKeysRefreshInterval: getDuration("API_KEYS_REFRESH_INTERVAL", time.Minute),
+
+		JWTEnabled:             getBool("JWT_ENABLED", false),
+		JWTIssuer:              getEnv("JWT_ISSUER", ""),
+		JWTAudience:            getEnv("JWT_AUDIENCE", ""),
+		JWTJWKSURL:             getEnv("JWT_JWKS_URL", ""),
+		JWTJWKSRefreshInterval: getDuration("JWT_JWKS_REFRESH_INTERVAL", time.Hour),
+		JWTScopeRead:           getEnv("JWT_SCOPE_READ", "room-mappings:read"),
+		JWTScopeWrite:          getEnv("JWT_SCOPE_WRITE", "room-mappings:write"),
+		JWTScopeAdmin:          getEnv("JWT_SCOPE_ADMIN", "room-mappings:admin"),
+
+		RateLimitRead:         getFloat("RATE_LIMIT_READ", 0),
+		RateLimitWrite:        getFloat("RATE_LIMIT_WRITE", 0),
+		RateLimitAdmin:        getFloat("RATE_LIMIT_ADMIN", 0),
+		RateLimitBurstSeconds: getFloat("RATE_LIMIT_BURST_SECONDS", 2),
+
+		QuotaDaily:     getInt("QUOTA_DAILY", 0),
+		QuotaMonthly:   getInt("QUOTA_MONTHLY", 0),
+		QuotaOverrides: parseQuotaOverrides(getEnv("QUOTA_OVERRIDES", "")),
+
+		MaxInFlightRequests: getInt("MAX_INFLIGHT_REQUESTS", 0),
+
+		CORSAllowedOrigins:   splitList(getEnv("CORS_ALLOWED_ORIGINS", "")),
+		CORSAllowedMethods:   splitList(getEnv("CORS_ALLOWED_METHODS", "GET,POST,PUT,DELETE,OPTIONS")),
+		CORSAllowedHeaders:   splitList(getEnv("CORS_ALLOWED_HEADERS", "Origin,Content-Type,Authorization,X-API-Key,X-Request-ID,X-Normalize-Names,X-Tenant,Idempotency-Key,X-Deadline-Ms")),
+		CORSAllowCredentials: getBool("CORS_ALLOW_CREDENTIALS", false),
+		CORSMaxAge:           getDuration("CORS_MAX_AGE", 12*time.Hour),
+
+		AdminAddr:         getEnv("ADMIN_ADDR", ""),
+		AdminAllowedCIDRs: splitList(getEnv("ADMIN_ALLOWED_CIDRS", "")),
+		TrustedProxies:    splitList(getEnv("TRUSTED_PROXIES", "")),
+		ClientIPHeaders:   splitList(getEnv("CLIENT_IP_HEADERS", "X-Forwarded-For,X-Real-IP")),
+		TrustedPlatform:   getEnv("TRUSTED_PLATFORM", ""),
+
+		LookupTimeout: getDuration("LOOKUP_TIMEOUT", 5*time.Second),
+		BatchTimeout:  getDuration("BATCH_TIMEOUT", 1500*time.Millisecond),
+		WriteTimeout:  getDuration("WRITE_TIMEOUT", 5*time.Second),
+		AdminTimeout:  getDuration("ADMIN_TIMEOUT", 5*time.Second),
+
+		ServerReadTimeout:  getDuration("SERVER_READ_TIMEOUT", 10*time.Second),
+		ServerWriteTimeout: getDuration("SERVER_WRITE_TIMEOUT", 10*time.Second),
+		ServerIdleTimeout:  getDuration("SERVER_IDLE_TIMEOUT", 120*time.Second),
+
+		ShutdownDrainDelay: getDuration("SHUTDOWN_DRAIN_DELAY", 5*time.Second),
+		ShutdownTimeout:    getDuration("SHUTDOWN_TIMEOUT", 25*time.Second),
+
+		MaintenanceMode:       strings.ToLower(getEnv("MAINTENANCE_MODE", "off")),
+		MaintenanceRetryAfter: getDuration("MAINTENANCE_RETRY_AFTER", time.Minute),
+
+		MaxBatchSize:     getInt("MAX_BATCH_SIZE", 100),
+		MaxRoomsPerHotel: getInt("MAX_ROOMS_PER_HOTEL", 2000),
+
+		MaxResponseBytes:  getInt("MAX_RESPONSE_BYTES", 0),
+		ResponseOverLimit: strings.ToLower(getEnv("RESPONSE_OVER_LIMIT", ResponseOverLimitTruncate)),
+
+		MatchBatchMaxHotels: getInt("MATCH_BATCH_MAX_HOTELS", 100),
+		MatchBatchMaxNames:  getInt("MATCH_BATCH_MAX_NAMES", 500),
+
+		MissingHotelNotFound: getBool("MISSING_HOTEL_NOT_FOUND", false),
+		RetryAfter:           getDuration("RETRY_AFTER", time.Second),
+
+		CompressionEncodings: splitList(strings.ToLower(getEnv("COMPRESSION_ENCODINGS", "zstd,br,gzip"))),
+		CompressionMinBytes:  getInt("COMPRESSION_MIN_BYTES", 1024),
+		CompressionGzipLevel: getInt("COMPRESSION_GZIP_LEVEL", 1),
+
+		MaxRequestBodyBytes: int64(getInt("MAX_REQUEST_BODY_BYTES", 4<<20)),
+
+		WriteSigningSecrets: splitList(getSecret("WRITE_SIGNING_SECRETS")),
+		SignatureMaxSkew:    getDuration("SIGNATURE_MAX_SKEW", 5*time.Minute),
+
+		WebhookSecrets:   splitList(getSecret("WEBHOOK_SECRETS")),
+		WebhookDedupeTTL: getDuration("WEBHOOK_DEDUPE_TTL", 24*time.Hour),
+
+		IdempotencyTTL: getDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+
+		Tenants:        splitList(getEnv("TENANTS", "")),
+		TenantRequired: getBool("TENANT_REQUIRED", false),
+	}
+	warnUnknownSettings()
+	return cfg
+}
+
+// SupplierTTL returns the freshness window for a supplier (0 = never expires)
+func (c *Config) SupplierTTL(supplier string) time.Duration {
+	if ttl, ok := c.SupplierTTLs[strings.ToLower(supplier)]; ok {
+		return ttl
+	}
+	return c.DefaultSupplierTTL
+}
+
+// HashTTL returns the expiry of a room hash holding rooms of the given
+// suppliers: the longest supplier window, capped by HotelTTL. Zero means the
+// hash never expires.
+func (c *Config) HashTTL(suppliers []string) time.Duration {
+	var longest time.Duration
+	for _, supplier := range suppliers {
+		ttl := c.SupplierTTL(supplier)
+		if ttl <= 0 {
+			longest = 0
+			break
+		}
+		longest = max(longest, ttl)
+	}
+	if c.HotelTTL > 0 && (longest == 0 || c.HotelTTL < longest) {
+		return c.HotelTTL
+	}
+	return longest
+}
+
+// splitList splits a comma-separated value, dropping empty items
+func splitList(raw string) []string {
+	var out []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// resolveClusterMode decides whether to use Redis Cluster: an explicit
+// REDIS_USE_CLUSTER wins, then the older REDIS_CLUSTER_MODE, and otherwise
+// ("auto" or unset) cluster mode is used when more than one address is given.
+func resolveClusterMode(addrs []string) (bool, string) {
+	for _, key := range []string{"REDIS_USE_CLUSTER", "REDIS_CLUSTER_MODE"} {
+		switch value := strings.ToLower(strings.TrimSpace(lookup(key))); value {
+		case "", "auto":
+		case "true", "1":
+			return true, key
+		case "false", "0":
+			return false, key
+		default:
+			parseErrs = append(parseErrs, fmt.Sprintf("%s must be true, false or auto, got %q", key, value))
+		}
+	}
+	return len(addrs) > 1, "auto"
+}
+
+func getBool(key string, defaultValue bool) bool {
+	value := strings.ToLower(lookup(key))
+	if value == "" {
+		return defaultValue
+	}
+	return value == "true" || value == "1"
+}
+
+func getInt(key string, defaultValue int) int {
+	value := lookup(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		parseErrs = append(parseErrs, fmt.Sprintf("%s must be an integer, got %q", key, value))
+		return defaultValue
+	}
+	return n
+}
+
+func getFloat(key string, defaultValue float64) float64 {
+	value := lookup(key)
+	if value == "" {
+		return defaultValue
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		parseErrs = append(parseErrs, fmt.Sprintf("%s must be a number, got %q", key, value))
+		return defaultValue
+	}
+	return f
+}
+
+func getDuration(key string, defaultValue time.Duration) time.Duration {
+	value := lookup(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		parseErrs = append(parseErrs, fmt.Sprintf("%s must be a duration, got %q", key, value))
+		return defaultValue
+	}
+	return d
+}
+
+// parseDurationMap parses "name=168h,other=0" into a map keyed by lowercased name
+func parseDurationMap(raw string) map[string]time.Duration {
+	out := make(map[string]time.Duration)
+	for _, pair := range strings.Split(raw, ",") {
+		name, value, ok := strings.Cut(pair, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !ok || name == "" {
+			continue
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			parseErrs = append(parseErrs, fmt.Sprintf("invalid duration %q for %q", value, name))
+			continue
+		}
+		out[name] = d
+	}
+	return out
+}
+
+// QuotaLimits are the daily and monthly request limits of a quota subject;
+// 0 leaves a period unlimited
+type QuotaLimits struct {
+	Daily   int
+	Monthly int
+}
+
+// parseQuotaOverrides parses "key:partner=10000/250000,tenant:brand=0/1000000"
+func parseQuotaOverrides(raw string) map[string]QuotaLimits {
+	out := make(map[string]QuotaLimits)
+	for _, pair := range splitList(raw) {
+		i := strings.LastIndex(pair, "=")
+		daily, monthly, ok := strings.Cut(pair[i+1:], "/")
+		subject := strings.TrimSpace(pair[:max(i, 0)])
+		d, dErr := strconv.Atoi(strings.TrimSpace(daily))
+		m, mErr := strconv.Atoi(strings.TrimSpace(monthly))
+		if i < 0 || !ok || subject == "" || dErr != nil || mErr != nil {
+			parseErrs = append(parseErrs, fmt.Sprintf("QUOTA_OVERRIDES entries must be subject=daily/monthly, got %q", pair))
+			continue
+		}
+		out[subject] = QuotaLimits{Daily: d, Monthly: m}
+	}
+	return out
+}
+
+// parseHeaders parses "Name: value;Other: value" into a header map
+func parseHeaders(raw string) map[string]string {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(raw, ";") {
+		name, value, ok := strings.Cut(pair, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			continue
+		}
+		headers[name] = strings.TrimSpace(value)
 	}
+	return headers
 }
 
 func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
+	if value := lookup(key); value != "" {
 		return value
 	}
 	return defaultValue
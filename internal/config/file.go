@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileValues holds settings from the YAML config file keyed by their
+// environment variable name. Environment variables take precedence.
+var (
+	fileValues = map[string]string{}
+	usedKeys   = map[string]bool{}
+)
+
+// mapSettings encode YAML mappings into the env format of map-valued settings
+var mapSettings = map[string]func(map[string]string) string{
+	"SUPPLIER_TTL_POLICIES": func(m map[string]string) string { return joinMap(m, "=", ",") },
+	"LOADER_HTTP_HEADERS":   func(m map[string]string) string { return joinMap(m, ":", ";") },
+}
+
+// LoadFile reads settings from a YAML file, then the environment, which
+// overrides it. Nested keys map to environment variable names by joining
+// them with underscores, so redis: {pool_size: 50} sets REDIS_POOL_SIZE;
+// keys under server: have no prefix (server: {addr: ":8080"} sets ADDR).
+// Lists become comma-separated values.
+func LoadFile(path string) (*Config, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	values := map[string]string{}
+	for key, v := range doc {
+		prefix := strings.ToUpper(key)
+		if prefix == "SERVER" {
+			prefix = ""
+		}
+		if err := flatten(values, prefix, v); err != nil {
+			return nil, fmt.Errorf("config file %s: %w", path, err)
+		}
+	}
+	fileValues = values
+	return Load(), nil
+}
+
+// warnUnknownSettings flags likely typos: file or flag settings that no
+// setting looked up
+func warnUnknownSettings() {
+	for source, values := range map[string]map[string]string{"config file": fileValues, "flags": flagValues} {
+		var unknown []string
+		for key := range values {
+			if !usedKeys[key] {
+				unknown = append(unknown, key)
+			}
+		}
+		if len(unknown) > 0 {
+			sort.Strings(unknown)
+			slog.Warn("Ignoring unknown settings", "source", source, "keys", unknown)
+		}
+	}
+}
+
+// flatten stores v under name, recursing into mappings
+func flatten(out map[string]string, name string, v interface{}) error {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if encode, ok := mapSettings[name]; ok {
+			m := make(map[string]string, len(val))
+			for k, item := range val {
+				m[k] = fmt.Sprint(item)
+			}
+			out[name] = encode(m)
+			return nil
+		}
+		for k, item := range val {
+			child := strings.ToUpper(k)
+			if name != "" {
+				child = name + "_" + child
+			}
+			if err := flatten(out, child, item); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		items := make([]string, len(val))
+		for i, item := range val {
+			items[i] = fmt.Sprint(item)
+		}
+		out[name] = strings.Join(items, ",")
+	case nil:
+	default:
+		if name == "" {
+			return fmt.Errorf("server must be a mapping")
+		}
+		out[name] = fmt.Sprint(val)
+	}
+	return nil
+}
+
+func joinMap(m map[string]string, kv, sep string) string {
+	pairs := make([]string, 0, len(m))
+	for k, v := range m {
+		pairs = append(pairs, k+kv+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, sep)
+}
+
+// lookup returns the value of key from flags, the environment, the config
+// file or the environment profile, in that order of precedence
+func lookup(key string) string {
+	usedKeys[key] = true
+	if value, ok := flagValues[key]; ok {
+		return value
+	}
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	if value, ok := fileValues[key]; ok {
+		return value
+	}
+	return profileValues[key]
+}
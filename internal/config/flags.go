@@ -0,0 +1,62 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// flagValues holds settings given on the command line keyed by environment
+// variable name. They override both the environment and the config file.
+var flagValues = map[string]string{}
+
+// namedFlags are the settings most often changed by hand; any other setting
+// can be given with -set NAME=value.
+var namedFlags = []struct {
+	name, env, usage string
+	isBool           bool
+}{
+	{"addr", "ADDR", "listen address", false},
+	{"environment", "ENVIRONMENT", "environment profile (development, staging or production)", false},
+	{"redis-addr", "REDIS_ADDR", "comma-separated Redis addresses", false},
+	{"redis-password", "REDIS_PASSWORD", "Redis password", false},
+	{"redis-cluster", "REDIS_USE_CLUSTER", "use Redis Cluster (true, false or auto)", true},
+	{"redis-db", "REDIS_DB", "Redis logical DB (single instance only)", false},
+	{"redis-key-prefix", "REDIS_KEY_PREFIX", "prefix for every Redis key", false},
+	{"cache", "CACHE_ENABLED", "enable the in-process cache", true},
+	{"cache-size", "CACHE_SIZE", "in-process cache size in hotels", false},
+	{"log-level", "LOG_LEVEL", "log level (debug, info, warn, error)", false},
+	{"log-format", "LOG_FORMAT", "log format (json or text)", false},
+	{"admin-addr", "ADMIN_ADDR", "separate listener for admin and metrics routes", false},
+	{"pprof", "PPROF_ENABLED", "serve pprof on the debug listener", true},
+	{"auth", "AUTH_ENABLED", "require API keys", true},
+}
+
+// RegisterFlags defines the setting flags on fs. Call before fs.Parse and
+// Load or LoadFile.
+func RegisterFlags(fs *flag.FlagSet) {
+	for _, f := range namedFlags {
+		env := f.env
+		usage := fmt.Sprintf("%s (%s)", f.usage, env)
+		if f.isBool {
+			fs.BoolFunc(f.name, usage, func(v string) error {
+				flagValues[env] = v
+				return nil
+			})
+			continue
+		}
+		fs.Func(f.name, usage, func(v string) error {
+			flagValues[env] = v
+			return nil
+		})
+	}
+	fs.Func("set", "set any setting by environment variable name, as NAME=value (repeatable)", func(v string) error {
+		name, value, ok := strings.Cut(v, "=")
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if !ok || name == "" {
+			return fmt.Errorf("expected NAME=value")
+		}
+		flagValues[name] = value
+		return nil
+	})
+}
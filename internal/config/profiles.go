@@ -0,0 +1,33 @@
+package config
+
+// profiles bundle defaults per ENVIRONMENT. They sit below the config file,
+// environment and flags in precedence, so any of them can still override a
+// single value.
+var profiles = map[string]map[string]string{
+	// Verbose, readable logs and generous timeouts for stepping through code
+	"development": {
+		"LOG_LEVEL":            "debug",
+		"LOG_FORMAT":           "text",
+		"GIN_MODE":             "debug",
+		"CACHE_SIZE":           "1000",
+		"LOOKUP_TIMEOUT":       "30s",
+		"BATCH_TIMEOUT":        "30s",
+		"WRITE_TIMEOUT":        "30s",
+		"ADMIN_TIMEOUT":        "30s",
+		"TRACING_SAMPLE_RATIO": "1",
+	},
+	"staging": {
+		"LOG_LEVEL":  "debug",
+		"GIN_MODE":   "release",
+		"CACHE_SIZE": "2000",
+	},
+	"production": {
+		"LOG_LEVEL":  "info",
+		"LOG_FORMAT": "json",
+		"GIN_MODE":   "release",
+		"CACHE_SIZE": "10000",
+	},
+}
+
+// profileValues holds the defaults of the selected profile
+var profileValues = map[string]string{}
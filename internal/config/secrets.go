@@ -0,0 +1,28 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// getSecret returns the value of key, or the contents of the file named by
+// key_FILE (as mounted by Docker and Kubernetes secrets), without its
+// trailing newline. Setting both is reported as a configuration problem.
+func getSecret(key string) string {
+	value := lookup(key)
+	path := lookup(key + "_FILE")
+	if path == "" {
+		return value
+	}
+	if value != "" {
+		parseErrs = append(parseErrs, fmt.Sprintf("set only one of %s and %s_FILE", key, key))
+		return value
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		parseErrs = append(parseErrs, fmt.Sprintf("%s_FILE %q is not readable: %v", key, path, err))
+		return ""
+	}
+	return strings.TrimRight(string(raw), "\r\n")
+}
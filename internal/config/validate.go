@@ -0,0 +1,432 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseErrs collects malformed values seen while loading, so Validate can
+// report them instead of silently falling back to defaults
+var parseErrs []string
+
+// tenantRe keeps tenant names out of the separators used in keys
+var tenantRe = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// ValidationError lists every problem found in a configuration
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%d configuration problem(s): %s", len(e.Problems), strings.Join(e.Problems, "; "))
+}
+
+// Validate checks the configuration for values that would otherwise only
+// fail later, e.g. as confusing Redis errors, and reports them all at once.
+func (c *Config) Validate() error {
+	v := &validator{problems: append([]string(nil), parseErrs...)}
+
+	v.listenAddr("ADDR", c.Addr)
+	if c.AdminAddr != "" {
+		v.listenAddr("ADMIN_ADDR", c.AdminAddr)
+	}
+	if c.PprofEnabled {
+		v.listenAddr("DEBUG_ADDR", c.DebugAddr)
+	}
+	if c.FaultInjection && c.Environment == "production" {
+		v.add("FAULT_INJECTION_ENABLED must not be set in production")
+	}
+
+	// Redis topology
+	switch c.RedisNetwork {
+	case "tcp":
+		for _, addr := range c.RedisAddrs {
+			v.hostPort("REDIS_ADDR", addr)
+		}
+	case "unix":
+		if len(c.RedisAddrs) != 1 {
+			v.add("REDIS_ADDR must be a single socket path with REDIS_NETWORK=unix")
+		}
+		if c.UseCluster {
+			v.add("REDIS_NETWORK=unix is not supported in cluster mode")
+		}
+	default:
+		v.add("REDIS_NETWORK must be tcp or unix, got %q", c.RedisNetwork)
+	}
+	switch c.StoreBackend {
+	case StoreBackendRedis:
+		if c.StoreSeed != "" {
+			v.add("STORE_SEED needs STORE_BACKEND=memory")
+		}
+	case StoreBackendMemory:
+		if len(c.RedisSecondaryAddrs) > 0 || len(c.RedisShadowAddrs) > 0 {
+			v.add("REDIS_SECONDARY_ADDR and REDIS_SHADOW_ADDR need STORE_BACKEND=redis")
+		}
+	default:
+		v.add("STORE_BACKEND must be redis or memory, got %q", c.StoreBackend)
+	}
+	if len(c.RedisAddrs) == 0 {
+		v.add("REDIS_ADDR is empty")
+	}
+	if !c.UseCluster && len(c.RedisAddrs) > 1 {
+		v.add("%d Redis addresses given but cluster mode is disabled", len(c.RedisAddrs))
+	}
+	if c.UseCluster && c.RedisDB != 0 {
+		v.add("REDIS_DB must be 0 in cluster mode")
+	}
+	if c.RedisDB < 0 {
+		v.add("REDIS_DB must not be negative")
+	}
+	if c.RedisMinIdleConns < 0 {
+		v.add("REDIS_MIN_IDLE_CONNS must not be negative")
+	}
+	for _, addr := range c.RedisSecondaryAddrs {
+		v.hostPort("REDIS_SECONDARY_ADDR", addr)
+	}
+	for _, addr := range c.RedisShadowAddrs {
+		v.hostPort("REDIS_SHADOW_ADDR", addr)
+	}
+	v.ratio("SHADOW_READ_SAMPLE_RATE", c.ShadowReadSampleRate)
+
+	// Zero means "use the default" for pool settings, so only negatives are wrong
+	v.nonNegative("REDIS_DIAL_TIMEOUT", c.RedisDialTimeout)
+	v.nonNegative("REDIS_READ_TIMEOUT", c.RedisReadTimeout)
+	v.nonNegative("REDIS_WRITE_TIMEOUT", c.RedisWriteTimeout)
+	v.nonNegative("REDIS_POOL_TIMEOUT", c.RedisPoolTimeout)
+	v.positive("REDIS_HEALTH_INTERVAL", c.RedisHealthInterval)
+	v.nonNegative("REDIS_HEDGE_DELAY", c.RedisHedgeDelay)
+	v.nonNegative("REDIS_COALESCE_WINDOW", c.RedisCoalesceWindow)
+	if c.RedisCoalesceWindow > 0 && c.RedisCoalesceMaxKeys < 1 {
+		v.add("REDIS_COALESCE_MAX_KEYS must be positive")
+	}
+	if len(c.RedisSecondaryAddrs) > 0 {
+		v.positive("REDIS_FAILOVER_CHECK_INTERVAL", c.RedisFailoverCheckInterval)
+		if c.RedisFailoverAfter < 1 {
+			v.add("REDIS_FAILOVER_AFTER must be positive")
+		}
+		if c.RedisFailbackAfter < 1 {
+			v.add("REDIS_FAILBACK_AFTER must be positive")
+		}
+	}
+	v.positive("BATCH_CHUNK_TIMEOUT", c.BatchChunkTimeout)
+	v.positive("LOOKUP_TIMEOUT", c.LookupTimeout)
+	v.positive("BATCH_TIMEOUT", c.BatchTimeout)
+	v.positive("WRITE_TIMEOUT", c.WriteTimeout)
+	v.positive("ADMIN_TIMEOUT", c.AdminTimeout)
+	v.positive("SERVER_READ_TIMEOUT", c.ServerReadTimeout)
+	v.positive("SERVER_WRITE_TIMEOUT", c.ServerWriteTimeout)
+	v.nonNegative("SHUTDOWN_DRAIN_DELAY", c.ShutdownDrainDelay)
+	v.positive("SHUTDOWN_TIMEOUT", c.ShutdownTimeout)
+	switch c.MaintenanceMode {
+	case "off", "read_only", "full":
+	default:
+		v.add("MAINTENANCE_MODE must be off, read_only or full")
+	}
+	v.positive("MAINTENANCE_RETRY_AFTER", c.MaintenanceRetryAfter)
+	v.positive("RETRY_AFTER", c.RetryAfter)
+	v.nonNegative("SERVER_IDLE_TIMEOUT", c.ServerIdleTimeout)
+	if c.H2CEnabled && c.HTTP2MaxConcurrentStreams <= 0 {
+		v.add("HTTP2_MAX_CONCURRENT_STREAMS must be positive")
+	}
+	if c.BatchChunkSize <= 0 {
+		v.add("BATCH_CHUNK_SIZE must be positive")
+	}
+	if c.BatchParseWorkers <= 0 {
+		v.add("BATCH_PARSE_WORKERS must be positive")
+	}
+	if c.CacheEnabled {
+		if c.CacheSize <= 0 {
+			v.add("CACHE_SIZE must be positive when the cache is enabled")
+		}
+		v.positive("CACHE_TTL", c.CacheTTL)
+	}
+	v.nonNegative("REFRESH_AHEAD_WINDOW", c.RefreshAheadWindow)
+	if c.RefreshAheadWindow > 0 {
+		if c.RefreshAheadWindow >= c.CacheTTL {
+			v.add("REFRESH_AHEAD_WINDOW must be shorter than CACHE_TTL, got %s", c.RefreshAheadWindow)
+		}
+		if c.RefreshAheadMinRate < 0 {
+			v.add("REFRESH_AHEAD_MIN_RATE must not be negative")
+		}
+	}
+	if c.NormalizedRooms {
+		v.positive("NORMALIZED_ROOMS_TTL", c.NormalizedRoomsTTL)
+	}
+	if c.AnalyticsEnabled {
+		v.positive("ANALYTICS_WINDOW", c.AnalyticsWindow)
+	}
+
+	v.ratio("ACCESS_LOG_SAMPLE_RATE", c.AccessLogSampleRate)
+	v.ratio("JOURNAL_SAMPLE_RATE", c.JournalSampleRate)
+	v.ratio("TRACING_SAMPLE_RATIO", c.TracingSampleRatio)
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		v.add("LOG_LEVEL must be debug, info, warn or error, got %q", c.LogLevel)
+	}
+	if c.GinMode != gin.DebugMode && c.GinMode != gin.ReleaseMode && c.GinMode != gin.TestMode {
+		v.add("GIN_MODE must be debug, release or test, got %q", c.GinMode)
+	}
+	if f := strings.ToLower(c.LogFormat); f != "json" && f != "text" {
+		v.add("LOG_FORMAT must be json or text, got %q", c.LogFormat)
+	}
+
+	if c.KafkaEnabled && (len(c.KafkaBrokers) == 0 || c.KafkaTopic == "" || c.KafkaGroupID == "") {
+		v.add("KAFKA_BROKERS, KAFKA_TOPIC and KAFKA_GROUP_ID are required with KAFKA_ENABLED")
+	}
+	for _, addr := range c.KafkaBrokers {
+		v.hostPort("KAFKA_BROKERS", addr)
+	}
+
+	if c.SQSEnabled {
+		if c.SQSQueueURL == "" {
+			v.add("SQS_QUEUE_URL is required with SQS_ENABLED")
+		}
+		if c.SQSBatchSize < 1 || c.SQSBatchSize > 10 {
+			v.add("SQS_BATCH_SIZE must be between 1 and 10, got %d", c.SQSBatchSize)
+		}
+		if c.SQSVisibilityTimeout < time.Second || c.SQSVisibilityTimeout > 12*time.Hour {
+			v.add("SQS_VISIBILITY_TIMEOUT must be between 1s and 12h, got %s", c.SQSVisibilityTimeout)
+		}
+		if c.SQSWaitTime < 0 || c.SQSWaitTime > 20*time.Second {
+			v.add("SQS_WAIT_TIME must be between 0 and 20s, got %s", c.SQSWaitTime)
+		}
+		if c.SQSMaxReceives < 1 {
+			v.add("SQS_MAX_RECEIVES must be positive")
+		}
+	}
+
+	if c.UpdatesStreamEnabled {
+		v.positive("UPDATES_STREAM_CLAIM_IDLE", c.UpdatesStreamClaimIdle)
+		if c.UpdatesStreamMaxDeliveries < 1 {
+			v.add("UPDATES_STREAM_MAX_DELIVERIES must be positive")
+		}
+		if c.UpdatesStreamDeadLetter == c.UpdatesStream {
+			v.add("UPDATES_STREAM_DEAD_LETTER must differ from UPDATES_STREAM")
+		}
+	}
+
+	if c.UpstreamRefresh || c.UpstreamReadThrough {
+		if !strings.HasPrefix(c.UpstreamURL, "https://") || !strings.Contains(c.UpstreamURL, "{hotel_id}") {
+			v.add("UPSTREAM_API_URL must be an https:// URL containing {hotel_id}, got %q", c.UpstreamURL)
+		}
+		v.positive("UPSTREAM_TIMEOUT", c.UpstreamTimeout)
+	}
+	if c.UpstreamReadThrough {
+		v.nonNegative("UPSTREAM_READ_THROUGH_TTL", c.UpstreamReadThroughTTL)
+	}
+	if c.UpstreamRefresh {
+		v.positive("UPSTREAM_REFRESH_INTERVAL", c.UpstreamRefreshInterval)
+		if c.UpstreamConcurrency < 1 {
+			v.add("UPSTREAM_CONCURRENCY must be positive")
+		}
+		if c.UpstreamHotelIDsFile != "" {
+			v.fileExists("UPSTREAM_HOTEL_IDS_FILE", c.UpstreamHotelIDsFile)
+		}
+		if len(c.UpstreamHotelIDs) == 0 && c.UpstreamHotelIDsFile == "" && c.UpstreamHotelIDsSet == "" {
+			v.add("UPSTREAM_REFRESH_ENABLED needs UPSTREAM_HOTEL_IDS, UPSTREAM_HOTEL_IDS_FILE or UPSTREAM_HOTEL_IDS_SET")
+		}
+	}
+
+	if c.ReadinessSelfTestLookups < 0 {
+		v.add("READINESS_SELF_TEST_LOOKUPS must not be negative")
+	}
+	v.nonNegative("HOTEL_TTL", c.HotelTTL)
+	v.nonNegative("CONSISTENCY_CHECK_INTERVAL", c.ConsistencyInterval)
+	v.nonNegative("CACHE_SAMPLE_INTERVAL", c.CacheSampleInterval)
+	if c.CacheSampleInterval > 0 && c.CacheSampleSize <= 0 {
+		v.add("CACHE_SAMPLE_SIZE must be positive")
+	}
+	if c.ConsistencyMaxIssues < 0 {
+		v.add("CONSISTENCY_MAX_ISSUES must not be negative")
+	}
+	v.nonNegative("ROOM_NAME_RULES_RELOAD_INTERVAL", c.RoomNameRulesReloadInterval)
+	for _, script := range c.RoomNameTransliteration {
+		switch script {
+		case "cyrillic", "greek", "cjk":
+		default:
+			v.add("ROOM_NAME_TRANSLITERATION may only list cyrillic, greek or cjk, got %q", script)
+		}
+	}
+	switch c.RoomNameConflictPolicy {
+	case "keep-first", "keep-lowest-id", "return-all-with-flag":
+	default:
+		v.add("ROOM_NAME_CONFLICT_POLICY must be keep-first, keep-lowest-id or return-all-with-flag, got %q", c.RoomNameConflictPolicy)
+	}
+	if c.HotelIDMaxLength < 0 {
+		v.add("HOTEL_ID_MAX_LENGTH must not be negative")
+	}
+	if c.HotelIDAllowedChars != "" {
+		if _, err := regexp.Compile("[" + c.HotelIDAllowedChars + "]"); err != nil {
+			v.add("HOTEL_ID_ALLOWED_CHARS must be a regexp character class body: %v", err)
+		}
+	}
+	switch c.HotelIDCase {
+	case "preserve", "lower", "upper":
+	default:
+		v.add("HOTEL_ID_CASE must be preserve, lower or upper, got %q", c.HotelIDCase)
+	}
+	if c.DeadLetterMaxPerHotel < 0 {
+		v.add("DEAD_LETTER_MAX_PER_HOTEL must not be negative")
+	}
+	if c.DeadLetterMaxPerHotel > 0 {
+		v.positive("DEAD_LETTER_TTL", c.DeadLetterTTL)
+	}
+	v.nonNegative("TOMBSTONE_RETENTION", c.TombstoneRetention)
+
+	// Auth and limits
+	if c.APIKeysFile != "" {
+		v.fileExists("API_KEYS_FILE", c.APIKeysFile)
+	}
+	if c.WarmupFile != "" {
+		v.fileExists("WARMUP_FILE", c.WarmupFile)
+	}
+	if c.JWTEnabled && (c.JWTIssuer == "" || c.JWTAudience == "" || c.JWTJWKSURL == "") {
+		v.add("JWT_ISSUER, JWT_AUDIENCE and JWT_JWKS_URL are required with JWT_ENABLED")
+	}
+	if c.RateLimitRead < 0 || c.RateLimitWrite < 0 || c.RateLimitAdmin < 0 {
+		v.add("rate limits must not be negative")
+	}
+	if c.MaxInFlightRequests < 0 {
+		v.add("MAX_INFLIGHT_REQUESTS must not be negative")
+	}
+	if c.MaxBatchSize <= 0 {
+		v.add("MAX_BATCH_SIZE must be positive")
+	}
+	if c.MaxRoomsPerHotel <= 0 {
+		v.add("MAX_ROOMS_PER_HOTEL must be positive")
+	}
+	if c.MatchBatchMaxHotels <= 0 {
+		v.add("MATCH_BATCH_MAX_HOTELS must be positive")
+	}
+	if c.MatchBatchMaxNames <= 0 {
+		v.add("MATCH_BATCH_MAX_NAMES must be positive")
+	}
+	if c.MaxResponseBytes < 0 {
+		v.add("MAX_RESPONSE_BYTES must not be negative")
+	}
+	if c.ResponseOverLimit != ResponseOverLimitTruncate && c.ResponseOverLimit != ResponseOverLimitReject {
+		v.add("RESPONSE_OVER_LIMIT must be truncate or reject, got %q", c.ResponseOverLimit)
+	}
+	v.positive("SLO_LATENCY_THRESHOLD", c.SLOLatencyThreshold)
+	for route, d := range c.SLORouteLatencyThresholds {
+		if !strings.HasPrefix(route, "/") || d <= 0 {
+			v.add("SLO_ROUTE_LATENCY_THRESHOLDS entries must map a route template to a positive duration, got %s=%s", route, d)
+		}
+	}
+	for _, enc := range c.CompressionEncodings {
+		if enc != "zstd" && enc != "br" && enc != "gzip" && enc != "identity" {
+			v.add("COMPRESSION_ENCODINGS may only list zstd, br, gzip or identity, got %q", enc)
+		}
+	}
+	if c.CompressionMinBytes < 0 {
+		v.add("COMPRESSION_MIN_BYTES must not be negative")
+	}
+	if c.CompressionGzipLevel < 1 || c.CompressionGzipLevel > 9 {
+		v.add("COMPRESSION_GZIP_LEVEL must be between 1 and 9, got %d", c.CompressionGzipLevel)
+	}
+	if !(len(c.TrustedProxies) == 1 && c.TrustedProxies[0] == "none") {
+		for _, proxy := range c.TrustedProxies {
+			if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+				v.add("TRUSTED_PROXIES entries must be IPs or CIDRs (or just none), got %q", proxy)
+			}
+		}
+	}
+
+	if c.MaxRequestBodyBytes <= 0 {
+		v.add("MAX_REQUEST_BODY_BYTES must be positive")
+	}
+	if len(c.WriteSigningSecrets) > 0 || len(c.WebhookSecrets) > 0 {
+		v.positive("SIGNATURE_MAX_SKEW", c.SignatureMaxSkew)
+	}
+	if len(c.WebhookSecrets) > 0 {
+		v.positive("WEBHOOK_DEDUPE_TTL", c.WebhookDedupeTTL)
+	}
+	v.nonNegative("IDEMPOTENCY_TTL", c.IdempotencyTTL)
+	for _, tenant := range c.Tenants {
+		if !tenantRe.MatchString(tenant) {
+			v.add("TENANTS entries may only use a-z, 0-9, _ and -, got %q", tenant)
+		}
+	}
+	if c.TenantRequired && len(c.Tenants) == 0 {
+		v.add("TENANT_REQUIRED needs TENANTS")
+	}
+	if c.QuotaDaily < 0 || c.QuotaMonthly < 0 {
+		v.add("QUOTA_DAILY and QUOTA_MONTHLY must not be negative")
+	}
+	for subject, limits := range c.QuotaOverrides {
+		if !strings.HasPrefix(subject, "key:") && !strings.HasPrefix(subject, "tenant:") {
+			v.add("QUOTA_OVERRIDES subjects must start with key: or tenant:, got %q", subject)
+		}
+		if limits.Daily < 0 || limits.Monthly < 0 {
+			v.add("QUOTA_OVERRIDES limits for %q must not be negative", subject)
+		}
+	}
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+type validator struct {
+	problems []string
+}
+
+func (v *validator) add(format string, args ...any) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+func (v *validator) listenAddr(name, addr string) {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		v.add("%s %q is not host:port: %v", name, addr, err)
+		return
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		v.add("%s %q has an invalid port", name, addr)
+	}
+}
+
+func (v *validator) hostPort(name, addr string) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		v.add("%s %q is not host:port", name, addr)
+		return
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		v.add("%s %q has an invalid port", name, addr)
+	}
+}
+
+func (v *validator) positive(name string, d time.Duration) {
+	if d <= 0 {
+		v.add("%s must be positive, got %s", name, d)
+	}
+}
+
+func (v *validator) nonNegative(name string, d time.Duration) {
+	if d < 0 {
+		v.add("%s must not be negative, got %s", name, d)
+	}
+}
+
+func (v *validator) ratio(name string, f float64) {
+	if f < 0 || f > 1 {
+		v.add("%s must be between 0 and 1, got %g", name, f)
+	}
+}
+
+func (v *validator) fileExists(name, path string) {
+	if _, err := os.Stat(path); err != nil {
+		v.add("%s %q is not readable: %v", name, path, err)
+	}
+}
@@ -0,0 +1,132 @@
+package errs
+
+import "errors"
+
+// Code identifies an error condition for clients to branch on. Codes are
+// stable: messages may be reworded, but a code keeps its meaning and is
+// never reused.
+type Code string
+
+const (
+	// CodeInvalidRequest: a parameter, header or body field is invalid;
+	// details.field names it when known
+	CodeInvalidRequest Code = "INVALID_REQUEST"
+	// CodeInvalidHotelID: a hotel ID fails the HOTEL_ID_* rules; details.hotel_id
+	// is the ID as sent
+	CodeInvalidHotelID Code = "INVALID_HOTEL_ID"
+	// CodeBatchTooLarge: a batch lists more hotels than allowed; details.max
+	// is the caller's current limit
+	CodeBatchTooLarge Code = "BATCH_TOO_LARGE"
+	// CodeBodyTooLarge: the request body exceeds the size limit
+	CodeBodyTooLarge Code = "BODY_TOO_LARGE"
+	// CodeResponseTooLarge: the answer would exceed the response size limit;
+	// details.max_bytes is the limit. Page with ?cursor= or split the batch.
+	CodeResponseTooLarge Code = "RESPONSE_TOO_LARGE"
+	// CodeHotelNotFound: the hotel has no room mappings
+	CodeHotelNotFound Code = "HOTEL_NOT_FOUND"
+	// CodeNotFound: any other missing resource
+	CodeNotFound Code = "NOT_FOUND"
+	// CodeRedisUnavailable: Redis could not be reached or answered with an
+	// error; retry with backoff
+	CodeRedisUnavailable Code = "REDIS_UNAVAILABLE"
+	// CodeMaintenance: the service is in maintenance; retry after Retry-After
+	CodeMaintenance Code = "MAINTENANCE"
+	// CodeTimeout: the request ran out of time; retry with backoff
+	CodeTimeout Code = "TIMEOUT"
+	// CodeBadData: stored data could not be decoded
+	CodeBadData Code = "BAD_DATA"
+	// CodeOverloaded: the instance is shedding load; retry after Retry-After
+	CodeOverloaded Code = "OVERLOADED"
+	// CodeRateLimited: the caller exceeded its request rate
+	CodeRateLimited Code = "RATE_LIMITED"
+	// CodeQuotaExceeded: the caller used up its daily or monthly quota
+	CodeQuotaExceeded Code = "QUOTA_EXCEEDED"
+	// CodeUnauthorized: credentials are missing or invalid
+	CodeUnauthorized Code = "UNAUTHORIZED"
+	// CodeForbidden: the credentials do not grant this route or tenant
+	CodeForbidden Code = "FORBIDDEN"
+	// CodeConflict: a concurrent request holds the same resource; retry
+	CodeConflict Code = "CONFLICT"
+	// CodeCanceled: the client cancelled the request; only seen in logs
+	CodeCanceled Code = "CANCELED"
+	// CodeInternal: an unexpected failure
+	CodeInternal Code = "INTERNAL"
+)
+
+// CodeOf returns the Code of err: the one set on an *Error, or the default
+// for its Kind
+func CodeOf(err error) Code {
+	var e *Error
+	if errors.As(err, &e) && e.Code != "" {
+		return e.Code
+	}
+	switch KindOf(err) {
+	case NotFound:
+		return CodeNotFound
+	case Invalid:
+		return CodeInvalidRequest
+	case Degraded:
+		return CodeRedisUnavailable
+	case Timeout:
+		return CodeTimeout
+	case BadData:
+		return CodeBadData
+	case Overloaded:
+		return CodeOverloaded
+	case Unauthorized:
+		return CodeUnauthorized
+	case Forbidden:
+		return CodeForbidden
+	case TooLarge:
+		return CodeBodyTooLarge
+	case Conflict:
+		return CodeConflict
+	case Canceled:
+		return CodeCanceled
+	default:
+		return CodeInternal
+	}
+}
+
+// Response is the body of every error response. Error, Kind, Retryable and
+// Field predate Code and are kept for existing clients.
+type Response struct {
+	Code    Code   `json:"code"`
+	Message string `json:"message"`
+	// RequestID matches the X-Request-ID response header and the log lines
+	RequestID string         `json:"request_id,omitempty"`
+	Details   map[string]any `json:"details,omitempty"`
+
+	Error     string `json:"error"`
+	Kind      Kind   `json:"kind"`
+	Retryable bool   `json:"retryable"`
+	Field     string `json:"field,omitempty"`
+}
+
+// NewResponse builds the status and body answering err. Only the
+// client-safe message of an *Error is exposed.
+func NewResponse(err error, requestID string) (int, Response) {
+	kind := KindOf(err)
+	resp := Response{
+		Code:      CodeOf(err),
+		Message:   "internal error",
+		RequestID: requestID,
+		Kind:      kind,
+		Retryable: Retryable(kind),
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		resp.Message, resp.Field = e.Msg, e.Field
+		if len(e.Details) > 0 || e.Field != "" {
+			resp.Details = make(map[string]any, len(e.Details)+1)
+			for k, v := range e.Details {
+				resp.Details[k] = v
+			}
+			if e.Field != "" {
+				resp.Details["field"] = e.Field
+			}
+		}
+	}
+	resp.Error = resp.Message
+	return HTTPStatus(kind), resp
+}
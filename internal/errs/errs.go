@@ -0,0 +1,155 @@
+// Package errs defines the error taxonomy shared by the Redis client, HTTP
+// handlers and background jobs. Each Kind maps to an HTTP status and a
+// retryability hint that is surfaced to clients.
+package errs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type Kind string
+
+const (
+	NotFound     Kind = "not_found"
+	Invalid      Kind = "invalid"
+	Degraded     Kind = "degraded"
+	Timeout      Kind = "timeout"
+	BadData      Kind = "bad_data"
+	Overloaded   Kind = "overloaded"
+	Unauthorized Kind = "unauthorized"
+	Forbidden    Kind = "forbidden"
+	TooLarge     Kind = "too_large"
+	Conflict     Kind = "conflict"
+	// Canceled: the client went away before the answer was ready
+	Canceled Kind = "canceled"
+	Internal Kind = "internal"
+)
+
+// StatusClientClosedRequest is the nginx convention for requests the client
+// abandoned; no response reaches the client, but logs and metrics see it
+const StatusClientClosedRequest = 499
+
+// Error carries a Kind plus a client-safe message; Err holds the underlying
+// cause. Field optionally names the offending request field. Code refines
+// the Kind's default code and Details adds machine-readable context.
+type Error struct {
+	Kind    Kind
+	Code    Code
+	Msg     string
+	Field   string
+	Details map[string]any
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Msg + ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func New(kind Kind, msg string) *Error {
+	return &Error{Kind: kind, Msg: msg}
+}
+
+func Wrap(kind Kind, msg string, err error) *Error {
+	return &Error{Kind: kind, Msg: msg, Err: err}
+}
+
+// Classify wraps err with the Kind inferred from it
+func Classify(msg string, err error) *Error {
+	return &Error{Kind: KindOf(err), Msg: msg, Err: err}
+}
+
+// KindOf infers the Kind of an arbitrary error
+func KindOf(err error) Kind {
+	if err == nil {
+		return ""
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+
+	switch {
+	case errors.Is(err, redis.Nil):
+		return NotFound
+	case errors.Is(err, context.DeadlineExceeded):
+		return Timeout
+	case errors.Is(err, context.Canceled):
+		return Canceled
+	case errors.Is(err, io.EOF), errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ECONNRESET):
+		return Degraded
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return Timeout
+		}
+		return Degraded
+	}
+
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "connection pool timeout"):
+		return Overloaded
+	case strings.HasPrefix(msg, "CLUSTERDOWN"), strings.HasPrefix(msg, "LOADING"),
+		strings.HasPrefix(msg, "TRYAGAIN"), strings.HasPrefix(msg, "MOVED"), strings.HasPrefix(msg, "ASK"):
+		return Degraded
+	}
+	return Internal
+}
+
+// HTTPStatus maps a Kind to the status code used in error responses
+func HTTPStatus(kind Kind) int {
+	switch kind {
+	case NotFound:
+		return http.StatusNotFound
+	case Invalid:
+		return http.StatusBadRequest
+	case Degraded:
+		return http.StatusServiceUnavailable
+	case Timeout:
+		return http.StatusGatewayTimeout
+	case BadData:
+		return http.StatusUnprocessableEntity
+	case Overloaded:
+		return http.StatusTooManyRequests
+	case Unauthorized:
+		return http.StatusUnauthorized
+	case Forbidden:
+		return http.StatusForbidden
+	case TooLarge:
+		return http.StatusRequestEntityTooLarge
+	case Conflict:
+		return http.StatusConflict
+	case Canceled:
+		return StatusClientClosedRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Retryable reports whether retrying the same request may succeed
+func Retryable(kind Kind) bool {
+	switch kind {
+	case Degraded, Timeout, Overloaded, Conflict:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,212 @@
+// Package faults injects artificial Redis latency, Redis errors and partial
+// batch failures into requests, so client teams can exercise their retry and
+// fallback logic against this service. It is refused in production.
+package faults
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"room-mapping-cache/internal/errs"
+	"room-mapping-cache/internal/logging"
+	"room-mapping-cache/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// Request headers overriding the instance-wide faults for one request
+const (
+	LatencyHeader          = "X-Fault-Redis-Latency"
+	ErrorRateHeader        = "X-Fault-Redis-Error-Rate"
+	BatchFailureRateHeader = "X-Fault-Batch-Failure-Rate"
+)
+
+// maxLatency bounds injected latency per command
+const maxLatency = 30 * time.Second
+
+// ErrInjected is returned by Redis commands failed on purpose. It classifies
+// as Degraded, like a lost connection.
+var ErrInjected = errs.New(errs.Degraded, "injected Redis fault")
+
+// Spec describes the faults applied to a request
+type Spec struct {
+	// RedisLatency is added before every Redis command or pipeline
+	RedisLatency time.Duration
+	// RedisErrorRate is the share of Redis commands failed with ErrInjected
+	RedisErrorRate float64
+	// BatchFailureRate is the share of hotels reported as failed in batch
+	// responses
+	BatchFailureRate float64
+}
+
+// Active reports whether s injects anything
+func (s Spec) Active() bool {
+	return s.RedisLatency > 0 || s.RedisErrorRate > 0 || s.BatchFailureRate > 0
+}
+
+// Validate checks the rates are shares and the latency is bounded
+func (s Spec) Validate() error {
+	switch {
+	case s.RedisLatency < 0 || s.RedisLatency > maxLatency:
+		return &errs.Error{Kind: errs.Invalid, Field: "redis_latency", Msg: "redis latency must be between 0 and 30s"}
+	case s.RedisErrorRate < 0 || s.RedisErrorRate > 1:
+		return &errs.Error{Kind: errs.Invalid, Field: "redis_error_rate", Msg: "redis error rate must be between 0 and 1"}
+	case s.BatchFailureRate < 0 || s.BatchFailureRate > 1:
+		return &errs.Error{Kind: errs.Invalid, Field: "batch_failure_rate", Msg: "batch failure rate must be between 0 and 1"}
+	}
+	return nil
+}
+
+type specKey struct{}
+
+// FromContext returns the faults of the request ctx belongs to
+func FromContext(ctx context.Context) Spec {
+	s, _ := ctx.Value(specKey{}).(*Spec)
+	if s == nil {
+		return Spec{}
+	}
+	return *s
+}
+
+// Active reports whether the request ctx belongs to has faults injected.
+// Such requests bypass the local cache and shared fetches, so the faults
+// reach Redis and stay confined to the request.
+func Active(ctx context.Context) bool {
+	return FromContext(ctx).Active()
+}
+
+// FailBatchHotel decides whether to report one batch hotel as failed
+func FailBatchHotel(ctx context.Context) bool {
+	if rate := FromContext(ctx).BatchFailureRate; rate > 0 && rand.Float64() < rate {
+		metrics.FaultsInjected.WithLabelValues("batch_failure").Inc()
+		return true
+	}
+	return false
+}
+
+// Injector holds the instance-wide faults set through the admin API
+type Injector struct {
+	global atomic.Pointer[Spec]
+}
+
+func New() *Injector {
+	i := &Injector{}
+	i.global.Store(&Spec{})
+	return i
+}
+
+// Global returns the faults applied to every request
+func (i *Injector) Global() Spec {
+	return *i.global.Load()
+}
+
+// SetGlobal replaces the faults applied to every request
+func (i *Injector) SetGlobal(s Spec) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	i.global.Store(&s)
+	return nil
+}
+
+// Middleware attaches the instance-wide faults, overridden by any fault
+// headers, to the request context
+func (i *Injector) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		spec, err := i.fromHeaders(c)
+		if err == nil {
+			err = spec.Validate()
+		}
+		if err != nil {
+			logging.AbortWithError(c, err)
+			return
+		}
+		if spec.Active() {
+			c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), specKey{}, &spec))
+		}
+		c.Next()
+	}
+}
+
+func (i *Injector) fromHeaders(c *gin.Context) (Spec, error) {
+	spec := i.Global()
+	if v := c.GetHeader(LatencyHeader); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return spec, &errs.Error{Kind: errs.Invalid, Field: LatencyHeader, Msg: LatencyHeader + " must be a duration such as 250ms"}
+		}
+		spec.RedisLatency = d
+	}
+	for _, h := range []struct {
+		name string
+		rate *float64
+	}{{ErrorRateHeader, &spec.RedisErrorRate}, {BatchFailureRateHeader, &spec.BatchFailureRate}} {
+		v := c.GetHeader(h.name)
+		if v == "" {
+			continue
+		}
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return spec, &errs.Error{Kind: errs.Invalid, Field: h.name, Msg: h.name + " must be a number between 0 and 1"}
+		}
+		*h.rate = rate
+	}
+	return spec, nil
+}
+
+// Hook returns a go-redis hook applying the request's Redis latency and
+// error rate to each command, and once per pipeline
+func Hook() redis.Hook {
+	return hook{}
+}
+
+type hook struct{}
+
+func (hook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (hook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if err := inject(ctx); err != nil {
+			cmd.SetErr(err)
+			return err
+		}
+		return next(ctx, cmd)
+	}
+}
+
+func (hook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if err := inject(ctx); err != nil {
+			for _, cmd := range cmds {
+				cmd.SetErr(err)
+			}
+			return err
+		}
+		return next(ctx, cmds)
+	}
+}
+
+func inject(ctx context.Context) error {
+	spec := FromContext(ctx)
+	if spec.RedisLatency > 0 {
+		metrics.FaultsInjected.WithLabelValues("latency").Inc()
+		t := time.NewTimer(spec.RedisLatency)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+	if spec.RedisErrorRate > 0 && rand.Float64() < spec.RedisErrorRate {
+		metrics.FaultsInjected.WithLabelValues("redis_error").Inc()
+		return ErrInjected
+	}
+	return nil
+}
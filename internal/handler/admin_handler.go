@@ -0,0 +1,274 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"room-mapping-cache/internal/analytics"
+	"room-mapping-cache/internal/errs"
+	"room-mapping-cache/internal/faults"
+	"room-mapping-cache/internal/jobs"
+	"room-mapping-cache/internal/journal"
+	"room-mapping-cache/internal/keys"
+	"room-mapping-cache/internal/limits"
+	"room-mapping-cache/internal/redis"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminStore is the part of the store the admin endpoints inspect directly;
+// everything else goes through the room handler
+type AdminStore interface {
+	redis.KeyValue
+	redis.HashReader
+	redis.Scanner
+	redis.Status
+}
+
+type AdminHandler struct {
+	redisClient    AdminStore
+	roomHandler    *RoomHandler
+	supplierExpiry *jobs.SupplierExpiry
+	consistency    *ConsistencyChecker
+	keyMigration   *KeyMigration
+	journal        *journal.Journal
+	quotas         *limits.Quotas
+	faults         *faults.Injector
+}
+
+type HotelScanResponse struct {
+	HotelIDs []string `json:"hotel_ids"`
+	Cursor   string   `json:"cursor"`
+}
+
+func NewAdminHandler(redisClient AdminStore, roomHandler *RoomHandler, supplierExpiry *jobs.SupplierExpiry, consistency *ConsistencyChecker, keyMigration *KeyMigration, j *journal.Journal, quotas *limits.Quotas) *AdminHandler {
+	return &AdminHandler{
+		redisClient:    redisClient,
+		roomHandler:    roomHandler,
+		supplierExpiry: supplierExpiry,
+		consistency:    consistency,
+		keyMigration:   keyMigration,
+		journal:        j,
+		quotas:         quotas,
+	}
+}
+
+// ListHotels scans room hash keys and returns the hotel IDs matching the
+// optional glob pattern. Pass the returned cursor back to continue; an empty
+// cursor means the scan is complete.
+func (h *AdminHandler) ListHotels(c *gin.Context) {
+	pattern := c.DefaultQuery("pattern", "*")
+	if _, err := path.Match(pattern, ""); err != nil {
+		respondError(c, errs.New(errs.Invalid, "invalid pattern"))
+		return
+	}
+
+	count := int64(500)
+	if raw := c.Query("count"); raw != "" {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n <= 0 || n > 10000 {
+			respondError(c, errs.New(errs.Invalid, "count must be between 1 and 10000"))
+			return
+		}
+		count = n
+	}
+
+	ctx := c.Request.Context()
+
+	found, next, err := h.redisClient.ScanKeys(ctx, c.Query("cursor"), keys.RoomScanPattern(), count)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to scan room mapping keys", "error", err)
+		respondError(c, errs.Classify("failed to scan keys", err))
+		return
+	}
+
+	hotelIDs := make([]string, 0, len(found))
+	for _, key := range found {
+		if keys.IsSnapshot(key) {
+			continue
+		}
+		hotelID, ok := keys.HotelID(key)
+		if !ok {
+			continue
+		}
+		if matched, _ := path.Match(pattern, hotelID); matched {
+			hotelIDs = append(hotelIDs, hotelID)
+		}
+	}
+
+	c.JSON(http.StatusOK, HotelScanResponse{HotelIDs: dedupStringsInPlace(hotelIDs), Cursor: next})
+}
+
+// SupplierExpiryReport returns the latest supplier expiry sweep report. Passing
+// ?run=true triggers a sweep synchronously first.
+func (h *AdminHandler) SupplierExpiryReport(c *gin.Context) {
+	if c.Query("run") == "true" {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Minute)
+		defer cancel()
+		c.JSON(http.StatusOK, h.supplierExpiry.Sweep(ctx))
+		return
+	}
+
+	report := h.supplierExpiry.LastReport()
+	if report == nil {
+		respondError(c, errs.New(errs.NotFound, "no supplier expiry sweep has run yet"))
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// ConsistencyReport returns the latest consistency check report. Passing
+// ?run=true runs a check synchronously first.
+func (h *AdminHandler) ConsistencyReport(c *gin.Context) {
+	if c.Query("run") == "true" {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Minute)
+		defer cancel()
+		c.JSON(http.StatusOK, h.consistency.Check(ctx))
+		return
+	}
+
+	report := h.consistency.LastReport(c.Request.Context())
+	if report == nil {
+		respondError(c, errs.New(errs.NotFound, "no consistency check has run yet"))
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// StartKeyMigration starts moving legacy non-hashtagged room hashes into the
+// hashtagged keys in the background; ?dry_run=true only counts. Progress is at
+// GET /admin/keys/migrate.
+func (h *AdminHandler) StartKeyMigration(c *gin.Context) {
+	report, started := h.keyMigration.Start(c.Query("dry_run") == "true")
+	if !started {
+		respondError(c, errs.New(errs.Overloaded, "a key migration is already running"))
+		return
+	}
+	c.JSON(http.StatusAccepted, report)
+}
+
+// KeyMigrationReport returns the progress of the current or last key migration
+func (h *AdminHandler) KeyMigrationReport(c *gin.Context) {
+	report := h.keyMigration.Report()
+	if report == nil {
+		respondError(c, errs.New(errs.NotFound, "no key migration has run yet"))
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// Journal downloads recent request journal entries as JSON lines
+func (h *AdminHandler) Journal(c *gin.Context) {
+	if h.journal == nil {
+		respondError(c, errs.New(errs.NotFound, "request journal is disabled"))
+		return
+	}
+
+	limit := 0
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			respondError(c, errs.New(errs.Invalid, "limit must be a non-negative integer"))
+			return
+		}
+		limit = n
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", `attachment; filename="journal.jsonl"`)
+	enc := json.NewEncoder(c.Writer)
+	for _, e := range h.journal.Recent(limit) {
+		_ = enc.Encode(e)
+	}
+}
+
+// CacheStats reports local cache hit rate, size and TTLs
+func (h *AdminHandler) CacheStats(c *gin.Context) {
+	c.JSON(http.StatusOK, h.roomHandler.CacheStats())
+}
+
+// TopHotelsResponse lists the busiest hotels over the analytics window
+type TopHotelsResponse struct {
+	Window  string                 `json:"window"`
+	Hotels  []analytics.HotelStats `json:"hotels"`
+	Dropped int64                  `json:"dropped"`
+}
+
+// TopHotels returns the most requested hotels and their cache outcomes, e.g.
+// to build warm-up lists. ?n= limits the result (default 100).
+func (h *AdminHandler) TopHotels(c *gin.Context) {
+	tracker := h.roomHandler.analytics
+	if tracker == nil {
+		respondError(c, errs.New(errs.NotFound, "hotel analytics are disabled"))
+		return
+	}
+
+	n := 100
+	if raw := c.Query("n"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 || v > 10000 {
+			respondError(c, errs.New(errs.Invalid, "n must be between 1 and 10000"))
+			return
+		}
+		n = v
+	}
+
+	c.JSON(http.StatusOK, TopHotelsResponse{
+		Window:  tracker.Window().String(),
+		Hotels:  tracker.Top(n),
+		Dropped: tracker.Dropped(),
+	})
+}
+
+// QuotaUsage returns a quota subject's usage in the current UTC day and
+// month. Subjects are key:<API key name> or tenant:<tenant>.
+func (h *AdminHandler) QuotaUsage(c *gin.Context) {
+	subject, ok := h.quotaSubject(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	usage, err := h.quotas.Usage(ctx, subject)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to read quota usage", "subject", subject, "error", err)
+		respondError(c, errs.Classify("failed to read quota usage", err))
+		return
+	}
+	c.JSON(http.StatusOK, usage)
+}
+
+// ResetQuota clears a subject's usage of ?period=daily or monthly, or of
+// both when no period is given, and returns the usage after the reset
+func (h *AdminHandler) ResetQuota(c *gin.Context) {
+	subject, ok := h.quotaSubject(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	if err := h.quotas.Reset(ctx, subject, c.Query("period")); err != nil {
+		slog.ErrorContext(ctx, "Failed to reset quota", "subject", subject, "error", err)
+		respondError(c, errs.Classify("failed to reset quota", err))
+		return
+	}
+	slog.InfoContext(ctx, "Quota reset", "subject", subject, "period", c.Query("period"))
+	h.QuotaUsage(c)
+}
+
+func (h *AdminHandler) quotaSubject(c *gin.Context) (string, bool) {
+	if h.quotas == nil {
+		respondError(c, errs.New(errs.NotFound, "quotas are disabled"))
+		return "", false
+	}
+	subject := c.Param("subject")
+	if !strings.HasPrefix(subject, "key:") && !strings.HasPrefix(subject, "tenant:") {
+		respondError(c, &errs.Error{Kind: errs.Invalid, Field: "subject", Msg: "subject must be key:<name> or tenant:<name>"})
+		return "", false
+	}
+	return subject, true
+}
@@ -0,0 +1,248 @@
+package handler
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+// RoomAttributes are structured facts parsed from a room name. Unknown
+// attributes are left empty.
+type RoomAttributes struct {
+	// BedType is king, queen, twin, double, single, bunk or sofa_bed
+	BedType   string `json:"bed_type,omitempty"`
+	Occupancy int    `json:"occupancy,omitempty"`
+	// View is what the view faces, e.g. sea, city or garden
+	View    string `json:"view,omitempty"`
+	Smoking *bool  `json:"smoking,omitempty"`
+	// Board is room_only, breakfast, half_board, full_board or all_inclusive
+	Board string `json:"board,omitempty"`
+}
+
+// Attribute names used in rules
+const (
+	AttrBedType   = "bed_type"
+	AttrOccupancy = "occupancy"
+	AttrView      = "view"
+	AttrSmoking   = "smoking"
+	AttrBoard     = "board"
+)
+
+// attributeRule sets an attribute when its pattern matches a normalized room
+// name. A value of "$n" takes the pattern's nth group.
+type attributeRule struct {
+	attribute string
+	value     string
+	re        *regexp.Regexp
+}
+
+// AttributeRuleFile is an attribute rule in the room name rules file:
+//
+//	attributes:
+//	  - {attribute: view, value: sea, pattern: '\bmar\b'}
+//
+// File rules are tried before the built-in ones; per attribute the first
+// matching rule wins.
+type AttributeRuleFile struct {
+	Attribute string `yaml:"attribute"`
+	Value     string `yaml:"value"`
+	Pattern   string `yaml:"pattern"`
+}
+
+func mustRule(attribute, value, pattern string) attributeRule {
+	return attributeRule{attribute: attribute, value: value, re: regexp.MustCompile(pattern)}
+}
+
+// builtinAttributeRules match names after normalization (lowercase, common
+// punctuation replaced with spaces). Order matters within an attribute.
+var builtinAttributeRules = []attributeRule{
+	mustRule(AttrBedType, "king", `\bking\b`),
+	mustRule(AttrBedType, "queen", `\bqueen\b`),
+	mustRule(AttrBedType, "twin", `\btwin\b|\b(?:2|two) single beds?\b`),
+	mustRule(AttrBedType, "double", `\b(?:double|dbl|matrimonial)\b`),
+	mustRule(AttrBedType, "single", `\b(?:single|sgl)\b`),
+	mustRule(AttrBedType, "bunk", `\bbunk\b`),
+	mustRule(AttrBedType, "sofa_bed", `\bsofa ?bed\b`),
+
+	mustRule(AttrOccupancy, "$1", `\b(\d{1,2}) ?(?:adults?|persons?|people|pax|guests?)\b`),
+	mustRule(AttrOccupancy, "4", `\bquad(?:ruple)?\b`),
+	mustRule(AttrOccupancy, "3", `\b(?:triple|tpl)\b`),
+	mustRule(AttrOccupancy, "2", `\b(?:double|dbl|twin)\b`),
+	mustRule(AttrOccupancy, "1", `\b(?:single|sgl)\b`),
+
+	mustRule(AttrView, "$1", `\b(sea|ocean|city|garden|pool|mountain|lake|river|park|courtyard|harbou?r) ?views?\b`),
+	mustRule(AttrView, "sea", `\b(?:sea|ocean) ?front\b`),
+
+	mustRule(AttrSmoking, "false", `\b(?:non|no) ?smoking\b`),
+	mustRule(AttrSmoking, "true", `\bsmoking\b`),
+
+	mustRule(AttrBoard, "all_inclusive", `\ball ?inclusive\b`),
+	mustRule(AttrBoard, "full_board", `\bfull ?board\b`),
+	mustRule(AttrBoard, "half_board", `\bhalf ?board\b`),
+	mustRule(AttrBoard, "breakfast", `\bbreakfast\b|\bb ?& ?b\b|\bbb\b`),
+	mustRule(AttrBoard, "room_only", `\broom only\b|\bno meals\b`),
+}
+
+// compileAttributeRules checks and compiles the rules file's attribute rules
+func compileAttributeRules(entries []AttributeRuleFile) ([]attributeRule, error) {
+	rules := make([]attributeRule, 0, len(entries))
+	for i, e := range entries {
+		re, err := regexp.Compile(e.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("attribute rule %d: %w", i, err)
+		}
+		rule := attributeRule{attribute: e.Attribute, value: e.Value, re: re}
+		if n, ok := groupRef(e.Value); ok {
+			if n > re.NumSubexp() {
+				return nil, fmt.Errorf("attribute rule %d: pattern has no group %d", i, n)
+			}
+		} else if err := checkAttributeValue(e.Attribute, e.Value); err != nil {
+			return nil, fmt.Errorf("attribute rule %d: %w", i, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func checkAttributeValue(attribute, value string) error {
+	switch attribute {
+	case AttrBedType, AttrView, AttrBoard:
+		if value == "" {
+			return fmt.Errorf("%s needs a value", attribute)
+		}
+	case AttrOccupancy:
+		if n, err := strconv.Atoi(value); err != nil || n < 1 {
+			return fmt.Errorf("occupancy must be a positive number, got %q", value)
+		}
+	case AttrSmoking:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("smoking must be true or false, got %q", value)
+		}
+	default:
+		return fmt.Errorf("unknown attribute %q", attribute)
+	}
+	return nil
+}
+
+// groupRef parses a "$n" rule value
+func groupRef(value string) (int, bool) {
+	if len(value) < 2 || value[0] != '$' {
+		return 0, false
+	}
+	n, err := strconv.Atoi(value[1:])
+	return n, err == nil && n > 0
+}
+
+// attributeCache memoizes extraction by room name; it is cleared when full
+// or when the rules change
+var attributeCache = struct {
+	sync.RWMutex
+	m map[string]RoomAttributes
+}{m: make(map[string]RoomAttributes)}
+
+const attributeCacheLimit = 50000
+
+func resetAttributeCache() {
+	attributeCache.Lock()
+	clear(attributeCache.m)
+	attributeCache.Unlock()
+}
+
+// roomAttributes extracts the attributes of a served room name, which may be
+// raw when the caller opted out of normalization
+func roomAttributes(name string) RoomAttributes {
+	attributeCache.RLock()
+	attrs, ok := attributeCache.m[name]
+	attributeCache.RUnlock()
+	if ok {
+		return attrs
+	}
+
+	normalized := normalizeRoomName(name)
+	var set [5]bool
+	apply := func(rules []attributeRule) {
+		for _, rule := range rules {
+			slot := attributeSlot(rule.attribute)
+			if slot < 0 || set[slot] {
+				continue
+			}
+			m := rule.re.FindStringSubmatch(normalized)
+			if m == nil {
+				continue
+			}
+			value := rule.value
+			if n, ok := groupRef(value); ok && n < len(m) {
+				value = m[n]
+			}
+			if attrs.set(rule.attribute, value) {
+				set[slot] = true
+			}
+		}
+	}
+	if rules := activeNameRules.Load(); rules != nil {
+		apply(rules.attributes)
+	}
+	apply(builtinAttributeRules)
+
+	attributeCache.Lock()
+	if len(attributeCache.m) >= attributeCacheLimit {
+		clear(attributeCache.m)
+	}
+	attributeCache.m[name] = attrs
+	attributeCache.Unlock()
+	return attrs
+}
+
+func attributeSlot(attribute string) int {
+	switch attribute {
+	case AttrBedType:
+		return 0
+	case AttrOccupancy:
+		return 1
+	case AttrView:
+		return 2
+	case AttrSmoking:
+		return 3
+	case AttrBoard:
+		return 4
+	}
+	return -1
+}
+
+// set stores a matched value, reporting false if it doesn't fit the attribute
+func (a *RoomAttributes) set(attribute, value string) bool {
+	switch attribute {
+	case AttrBedType:
+		a.BedType = value
+	case AttrOccupancy:
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return false
+		}
+		a.Occupancy = n
+	case AttrView:
+		a.View = value
+	case AttrSmoking:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return false
+		}
+		a.Smoking = &b
+	case AttrBoard:
+		a.Board = value
+	}
+	return value != ""
+}
+
+// withAttributes returns a copy of rooms with attributes filled in; cached
+// room slices are shared and must not be modified
+func withAttributes(rooms []Room) []Room {
+	out := make([]Room, len(rooms))
+	for i, r := range rooms {
+		attrs := roomAttributes(r.Name)
+		r.Attributes = &attrs
+		out[i] = r
+	}
+	return out
+}
@@ -0,0 +1,56 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+
+	"room-mapping-cache/internal/keys"
+	"room-mapping-cache/testutil"
+)
+
+// A chunk whose last hotel is served from the local cache queues no room
+// keys of its own; the chunk bounds must still cover the hotels before it.
+func TestBatchChunkEndingOnCachedHotel(t *testing.T) {
+	srv := testutil.NewServer(t, "BATCH_CHUNK_SIZE=2", "CACHE_ENABLED=true")
+	if srv.Redis == nil {
+		t.Skip("needs the in-process Redis to drop cached hotels")
+	}
+	srv.PutHotel("1001", "acme", map[string]int64{"Double": 1})
+	srv.PutHotel("1002", "acme", map[string]int64{"Twin": 2})
+	srv.PutHotel("1003", "acme", map[string]int64{"Suite": 3})
+	srv.PutHotel("1004", "acme", map[string]int64{"King": 4})
+
+	// Cache 1002 and 1004, then drop them from Redis so only the cache has them
+	srv.Get("/room-mappings/1002").ExpectStatus(http.StatusOK)
+	srv.Get("/room-mappings/1004").ExpectStatus(http.StatusOK)
+	srv.Redis.Del(keys.Room("1002"))
+	srv.Redis.Del(keys.Room("1004"))
+
+	resp := srv.Do(http.MethodPost, "/room-mappings/batch", map[string]any{
+		"hotel_ids": []string{"1001", "1002", "1003", "1004"},
+	}, nil).ExpectStatus(http.StatusOK)
+
+	var body struct {
+		Hotels map[string]struct {
+			Status string          `json:"status"`
+			Rooms  []testutil.Room `json:"rooms"`
+		} `json:"hotels"`
+		Partial bool `json:"partial"`
+	}
+	resp.JSON(&body)
+	if body.Partial {
+		t.Fatalf("partial response: %s", resp.Body)
+	}
+	want := map[string]testutil.Room{
+		"1001": {Name: "double", ID: 1},
+		"1002": {Name: "twin", ID: 2},
+		"1003": {Name: "suite", ID: 3},
+		"1004": {Name: "king", ID: 4},
+	}
+	for hotelID, room := range want {
+		got := body.Hotels[hotelID]
+		if got.Status != "ok" || len(got.Rooms) != 1 || got.Rooms[0] != room {
+			t.Errorf("hotel %s: status %q rooms %+v, want ok %+v", hotelID, got.Status, got.Rooms, room)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"testing"
+
+	"room-mapping-cache/internal/config"
+)
+
+// benchHotel is a room hash as stored by the write path, with n rooms
+func benchHotel(n int) map[string]string {
+	hash := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("Deluxe King Room, City View #%d", i)
+		hash[name] = `{"id":` + strconv.Itoa(i+1) + `,"supplier":"acme","updated_at":"2026-01-01T00:00:00Z"}`
+	}
+	return hash
+}
+
+func benchResponse(n int) RoomMappingsResponse {
+	rooms := make([]Room, n)
+	for i := range rooms {
+		rooms[i] = Room{Name: fmt.Sprintf("deluxe king room city view %d", i), ID: int64(i + 1)}
+	}
+	return RoomMappingsResponse{Rooms: rooms, Version: 42, UpdatedAt: "2026-01-01T00:00:00Z"}
+}
+
+func BenchmarkParseRooms(b *testing.B) {
+	cfg := config.Load()
+	h := NewRoomHandler(nil, cfg, nil)
+	for _, n := range []int{10, 100, 1000} {
+		hash := benchHotel(n)
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				h.parseRooms("1001", hash)
+			}
+		})
+	}
+}
+
+func BenchmarkAppendResponseJSON(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		resp := benchResponse(n)
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			b.ReportAllocs()
+			buf := make([]byte, 0, 64<<10)
+			for i := 0; i < b.N; i++ {
+				buf = appendResponseJSON(buf[:0], resp)
+			}
+			b.SetBytes(int64(len(buf)))
+		})
+	}
+}
+
+func BenchmarkAppendBatchJSON(b *testing.B) {
+	batch := BatchRoomMappingsResponse{Hotels: make(map[string]RoomMappingsResponse, 100)}
+	for i := 0; i < 100; i++ {
+		batch.Hotels[strconv.Itoa(1000+i)] = benchResponse(20)
+	}
+	b.ReportAllocs()
+	buf := make([]byte, 0, 64<<10)
+	for i := 0; i < b.N; i++ {
+		buf = appendResponseJSON(buf[:0], batch)
+	}
+	b.SetBytes(int64(len(buf)))
+}
+
+func BenchmarkCompress(b *testing.B) {
+	body := appendResponseJSON(nil, benchResponse(1000))
+	for _, enc := range allEncodings {
+		b.Run(enc, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(body)))
+			for i := 0; i < b.N; i++ {
+				cw := getCompressor(enc, io.Discard)
+				_, _ = cw.Write(body)
+				_ = cw.Close()
+				putCompressor(enc, cw)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"room-mapping-cache/internal/errs"
+	"room-mapping-cache/internal/hotelid"
+	"room-mapping-cache/internal/keys"
+)
+
+// bulkBookkeepingWorkers bounds the concurrent afterWrite calls of a bulk write
+const bulkBookkeepingWorkers = 16
+
+// HotelRooms is one hotel's rooms for one supplier, as PutRoomMappings takes them
+type HotelRooms struct {
+	HotelID  string
+	Supplier string
+	Rooms    map[string]map[string]interface{}
+}
+
+// WriteHotels upserts many hotels at once: room hashes are written in
+// pipelines, then each written hotel gets the usual post-write bookkeeping.
+// The returned errors are aligned with hotels; nil means written.
+func (h *RoomHandler) WriteHotels(ctx context.Context, hotels []HotelRooms) []error {
+	results := make([]error, len(hotels))
+	var (
+		idx    []int
+		ids    []string
+		hkeys  []string
+		values []map[string]interface{}
+	)
+	for i, hotel := range hotels {
+		supplier := strings.ToLower(strings.TrimSpace(hotel.Supplier))
+		hotelID, err := hotelid.Canonical(hotel.HotelID)
+		if err != nil {
+			results[i] = err
+			continue
+		}
+		if supplier == "" || len(hotel.Rooms) == 0 {
+			results[i] = errs.New(errs.Invalid, "hotel_id, supplier and at least one room are required")
+			continue
+		}
+		fields, err := encodeRoomFields(hotelID, supplier, hotel.Rooms)
+		if err != nil {
+			results[i] = err
+			continue
+		}
+		idx = append(idx, i)
+		ids = append(ids, hotelID)
+		hkeys = append(hkeys, keys.Room(hotelID))
+		values = append(values, fields)
+	}
+
+	keyErrs, _ := h.redisClient.HSetMulti(ctx, hkeys, values)
+	written := make([]string, 0, len(idx))
+	for j, i := range idx {
+		if err := keyErrs[j]; err != nil {
+			results[i] = errs.Classify("failed to write room mappings", err)
+			continue
+		}
+		written = append(written, ids[j])
+	}
+
+	var (
+		wg   sync.WaitGroup
+		next atomic.Int64
+	)
+	for w := 0; w < min(bulkBookkeepingWorkers, len(written)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				n := int(next.Add(1) - 1)
+				if n >= len(written) {
+					return
+				}
+				h.afterWrite(ctx, written[n])
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
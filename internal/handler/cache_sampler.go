@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"time"
+
+	"room-mapping-cache/internal/metrics"
+)
+
+// cacheSamplerRecent is how many recent cache hits the sampler picks from
+const cacheSamplerRecent = 1024
+
+// Cache sample outcomes, the room_cache_cache_samples_total result label
+const (
+	sampleConsistent = "consistent"
+	// sampleStale: Redis has a newer version than the one cached
+	sampleStale = "stale"
+	// sampleDivergent: the rooms differ although the versions don't, or the
+	// cached version is ahead of Redis
+	sampleDivergent = "divergent"
+	// sampleEvicted: the hotel left the cache before it was sampled
+	sampleEvicted = "evicted"
+	sampleError   = "error"
+)
+
+// cacheSampler remembers hotels recently served from the local cache and
+// periodically re-reads a random few from Redis, measuring how often the
+// cache serves data that has diverged from it. A nil *cacheSampler does
+// nothing.
+type cacheSampler struct {
+	h    *RoomHandler
+	size int
+
+	mu     sync.Mutex
+	recent []string
+	next   int
+}
+
+// SetCacheSampler makes the handler remember the hotels it serves from the
+// local cache, for RunCacheSampler to compare up to size of them with Redis
+// per round. It must be called before serving traffic.
+func (h *RoomHandler) SetCacheSampler(size int) {
+	h.sampler = &cacheSampler{h: h, size: size, recent: make([]string, 0, cacheSamplerRecent)}
+}
+
+// RunCacheSampler samples the cache every interval until ctx is cancelled
+func (h *RoomHandler) RunCacheSampler(ctx context.Context, interval time.Duration) {
+	if h.sampler == nil || h.hotelCache == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		h.sampler.round(ctx)
+	}
+}
+
+// served records a hotel answered from the local cache
+func (s *cacheSampler) served(hotelID string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if len(s.recent) < cacheSamplerRecent {
+		s.recent = append(s.recent, hotelID)
+	} else {
+		s.recent[s.next] = hotelID
+		s.next = (s.next + 1) % cacheSamplerRecent
+	}
+	s.mu.Unlock()
+}
+
+// pick returns up to size distinct hotels of the recent ones, at random
+func (s *cacheSampler) pick() []string {
+	s.mu.Lock()
+	recent := append([]string(nil), s.recent...)
+	s.mu.Unlock()
+
+	rand.Shuffle(len(recent), func(i, j int) { recent[i], recent[j] = recent[j], recent[i] })
+	seen := make(map[string]bool, s.size)
+	ids := make([]string, 0, s.size)
+	for _, hotelID := range recent {
+		if len(ids) == s.size {
+			break
+		}
+		if !seen[hotelID] {
+			seen[hotelID] = true
+			ids = append(ids, hotelID)
+		}
+	}
+	return ids
+}
+
+// round compares a sample of recently served hotels with Redis
+func (s *cacheSampler) round(ctx context.Context) {
+	if RedisDegraded() {
+		return
+	}
+	counts := make(map[string]int)
+	for _, hotelID := range s.pick() {
+		result := s.check(ctx, hotelID)
+		metrics.CacheSamples.WithLabelValues(result).Inc()
+		counts[result]++
+	}
+	if compared := counts[sampleConsistent] + counts[sampleStale] + counts[sampleDivergent]; compared > 0 {
+		metrics.CacheConsistency.Set(float64(counts[sampleConsistent]) / float64(compared))
+	}
+	if counts[sampleStale] > 0 || counts[sampleDivergent] > 0 {
+		slog.Info("Cache sample found inconsistent hotels",
+			"stale", counts[sampleStale], "divergent", counts[sampleDivergent], "consistent", counts[sampleConsistent])
+	}
+}
+
+// check compares one cached hotel with Redis
+func (s *cacheSampler) check(ctx context.Context, hotelID string) string {
+	cached, ok := s.h.hotelCache.Peek(hotelID)
+	if !ok {
+		return sampleEvicted
+	}
+	readCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	rooms, version, err := s.h.readHotel(readCtx, s.h.redisClient, hotelID)
+	cancel()
+	if err != nil {
+		slog.Warn("Cache sample read failed", "hotel_id", hotelID, "error", err)
+		return sampleError
+	}
+
+	switch {
+	case sameRooms(cached.Rooms, rooms):
+		return sampleConsistent
+	case version.Version > cached.Version.Version:
+		if !cached.Version.UpdatedAt.IsZero() {
+			metrics.CacheSampleLag.Observe(version.UpdatedAt.Sub(cached.Version.UpdatedAt).Seconds())
+		}
+		return sampleStale
+	}
+	slog.Warn("Cached hotel diverges from Redis", "hotel_id", hotelID,
+		"cached_version", cached.Version.Version, "version", version.Version,
+		"cached_rooms", len(cached.Rooms), "rooms", len(rooms))
+	return sampleDivergent
+}
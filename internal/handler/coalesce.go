@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"room-mapping-cache/internal/faults"
+	"room-mapping-cache/internal/metrics"
+	"room-mapping-cache/internal/redis"
+)
+
+// coalescer merges the hash reads of single-hotel lookups arriving within a
+// short window into one pipelined HGetAllMulti, trading up to window of
+// latency for fewer round trips under bursty per-hotel traffic.
+type coalescer struct {
+	client  redis.HashReader
+	window  time.Duration
+	maxKeys int
+
+	mu      sync.Mutex
+	pending []*coalescedRead
+	keys    int
+	timer   *time.Timer
+}
+
+type coalescedRead struct {
+	keys []string
+	done chan coalescedResult
+}
+
+type coalescedResult struct {
+	cmds []*redis.HashResult
+	err  error
+}
+
+// readHashes reads the hashes of a single-hotel lookup, through the coalescer
+// when enabled. Fault-injected requests keep their own round trip.
+func (h *RoomHandler) readHashes(ctx context.Context, keys []string) ([]*redis.HashResult, error) {
+	if h.coalescer == nil || faults.Active(ctx) {
+		return h.redisClient.HGetAllMulti(ctx, keys)
+	}
+	return h.coalescer.HGetAllMulti(ctx, keys)
+}
+
+func newCoalescer(client redis.HashReader, window time.Duration, maxKeys int) *coalescer {
+	return &coalescer{client: client, window: window, maxKeys: maxKeys}
+}
+
+// HGetAllMulti queues keys for the next flush and waits for their commands,
+// aligned with keys like redis.Client.HGetAllMulti
+func (c *coalescer) HGetAllMulti(ctx context.Context, keys []string) ([]*redis.HashResult, error) {
+	read := &coalescedRead{keys: keys, done: make(chan coalescedResult, 1)}
+
+	c.mu.Lock()
+	c.pending = append(c.pending, read)
+	c.keys += len(keys)
+	switch {
+	case c.keys >= c.maxKeys:
+		if c.timer != nil {
+			c.timer.Stop()
+		}
+		go c.flush(c.take())
+	case c.timer == nil:
+		c.timer = time.AfterFunc(c.window, func() {
+			c.mu.Lock()
+			reads := c.take()
+			c.mu.Unlock()
+			c.flush(reads)
+		})
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-read.done:
+		return res.cmds, res.err
+	}
+}
+
+// take empties the queue; c.mu must be held
+func (c *coalescer) take() []*coalescedRead {
+	reads := c.pending
+	c.pending, c.keys, c.timer = nil, 0, nil
+	return reads
+}
+
+// flush reads every queued key in one call, detached from the waiters'
+// contexts since none of them owns it
+func (c *coalescer) flush(reads []*coalescedRead) {
+	if len(reads) == 0 {
+		return
+	}
+	var keys []string
+	for _, read := range reads {
+		keys = append(keys, read.keys...)
+	}
+	metrics.CoalescedReads.Observe(float64(len(reads)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cmds, err := c.client.HGetAllMulti(ctx, keys)
+	offset := 0
+	for _, read := range reads {
+		res := coalescedResult{err: err}
+		if cmds != nil {
+			res.cmds = cmds[offset : offset+len(read.keys)]
+		}
+		offset += len(read.keys)
+		read.done <- res
+	}
+}
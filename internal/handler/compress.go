@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"room-mapping-cache/internal/metrics"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Content encodings the service can produce
+const (
+	encodingIdentity = "identity"
+	encodingZstd     = "zstd"
+	encodingBrotli   = "br"
+	encodingGzip     = "gzip"
+)
+
+// allEncodings indexes the per-encoding slots of renderedBodies
+var allEncodings = [...]string{encodingZstd, encodingBrotli, encodingGzip}
+
+var (
+	// compressionPrefs lists the enabled encodings, most preferred first;
+	// the server's order breaks ties between equal client q-values
+	compressionPrefs = allEncodings[:]
+	// compressionMinBytes is the smallest body worth compressing
+	compressionMinBytes = 0
+	// gzipLevel is the level of new gzip writers
+	gzipLevel = gzip.BestSpeed
+)
+
+// SetCompression enables encodings in order of preference and sets the
+// smallest body that is compressed and the gzip level. Unknown encodings are
+// ignored.
+func SetCompression(encodings []string, minBytes, level int) {
+	prefs := make([]string, 0, len(encodings))
+	for _, enc := range encodings {
+		if encodingIndex(enc) >= 0 {
+			prefs = append(prefs, enc)
+		}
+	}
+	compressionPrefs = prefs
+	compressionMinBytes = minBytes
+	gzipLevel = level
+}
+
+// compressor is the API shared by the gzip, zstd and brotli writers
+type compressor interface {
+	io.WriteCloser
+	Reset(io.Writer)
+}
+
+var (
+	gzipPool = sync.Pool{
+		New: func() any {
+			metrics.GzipWriters.WithLabelValues("created").Inc()
+			// BestSpeed, the default, is usually the right tradeoff for
+			// 1000 rps services
+			w, _ := gzip.NewWriterLevel(io.Discard, gzipLevel)
+			return w
+		},
+	}
+	zstdPool = sync.Pool{
+		New: func() any {
+			// Synchronous encoders: concurrency comes from requests, not goroutines
+			w, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest), zstd.WithEncoderConcurrency(1))
+			return w
+		},
+	}
+	brotliPool = sync.Pool{
+		New: func() any {
+			return brotli.NewWriterLevel(io.Discard, 4)
+		},
+	}
+)
+
+// getGzipWriter takes a writer from the pool; callers reset and return it
+func getGzipWriter() *gzip.Writer {
+	metrics.GzipWriters.WithLabelValues("acquired").Inc()
+	return gzipPool.Get().(*gzip.Writer)
+}
+
+// getCompressor takes an encoder for enc from its pool, reset to write to w
+func getCompressor(enc string, w io.Writer) compressor {
+	var cw compressor
+	switch enc {
+	case encodingZstd:
+		cw = zstdPool.Get().(*zstd.Encoder)
+	case encodingBrotli:
+		cw = brotliPool.Get().(*brotli.Writer)
+	default:
+		cw = getGzipWriter()
+	}
+	cw.Reset(w)
+	return cw
+}
+
+func putCompressor(enc string, cw compressor) {
+	switch enc {
+	case encodingZstd:
+		zstdPool.Put(cw)
+	case encodingBrotli:
+		brotliPool.Put(cw)
+	default:
+		gzipPool.Put(cw)
+	}
+}
+
+// compressBody returns body compressed with enc
+func compressBody(enc string, body []byte) []byte {
+	var buf bytes.Buffer
+	cw := getCompressor(enc, &buf)
+	_, _ = cw.Write(body)
+	_ = cw.Close()
+	putCompressor(enc, cw)
+	return buf.Bytes()
+}
+
+func encodingIndex(enc string) int {
+	for i, e := range allEncodings {
+		if e == enc {
+			return i
+		}
+	}
+	return -1
+}
+
+// negotiateEncoding picks the encoding for a body of size bytes from an
+// Accept-Encoding header: the enabled encoding with the highest q-value,
+// falling back to identity for small bodies or when none is acceptable.
+func negotiateEncoding(acceptEncoding string, size int) string {
+	if acceptEncoding == "" || size < compressionMinBytes {
+		return encodingIdentity
+	}
+	accepted := make(map[string]float64, 4)
+	for _, item := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(item, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		if k, v, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(k) == "q" {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				q = f
+			}
+		}
+		accepted[name] = q
+	}
+
+	best, bestQ := encodingIdentity, 0.0
+	for _, enc := range compressionPrefs {
+		q, ok := accepted[enc]
+		if !ok {
+			q, ok = accepted["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best
+}
+
+// setEncodingHeaders marks a JSON response and its content encoding
+func setEncodingHeaders(c *gin.Context, enc string) {
+	c.Header("Content-Type", "application/json")
+	c.Writer.Header().Add("Vary", "Accept-Encoding")
+	if enc != encodingIdentity {
+		c.Header("Content-Encoding", enc)
+	}
+	metrics.ResponseEncodings.WithLabelValues(enc).Inc()
+}
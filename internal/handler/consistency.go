@@ -0,0 +1,257 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"room-mapping-cache/internal/errs"
+	"room-mapping-cache/internal/keys"
+	"room-mapping-cache/internal/metrics"
+	"room-mapping-cache/internal/redis"
+)
+
+// Consistency issue kinds
+const (
+	IssueUndecryptable = "undecryptable"
+	IssueMalformedJSON = "malformed_json"
+	IssueZeroID        = "zero_id"
+	IssueDuplicateID   = "duplicate_id"
+	IssueDuplicateName = "duplicate_name"
+)
+
+var issueKinds = []string{IssueUndecryptable, IssueMalformedJSON, IssueZeroID, IssueDuplicateID, IssueDuplicateName}
+
+// ConsistencyIssue is one problem found in a room hash. Rooms are the raw
+// field names involved.
+type ConsistencyIssue struct {
+	HotelID string   `json:"hotel_id"`
+	Key     string   `json:"key"`
+	Kind    string   `json:"kind"`
+	Rooms   []string `json:"rooms"`
+	Detail  string   `json:"detail,omitempty"`
+}
+
+// ConsistencyReport summarizes one consistency check. IssueCounts covers every
+// issue found; Issues lists at most ConsistencyMaxIssues of them.
+type ConsistencyReport struct {
+	StartedAt        time.Time          `json:"started_at"`
+	FinishedAt       time.Time          `json:"finished_at"`
+	HotelsScanned    int                `json:"hotels_scanned"`
+	RoomsScanned     int                `json:"rooms_scanned"`
+	HotelsWithIssues int                `json:"hotels_with_issues"`
+	IssueCounts      map[string]int     `json:"issue_counts"`
+	Issues           []ConsistencyIssue `json:"issues"`
+	IssuesTruncated  bool               `json:"issues_truncated,omitempty"`
+	Error            string             `json:"error,omitempty"`
+	ErrorKind        errs.Kind          `json:"error_kind,omitempty"`
+}
+
+// ConsistencyChecker scans every room hash for data that readers would skip
+// or serve ambiguously, and keeps the latest report in Redis so any replica
+// can serve it.
+type ConsistencyChecker struct {
+	redisClient ConsistencyStore
+	maxIssues   int
+	interval    time.Duration
+
+	running    sync.Mutex
+	mu         sync.RWMutex
+	lastReport *ConsistencyReport
+}
+
+// ConsistencyStore is the part of the store ConsistencyChecker scans and
+// keeps its report in
+type ConsistencyStore interface {
+	redis.KeyValue
+	redis.HashReader
+	redis.Scanner
+}
+
+// NewConsistencyChecker creates the checker. interval 0 leaves it on-demand.
+func NewConsistencyChecker(redisClient ConsistencyStore, interval time.Duration, maxIssues int) *ConsistencyChecker {
+	return &ConsistencyChecker{
+		redisClient: redisClient,
+		maxIssues:   maxIssues,
+		interval:    interval,
+	}
+}
+
+// Run checks on the configured interval until ctx is cancelled
+func (j *ConsistencyChecker) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report := j.Check(ctx)
+			slog.Info("Consistency check finished", "hotels_scanned", report.HotelsScanned,
+				"hotels_with_issues", report.HotelsWithIssues, "issues", report.IssueCounts)
+		}
+	}
+}
+
+// LastReport returns the latest stored report, falling back to this replica's
+// own last run while Redis is unreadable. Nil if no check has run.
+func (j *ConsistencyChecker) LastReport(ctx context.Context) *ConsistencyReport {
+	raw, err := j.redisClient.Get(ctx, keys.ConsistencyReport())
+	if err == nil {
+		var report ConsistencyReport
+		if err := json.Unmarshal([]byte(raw), &report); err == nil {
+			return &report
+		}
+	} else if !errors.Is(err, redis.Nil) {
+		slog.WarnContext(ctx, "Failed to read consistency report", "error", err)
+	}
+
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.lastReport
+}
+
+// Check performs a single pass over all room hashes and stores the report
+func (j *ConsistencyChecker) Check(ctx context.Context) *ConsistencyReport {
+	report := &ConsistencyReport{
+		StartedAt:   time.Now(),
+		IssueCounts: make(map[string]int, len(issueKinds)),
+		Issues:      []ConsistencyIssue{},
+	}
+	if !j.running.TryLock() {
+		report.FinishedAt = report.StartedAt
+		report.Error = "skipped: a consistency check is already running"
+		report.ErrorKind = errs.Overloaded
+		return report
+	}
+	defer j.running.Unlock()
+	defer j.finish(ctx, report)
+
+	cursor := ""
+	for {
+		found, next, err := j.redisClient.ScanKeys(ctx, cursor, keys.RoomScanPattern(), 500)
+		if err != nil {
+			slog.Error("Consistency scan failed", "error", err)
+			report.Error = err.Error()
+			report.ErrorKind = errs.KindOf(err)
+			return report
+		}
+
+		live := found[:0]
+		for _, key := range found {
+			if !keys.IsSnapshot(key) {
+				live = append(live, key)
+			}
+		}
+		cmds, _ := j.redisClient.HGetAllMulti(ctx, live)
+		for i, key := range live {
+			hashData, err := cmds[i].Result()
+			if err != nil {
+				slog.Error("Consistency check failed to read hash", "key", key, "error", err)
+				continue
+			}
+			report.HotelsScanned++
+			report.RoomsScanned += len(hashData)
+			j.addIssues(report, checkRoomHash(key, hashData))
+		}
+
+		if next == "" {
+			return report
+		}
+		cursor = next
+	}
+}
+
+// finish records the report locally, in Redis and in the issue gauges
+func (j *ConsistencyChecker) finish(ctx context.Context, report *ConsistencyReport) {
+	report.FinishedAt = time.Now()
+	j.mu.Lock()
+	j.lastReport = report
+	j.mu.Unlock()
+
+	if report.Error == "" {
+		for _, kind := range issueKinds {
+			metrics.ConsistencyIssues.WithLabelValues(kind).Set(float64(report.IssueCounts[kind]))
+		}
+	}
+	raw, err := json.Marshal(report)
+	if err != nil {
+		return
+	}
+	storeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := j.redisClient.Set(storeCtx, keys.ConsistencyReport(), string(raw), 0); err != nil {
+		slog.Error("Failed to store consistency report", "error", err)
+	}
+}
+
+func (j *ConsistencyChecker) addIssues(report *ConsistencyReport, issues []ConsistencyIssue) {
+	if len(issues) == 0 {
+		return
+	}
+	report.HotelsWithIssues++
+	for _, issue := range issues {
+		report.IssueCounts[issue.Kind]++
+		if len(report.Issues) < j.maxIssues {
+			report.Issues = append(report.Issues, issue)
+		} else {
+			report.IssuesTruncated = true
+		}
+	}
+}
+
+// checkRoomHash finds the rooms of one hash that parseRooms would drop, and
+// IDs or normalized names that more than one room claims
+func checkRoomHash(key string, hashData map[string]string) []ConsistencyIssue {
+	hotelID, _ := keys.HotelID(key)
+	var issues []ConsistencyIssue
+	add := func(kind, detail string, rooms ...string) {
+		sort.Strings(rooms)
+		issues = append(issues, ConsistencyIssue{HotelID: hotelID, Key: key, Kind: kind, Rooms: rooms, Detail: detail})
+	}
+
+	byID := make(map[int64][]string)
+	byName := make(map[string][]string)
+	for name, raw := range hashData {
+		plain, err := valueKeyring.Decrypt(raw, valueAAD(hotelID, name))
+		if err != nil {
+			add(IssueUndecryptable, err.Error(), name)
+			continue
+		}
+		id, err := roomID(plain)
+		if err != nil {
+			add(IssueMalformedJSON, err.Error(), name)
+			continue
+		}
+		if id == 0 {
+			add(IssueZeroID, "", name)
+			continue
+		}
+		byID[id] = append(byID[id], name)
+		normalized := normalizeRoomName(name)
+		byName[normalized] = append(byName[normalized], name)
+	}
+
+	for _, names := range byID {
+		if len(names) > 1 {
+			add(IssueDuplicateID, "", names...)
+		}
+	}
+	for normalized, names := range byName {
+		if len(names) > 1 {
+			add(IssueDuplicateName, normalized, names...)
+		}
+	}
+	sort.Slice(issues, func(a, b int) bool {
+		if issues[a].Kind != issues[b].Kind {
+			return issues[a].Kind < issues[b].Kind
+		}
+		return issues[a].Rooms[0] < issues[b].Rooms[0]
+	})
+	return issues
+}
@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"room-mapping-cache/internal/errs"
+	"room-mapping-cache/internal/hotelid"
+	"room-mapping-cache/internal/keys"
+	"room-mapping-cache/internal/metrics"
+	"room-mapping-cache/internal/redis"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// deadLetterQueueSize bounds entries waiting to be written; more are dropped
+	deadLetterQueueSize = 1024
+	// deadLetterRawLimit caps the stored raw value
+	deadLetterRawLimit = 4096
+	// deadLetterSeenLimit bounds the set of entries already recorded by this
+	// replica, which is cleared when full
+	deadLetterSeenLimit = 10000
+)
+
+// DeadLetter is a room entry that readers skipped, with the value as stored
+// so the upstream record can be found and fixed
+type DeadLetter struct {
+	HotelID string `json:"hotel_id"`
+	Room    string `json:"room"`
+	// Reason is one of the undecryptable, malformed_json or zero_id issue kinds
+	Reason string `json:"reason"`
+	Error  string `json:"error,omitempty"`
+	// Raw is the value as stored, so still encrypted, cut at 4 KiB. Listing
+	// shows it decrypted when it was not cut and decrypts with the keys now
+	// configured.
+	Raw          string    `json:"raw"`
+	RawTruncated bool      `json:"raw_truncated,omitempty"`
+	RecordedAt   time.Time `json:"recorded_at"`
+}
+
+// deadLetters records skipped room entries in Redis off the request path. A
+// nil *deadLetters records nothing.
+type deadLetters struct {
+	redisClient deadLetterStore
+	maxPerHotel int
+	ttl         time.Duration
+	queue       chan DeadLetter
+
+	mu   sync.Mutex
+	seen map[uint64]struct{}
+}
+
+// deadLetterStore is the part of the store dead letters are kept in
+type deadLetterStore interface {
+	redis.HashReader
+	redis.HashWriter
+	redis.Scanner
+}
+
+func newDeadLetters(redisClient deadLetterStore, maxPerHotel int, ttl time.Duration) *deadLetters {
+	d := &deadLetters{
+		redisClient: redisClient,
+		maxPerHotel: maxPerHotel,
+		ttl:         ttl,
+		queue:       make(chan DeadLetter, deadLetterQueueSize),
+		seen:        make(map[uint64]struct{}),
+	}
+	go d.run()
+	return d
+}
+
+// record queues a skipped entry. The same hotel, room and value are queued
+// once per replica, and entries are dropped if the writer falls behind.
+func (d *deadLetters) record(hotelID, room, stored, reason string, err error) {
+	if d == nil || hotelID == "" {
+		return
+	}
+	sum := fnv.New64a()
+	for _, s := range []string{hotelID, room, stored} {
+		sum.Write([]byte(s))
+		sum.Write([]byte{0})
+	}
+	d.mu.Lock()
+	_, dup := d.seen[sum.Sum64()]
+	if !dup {
+		if len(d.seen) >= deadLetterSeenLimit {
+			clear(d.seen)
+		}
+		d.seen[sum.Sum64()] = struct{}{}
+	}
+	d.mu.Unlock()
+	if dup {
+		return
+	}
+
+	entry := DeadLetter{HotelID: hotelID, Room: room, Reason: reason, Raw: stored, RecordedAt: time.Now().UTC()}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if len(entry.Raw) > deadLetterRawLimit {
+		entry.Raw, entry.RawTruncated = entry.Raw[:deadLetterRawLimit], true
+	}
+	select {
+	case d.queue <- entry:
+	default:
+		metrics.DeadLetters.WithLabelValues("dropped").Inc()
+	}
+}
+
+func (d *deadLetters) run() {
+	for entry := range d.queue {
+		data, _ := json.Marshal(entry)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		stored, err := d.redisClient.HSetCapped(ctx, keys.DeadLetters(entry.HotelID), entry.Room, string(data), d.maxPerHotel, d.ttl)
+		cancel()
+		switch {
+		case err != nil:
+			metrics.DeadLetters.WithLabelValues("error").Inc()
+			slog.Error("Failed to record dead letter", "hotel_id", entry.HotelID, "room", entry.Room, "error", err)
+		case !stored:
+			metrics.DeadLetters.WithLabelValues("full").Inc()
+		default:
+			metrics.DeadLetters.WithLabelValues("recorded").Inc()
+		}
+	}
+}
+
+// DeadLetters serves GET /admin/dead-letters. With ?hotel_id= it lists that
+// hotel's entries; otherwise it scans for up to ?limit= entries (default 100)
+// across hotels. Entries are newest first.
+func (h *AdminHandler) DeadLetters(c *gin.Context) {
+	ctx := c.Request.Context()
+	limit := 100
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > 10000 {
+			respondError(c, &errs.Error{Kind: errs.Invalid, Field: "limit", Msg: "limit must be between 1 and 10000"})
+			return
+		}
+		limit = n
+	}
+
+	var hashes []map[string]string
+	if raw := c.Query("hotel_id"); raw != "" {
+		hotelID, err := hotelid.Canonical(raw)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		hashData, err := h.redisClient.HGetAll(ctx, keys.DeadLetters(hotelID))
+		if err != nil {
+			respondError(c, errs.Classify("failed to read dead letters", err))
+			return
+		}
+		hashes = append(hashes, hashData)
+	} else {
+		found, total := []string{}, 0
+		cursor := ""
+		for total < limit {
+			page, next, err := h.redisClient.ScanKeys(ctx, cursor, keys.DeadLetterScanPattern(), 500)
+			if err != nil {
+				respondError(c, errs.Classify("failed to list dead letters", err))
+				return
+			}
+			found = append(found, page...)
+			total += len(page)
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+		cmds, err := h.redisClient.HGetAllMulti(ctx, found)
+		if err != nil && len(cmds) == 0 {
+			respondError(c, errs.Classify("failed to read dead letters", err))
+			return
+		}
+		for _, cmd := range cmds {
+			if cmd != nil {
+				hashes = append(hashes, cmd.Val())
+			}
+		}
+	}
+
+	entries := make([]DeadLetter, 0)
+	for _, hashData := range hashes {
+		for _, data := range hashData {
+			var entry DeadLetter
+			if err := json.Unmarshal([]byte(data), &entry); err != nil {
+				continue
+			}
+			// Show the plaintext when it can be decrypted now, e.g. after a key was added
+			if plain, err := valueKeyring.Decrypt(entry.Raw, valueAAD(entry.HotelID, entry.Room)); err == nil && !entry.RawTruncated {
+				entry.Raw = plain
+			}
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].RecordedAt.After(entries[j].RecordedAt) })
+	truncated := len(entries) > limit
+	if truncated {
+		entries = entries[:limit]
+	}
+	c.JSON(http.StatusOK, gin.H{"dead_letters": entries, "count": len(entries), "truncated": truncated})
+}
@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"room-mapping-cache/internal/errs"
+	"room-mapping-cache/internal/keys"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RoomRename struct {
+	ID   int64  `json:"id"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type RoomIDChange struct {
+	Name   string `json:"name"`
+	FromID int64  `json:"from_id"`
+	ToID   int64  `json:"to_id"`
+}
+
+type RoomMappingsDiffResponse struct {
+	From      string         `json:"from"`
+	To        string         `json:"to"`
+	Added     []Room         `json:"added"`
+	Removed   []Room         `json:"removed"`
+	Renamed   []RoomRename   `json:"renamed"`
+	IDChanged []RoomIDChange `json:"id_changed"`
+}
+
+// GetRoomMappingsDiff compares two views of a hotel's mapping. Each side of
+// ?from=&to= is a version number, "current" (the live hash), or
+// "supplier:<name>" (the live hash restricted to one supplier's rooms).
+func (h *RoomHandler) GetRoomMappingsDiff(c *gin.Context) {
+	from, to := c.Query("from"), c.DefaultQuery("to", "current")
+	if c.Param("hotel_id") == "" || from == "" {
+		respondError(c, errs.New(errs.Invalid, "hotel_id and from are required"))
+		return
+	}
+	hotelID, err := h.hotelParam(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	fromRooms, err := h.loadDiffSide(ctx, hotelID, "from", from)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	toRooms, err := h.loadDiffSide(ctx, hotelID, "to", to)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	response := diffRooms(fromRooms, toRooms)
+	response.From, response.To = from, to
+	c.JSON(http.StatusOK, response)
+}
+
+// loadDiffSide reads the rooms of one side of a diff, given by the query
+// parameter name. Only a malformed side is the caller's error; a version
+// that isn't retained is not found and Redis failures keep their kind.
+func (h *RoomHandler) loadDiffSide(ctx context.Context, hotelID, name, side string) ([]Room, error) {
+	if side == "current" {
+		res, err := h.fetchRoomsForHotel(ctx, hotelID)
+		if err != nil {
+			return nil, errs.Classify(name+": failed to fetch room mappings", err)
+		}
+		return res.rooms, nil
+	}
+
+	if supplier, ok := strings.CutPrefix(side, "supplier:"); ok {
+		hashData, err := h.redisClient.HGetAll(ctx, keys.Room(hotelID))
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to fetch room mappings", "hotel_id", hotelID, "error", err)
+			return nil, errs.Classify(name+": failed to fetch room mappings", err)
+		}
+		rooms, _ := h.parseRooms(hotelID, filterBySupplier(hotelID, hashData, strings.ToLower(supplier)))
+		return rooms, nil
+	}
+
+	version, err := strconv.ParseInt(side, 10, 64)
+	if err != nil || version <= 0 {
+		return nil, &errs.Error{Kind: errs.Invalid, Field: name,
+			Msg: name + `: expected a version number, "current" or "supplier:<name>"`}
+	}
+	hashData, err := h.redisClient.HGetAll(ctx, keys.Snapshot(hotelID, version))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to fetch snapshot", "hotel_id", hotelID, "version", version, "error", err)
+		return nil, errs.Classify(fmt.Sprintf("%s: failed to fetch version %d", name, version), err)
+	}
+	if len(hashData) == 0 {
+		return nil, errs.New(errs.NotFound, fmt.Sprintf("%s: version %d not found or no longer retained", name, version))
+	}
+	rooms, _ := h.parseRooms(hotelID, hashData)
+	return rooms, nil
+}
+
+// filterBySupplier keeps only the hash entries written for the given supplier
+func filterBySupplier(hotelID string, hashData map[string]string, supplier string) map[string]string {
+	out := make(map[string]string)
+	for name, raw := range hashData {
+		plain, err := valueKeyring.Decrypt(raw, valueAAD(hotelID, name))
+		if err != nil {
+			continue
+		}
+		var rv struct {
+			Supplier string `json:"supplier"`
+		}
+		if json.Unmarshal([]byte(plain), &rv) == nil && rv.Supplier == supplier {
+			out[name] = raw
+		}
+	}
+	return out
+}
+
+// diffRooms matches rooms by name first, then pairs the leftovers by ID to
+// detect renames.
+func diffRooms(from, to []Room) RoomMappingsDiffResponse {
+	diff := RoomMappingsDiffResponse{
+		Added:     []Room{},
+		Removed:   []Room{},
+		Renamed:   []RoomRename{},
+		IDChanged: []RoomIDChange{},
+	}
+
+	fromByName := make(map[string]int64, len(from))
+	for _, r := range from {
+		fromByName[r.Name] = r.ID
+	}
+	toByName := make(map[string]int64, len(to))
+	for _, r := range to {
+		toByName[r.Name] = r.ID
+	}
+
+	// Rooms present only on one side, keyed by ID for rename detection
+	removedByID := make(map[int64]string)
+	for _, r := range from {
+		toID, ok := toByName[r.Name]
+		switch {
+		case !ok:
+			removedByID[r.ID] = r.Name
+		case toID != r.ID:
+			diff.IDChanged = append(diff.IDChanged, RoomIDChange{Name: r.Name, FromID: r.ID, ToID: toID})
+		}
+	}
+
+	for _, r := range to {
+		if _, ok := fromByName[r.Name]; ok {
+			continue
+		}
+		if oldName, ok := removedByID[r.ID]; ok {
+			diff.Renamed = append(diff.Renamed, RoomRename{ID: r.ID, From: oldName, To: r.Name})
+			delete(removedByID, r.ID)
+			continue
+		}
+		diff.Added = append(diff.Added, r)
+	}
+
+	for _, r := range from {
+		if name, ok := removedByID[r.ID]; ok && name == r.Name {
+			diff.Removed = append(diff.Removed, r)
+		}
+	}
+
+	sort.Slice(diff.Renamed, func(i, j int) bool { return diff.Renamed[i].From < diff.Renamed[j].From })
+	return diff
+}
@@ -0,0 +1,265 @@
+package handler
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+// Buffers above this size aren't pooled so one huge batch doesn't pin memory
+const maxPooledBuffer = 1 << 20
+
+var encodeBufPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, 0, 64<<10)
+		return &b
+	},
+}
+
+func getEncodeBuffer() *[]byte {
+	return encodeBufPool.Get().(*[]byte)
+}
+
+func putEncodeBuffer(b *[]byte) {
+	if cap(*b) > maxPooledBuffer {
+		return
+	}
+	*b = (*b)[:0]
+	encodeBufPool.Put(b)
+}
+
+// appendResponseJSON appends v as JSON followed by a newline, byte for byte
+// what json.Encoder writes. Room responses take a hand-rolled path that
+// avoids reflection; anything else goes through encoding/json.
+func appendResponseJSON(dst []byte, v any) []byte {
+	switch r := v.(type) {
+	case RoomMappingsResponse:
+		dst = r.appendJSON(dst)
+	case *RoomMappingsResponse:
+		dst = r.appendJSON(dst)
+	case BatchRoomMappingsResponse:
+		dst = r.appendJSON(dst)
+	case *BatchRoomMappingsResponse:
+		dst = r.appendJSON(dst)
+	default:
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return dst
+		}
+		dst = append(dst, raw...)
+	}
+	return append(dst, '\n')
+}
+
+func (r *BatchRoomMappingsResponse) appendJSON(dst []byte) []byte {
+	ids := make([]string, 0, len(r.Hotels))
+	for id := range r.Hotels {
+		ids = append(ids, id)
+	}
+	// encoding/json sorts map keys
+	sort.Strings(ids)
+
+	dst = append(dst, '{')
+	if len(r.Hotels) > 0 {
+		dst = append(dst, `"hotels":{`...)
+		for i, id := range ids {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendJSONString(dst, id)
+			dst = append(dst, ':')
+			hotel := r.Hotels[id]
+			hotel.fields = r.fields
+			dst = hotel.appendJSON(dst)
+		}
+		dst = append(dst, "},"...)
+	}
+	if len(r.Results) > 0 {
+		dst = append(dst, `"results":[`...)
+		for i := range r.Results {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = r.Results[i].appendJSON(dst, r.fields)
+		}
+		dst = append(dst, "],"...)
+	}
+	dst = append(dst, `"partial":`...)
+	dst = strconv.AppendBool(dst, r.Partial)
+	return append(dst, '}')
+}
+
+func (r *BatchHotelResult) appendJSON(dst []byte, fields roomFields) []byte {
+	dst = append(dst, `{"hotel_id":`...)
+	dst = appendJSONString(dst, r.HotelID)
+	dst = append(dst, ',')
+	// The embedded response's fields follow hotel_id in the same object
+	mark := len(dst)
+	hotel := r.RoomMappingsResponse
+	hotel.fields = fields
+	dst = hotel.appendJSON(dst)
+	return append(dst[:mark], dst[mark+1:]...)
+}
+
+func (r *RoomMappingsResponse) appendJSON(dst []byte) []byte {
+	if r.Rooms == nil {
+		dst = append(dst, `{"rooms":null`...)
+	} else {
+		dst = append(dst, `{"rooms":[`...)
+		for i := range r.Rooms {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendRoomJSON(dst, &r.Rooms[i], r.fields)
+		}
+		dst = append(dst, ']')
+	}
+	if r.Meta != nil {
+		// Rare and small, not worth a hand-rolled encoder
+		raw, err := json.Marshal(r.Meta)
+		if err == nil {
+			dst = append(dst, `,"meta":`...)
+			dst = append(dst, raw...)
+		}
+	}
+	if len(r.Deleted) > 0 {
+		raw, err := json.Marshal(r.Deleted)
+		if err == nil {
+			dst = append(dst, `,"deleted":`...)
+			dst = append(dst, raw...)
+		}
+	}
+	if r.Version != 0 {
+		dst = append(dst, `,"version":`...)
+		dst = strconv.AppendInt(dst, r.Version, 10)
+	}
+	if r.UpdatedAt != "" {
+		dst = append(dst, `,"updated_at":`...)
+		dst = appendJSONString(dst, r.UpdatedAt)
+	}
+	if r.NotModified {
+		dst = append(dst, `,"not_modified":true`...)
+	}
+	if r.Truncated {
+		dst = append(dst, `,"truncated":true`...)
+	}
+	if r.NextCursor != "" {
+		dst = append(dst, `,"next_cursor":`...)
+		dst = appendJSONString(dst, r.NextCursor)
+	}
+	if r.Stale {
+		dst = append(dst, `,"stale":true`...)
+	}
+	if r.Exists != nil {
+		dst = append(dst, `,"exists":`...)
+		dst = strconv.AppendBool(dst, *r.Exists)
+	}
+	if r.Status != "" {
+		dst = append(dst, `,"status":`...)
+		dst = appendJSONString(dst, r.Status)
+	}
+	if r.Error != "" {
+		dst = append(dst, `,"error":`...)
+		dst = appendJSONString(dst, r.Error)
+	}
+	if r.ErrorCode != "" {
+		dst = append(dst, `,"code":`...)
+		dst = appendJSONString(dst, string(r.ErrorCode))
+	}
+	if r.ErrorKind != "" {
+		dst = append(dst, `,"kind":`...)
+		dst = appendJSONString(dst, string(r.ErrorKind))
+	}
+	if r.Retryable {
+		dst = append(dst, `,"retryable":true`...)
+	}
+	return append(dst, '}')
+}
+
+// appendRoomJSON appends one room with the selected fields
+func appendRoomJSON(dst []byte, room *Room, fields roomFields) []byte {
+	sep := byte('{')
+	if fields.has(roomFieldName) {
+		dst = append(dst, sep)
+		dst = append(dst, `"name":`...)
+		dst = appendJSONString(dst, room.Name)
+		sep = ','
+	}
+	if fields.has(roomFieldID) {
+		dst = append(dst, sep)
+		dst = append(dst, `"id":`...)
+		dst = strconv.AppendInt(dst, room.ID, 10)
+		sep = ','
+	}
+	if room.Conflict && fields.has(roomFieldConflict) {
+		dst = append(dst, sep)
+		dst = append(dst, `"conflict":true`...)
+		sep = ','
+	}
+	if room.Attributes != nil {
+		raw, err := json.Marshal(room.Attributes)
+		if err == nil {
+			dst = append(dst, sep)
+			dst = append(dst, `"attributes":`...)
+			dst = append(dst, raw...)
+			sep = ','
+		}
+	}
+	if sep == '{' {
+		dst = append(dst, '{')
+	}
+	return append(dst, '}')
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString quotes s the way encoding/json does, including its HTML
+// escaping and replacement of invalid UTF-8.
+func appendJSONString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch b {
+			case '"', '\\':
+				dst = append(dst, '\\', b)
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hexDigits[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"syscall"
+	"time"
+
+	"room-mapping-cache/internal/errs"
+	"room-mapping-cache/internal/logging"
+	"room-mapping-cache/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorResponse is the body of every error response
+type ErrorResponse = errs.Response
+
+// Retry-After seconds sent with retryable errors, normally and while the
+// health monitor reports Redis down
+var retryAfter, degradedRetryAfter = "1", "10"
+
+// SetRetryAfter sets the Retry-After of retryable errors. While Redis is down
+// degraded is sent instead, since retries before the next health check only
+// find it down again.
+func SetRetryAfter(normal, degraded time.Duration) {
+	retryAfter = strconv.Itoa(max(int(normal.Seconds()), 1))
+	degradedRetryAfter = strconv.Itoa(max(int(degraded.Seconds()), 1))
+}
+
+// respondError writes err using the status, code and retryability of its
+// Kind. While the health monitor reports Redis down, failures it cannot
+// classify are put down to the outage rather than answered with a 500.
+func respondError(c *gin.Context, err error) {
+	degraded := RedisDegraded()
+	if degraded && errs.KindOf(err) == errs.Internal && errors.Unwrap(err) != nil {
+		err = errs.Wrap(errs.Degraded, errRedisDegraded.Msg, err)
+	}
+	if kind := errs.KindOf(err); errs.Retryable(kind) && c.Writer.Header().Get("Retry-After") == "" {
+		if degraded {
+			c.Header("Retry-After", degradedRetryAfter)
+		} else {
+			c.Header("Retry-After", retryAfter)
+		}
+	}
+	logging.AbortWithError(c, err)
+}
+
+// Recovery turns panics into the internal error envelope, answers errors
+// attached with c.Error that no handler rendered, and counts error responses
+// by route and code. Stack traces only go to the log.
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if r := recover(); r != nil {
+				if r == http.ErrAbortHandler {
+					panic(r)
+				}
+				route := routeLabel(c)
+				if brokenConnection(r) {
+					slog.WarnContext(c.Request.Context(), "Client connection broken", "route", route, "error", r)
+					c.Abort()
+					return
+				}
+				metrics.Panics.WithLabelValues(route).Inc()
+				slog.ErrorContext(c.Request.Context(), "Panic serving request", "route", route, "panic", fmt.Sprint(r), "stack", string(debug.Stack()))
+				if c.Writer.Written() {
+					c.Abort()
+				} else {
+					respondError(c, errs.New(errs.Internal, "internal error"))
+				}
+				countErrorResponse(c)
+			}
+		}()
+		c.Next()
+
+		if len(c.Errors) > 0 && !c.Writer.Written() {
+			respondError(c, c.Errors.Last().Err)
+		}
+		countErrorResponse(c)
+	}
+}
+
+func countErrorResponse(c *gin.Context) {
+	if code := c.GetString(logging.ErrorCodeKey); code != "" {
+		metrics.ErrorResponses.WithLabelValues(routeLabel(c), code).Inc()
+	}
+}
+
+func routeLabel(c *gin.Context) string {
+	if route := c.FullPath(); route != "" {
+		return route
+	}
+	return "unmatched"
+}
+
+// brokenConnection reports whether a panic comes from writing to a client
+// that went away, which needs no answer
+func brokenConnection(r any) bool {
+	err, ok := r.(error)
+	if !ok {
+		return false
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && (errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET))
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"room-mapping-cache/internal/errs"
+	"room-mapping-cache/internal/faults"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FaultsSpec is the JSON form of faults.Spec
+type FaultsSpec struct {
+	// RedisLatency is a duration such as 250ms
+	RedisLatency     string  `json:"redis_latency"`
+	RedisErrorRate   float64 `json:"redis_error_rate"`
+	BatchFailureRate float64 `json:"batch_failure_rate"`
+}
+
+func faultsSpec(s faults.Spec) FaultsSpec {
+	return FaultsSpec{
+		RedisLatency:     s.RedisLatency.String(),
+		RedisErrorRate:   s.RedisErrorRate,
+		BatchFailureRate: s.BatchFailureRate,
+	}
+}
+
+// SetFaults enables the fault injection admin routes
+func (h *AdminHandler) SetFaults(f *faults.Injector) {
+	h.faults = f
+}
+
+// Faults returns the faults injected into every request
+func (h *AdminHandler) Faults(c *gin.Context) {
+	c.JSON(http.StatusOK, faultsSpec(h.faults.Global()))
+}
+
+// SetFaultsDefault replaces the faults injected into every request. Request
+// headers still override them.
+func (h *AdminHandler) SetFaultsDefault(c *gin.Context) {
+	var request FaultsSpec
+	if err := bindJSON(c, &request, h.roomHandler.cfg.MaxRequestBodyBytes); err != nil {
+		respondError(c, err)
+		return
+	}
+	spec := faults.Spec{RedisErrorRate: request.RedisErrorRate, BatchFailureRate: request.BatchFailureRate}
+	if request.RedisLatency != "" {
+		d, err := time.ParseDuration(request.RedisLatency)
+		if err != nil {
+			respondError(c, &errs.Error{Kind: errs.Invalid, Field: "redis_latency", Msg: "redis_latency must be a duration such as 250ms"})
+			return
+		}
+		spec.RedisLatency = d
+	}
+	if err := h.faults.SetGlobal(spec); err != nil {
+		respondError(c, err)
+		return
+	}
+	slog.WarnContext(c.Request.Context(), "Injected faults changed", "redis_latency", spec.RedisLatency.String(),
+		"redis_error_rate", spec.RedisErrorRate, "batch_failure_rate", spec.BatchFailureRate)
+	c.JSON(http.StatusOK, faultsSpec(spec))
+}
+
+// ClearFaults stops injecting faults into requests without fault headers
+func (h *AdminHandler) ClearFaults(c *gin.Context) {
+	_ = h.faults.SetGlobal(faults.Spec{})
+	slog.InfoContext(c.Request.Context(), "Injected faults cleared")
+	c.JSON(http.StatusOK, faultsSpec(faults.Spec{}))
+}
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"strings"
+
+	"room-mapping-cache/internal/errs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// roomFields selects the room fields a response carries; zero means all
+type roomFields uint8
+
+const (
+	roomFieldName roomFields = 1 << iota
+	roomFieldID
+	roomFieldConflict
+)
+
+var roomFieldNames = map[string]roomFields{
+	"name":     roomFieldName,
+	"id":       roomFieldID,
+	"conflict": roomFieldConflict,
+}
+
+func (f roomFields) has(field roomFields) bool {
+	return f == 0 || f&field != 0
+}
+
+// roomFieldsRequested parses ?fields=, e.g. fields=id or fields=id,name, so
+// callers that only need IDs get smaller responses. Attributes still come
+// with include=attributes.
+func roomFieldsRequested(c *gin.Context) (roomFields, error) {
+	raw := c.Query("fields")
+	if raw == "" {
+		return 0, nil
+	}
+	var fields roomFields
+	for _, name := range strings.Split(raw, ",") {
+		field, ok := roomFieldNames[strings.TrimSpace(name)]
+		if !ok {
+			return 0, &errs.Error{Kind: errs.Invalid, Field: "fields", Msg: "fields must list id, name or conflict, separated by commas"}
+		}
+		fields |= field
+	}
+	return fields, nil
+}
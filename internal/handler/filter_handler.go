@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+
+	"room-mapping-cache/internal/errs"
+	"room-mapping-cache/internal/redis"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RoomCountResponse struct {
+	Count int64 `json:"count"`
+}
+
+// FilterRoomMappings returns the rooms whose normalized name contains ?name=
+// (normalized the same way) and has every word of ?q=
+func (h *RoomHandler) FilterRoomMappings(c *gin.Context) {
+	hotelID, err := h.hotelParam(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	fields, err := roomFieldsRequested(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	pattern := strings.ToLower(strings.TrimSpace(c.Query("name")))
+
+	ctx := c.Request.Context()
+
+	if q := c.Query("q"); strings.TrimSpace(q) != "" {
+		rooms, truncated, err := h.searchRooms(ctx, hotelID, q, pattern)
+		if err != nil {
+			respondError(c, errs.Classify("failed to fetch room mappings", err))
+			return
+		}
+		writeJSON(c, RoomMappingsResponse{Rooms: rooms, Truncated: truncated, fields: fields})
+		return
+	}
+
+	rooms, truncated, err := h.matchRooms(ctx, hotelID, pattern)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to filter rooms", "hotel_id", hotelID, "error", err)
+		respondError(c, errs.Classify("failed to fetch room mappings", err))
+		return
+	}
+	writeJSON(c, RoomMappingsResponse{Rooms: rooms, Truncated: truncated, fields: fields})
+}
+
+// CountRoomMappings returns the number of rooms, optionally filtered by ?name=
+// and ?q= as for FilterRoomMappings
+func (h *RoomHandler) CountRoomMappings(c *gin.Context) {
+	hotelID, err := h.hotelParam(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	pattern := strings.ToLower(strings.TrimSpace(c.Query("name")))
+
+	ctx := c.Request.Context()
+
+	if q := c.Query("q"); strings.TrimSpace(q) != "" {
+		rooms, _, err := h.searchRooms(ctx, hotelID, q, pattern)
+		if err != nil {
+			respondError(c, errs.Classify("failed to count room mappings", err))
+			return
+		}
+		c.JSON(http.StatusOK, RoomCountResponse{Count: int64(len(rooms))})
+		return
+	}
+
+	rooms, _, err := h.matchRooms(ctx, hotelID, pattern)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to count rooms", "hotel_id", hotelID, "error", err)
+		respondError(c, errs.Classify("failed to count room mappings", err))
+		return
+	}
+	c.JSON(http.StatusOK, RoomCountResponse{Count: int64(len(rooms))})
+}
+
+// matchRooms returns the rooms whose normalized name contains the normalized
+// pattern, sorted by name, and whether the hotel had more than MaxRoomsPerHotel
+// rooms. Encrypted values can't be inspected inside Redis, so they are read
+// whole; otherwise only names and ids cross the wire.
+func (h *RoomHandler) matchRooms(ctx context.Context, hotelID, pattern string) ([]Room, bool, error) {
+	pattern = normalizeRoomName(pattern)
+
+	if valueKeyring != nil {
+		res, err := h.fetchRoomsShared(ctx, hotelID)
+		if err != nil {
+			return nil, false, err
+		}
+		rooms := make([]Room, 0, len(res.rooms))
+		for _, r := range res.rooms {
+			if strings.Contains(r.Name, pattern) {
+				rooms = append(rooms, r)
+			}
+		}
+		return rooms, res.truncated, nil
+	}
+
+	ids, truncated, err := h.readRoomIDs(ctx, hotelID)
+	if err != nil {
+		return nil, false, err
+	}
+	rooms := make([]Room, 0, len(ids))
+	for _, r := range ids {
+		if name := normalizeRoomName(r.Name); strings.Contains(name, pattern) {
+			rooms = append(rooms, Room{Name: name, ID: r.ID})
+		}
+	}
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].Name < rooms[j].Name })
+	return rooms, truncated, nil
+}
+
+// readRoomIDs reads the names and ids of the hashtagged key, falling back to
+// the plain key when the first yields nothing. The keys live in different
+// slots so they can't be read in one call.
+func (h *RoomHandler) readRoomIDs(ctx context.Context, hotelID string) ([]redis.RoomID, bool, error) {
+	var (
+		ids       []redis.RoomID
+		truncated bool
+		err       error
+	)
+	for _, key := range h.roomHashKeys(hotelID) {
+		if ids, truncated, err = h.redisClient.RoomIDs(ctx, key, h.cfg.MaxRoomsPerHotel); err != nil || len(ids) > 0 {
+			break
+		}
+	}
+	return ids, truncated, err
+}
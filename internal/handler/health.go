@@ -3,35 +3,206 @@ package handler
 import (
 	"context"
 	"net/http"
+	"sync/atomic"
 	"time"
 
+	"room-mapping-cache/internal/buildinfo"
+	"room-mapping-cache/internal/errs"
+	"room-mapping-cache/internal/limits"
 	"room-mapping-cache/internal/redis"
 
 	"github.com/gin-gonic/gin"
 )
 
-var redisClient *redis.Client
+var redisClient redis.Status
 
-func SetRedisClient(client *redis.Client) {
+func SetRedisClient(client redis.Status) {
 	redisClient = client
 }
 
+// redisDegraded is set by the background health monitor while Redis is
+// unreachable. Reads then fail fast and are served from the local cache.
+var redisDegraded atomic.Bool
+
+var errRedisDegraded = errs.New(errs.Degraded, "redis is unavailable")
+
+// SetRedisDegraded records the monitor's latest verdict and returns the previous one
+func SetRedisDegraded(degraded bool) bool {
+	return redisDegraded.Swap(degraded)
+}
+
+// RedisDegraded reports whether the service is running in degraded mode
+func RedisDegraded() bool {
+	return redisDegraded.Load()
+}
+
+// warmedUp is set once the start-up cache warm-up and self-test lookups have
+// finished; until then /ready returns 503 so new instances get no cold traffic
+var warmedUp atomic.Bool
+
+// MarkWarmedUp lets /ready report ready
+func MarkWarmedUp() {
+	warmedUp.Store(true)
+}
+
+// draining is set once shutdown begins, so load balancers stop routing here
+var draining atomic.Bool
+
+// SetDraining marks the instance as shutting down
+func SetDraining() {
+	draining.Store(true)
+}
+
+// HealthCheck is the liveness probe. It stays 200 in degraded mode so the
+// instance, and its local cache, survive a Redis outage.
 func HealthCheck(c *gin.Context) {
-	// If Redis client is set, verify Redis connectivity
-	if redisClient != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-		defer cancel()
-
-		if err := redisClient.HealthCheck(ctx); err != nil {
-			c.JSON(http.StatusServiceUnavailable, gin.H{
-				"status": "unhealthy",
-				"error":  "Redis cluster is not accessible",
-			})
-			return
-		}
+	if RedisDegraded() {
+		c.JSON(http.StatusOK, gin.H{
+			"status": "degraded",
+		})
+		return
+	}
+
+	if redisClient != nil && redisClient.FailedOver() {
+		c.JSON(http.StatusOK, gin.H{
+			"status":            "healthy",
+			"redis":             "secondary",
+			"failover_switches": redisClient.FailoverSwitches(),
+		})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"status": "healthy",
 	})
 }
+
+// Ready is the readiness probe; it returns 503 until the start-up warm-up has
+// finished, while Redis is unreachable and once shutdown has begun
+func Ready(c *gin.Context) {
+	if draining.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":    "draining",
+			"kind":      errs.Degraded,
+			"retryable": true,
+		})
+		return
+	}
+	if !warmedUp.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":    "warming_up",
+			"kind":      errs.Degraded,
+			"retryable": true,
+		})
+		return
+	}
+	if RedisDegraded() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":    "degraded",
+			"error":     "Redis is not accessible",
+			"kind":      errs.Degraded,
+			"retryable": true,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ready",
+	})
+}
+
+// startTime is when the process started, for uptime reporting
+var startTime = time.Now()
+
+// HealthDetailResponse breaks service health down by component
+type HealthDetailResponse struct {
+	Status    string             `json:"status"`
+	Degraded  bool               `json:"degraded"`
+	WarmingUp bool               `json:"warming_up,omitempty"`
+	Draining  bool               `json:"draining,omitempty"`
+	Uptime    string             `json:"uptime"`
+	Build     buildinfo.Info     `json:"build"`
+	Redis     RedisHealth        `json:"redis"`
+	Cache     CacheStatsResponse `json:"cache"`
+
+	// Maintenance is set while this instance is in a maintenance mode
+	Maintenance *limits.MaintenanceState `json:"maintenance,omitempty"`
+}
+
+// RedisHealth describes the Redis endpoint currently serving reads
+type RedisHealth struct {
+	Endpoint         string            `json:"endpoint"`
+	Mode             string            `json:"mode"`
+	LatencyMs        float64           `json:"latency_ms"`
+	Error            string            `json:"error,omitempty"`
+	Kind             errs.Kind         `json:"kind,omitempty"`
+	FailoverSwitches int64             `json:"failover_switches"`
+	Pool             *redis.PoolStats  `json:"pool"`
+	SecondaryPool    *redis.PoolStats  `json:"secondary_pool,omitempty"`
+	Cluster          map[string]string `json:"cluster,omitempty"`
+}
+
+// clusterHealthFields are the CLUSTER INFO fields worth reporting
+var clusterHealthFields = []string{"cluster_state", "cluster_slots_ok", "cluster_slots_pfail", "cluster_slots_fail", "cluster_known_nodes", "cluster_size"}
+
+// HealthDetail reports Redis round-trip latency, pool and cluster state, local
+// cache status, uptime and build, to tell pool exhaustion apart from Redis
+// being down.
+func (h *AdminHandler) HealthDetail(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	resp := HealthDetailResponse{
+		Status:    "healthy",
+		Degraded:  RedisDegraded(),
+		WarmingUp: !warmedUp.Load(),
+		Draining:  draining.Load(),
+		Uptime:    time.Since(startTime).Round(time.Second).String(),
+		Build:     buildinfo.Get(),
+		Cache:     h.roomHandler.CacheStats(),
+		Redis: RedisHealth{
+			Endpoint:         "primary",
+			Mode:             "single",
+			FailoverSwitches: h.redisClient.FailoverSwitches(),
+			Pool:             h.redisClient.PoolStats(),
+			SecondaryPool:    h.redisClient.SecondaryPoolStats(),
+		},
+	}
+	if m := h.roomHandler.maintenance; m != nil && !m.Writable() {
+		state := m.State()
+		resp.Maintenance = &state
+	}
+	if h.redisClient.FailedOver() {
+		resp.Redis.Endpoint = "secondary"
+	}
+	if h.redisClient.IsCluster() {
+		resp.Redis.Mode = "cluster"
+	}
+
+	code := http.StatusOK
+	start := time.Now()
+	err := h.redisClient.ActiveHealthCheck(ctx)
+	resp.Redis.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		resp.Redis.Kind = errs.KindOf(err)
+		resp.Redis.Error = err.Error()
+		resp.Status, code = "unhealthy", errs.HTTPStatus(resp.Redis.Kind)
+	} else if resp.Degraded {
+		resp.Status = "recovering"
+	}
+
+	if info, err := h.redisClient.ClusterInfo(ctx); err == nil && info != nil {
+		resp.Redis.Cluster = make(map[string]string, len(clusterHealthFields))
+		for _, k := range clusterHealthFields {
+			if v, ok := info[k]; ok {
+				resp.Redis.Cluster[k] = v
+			}
+		}
+	}
+
+	c.JSON(code, resp)
+}
+
+// Version reports which build is serving traffic
+func Version(c *gin.Context) {
+	c.JSON(http.StatusOK, buildinfo.Get())
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"room-mapping-cache/internal/metrics"
+)
+
+// hedge runs fn and, if it hasn't returned after delay, runs it a second time
+// concurrently, returning the first success and cancelling the other call.
+// The second call takes another pooled connection, and with replica reads in
+// cluster mode usually another node, so one slow connection or node doesn't
+// set the latency. A failure before the delay is returned as is; retries are
+// the client's job.
+func hedge[T any](ctx context.Context, delay time.Duration, fn func(context.Context) (T, error)) (T, error) {
+	if delay <= 0 {
+		return fn(ctx)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		val    T
+		err    error
+		hedged bool
+	}
+	results := make(chan result, 2)
+	run := func(hedged bool) {
+		val, err := fn(ctx)
+		results <- result{val, err, hedged}
+	}
+	go run(false)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	pending, sent := 1, false
+	for {
+		select {
+		case <-timer.C:
+			metrics.HedgedRequests.WithLabelValues("sent").Inc()
+			pending++
+			sent = true
+			go run(true)
+		case r := <-results:
+			pending--
+			if r.err == nil || !sent || pending == 0 {
+				if r.err == nil && r.hedged {
+					metrics.HedgedRequests.WithLabelValues("won").Inc()
+				}
+				return r.val, r.err
+			}
+			// One call failed; wait for the other
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"room-mapping-cache/internal/keys"
+	"room-mapping-cache/internal/redis"
+)
+
+// InvalidationChannel carries hotel IDs whose local cache entries every
+// instance must drop after a write.
+const InvalidationChannel = "room_map_invalidations"
+
+// invalidateEverywhere evicts the hotel locally and tells other instances to
+// do the same.
+func (h *RoomHandler) invalidateEverywhere(ctx context.Context, hotelID string) {
+	h.InvalidateHotel(hotelID)
+	if err := h.redisClient.Publish(ctx, InvalidationChannel, hotelID); err != nil {
+		slog.ErrorContext(ctx, "Failed to publish cache invalidation", "hotel_id", hotelID, "error", err)
+	}
+}
+
+// ListenInvalidations evicts local cache entries for hotel IDs published on
+// the invalidation channel until ctx is cancelled. go-redis re-subscribes
+// automatically after connection loss.
+func (h *RoomHandler) ListenInvalidations(ctx context.Context) {
+	if h.hotelCache == nil {
+		return
+	}
+
+	pubsub := h.redisClient.Subscribe(ctx, InvalidationChannel)
+	defer pubsub.Close()
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			h.InvalidateHotel(msg.Payload)
+		}
+	}
+}
+
+// keyspacePrefix precedes the key in keyspace notification channel names
+const keyspacePrefix = "__keyspace@"
+
+// ListenKeyspaceEvents evicts local cache entries when room hashes change
+// outside this service, using Redis keyspace notifications. The server must
+// have notify-keyspace-events enabled for generic and hash events (e.g. "Kgh"
+// plus "x" for expiries). Every event on a room key evicts, including our own
+// writes, which are already invalidated and so are harmless.
+func (h *RoomHandler) ListenKeyspaceEvents(ctx context.Context) {
+	if h.hotelCache == nil {
+		return
+	}
+
+	subs, err := h.redisClient.PSubscribeAllNodes(ctx, keyspacePrefix+"*__:"+h.redisClient.KeyPrefix()+keys.RoomScanPattern())
+	if err != nil {
+		slog.Error("Failed to subscribe to keyspace notifications", "error", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, sub := range subs {
+		wg.Add(1)
+		go func(sub redis.Subscription) {
+			defer wg.Done()
+			defer sub.Close()
+			ch := sub.Channel()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case msg, ok := <-ch:
+					if !ok {
+						return
+					}
+					h.evictForKeyspaceEvent(msg.Channel)
+				}
+			}
+		}(sub)
+	}
+	wg.Wait()
+}
+
+// evictForKeyspaceEvent maps a "__keyspace@<db>__:<key>" channel to its hotel
+func (h *RoomHandler) evictForKeyspaceEvent(channel string) {
+	_, key, ok := strings.Cut(channel, "__:")
+	key = strings.TrimPrefix(key, h.redisClient.KeyPrefix())
+	if !ok || keys.IsSnapshot(key) {
+		return
+	}
+	if hotelID, ok := keys.HotelID(key); ok {
+		h.InvalidateHotel(hotelID)
+	}
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"room-mapping-cache/internal/errs"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// ConsumeKafka applies room mapping changes from the configured Kafka topic
+// until ctx is cancelled. Messages are JSON MappingUpdates; the consumer
+// group spreads partitions across instances. Offsets are committed only
+// after a message is applied, so a failing message is retried with backoff
+// rather than skipped, except malformed ones, which never succeed.
+func (h *RoomHandler) ConsumeKafka(ctx context.Context) {
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers: h.cfg.KafkaBrokers,
+		Topic:   h.cfg.KafkaTopic,
+		GroupID: h.cfg.KafkaGroupID,
+	})
+	defer reader.Close()
+	slog.Info("Consuming room mapping updates from Kafka", "brokers", h.cfg.KafkaBrokers, "topic", h.cfg.KafkaTopic, "group", h.cfg.KafkaGroupID)
+
+	for h.maintenance.WaitWritable(ctx) == nil {
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Error("Failed to read from Kafka", "topic", h.cfg.KafkaTopic, "error", err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		for backoff := 100 * time.Millisecond; ; backoff = min(2*backoff, 30*time.Second) {
+			err := h.applyKafkaMessage(ctx, msg.Value)
+			if err == nil {
+				break
+			}
+			slog.Error("Failed to apply Kafka update", "partition", msg.Partition, "offset", msg.Offset, "error", err)
+			if errs.KindOf(err) == errs.Invalid {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+		}
+
+		if err := reader.CommitMessages(ctx, msg); err != nil && ctx.Err() == nil {
+			slog.Error("Failed to commit Kafka offset", "partition", msg.Partition, "offset", msg.Offset, "error", err)
+		}
+	}
+}
+
+func (h *RoomHandler) applyKafkaMessage(ctx context.Context, value []byte) error {
+	var u MappingUpdate
+	if err := json.Unmarshal(value, &u); err != nil {
+		return errs.Wrap(errs.Invalid, "malformed update message", err)
+	}
+	applyCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return h.ApplyUpdate(applyCtx, u)
+}
@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"room-mapping-cache/internal/errs"
+	"room-mapping-cache/internal/keys"
+)
+
+// KeyMigrationReport describes a run of the legacy key migration. In a dry
+// run the counts are what a real run would do.
+type KeyMigrationReport struct {
+	DryRun     bool       `json:"dry_run"`
+	Running    bool       `json:"running"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// LegacyKeys is the number of non-hashtagged room hashes found
+	LegacyKeys     int `json:"legacy_keys"`
+	HotelsMigrated int `json:"hotels_migrated"`
+	FieldsCopied   int `json:"fields_copied"`
+	// FieldsKept counts legacy fields dropped because the hashtagged key
+	// already had them
+	FieldsKept int       `json:"fields_kept"`
+	Failed     int       `json:"failed"`
+	Error      string    `json:"error,omitempty"`
+	ErrorKind  errs.Kind `json:"error_kind,omitempty"`
+}
+
+// KeyMigration moves rooms stored under legacy non-hashtagged keys into the
+// hashtagged keys, so the read fallback can be turned off. Runs happen in the
+// background, one at a time.
+type KeyMigration struct {
+	ctx         context.Context
+	roomHandler *RoomHandler
+
+	mu     sync.Mutex
+	report *KeyMigrationReport
+}
+
+// NewKeyMigration creates the migration. Runs stop when ctx is cancelled.
+func NewKeyMigration(ctx context.Context, roomHandler *RoomHandler) *KeyMigration {
+	return &KeyMigration{ctx: ctx, roomHandler: roomHandler}
+}
+
+// Start begins a run in the background and returns its initial report, or
+// false if a run is already in progress.
+func (m *KeyMigration) Start(dryRun bool) (KeyMigrationReport, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.report != nil && m.report.Running {
+		return *m.report, false
+	}
+	m.report = &KeyMigrationReport{DryRun: dryRun, Running: true, StartedAt: time.Now()}
+	go m.run(dryRun)
+	return *m.report, true
+}
+
+// Report returns a copy of the current or last run's report, or nil if none
+// has been started
+func (m *KeyMigration) Report() *KeyMigrationReport {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.report == nil {
+		return nil
+	}
+	report := *m.report
+	return &report
+}
+
+func (m *KeyMigration) run(dryRun bool) {
+	slog.Info("Key migration started", "dry_run", dryRun)
+	err := m.scan(dryRun)
+
+	m.mu.Lock()
+	now := time.Now()
+	report := m.report
+	report.Running = false
+	report.FinishedAt = &now
+	if err != nil {
+		report.Error = err.Error()
+		report.ErrorKind = errs.KindOf(err)
+	}
+	m.mu.Unlock()
+
+	if err != nil {
+		slog.Error("Key migration stopped", "error", err, "legacy_keys", report.LegacyKeys, "migrated", report.HotelsMigrated)
+		return
+	}
+	slog.Info("Key migration finished", "dry_run", dryRun, "legacy_keys", report.LegacyKeys,
+		"migrated", report.HotelsMigrated, "fields_copied", report.FieldsCopied,
+		"fields_kept", report.FieldsKept, "failed", report.Failed)
+}
+
+func (m *KeyMigration) scan(dryRun bool) error {
+	client := m.roomHandler.redisClient
+	cursor := ""
+	for {
+		// Reads would come from the secondary, which may lag the primary
+		if client.FailedOver() {
+			return errs.New(errs.Degraded, "stopped: reads are failed over to the secondary Redis")
+		}
+		found, next, err := client.ScanKeys(m.ctx, cursor, keys.RoomScanPattern(), 500)
+		if err != nil {
+			return err
+		}
+		for _, key := range found {
+			hotelID, ok := keys.HotelID(key)
+			if !ok || keys.IsSnapshot(key) || key != keys.RoomFallback(hotelID) {
+				continue
+			}
+			copied, kept, err := m.migrateKey(hotelID, dryRun)
+
+			m.mu.Lock()
+			m.report.LegacyKeys++
+			if err != nil {
+				m.report.Failed++
+			} else {
+				m.report.HotelsMigrated++
+				m.report.FieldsCopied += copied
+				m.report.FieldsKept += kept
+			}
+			m.mu.Unlock()
+			if err != nil {
+				slog.Error("Failed to migrate legacy room key", "hotel_id", hotelID, "error", err)
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// migrateKey merges one hotel's legacy hash into its hashtagged hash, keeping
+// the hashtagged value of fields present in both, then deletes the legacy key
+func (m *KeyMigration) migrateKey(hotelID string, dryRun bool) (copied, kept int, err error) {
+	h := m.roomHandler
+	ctx, cancel := context.WithTimeout(m.ctx, 30*time.Second)
+	defer cancel()
+
+	legacyKey, canonicalKey := keys.RoomFallback(hotelID), keys.Room(hotelID)
+	// The keys live in different slots, so they can't be read or merged atomically
+	cmds, err := h.redisClient.HGetAllMulti(ctx, []string{legacyKey, canonicalKey})
+	if err != nil {
+		return 0, 0, err
+	}
+	legacy, canonical := cmds[0].Val(), cmds[1].Val()
+	if len(legacy) == 0 {
+		return 0, 0, nil
+	}
+
+	if dryRun {
+		for field := range legacy {
+			if _, ok := canonical[field]; ok {
+				kept++
+			} else {
+				copied++
+			}
+		}
+		return copied, kept, nil
+	}
+
+	n, err := h.redisClient.HSetMissing(ctx, canonicalKey, legacy)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := h.redisClient.Del(ctx, legacyKey); err != nil {
+		return n, len(legacy) - n, err
+	}
+	h.afterWrite(ctx, hotelID)
+	return n, len(legacy) - n, nil
+}
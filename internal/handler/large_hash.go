@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+
+	"room-mapping-cache/internal/errs"
+	"room-mapping-cache/internal/keys"
+	"room-mapping-cache/internal/redis"
+)
+
+// fetchRoomsSizeAware checks both key variants with HLEN first and reads the
+// winner with HGETALL, or with a bounded HSCAN when it exceeds the threshold.
+func (h *RoomHandler) fetchRoomsSizeAware(ctx context.Context, hotelID string, names roomNamer) (fetchResult, error) {
+	hashKeys := h.roomHashKeys(hotelID)
+	lens, err := h.redisClient.HLenMulti(ctx, hashKeys)
+	if slices.Max(lens) < 0 {
+		return fetchResult{variant: keyVariantNone}, err
+	}
+
+	key, size, variant := hashKeys[0], lens[0], keyVariantHashtag
+	if size <= 0 && len(hashKeys) > 1 {
+		key, size, variant = hashKeys[1], lens[1], keyVariantPlain
+	}
+	if size <= 0 {
+		// Not found only if every key was read
+		if slices.Min(lens) < 0 {
+			return fetchResult{variant: keyVariantNone}, errRoomRead(err)
+		}
+		return fetchResult{rooms: []Room{}, variant: keyVariantNone}, nil
+	}
+
+	var (
+		hashData map[string]string
+		res      fetchResult
+	)
+	scanned := size > int64(h.cfg.LargeHashThreshold)
+	if scanned {
+		slog.WarnContext(ctx, "Oversized room hash, reading a bounded HSCAN", "hotel_id", hotelID, "fields", size, "limit", h.cfg.LargeHashScanLimit)
+		hashData, err = h.redisClient.HScanLimited(ctx, key, h.cfg.LargeHashScanLimit)
+	} else {
+		var cmds []*redis.HashResult
+		cmds, err = h.readHashes(ctx, []string{key, keys.Version(hotelID)})
+		if cmds != nil {
+			hashData, err = cmds[0].Result()
+			versionData, versionErr := cmds[1].Result()
+			res.version, res.versionRead = parseHotelVersion(versionData), versionErr == nil
+		}
+	}
+	if err != nil {
+		return fetchResult{variant: keyVariantNone}, err
+	}
+	rooms, truncated := h.parseRoomsWith(hotelID, hashData, names)
+	res.rooms, res.variant, res.truncated = rooms, variant, truncated || (scanned && size > int64(len(hashData)))
+	return res, nil
+}
+
+// errRoomRead is err, or a stand-in when a single HLEN failed without failing
+// its pipeline
+func errRoomRead(err error) error {
+	if err != nil {
+		return err
+	}
+	return errs.New(errs.Degraded, "failed to read room mappings")
+}
+
+// readOversizedHashes runs HLEN over the uncached hotels' keys and reads any
+// hash above the threshold with a bounded HSCAN, storing the result in the
+// matching command slot. The returned flags (two per hotel: primary, fallback)
+// mark keys that must not be queued for HGETALL.
+func (h *RoomHandler) readOversizedHashes(ctx context.Context, hotelIDs []string, cached []*cachedHotel, primaryCmds, fallbackCmds []*redis.HashResult) []bool {
+	oversized := make([]bool, 2*len(hotelIDs))
+	if h.cfg.LargeHashThreshold <= 0 {
+		return oversized
+	}
+
+	hashKeys := make([]string, 0, 2*len(hotelIDs))
+	slots := make([]int, 0, 2*len(hotelIDs))
+	for i, hotelID := range hotelIDs {
+		if cached[i] != nil {
+			continue
+		}
+		hashKeys = append(hashKeys, keys.Room(hotelID))
+		slots = append(slots, 2*i)
+		if h.cfg.RoomKeyFallback {
+			hashKeys = append(hashKeys, keys.RoomFallback(hotelID))
+			slots = append(slots, 2*i+1)
+		}
+	}
+
+	lens, err := h.redisClient.HLenMulti(ctx, hashKeys)
+	if err != nil {
+		slog.ErrorContext(ctx, "Redis HLEN pipeline failed", "error", err)
+	}
+	for j, n := range lens {
+		if n <= int64(h.cfg.LargeHashThreshold) {
+			continue
+		}
+		slot := slots[j]
+		hotelID := hotelIDs[slot/2]
+		slog.WarnContext(ctx, "Oversized room hash, reading a bounded HSCAN", "hotel_id", hotelID, "fields", n, "limit", h.cfg.LargeHashScanLimit)
+
+		cmd := redis.NewHashResult(h.redisClient.HScanLimited(ctx, hashKeys[j], h.cfg.LargeHashScanLimit))
+		if slot%2 == 0 {
+			primaryCmds[slot/2] = cmd
+		} else {
+			fallbackCmds[slot/2] = cmd
+		}
+		oversized[slot] = true
+	}
+	return oversized
+}
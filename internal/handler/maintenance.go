@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+
+	"room-mapping-cache/internal/limits"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaintenanceRequest switches the maintenance mode
+type MaintenanceRequest struct {
+	Mode   string `json:"mode"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// SetMaintenance makes the update consumers and upstream refresh wait while
+// m freezes mutations
+func (h *RoomHandler) SetMaintenance(m *limits.Maintenance) {
+	h.maintenance = m
+}
+
+// Maintenance returns this instance's maintenance mode
+func (h *AdminHandler) Maintenance(c *gin.Context) {
+	c.JSON(http.StatusOK, h.roomHandler.maintenance.State())
+}
+
+// SetMaintenance switches this instance to off, read_only or full. Admin
+// routes stay available in every mode so it can be switched back.
+func (h *AdminHandler) SetMaintenance(c *gin.Context) {
+	var request MaintenanceRequest
+	if err := bindJSON(c, &request, h.roomHandler.cfg.MaxRequestBodyBytes); err != nil {
+		respondError(c, err)
+		return
+	}
+	m := h.roomHandler.maintenance
+	previous := m.State().Mode
+	if err := m.Set(request.Mode, request.Reason); err != nil {
+		respondError(c, err)
+		return
+	}
+	slog.WarnContext(c.Request.Context(), "Maintenance mode changed", "from", previous, "to", request.Mode, "reason", request.Reason)
+	c.JSON(http.StatusOK, m.State())
+}
@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+
+	"room-mapping-cache/internal/errs"
+	"room-mapping-cache/internal/faults"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MatchBatchRequest struct {
+	Hotels []MatchBatchHotel `json:"hotels" binding:"required"`
+}
+
+type MatchBatchHotel struct {
+	HotelID   string   `json:"hotel_id"`
+	RoomNames []string `json:"room_names"`
+}
+
+// MatchBatchResponse lists one entry per requested hotel, in request order
+type MatchBatchResponse struct {
+	Hotels []HotelMatches `json:"hotels"`
+	// Partial is true when at least one hotel has status "error" and can be retried
+	Partial bool `json:"partial"`
+}
+
+// HotelMatches answers one hotel of a match batch. Status is one of the
+// batch hotel statuses (ok, not_found or error).
+type HotelMatches struct {
+	HotelID   string      `json:"hotel_id"`
+	Status    string      `json:"status"`
+	Matches   []NameMatch `json:"matches"`
+	Truncated bool        `json:"truncated,omitempty"`
+	Stale     bool        `json:"stale,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	ErrorCode errs.Code   `json:"code,omitempty"`
+	ErrorKind errs.Kind   `json:"kind,omitempty"`
+	Retryable bool        `json:"retryable,omitempty"`
+}
+
+// NameMatch is the rooms matching one requested name: those with the same
+// normalized name when there are any (Exact), otherwise those whose names
+// contain every word of it
+type NameMatch struct {
+	Name  string `json:"name"`
+	Exact bool   `json:"exact"`
+	Rooms []Room `json:"rooms"`
+}
+
+// MatchBatch matches supplier room names against several hotels' mappings,
+// reading every uncached hotel in one pipelined round trip
+func (h *RoomHandler) MatchBatch(c *gin.Context) {
+	var request MatchBatchRequest
+	if err := bindJSON(c, &request, h.cfg.MaxRequestBodyBytes); err != nil {
+		respondError(c, err)
+		return
+	}
+	maxHotels := h.cfg.MatchBatchMaxHotels
+	if len(request.Hotels) == 0 || len(request.Hotels) > maxHotels {
+		e := &errs.Error{Kind: errs.Invalid, Field: "hotels", Msg: fmt.Sprintf("hotels must contain 1..%d items", maxHotels)}
+		if len(request.Hotels) > maxHotels {
+			e.Code, e.Details = errs.CodeBatchTooLarge, map[string]any{"max": maxHotels}
+		}
+		respondError(c, e)
+		return
+	}
+	maxNames := h.cfg.MatchBatchMaxNames
+	// Keys are read under the tenant's IDs; the response uses the caller's
+	hotelIDs := make([]string, len(request.Hotels))
+	for i, hotel := range request.Hotels {
+		if hotel.HotelID == "" || len(hotel.RoomNames) == 0 || len(hotel.RoomNames) > maxNames {
+			respondError(c, &errs.Error{Kind: errs.Invalid, Field: "hotels",
+				Msg: fmt.Sprintf("every hotels entry needs a hotel_id and 1..%d room_names", maxNames)})
+			return
+		}
+		var err error
+		if hotelIDs[i], err = h.scopeHotelID(c, hotel.HotelID); err != nil {
+			respondError(c, err)
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+
+	// Serve what the local cache has and read the rest, both key variants
+	// of every hotel, in one pipeline
+	rooms := make(map[string]fetchResult, len(hotelIDs))
+	var pending []string
+	var hashKeys []string
+	for _, hotelID := range dedupStringsInPlace(append([]string(nil), hotelIDs...)) {
+		if !faults.Active(ctx) {
+			if hotel, ok := h.getCachedHotel(hotelID); ok {
+				rooms[hotelID] = fetchResult{rooms: hotel.Rooms, truncated: hotel.Truncated}
+				continue
+			}
+		}
+		pending = append(pending, hotelID)
+		hashKeys = append(hashKeys, h.roomHashKeys(hotelID)...)
+	}
+	failed := make(map[string]error)
+	if len(pending) > 0 {
+		cmds, execErr := h.redisClient.HGetAllMulti(ctx, hashKeys)
+		perHotel := len(hashKeys) / len(pending)
+		for i, hotelID := range pending {
+			if len(cmds) == 0 {
+				failed[hotelID] = execErr
+				continue
+			}
+			res := fetchResult{rooms: []Room{}}
+			// Only an error when no key variant could be read
+			var readErr error
+			errored := 0
+			for _, cmd := range cmds[i*perHotel : (i+1)*perHotel] {
+				hashData, err := cmd.Result()
+				if err != nil {
+					readErr = err
+					errored++
+					continue
+				}
+				if len(hashData) > 0 {
+					res.rooms, res.truncated = h.parseRooms(hotelID, hashData)
+					break
+				}
+			}
+			if errored == perHotel {
+				failed[hotelID] = readErr
+				continue
+			}
+			rooms[hotelID] = res
+		}
+	}
+
+	response := MatchBatchResponse{Hotels: make([]HotelMatches, len(request.Hotels))}
+	for i, hotel := range request.Hotels {
+		hotelID := hotelIDs[i]
+		result := HotelMatches{HotelID: hotel.HotelID, Status: HotelStatusOK}
+		res, ok := rooms[hotelID]
+		if err := failed[hotelID]; err != nil {
+			stale, found := h.getStaleHotel(hotelID)
+			if !found {
+				slog.ErrorContext(ctx, "Failed to fetch room mappings", "hotel_id", hotelID, "error", err)
+				kind := errs.KindOf(err)
+				result.Status, result.Error = HotelStatusError, "failed to fetch room mappings"
+				result.ErrorCode, result.ErrorKind, result.Retryable = errs.CodeOf(err), kind, errs.Retryable(kind)
+				result.Matches = []NameMatch{}
+				response.Hotels[i] = result
+				response.Partial = true
+				continue
+			}
+			res, ok = fetchResult{rooms: stale.Rooms, truncated: stale.Truncated}, true
+			result.Stale = true
+			markStale(c)
+		}
+		if !ok || len(res.rooms) == 0 {
+			result.Status = HotelStatusNotFound
+		}
+		result.Truncated = res.truncated
+		result.Matches = matchRoomNames(res.rooms, hotel.RoomNames)
+		response.Hotels[i] = result
+	}
+	writeJSON(c, response)
+}
+
+// matchRoomNames matches each name against a hotel's rooms
+func matchRoomNames(rooms []Room, names []string) []NameMatch {
+	byName := make(map[string][]Room, len(rooms))
+	for _, r := range rooms {
+		byName[r.Name] = append(byName[r.Name], r)
+	}
+	matches := make([]NameMatch, len(names))
+	for i, name := range names {
+		normalized := normalizeRoomName(name)
+		if exact, ok := byName[normalized]; ok {
+			matches[i] = NameMatch{Name: name, Exact: true, Rooms: exact}
+			continue
+		}
+		matches[i] = NameMatch{Name: name, Rooms: []Room{}}
+		tokens := nameTokens(normalized)
+		if len(tokens) == 0 {
+			continue
+		}
+		for _, r := range rooms {
+			if hasAllTokens(r.Name, tokens) {
+				matches[i].Rooms = append(matches[i].Rooms, r)
+			}
+		}
+	}
+	return matches
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"room-mapping-cache/internal/errs"
+	"room-mapping-cache/internal/keys"
+	"room-mapping-cache/internal/redis"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HotelMeta is the optional hotel context stored in the hotel_meta:{id} hash
+type HotelMeta struct {
+	Name      string   `json:"name,omitempty"`
+	Chain     string   `json:"chain,omitempty"`
+	City      string   `json:"city,omitempty"`
+	Suppliers []string `json:"suppliers,omitempty"`
+}
+
+// GetHotelMeta returns the metadata hash for a hotel
+func (h *RoomHandler) GetHotelMeta(c *gin.Context) {
+	hotelID, err := h.hotelParam(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	hashData, err := h.redisClient.HGetAll(ctx, keys.Meta(hotelID))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to fetch metadata", "hotel_id", hotelID, "error", err)
+		respondError(c, errs.Classify("failed to fetch hotel metadata", err))
+		return
+	}
+	if len(hashData) == 0 {
+		respondError(c, errs.New(errs.NotFound, "hotel metadata not found"))
+		return
+	}
+
+	c.JSON(http.StatusOK, parseHotelMeta(hashData))
+}
+
+// PutHotelMeta replaces the metadata hash for a hotel
+func (h *RoomHandler) PutHotelMeta(c *gin.Context) {
+	hotelID, err := h.hotelParam(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	var meta HotelMeta
+	if err := bindJSON(c, &meta, h.cfg.MaxRequestBodyBytes); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Echo what is stored, not what was sent
+	meta.Name = strings.TrimSpace(meta.Name)
+	meta.Chain = strings.TrimSpace(meta.Chain)
+	meta.City = strings.TrimSpace(meta.City)
+	meta.Suppliers = dedupStringsInPlace(meta.Suppliers)
+	suppliers, _ := json.Marshal(meta.Suppliers)
+	fields := map[string]interface{}{
+		"name":      meta.Name,
+		"chain":     meta.Chain,
+		"city":      meta.City,
+		"suppliers": string(suppliers),
+	}
+
+	ctx := c.Request.Context()
+
+	// Replace rather than merge so removed fields don't linger
+	if err := h.redisClient.ReplaceHash(ctx, keys.Meta(hotelID), fields, 0); err != nil {
+		slog.ErrorContext(ctx, "Failed to store metadata", "hotel_id", hotelID, "error", err)
+		respondError(c, errs.Classify("failed to store hotel metadata", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, meta)
+}
+
+// fetchHotelMeta returns nil when the hotel has no metadata
+func (h *RoomHandler) fetchHotelMeta(ctx context.Context, hotelID string) (*HotelMeta, error) {
+	hashData, err := h.redisClient.HGetAll(ctx, keys.Meta(hotelID))
+	if err != nil {
+		return nil, err
+	}
+	if len(hashData) == 0 {
+		return nil, nil
+	}
+	return parseHotelMeta(hashData), nil
+}
+
+func metaFromCmd(cmd *redis.HashResult) *HotelMeta {
+	hashData, err := cmd.Result()
+	if err != nil || len(hashData) == 0 {
+		return nil
+	}
+	return parseHotelMeta(hashData)
+}
+
+func parseHotelMeta(hashData map[string]string) *HotelMeta {
+	meta := &HotelMeta{
+		Name:  hashData["name"],
+		Chain: hashData["chain"],
+		City:  hashData["city"],
+	}
+	if raw := hashData["suppliers"]; raw != "" {
+		if err := json.Unmarshal([]byte(raw), &meta.Suppliers); err != nil {
+			slog.Error("Failed to parse hotel supplier coverage", "error", err)
+		}
+	}
+	return meta
+}
+
+// includes reports whether the comma-separated ?include= parameter contains name
+func includes(c *gin.Context, name string) bool {
+	for _, v := range strings.Split(c.Query("include"), ",") {
+		if strings.TrimSpace(v) == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"room-mapping-cache/internal/metrics"
+)
+
+// Policies for rooms whose names normalize alike but have different IDs
+const (
+	// ConflictKeepFirst keeps the room whose raw name sorts first
+	ConflictKeepFirst = "keep-first"
+	// ConflictKeepLowestID keeps the room with the lowest ID
+	ConflictKeepLowestID = "keep-lowest-id"
+	// ConflictReturnAll returns every room, marked with "conflict": true
+	ConflictReturnAll = "return-all-with-flag"
+)
+
+// recentConflictsLimit is how many conflicts /admin/cache/stats lists
+const recentConflictsLimit = 100
+
+// NameConflict is a normalized name shared by rooms with different IDs
+type NameConflict struct {
+	HotelID  string    `json:"hotel_id"`
+	Name     string    `json:"name"`
+	IDs      []int64   `json:"ids"`
+	RawNames []string  `json:"raw_names"`
+	SeenAt   time.Time `json:"seen_at"`
+}
+
+// NameConflictStats is the conflicts section of /admin/cache/stats. Conflicts
+// are counted when a hash is parsed, so hotels served from cache are not
+// counted again.
+type NameConflictStats struct {
+	Policy   string         `json:"policy"`
+	Detected int64          `json:"detected"`
+	Recent   []NameConflict `json:"recent"`
+}
+
+// conflictTracker applies the conflict policy and keeps recent conflicts
+type conflictTracker struct {
+	policy string
+
+	mu       sync.Mutex
+	detected int64
+	recent   []NameConflict
+	next     int
+}
+
+func newConflictTracker(policy string) *conflictTracker {
+	return &conflictTracker{policy: policy}
+}
+
+func (t *conflictTracker) observe(conflict NameConflict) {
+	metrics.NameConflicts.Inc()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.detected++
+	if len(t.recent) < recentConflictsLimit {
+		t.recent = append(t.recent, conflict)
+		return
+	}
+	t.recent[t.next] = conflict
+	t.next = (t.next + 1) % recentConflictsLimit
+}
+
+// Stats returns the policy, total and most recent conflicts, newest first
+func (t *conflictTracker) Stats() NameConflictStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats := NameConflictStats{Policy: t.policy, Detected: t.detected, Recent: make([]NameConflict, 0, len(t.recent))}
+	for i := len(t.recent) - 1; i >= 0; i-- {
+		stats.Recent = append(stats.Recent, t.recent[(t.next+i)%len(t.recent)])
+	}
+	return stats
+}
+
+// roomsByName sorts rooms by name, then raw name, keeping rawNames aligned
+type roomsByName struct {
+	rooms    []Room
+	rawNames []string
+}
+
+func (s roomsByName) Len() int { return len(s.rooms) }
+
+func (s roomsByName) Less(i, j int) bool {
+	if s.rooms[i].Name != s.rooms[j].Name {
+		return s.rooms[i].Name < s.rooms[j].Name
+	}
+	return s.rawNames[i] < s.rawNames[j]
+}
+
+func (s roomsByName) Swap(i, j int) {
+	s.rooms[i], s.rooms[j] = s.rooms[j], s.rooms[i]
+	s.rawNames[i], s.rawNames[j] = s.rawNames[j], s.rawNames[i]
+}
+
+// resolveNameConflicts applies the conflict policy to rooms sorted by name.
+// Runs of the same name with a single ID are left alone.
+func (h *RoomHandler) resolveNameConflicts(hotelID string, rooms []Room, rawNames []string) []Room {
+	out := rooms[:0]
+	for start := 0; start < len(rooms); {
+		end := start + 1
+		conflict := false
+		for end < len(rooms) && rooms[end].Name == rooms[start].Name {
+			conflict = conflict || rooms[end].ID != rooms[start].ID
+			end++
+		}
+		if !conflict {
+			out = append(out, rooms[start:end]...)
+			start = end
+			continue
+		}
+
+		group := NameConflict{HotelID: hotelID, Name: rooms[start].Name, RawNames: append([]string(nil), rawNames[start:end]...), SeenAt: time.Now()}
+		for _, r := range rooms[start:end] {
+			group.IDs = append(group.IDs, r.ID)
+		}
+		if hotelID != "" {
+			h.conflicts.observe(group)
+		}
+		slog.Debug("Room name conflict", "hotel_id", hotelID, "name", group.Name, "ids", group.IDs, "policy", h.conflicts.policy)
+
+		switch h.conflicts.policy {
+		case ConflictKeepFirst:
+			out = append(out, rooms[start])
+		case ConflictKeepLowestID:
+			keep := rooms[start]
+			for _, r := range rooms[start+1 : end] {
+				if r.ID < keep.ID {
+					keep = r
+				}
+			}
+			out = append(out, keep)
+		default:
+			for _, r := range rooms[start:end] {
+				r.Conflict = true
+				out = append(out, r)
+			}
+		}
+		start = end
+	}
+	return out
+}
@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// NameRulesFile is the YAML layout of the room name rules file:
+//
+//	stopwords: [room]
+//	synonyms: {dbl: double, std: standard}
+//	punctuation: ["-", ",", ".", "/", "(", ")", "&"]
+//	attributes: [{attribute: view, value: sea, pattern: '\bmar\b'}]
+//
+// Punctuation is replaced with spaces and defaults to the built-in set.
+// Synonyms and stopwords match whole words after punctuation is removed.
+// Attribute rules are described at AttributeRuleFile.
+type NameRulesFile struct {
+	Stopwords   []string            `yaml:"stopwords"`
+	Synonyms    map[string]string   `yaml:"synonyms"`
+	Punctuation []string            `yaml:"punctuation"`
+	Attributes  []AttributeRuleFile `yaml:"attributes"`
+}
+
+// nameRules is a compiled rules file
+type nameRules struct {
+	punct     *strings.Replacer
+	synonyms  map[string]string
+	stopwords map[string]bool
+	// attributes are tried before builtinAttributeRules
+	attributes []attributeRule
+	// digest identifies the file contents, so stored room lists built with
+	// other rules can be told apart
+	digest string
+}
+
+// activeNameRules is nil when no rules file is configured
+var activeNameRules atomic.Pointer[nameRules]
+
+// LoadNameRules reads and applies a rules file. On error the previous rules
+// stay in effect. Names already in the local cache keep their old form until
+// they expire.
+func LoadNameRules(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read room name rules: %w", err)
+	}
+	var file NameRulesFile
+	if err := yaml.Unmarshal(raw, &file); err != nil {
+		return fmt.Errorf("failed to parse room name rules %s: %w", path, err)
+	}
+	rules, err := compileNameRules(file)
+	if err != nil {
+		return fmt.Errorf("invalid room name rules %s: %w", path, err)
+	}
+	sum := sha256.Sum256(raw)
+	rules.digest = hex.EncodeToString(sum[:6])
+	activeNameRules.Store(rules)
+	resetAttributeCache()
+	return nil
+}
+
+func compileNameRules(file NameRulesFile) (*nameRules, error) {
+	rules := &nameRules{
+		punct:     punctReplacer,
+		synonyms:  make(map[string]string, len(file.Synonyms)),
+		stopwords: make(map[string]bool, len(file.Stopwords)),
+	}
+	if len(file.Punctuation) > 0 {
+		pairs := make([]string, 0, 2*len(file.Punctuation))
+		for _, p := range file.Punctuation {
+			if p == "" {
+				return nil, fmt.Errorf("empty punctuation entry")
+			}
+			pairs = append(pairs, p, " ")
+		}
+		rules.punct = strings.NewReplacer(pairs...)
+	}
+	// Entries are normalized like names so they match what they are compared to
+	word := func(s string) string {
+		return strings.Join(strings.Fields(rules.punct.Replace(strings.ToLower(foldIfEnabled(transliterate(s))))), " ")
+	}
+	for from, to := range file.Synonyms {
+		from, to = word(from), word(to)
+		if from == "" || strings.Contains(from, " ") {
+			return nil, fmt.Errorf("synonym %q must be a single word", from)
+		}
+		rules.synonyms[from] = to
+	}
+	for _, s := range file.Stopwords {
+		if s = word(s); s != "" {
+			rules.stopwords[s] = true
+		}
+	}
+	attributes, err := compileAttributeRules(file.Attributes)
+	if err != nil {
+		return nil, err
+	}
+	rules.attributes = attributes
+	return rules, nil
+}
+
+// apply rewrites a lowercased name word by word. A name made only of
+// stopwords is kept, since an empty name would match nothing.
+func (r *nameRules) apply(s string) string {
+	s = r.punct.Replace(s)
+	words := strings.Fields(s)
+	out := make([]string, 0, len(words))
+	for _, w := range words {
+		if to, ok := r.synonyms[w]; ok {
+			w = to
+		}
+		if w != "" && !r.stopwords[w] {
+			out = append(out, w)
+		}
+	}
+	if len(out) == 0 {
+		return strings.Join(words, " ")
+	}
+	return strings.Join(out, " ")
+}
+
+// WatchNameRules reloads the rules file when its modification time or size
+// changes, checking every interval until ctx is cancelled
+func WatchNameRules(ctx context.Context, path string, interval time.Duration) {
+	if path == "" || interval <= 0 {
+		return
+	}
+	last, _ := os.Stat(path)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(path)
+			if err != nil {
+				slog.Error("Failed to check room name rules", "path", path, "error", err)
+				continue
+			}
+			if last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+				continue
+			}
+			last = info
+			if err := LoadNameRules(path); err != nil {
+				slog.Error("Failed to reload room name rules", "error", err)
+				continue
+			}
+			slog.Info("Reloaded room name rules", "path", path, "digest", activeNameRules.Load().digest)
+		}
+	}
+}
+
+// nameNormalization identifies the current room name normalization; it is
+// empty for the built-in rules
+func nameNormalization() string {
+	var parts []string
+	if unicodeFolding {
+		parts = append(parts, "fold")
+	}
+	if transliterationID != "" {
+		parts = append(parts, "translit:"+transliterationID)
+	}
+	if rules := activeNameRules.Load(); rules != nil {
+		parts = append(parts, "rules:"+rules.digest)
+	}
+	return strings.Join(parts, ",")
+}
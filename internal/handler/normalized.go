@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"room-mapping-cache/internal/keys"
+	"room-mapping-cache/internal/metrics"
+	"room-mapping-cache/internal/redis"
+)
+
+// normalizedField is the AAD field of the normalized copy
+const normalizedField = "normalized"
+
+// normalizedRooms is the stored form of a hotel's parsed room list
+type normalizedRooms struct {
+	Rooms     []Room `json:"rooms"`
+	Variant   string `json:"variant"`
+	Truncated bool   `json:"truncated,omitempty"`
+	// Normalization identifies the room name rules the names were built with
+	Normalization string `json:"normalization,omitempty"`
+}
+
+// readNormalized returns the hotel's stored room list, if there is a usable one
+func (h *RoomHandler) readNormalized(ctx context.Context, hotelID string) (fetchResult, bool) {
+	raw, err := h.redisClient.Get(ctx, keys.Normalized(hotelID))
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			slog.WarnContext(ctx, "Failed to read normalized rooms", "hotel_id", hotelID, "error", err)
+		}
+		metrics.NormalizedReads.WithLabelValues("miss").Inc()
+		return fetchResult{}, false
+	}
+	plain, err := valueKeyring.Decrypt(raw, valueAAD(hotelID, normalizedField))
+	var stored normalizedRooms
+	if err == nil {
+		err = json.Unmarshal([]byte(plain), &stored)
+	}
+	if err == nil && stored.Normalization != nameNormalization() {
+		err = errors.New("stored with different room name normalization")
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Ignoring unreadable normalized rooms", "hotel_id", hotelID, "error", err)
+		metrics.NormalizedReads.WithLabelValues("miss").Inc()
+		return fetchResult{}, false
+	}
+	metrics.NormalizedReads.WithLabelValues("hit").Inc()
+	if stored.Rooms == nil {
+		stored.Rooms = []Room{}
+	}
+	return fetchResult{rooms: stored.Rooms, variant: stored.Variant, truncated: stored.Truncated}, true
+}
+
+// storeNormalized saves res as the hotel's room list. It expires with the room
+// hash, and after NormalizedRoomsTTL at the latest.
+func (h *RoomHandler) storeNormalized(ctx context.Context, hotelID string, res fetchResult) {
+	// Writes go to the primary, which may be the reason reads failed over
+	if h.redisClient.FailedOver() {
+		return
+	}
+	ttl := h.cfg.NormalizedRoomsTTL
+	roomKey := keys.Room(hotelID)
+	if res.variant == keyVariantPlain {
+		roomKey = keys.RoomFallback(hotelID)
+	}
+	if left, err := h.redisClient.PTTL(ctx, roomKey); err == nil && left > 0 && left < ttl {
+		ttl = left
+	}
+
+	raw, err := json.Marshal(normalizedRooms{Rooms: res.rooms, Variant: res.variant, Truncated: res.truncated, Normalization: nameNormalization()})
+	if err != nil {
+		return
+	}
+	value := string(raw)
+	if valueKeyring != nil {
+		if value, err = valueKeyring.Encrypt(value, valueAAD(hotelID, normalizedField)); err != nil {
+			slog.ErrorContext(ctx, "Failed to encrypt normalized rooms", "hotel_id", hotelID, "error", err)
+			return
+		}
+	}
+	if err := h.redisClient.Set(ctx, keys.Normalized(hotelID), value, ttl); err != nil {
+		slog.WarnContext(ctx, "Failed to store normalized rooms", "hotel_id", hotelID, "error", err)
+	}
+}
+
+// storeNormalizedAsync stores res without holding up the request that read it
+func (h *RoomHandler) storeNormalizedAsync(ctx context.Context, hotelID string, res fetchResult) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 2*time.Second)
+		defer cancel()
+		h.storeNormalized(ctx, hotelID, res)
+	}()
+}
+
+// rebuildNormalized replaces the hotel's stored room list after its hash changed
+func (h *RoomHandler) rebuildNormalized(ctx context.Context, hotelID string) {
+	res, err := h.fetchRoomsFromHash(ctx, hotelID, normalizeRoomName)
+	if err != nil {
+		// A stale copy is worse than none
+		if err := h.redisClient.Del(ctx, keys.Normalized(hotelID)); err != nil {
+			slog.ErrorContext(ctx, "Failed to drop normalized rooms", "hotel_id", hotelID, "error", err)
+		}
+		return
+	}
+	h.storeNormalized(ctx, hotelID, res)
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"room-mapping-cache/internal/errs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RawHotelResponse is what Redis holds for a hotel, per room key variant
+type RawHotelResponse struct {
+	HotelID string    `json:"hotel_id"`
+	Keys    []RawHash `json:"keys"`
+	// MaxRooms is MaxRoomsPerHotel; larger hashes are served truncated
+	MaxRooms int `json:"max_rooms"`
+}
+
+// RawHash is one room hash as stored
+type RawHash struct {
+	Key     string `json:"key"`
+	Variant string `json:"variant"`
+	Exists  bool   `json:"exists"`
+	// TTLSeconds is the remaining lifetime; -1 means the hash never expires
+	TTLSeconds int64      `json:"ttl_seconds,omitempty"`
+	Fields     []RawField `json:"fields"`
+}
+
+// RawField is one hash field, with the room it is served as or the reason it
+// is dropped
+type RawField struct {
+	Field string `json:"field"`
+	// Value is the stored value, decrypted when encryption at rest is on
+	Value string `json:"value"`
+	Name  string `json:"name,omitempty"`
+	ID    int64  `json:"id,omitempty"`
+	// Issue is the consistency issue that keeps the field from being served
+	Issue string `json:"issue,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// RawRoomMappings serves GET /admin/room-mappings/:hotel_id/raw: the hotel's
+// hash fields and values as stored, before normalization and ID extraction
+func (h *AdminHandler) RawRoomMappings(c *gin.Context) {
+	ctx := c.Request.Context()
+	hotelID := c.Param("hotel_id")
+	hashKeys := h.roomHandler.roomHashKeys(hotelID)
+	variants := []string{keyVariantHashtag, keyVariantPlain}
+
+	resp := RawHotelResponse{HotelID: hotelID, Keys: make([]RawHash, 0, len(hashKeys)), MaxRooms: h.roomHandler.cfg.MaxRoomsPerHotel}
+	found := false
+	for i, key := range hashKeys {
+		pttl, err := h.redisClient.PTTL(ctx, key)
+		if err != nil {
+			respondError(c, errs.Classify("failed to read TTL", err))
+			return
+		}
+		hash := RawHash{Key: key, Variant: variants[i], Fields: []RawField{}}
+		if pttl != -2 {
+			hashData, err := h.redisClient.HGetAll(ctx, key)
+			if err != nil {
+				respondError(c, errs.Classify("failed to read room mappings", err))
+				return
+			}
+			hash.Exists, hash.TTLSeconds = true, -1
+			if pttl >= 0 {
+				hash.TTLSeconds = int64(pttl / time.Second)
+			}
+			hash.Fields = rawFields(hotelID, hashData)
+			found = true
+		}
+		resp.Keys = append(resp.Keys, hash)
+	}
+	if !found {
+		respondError(c, &errs.Error{Kind: errs.NotFound, Code: errs.CodeHotelNotFound, Msg: "hotel has no room mappings"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// rawFields explains each field the way parseRooms reads it, sorted by field
+func rawFields(hotelID string, hashData map[string]string) []RawField {
+	fields := make([]RawField, 0, len(hashData))
+	for field, value := range hashData {
+		f := RawField{Field: field, Value: value}
+		plain, err := valueKeyring.Decrypt(value, valueAAD(hotelID, field))
+		switch {
+		case err != nil:
+			f.Issue, f.Error = IssueUndecryptable, err.Error()
+		default:
+			f.Value = plain
+			id, err := roomID(plain)
+			switch {
+			case err != nil:
+				f.Issue, f.Error = IssueMalformedJSON, err.Error()
+			case id == 0:
+				f.Issue = IssueZeroID
+			default:
+				f.Name, f.ID = normalizeRoomName(field), id
+			}
+		}
+		fields = append(fields, f)
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Field < fields[j].Field })
+	return fields
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"strconv"
+
+	"room-mapping-cache/internal/errs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NormalizeNamesHeader is the header equivalent of ?normalize=
+const NormalizeNamesHeader = "X-Normalize-Names"
+
+// roomNamer derives the served room name from a hash field name
+type roomNamer func(string) string
+
+// rawRoomName serves the field name byte for byte
+func rawRoomName(name string) string {
+	return name
+}
+
+// rawNamesRequested reports whether the caller opted out of room name
+// normalization with ?normalize=false or X-Normalize-Names: false. The query
+// parameter wins when both are given. Such requests bypass the local cache
+// and stored room lists, which hold normalized names.
+func rawNamesRequested(c *gin.Context) (bool, error) {
+	c.Writer.Header().Add("Vary", NormalizeNamesHeader)
+	raw, field := c.Query("normalize"), "normalize"
+	if raw == "" {
+		raw, field = c.GetHeader(NormalizeNamesHeader), NormalizeNamesHeader
+	}
+	if raw == "" {
+		return false, nil
+	}
+	normalize, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, &errs.Error{Kind: errs.Invalid, Field: field, Msg: field + " must be true or false"}
+	}
+	return !normalize, nil
+}
+
+// namerFor returns the room namer for a request
+func namerFor(rawNames bool) roomNamer {
+	if rawNames {
+		return rawRoomName
+	}
+	return normalizeRoomName
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"sync/atomic"
+	"time"
+
+	"room-mapping-cache/internal/metrics"
+)
+
+// hotelHeat is shared by the copies of one cache entry
+type hotelHeat struct {
+	hits       atomic.Int64
+	refreshing atomic.Bool
+}
+
+// refreshAhead counts a cache hit and, once a hot entry is within
+// RefreshAheadWindow of expiring, re-reads the hotel in the background so its
+// next lookups don't wait on Redis. Each entry is refreshed at most once; the
+// refresh replaces it.
+func (h *RoomHandler) refreshAhead(hotelID string, hotel cachedHotel, expiresAt time.Time) {
+	if hotel.heat == nil {
+		return
+	}
+	hits := hotel.heat.hits.Add(1)
+	remaining := time.Until(expiresAt)
+	if remaining > h.cfg.RefreshAheadWindow {
+		return
+	}
+	age := h.cfg.CacheTTL - remaining
+	if age <= 0 || float64(hits)/age.Seconds() < h.cfg.RefreshAheadMinRate {
+		return
+	}
+	if hotel.heat.refreshing.CompareAndSwap(false, true) {
+		metrics.RefreshAheads.Inc()
+		h.refreshInBackground(hotelID)
+	}
+}
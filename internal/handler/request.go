@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"room-mapping-cache/internal/errs"
+	"room-mapping-cache/internal/hotelid"
+	"room-mapping-cache/internal/tenant"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// bindJSON strictly decodes a request body of at most maxBytes into dst:
+// unknown fields and trailing data are rejected, and binding tags are
+// validated. Errors name the offending field where possible.
+func bindJSON(c *gin.Context, dst interface{}, maxBytes int64) error {
+	body := c.Request.Body
+	if maxBytes > 0 {
+		body = http.MaxBytesReader(c.Writer, body, maxBytes)
+	}
+	dec := json.NewDecoder(body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		return decodeError(err, maxBytes)
+	}
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		if err != nil {
+			return decodeError(err, maxBytes)
+		}
+		return errs.New(errs.Invalid, "request body must be a single JSON value")
+	}
+	if err := binding.Validator.ValidateStruct(dst); err != nil {
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) && len(ve) > 0 {
+			field := jsonFieldName(dst, ve[0].StructField())
+			msg := fmt.Sprintf("field %q is required", field)
+			if ve[0].Tag() != "required" {
+				msg = fmt.Sprintf("field %q failed %s validation", field, ve[0].Tag())
+			}
+			return &errs.Error{Kind: errs.Invalid, Field: field, Msg: msg, Err: err}
+		}
+		return errs.Wrap(errs.Invalid, "invalid request", err)
+	}
+	return nil
+}
+
+// jsonFieldName returns the JSON name of a top-level struct field of dst
+func jsonFieldName(dst interface{}, structField string) string {
+	t := reflect.TypeOf(dst)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Struct {
+		if f, ok := t.FieldByName(structField); ok {
+			if name, _, _ := strings.Cut(f.Tag.Get("json"), ","); name != "" && name != "-" {
+				return name
+			}
+		}
+	}
+	return structField
+}
+
+// decodeError maps a JSON decoding failure to a client-safe error
+func decodeError(err error, maxBytes int64) error {
+	var (
+		maxErr    *http.MaxBytesError
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &maxErr):
+		return errs.New(errs.TooLarge, fmt.Sprintf("request body exceeds %d bytes", maxBytes))
+	case errors.Is(err, io.EOF):
+		return errs.New(errs.Invalid, "request body is empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return errs.New(errs.Invalid, "request body is truncated JSON")
+	case errors.As(err, &syntaxErr):
+		return errs.New(errs.Invalid, fmt.Sprintf("malformed JSON at offset %d", syntaxErr.Offset))
+	case errors.As(err, &typeErr):
+		return &errs.Error{Kind: errs.Invalid, Field: typeErr.Field,
+			Msg: fmt.Sprintf("field %q must be %s", typeErr.Field, typeErr.Type)}
+	}
+	// encoding/json reports unknown fields only as `json: unknown field "x"`
+	var field string
+	if _, scanErr := fmt.Sscanf(err.Error(), "json: unknown field %q", &field); scanErr == nil {
+		return &errs.Error{Kind: errs.Invalid, Field: field, Msg: fmt.Sprintf("unknown field %q", field)}
+	}
+	return errs.Wrap(errs.Invalid, "invalid JSON body", err)
+}
+
+// hotelParam returns the request's :hotel_id, canonical and scoped to its
+// tenant
+func (h *RoomHandler) hotelParam(c *gin.Context) (string, error) {
+	return h.scopeHotelID(c, c.Param("hotel_id"))
+}
+
+// scopeHotelID returns the ID a hotel named by the caller is stored under
+func (h *RoomHandler) scopeHotelID(c *gin.Context, raw string) (string, error) {
+	hotelID, err := hotelid.Canonical(raw)
+	if err != nil {
+		return "", err
+	}
+	if len(h.cfg.Tenants) == 0 {
+		return hotelID, nil
+	}
+	return tenant.Scope(tenant.From(c), hotelID)
+}
+
+// queryBool parses an optional true/false query parameter
+func queryBool(c *gin.Context, name string) (bool, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, &errs.Error{Kind: errs.Invalid, Field: name, Msg: name + " must be true or false"}
+	}
+	return b, nil
+}
@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"sort"
+	"strconv"
+
+	"room-mapping-cache/internal/config"
+	"room-mapping-cache/internal/errs"
+	"room-mapping-cache/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// roomCursor parses ?cursor=, the offset of the first room to list
+func roomCursor(c *gin.Context) (int, error) {
+	raw := c.Query("cursor")
+	if raw == "" {
+		return 0, nil
+	}
+	cursor, err := strconv.Atoi(raw)
+	if err != nil || cursor < 0 {
+		return 0, &errs.Error{Kind: errs.Invalid, Field: "cursor", Msg: "cursor must be a non-negative integer"}
+	}
+	return cursor, nil
+}
+
+// limitRooms skips the rooms before cursor and applies MaxResponseBytes to a
+// single-hotel response: rooms that don't fit are cut, with NextCursor
+// pointing at the first of them, or the request is rejected
+func (h *RoomHandler) limitRooms(r *RoomMappingsResponse, cursor int) error {
+	if cursor > 0 {
+		r.Rooms = r.Rooms[min(cursor, len(r.Rooms)):]
+	}
+	maxBytes := h.cfg.MaxResponseBytes
+	if maxBytes <= 0 {
+		return nil
+	}
+
+	rooms, truncated := r.Rooms, r.Truncated
+	// Measure with the truncation fields the cut response would carry
+	r.Truncated, r.NextCursor = true, strconv.Itoa(cursor+len(rooms))
+	n := fitRooms(r, maxBytes)
+	if n == len(rooms) {
+		r.Truncated, r.NextCursor = truncated, ""
+		return nil
+	}
+	if n == 0 || h.cfg.ResponseOverLimit == config.ResponseOverLimitReject {
+		return responseTooLarge(maxBytes)
+	}
+	metrics.ResponseLimits.WithLabelValues("truncated").Inc()
+	r.Rooms, r.NextCursor = rooms[:n], strconv.Itoa(cursor+n)
+	return nil
+}
+
+// fitRooms returns how many of r's rooms fit in an encoded body of maxBytes
+func fitRooms(r *RoomMappingsResponse, maxBytes int) int {
+	buf := getEncodeBuffer()
+	defer putEncodeBuffer(buf)
+
+	rooms := r.Rooms
+	r.Rooms = []Room{}
+	*buf = appendResponseJSON((*buf)[:0], r)
+	r.Rooms = rooms
+	size := len(*buf)
+	for i := range rooms {
+		*buf = appendRoomJSON((*buf)[:0], &rooms[i], r.fields)
+		size += len(*buf)
+		if i > 0 {
+			size++ // comma
+		}
+		if size > maxBytes {
+			return i
+		}
+	}
+	return len(rooms)
+}
+
+// limitBatch applies MaxResponseBytes to a batch response. Truncation empties
+// the hotels that save the most bytes, largest first, and marks them
+// truncated with a cursor of 0 so they can be paged with single lookups.
+func (h *RoomHandler) limitBatch(r *BatchRoomMappingsResponse) error {
+	maxBytes := h.cfg.MaxResponseBytes
+	if maxBytes <= 0 {
+		return nil
+	}
+	buf := getEncodeBuffer()
+	defer putEncodeBuffer(buf)
+	size := func(v any) int {
+		*buf = appendResponseJSON((*buf)[:0], v)
+		return len(*buf)
+	}
+
+	total := size(r)
+	if total <= maxBytes {
+		return nil
+	}
+	if h.cfg.ResponseOverLimit == config.ResponseOverLimitReject {
+		return responseTooLarge(maxBytes)
+	}
+
+	// Ordered responses may repeat a hotel, so savings add up per ID
+	savings := make(map[string]int)
+	r.update(func(id string, hotel *RoomMappingsResponse) {
+		if len(hotel.Rooms) == 0 {
+			return
+		}
+		hotel.fields = r.fields
+		emptied := *hotel
+		emptyHotel(&emptied)
+		savings[id] += size(hotel) - size(&emptied)
+	})
+	ids := make([]string, 0, len(savings))
+	for id := range savings {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return savings[ids[i]] > savings[ids[j]] })
+
+	cut := make(map[string]bool)
+	for _, id := range ids {
+		if total <= maxBytes {
+			break
+		}
+		cut[id] = true
+		total -= savings[id]
+	}
+	if total > maxBytes {
+		return responseTooLarge(maxBytes)
+	}
+	metrics.ResponseLimits.WithLabelValues("truncated").Inc()
+	r.update(func(id string, hotel *RoomMappingsResponse) {
+		if cut[id] {
+			emptyHotel(hotel)
+		}
+	})
+	return nil
+}
+
+// update calls fn on every hotel entry of r and keeps its changes
+func (r *BatchRoomMappingsResponse) update(fn func(id string, hotel *RoomMappingsResponse)) {
+	for id, hotel := range r.Hotels {
+		fn(id, &hotel)
+		r.Hotels[id] = hotel
+	}
+	for i := range r.Results {
+		fn(r.Results[i].HotelID, &r.Results[i].RoomMappingsResponse)
+	}
+}
+
+// emptyHotel drops a batch hotel's rooms for the caller to page separately
+func emptyHotel(hotel *RoomMappingsResponse) {
+	hotel.Rooms, hotel.Truncated, hotel.NextCursor = []Room{}, true, "0"
+}
+
+func responseTooLarge(maxBytes int) error {
+	metrics.ResponseLimits.WithLabelValues("rejected").Inc()
+	return &errs.Error{Kind: errs.TooLarge, Code: errs.CodeResponseTooLarge,
+		Msg: "response exceeds the size limit", Details: map[string]any{"max_bytes": maxBytes}}
+}
@@ -1,53 +1,123 @@
 package handler
 
 import (
-	"compress/gzip"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"log"
-	"net/http"
-	"regexp"
+	"log/slog"
 	"sort"
-	"strings"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"room-mapping-cache/internal/analytics"
+	"room-mapping-cache/internal/cache"
+	"room-mapping-cache/internal/config"
+	"room-mapping-cache/internal/encryption"
+	"room-mapping-cache/internal/errs"
+	"room-mapping-cache/internal/faults"
+	"room-mapping-cache/internal/journal"
+	"room-mapping-cache/internal/keys"
+	"room-mapping-cache/internal/limits"
+	"room-mapping-cache/internal/logging"
 	"room-mapping-cache/internal/redis"
+	"room-mapping-cache/internal/tenant"
 
 	"github.com/gin-gonic/gin"
-	redisc "github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"golang.org/x/sync/singleflight"
 )
 
 var (
-	wsRe          = regexp.MustCompile(`\s+`)
-	punctReplacer = strings.NewReplacer(
-		"-", " ",
-		",", " ",
-		".", " ",
-		"/", " ",
-		"(", " ",
-		")", " ",
-	)
-
-	gzipPool = sync.Pool{
-		New: func() any {
-			// BestSpeed is usually the right tradeoff for 1000 rps services.
-			w, _ := gzip.NewWriterLevel(io.Discard, gzip.BestSpeed)
-			return w
-		},
-	}
+	// tracer separates response encoding from Redis time in request traces
+	tracer = otel.Tracer("room-mapping-cache/handler")
 )
 
+// valueKeyring decrypts (and on writes, encrypts) stored room values. Nil when
+// encryption at rest is disabled.
+var valueKeyring *encryption.Keyring
+
+func SetValueKeyring(k *encryption.Keyring) {
+	valueKeyring = k
+}
+
+// valueAAD names where a value of the hotel is stored, for encryption to bind
+// it to. field is the hash field, or the kind of a value with its own key.
+func valueAAD(hotelID, field string) encryption.AAD {
+	t, id := tenant.Split(hotelID)
+	return encryption.AAD{Tenant: t, HotelID: id, Field: field}
+}
+
 type RoomHandler struct {
-	redisClient *redis.Client
+	redisClient redis.RoomStore
+	cfg         *config.Config
+	journal     *journal.Journal
+	softQuota   *limits.SoftQuota
+	hotelCache  *cache.LRU[string, cachedHotel]
+	analytics   *analytics.Tracker
+	fetches     singleflight.Group
+	deadLetters *deadLetters
+	conflicts   *conflictTracker
+	maintenance *limits.Maintenance
+	coalescer   *coalescer
+	shadow      *shadowReads
+	sampler     *cacheSampler
+}
+
+// cachedHotel is what the local cache keeps per hotel. Rooms is shared between
+// requests and must be treated as read-only.
+type cachedHotel struct {
+	Rooms []Room
+	// Truncated is set when the hotel had more rooms than are served
+	Truncated bool
+	Variant   string
+	Version   hotelVersion
+	// bodies holds the rendered single-hotel response, built on first use
+	bodies *renderedBodies
+	// heat counts lookups served from this entry, for refresh-ahead
+	heat *hotelHeat
+}
+
+// renderedBodies caches the final response bytes per content encoding so
+// repeated requests for a hot hotel skip JSON encoding and compression.
+type renderedBodies struct {
+	json       renderedBody
+	compressed [len(allEncodings)]renderedBody
+}
+
+type renderedBody struct {
+	once sync.Once
+	body []byte
+}
+
+func (b *renderedBodies) get(v any, enc string) []byte {
+	i := encodingIndex(enc)
+	if i < 0 {
+		return b.plain(v)
+	}
+	r := &b.compressed[i]
+	r.once.Do(func() {
+		r.body = compressBody(enc, b.plain(v))
+	})
+	return r.body
+}
+
+func (b *renderedBodies) plain(v any) []byte {
+	b.json.once.Do(func() {
+		b.json.body = appendResponseJSON(nil, v)
+	})
+	return b.json.body
 }
 
 type Room struct {
 	Name string `json:"name"`
 	ID   int64  `json:"id"`
+	// Conflict marks rooms sharing a normalized name with a room of another ID
+	Conflict bool `json:"conflict,omitempty"`
+	// Attributes are only filled in for ?include=attributes
+	Attributes *RoomAttributes `json:"attributes,omitempty"`
 }
 
 type roomValue struct {
@@ -55,200 +125,1056 @@ type roomValue struct {
 }
 
 type RoomMappingsResponse struct {
-	Rooms []Room `json:"rooms"`
+	Rooms []Room     `json:"rooms"`
+	Meta  *HotelMeta `json:"meta,omitempty"`
+	// Deleted is only filled in for ?include=deleted
+	Deleted     []DeletedRoom `json:"deleted,omitempty"`
+	Version     int64         `json:"version,omitempty"`
+	UpdatedAt   string        `json:"updated_at,omitempty"`
+	NotModified bool          `json:"not_modified,omitempty"`
+	// Truncated is set when the hotel has more rooms than MaxRoomsPerHotel,
+	// or than fit in MaxResponseBytes; NextCursor then continues the listing
+	Truncated  bool   `json:"truncated,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	// Stale marks batch entries served from the local cache after a Redis error
+	Stale bool `json:"stale,omitempty"`
+	// Exists is only set in single lookups: false when Redis answered and the
+	// hotel has no mappings. Batch entries say so with Status.
+	Exists *bool `json:"exists,omitempty"`
+	// Status and the error fields are only set in batch responses
+	Status    string    `json:"status,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	ErrorCode errs.Code `json:"code,omitempty"`
+	ErrorKind errs.Kind `json:"kind,omitempty"`
+	Retryable bool      `json:"retryable,omitempty"`
+
+	fields roomFields
 }
 
+// Per-hotel statuses in batch responses
+const (
+	HotelStatusOK       = "ok"
+	HotelStatusNotFound = "not_found"
+	HotelStatusError    = "error"
+	// HotelStatusNotModified answers a conditional batch entry whose
+	// known_version is current; rooms are left out
+	HotelStatusNotModified = "not_modified"
+)
+
 type BatchRoomMappingsResponse struct {
-	Hotels map[string]RoomMappingsResponse `json:"hotels"`
+	Hotels map[string]RoomMappingsResponse `json:"hotels,omitempty"`
+	// Results replaces Hotels for ?ordered=true: one entry per requested ID,
+	// in request order and including duplicates
+	Results []BatchHotelResult `json:"results,omitempty"`
+	// Partial is true when at least one hotel has status "error" and can be retried
+	Partial bool `json:"partial"`
+
+	fields roomFields
 }
 
-func NewRoomHandler(redisClient *redis.Client) *RoomHandler {
-	return &RoomHandler{
+// BatchHotelResult is one entry of an ordered batch response
+type BatchHotelResult struct {
+	HotelID string `json:"hotel_id"`
+	RoomMappingsResponse
+}
+
+// orderBy moves the per-hotel answers into Results following ids
+func (r *BatchRoomMappingsResponse) orderBy(ids []string) {
+	r.Results = make([]BatchHotelResult, len(ids))
+	for i, id := range ids {
+		hotel, ok := r.Hotels[id]
+		if !ok {
+			// Empty IDs are skipped by the lookup
+			hotel = RoomMappingsResponse{Rooms: []Room{}, Status: HotelStatusNotFound}
+		}
+		r.Results[i] = BatchHotelResult{HotelID: id, RoomMappingsResponse: hotel}
+	}
+	r.Hotels = nil
+}
+
+// Key variants reported in the request journal
+const (
+	keyVariantHashtag = "hashtag"
+	keyVariantPlain   = "plain"
+	keyVariantNone    = "none"
+)
+
+// NewRoomHandler creates the room mapping handler. j may be nil when the
+// request journal is disabled.
+func NewRoomHandler(redisClient redis.RoomStore, cfg *config.Config, j *journal.Journal) *RoomHandler {
+	h := &RoomHandler{
 		redisClient: redisClient,
+		cfg:         cfg,
+		journal:     j,
+		conflicts:   newConflictTracker(cfg.RoomNameConflictPolicy),
 	}
+	if cfg.SoftQuotaPerMinute > 0 {
+		h.softQuota = limits.NewSoftQuota(cfg.SoftQuotaPerMinute, cfg.MaxBatchSize, cfg.DegradedBatchMin)
+	}
+	if cfg.AnalyticsEnabled {
+		h.analytics = analytics.NewTracker(cfg.AnalyticsWindow, time.Minute, cfg.AnalyticsMaxHotels)
+	}
+	if cfg.DeadLetterMaxPerHotel > 0 {
+		h.deadLetters = newDeadLetters(redisClient, cfg.DeadLetterMaxPerHotel, cfg.DeadLetterTTL)
+	}
+	if cfg.RedisCoalesceWindow > 0 {
+		h.coalescer = newCoalescer(redisClient, cfg.RedisCoalesceWindow, cfg.RedisCoalesceMaxKeys)
+	}
+	if cfg.CacheEnabled {
+		h.hotelCache = cache.NewLRU[string, cachedHotel](cfg.CacheSize, cfg.CacheTTL, cfg.CacheStaleTTL)
+		h.hotelCache.SetSizer(estimateHotelSize)
+	}
+	return h
 }
 
-func (h *RoomHandler) GetRoomMappings(c *gin.Context) {
-	hotelID := c.Param("hotel_id")
-	if hotelID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "hotel_id is required"})
+// CacheStatsResponse describes the local cache layer for tuning
+type CacheStatsResponse struct {
+	Enabled bool `json:"enabled"`
+	cache.Stats
+	HitRate   float64           `json:"hit_rate"`
+	TTLs      map[string]string `json:"ttls"`
+	Conflicts NameConflictStats `json:"conflicts"`
+}
+
+// CacheStats returns local cache counters and configured per-tier TTLs
+func (h *RoomHandler) CacheStats() CacheStatsResponse {
+	resp := CacheStatsResponse{
+		Enabled: h.hotelCache != nil,
+		TTLs: map[string]string{
+			"fresh":    h.cfg.CacheTTL.String(),
+			"stale":    h.cfg.CacheStaleTTL.String(),
+			"negative": h.cfg.NegativeCacheTTL.String(),
+		},
+		Conflicts: h.conflicts.Stats(),
+	}
+	if h.hotelCache == nil {
+		return resp
+	}
+	resp.Stats = h.hotelCache.Stats()
+	if total := resp.Hits + resp.Misses; total > 0 {
+		resp.HitRate = float64(resp.Hits) / float64(total)
+	}
+	return resp
+}
+
+// estimateHotelSize approximates the memory held by a cache entry: room
+// structs plus name bytes, and the rendered bodies when present.
+func estimateHotelSize(hotelID string, hotel cachedHotel) int {
+	const roomOverhead = 32 // string header + int64
+	size := len(hotelID) + 64
+	for _, r := range hotel.Rooms {
+		size += roomOverhead + len(r.Name)
+	}
+	if hotel.bodies != nil {
+		// Bodies render lazily; assume JSON is about the size of the room data
+		size += size
+	}
+	return size
+}
+
+func (h *RoomHandler) getCachedHotel(hotelID string) (cachedHotel, bool) {
+	if h.hotelCache == nil {
+		return cachedHotel{}, false
+	}
+	if h.cfg.RefreshAheadWindow <= 0 {
+		return h.hotelCache.Get(hotelID)
+	}
+	hotel, expiresAt, ok := h.hotelCache.GetWithExpiry(hotelID)
+	if ok {
+		h.refreshAhead(hotelID, hotel, expiresAt)
+	}
+	return hotel, ok
+}
+
+// cacheHotel stores a hotel in the local cache. Hotels without mappings are
+// negatively cached with the shorter negative TTL (or not at all if it's zero).
+func (h *RoomHandler) cacheHotel(hotelID string, hotel cachedHotel) {
+	if h.hotelCache == nil {
+		return
+	}
+	if len(hotel.Rooms) == 0 {
+		if h.cfg.NegativeCacheTTL > 0 {
+			h.hotelCache.SetWithTTL(hotelID, hotel, h.cfg.NegativeCacheTTL)
+		}
+		return
+	}
+	if hotel.bodies == nil {
+		hotel.bodies = &renderedBodies{}
+	}
+	hotel.heat = &hotelHeat{}
+	h.hotelCache.Set(hotelID, hotel)
+}
+
+// getStaleHotel returns a cached entry even if expired, for use when Redis fails
+func (h *RoomHandler) getStaleHotel(hotelID string) (cachedHotel, bool) {
+	if h.hotelCache == nil {
+		return cachedHotel{}, false
+	}
+	return h.hotelCache.GetStale(hotelID)
+}
+
+// refreshInBackground re-fetches a hotel into the cache: after it was served
+// stale, so the cache recovers as soon as Redis does, or ahead of expiry
+func (h *RoomHandler) refreshInBackground(hotelID string) {
+	if RedisDegraded() {
+		// The health monitor will notice recovery; until then don't pile on
 		return
 	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		res, err := h.fetchRoomsShared(ctx, hotelID)
+		if err != nil {
+			return
+		}
+		version, _ := h.resultVersion(ctx, hotelID, res)
+		h.cacheHotel(hotelID, cachedHotel{Rooms: res.rooms, Truncated: res.truncated, Variant: res.variant, Version: version})
+	}()
+}
+
+// markStale flags a response as containing stale data (RFC 7234 warning 110)
+func markStale(c *gin.Context) {
+	c.Header("Warning", `110 - "Response is Stale"`)
+	c.Header("X-Stale", "true")
+}
+
+// InvalidateHotel drops a hotel from the local cache
+func (h *RoomHandler) InvalidateHotel(hotelID string) {
+	if h.hotelCache != nil {
+		h.hotelCache.Delete(hotelID)
+	}
+}
 
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
-	defer cancel()
+func (h *RoomHandler) GetRoomMappings(c *gin.Context) {
+	hotelID, err := h.hotelParam(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
 
-	// Use the shared function to fetch room mappings (tries both hashtagged and non-hashtagged)
-	rooms, err := h.fetchRoomsForHotel(ctx, hotelID)
+	rawNames, err := rawNamesRequested(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	fields, err := roomFieldsRequested(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	cursor, err := roomCursor(c)
 	if err != nil {
-		log.Printf("ERROR: Failed to fetch from Redis hash for hotel %s: %v", hotelID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch room mappings"})
+		respondError(c, err)
+		return
+	}
+	if rawVersion := c.Query("version"); rawVersion != "" {
+		h.getRoomMappingsSnapshot(c, hotelID, rawVersion, namerFor(rawNames), fields)
+		return
+	}
+
+	var ifVersionGt int64 = -1
+	if raw := c.Query("if_version_gt"); raw != "" {
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || v < 0 {
+			respondError(c, errs.New(errs.Invalid, "if_version_gt must be a non-negative integer"))
+			return
+		}
+		ifVersionGt = v
+	}
+
+	ctx := c.Request.Context()
+
+	var entry *journal.Entry
+	if h.journal.Sampled() {
+		start := time.Now()
+		entry = &journal.Entry{Route: "single", HotelIDs: []string{hotelID}}
+		defer func() {
+			entry.Time = start
+			entry.Status = c.Writer.Status()
+			entry.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+			entry.ResponseBytes = c.Writer.Size()
+			h.journal.Record(*entry)
+		}()
+	}
+
+	var hotel cachedHotel
+	fromCache := false
+	if !rawNames && !faults.Active(ctx) {
+		hotel, fromCache = h.getCachedHotel(hotelID)
+	}
+	outcome := analytics.Miss
+	if fromCache {
+		outcome = analytics.Hit
+		h.sampler.served(hotelID)
+	}
+	defer func() { h.analytics.Record(hotelID, outcome) }()
+	versionKnown := fromCache
+	// Cheap delta polling: read the version alone and skip the room fetch when
+	// the caller is up to date, which it never is for an unversioned hotel
+	// (version 0). Other lookups read it along with the rooms.
+	if !fromCache && ifVersionGt >= 0 {
+		hotel.Version, versionKnown = h.resultVersion(ctx, hotelID, fetchResult{})
+	}
+	if versionKnown && ifVersionGt >= 0 && hotel.Version.Version > 0 && hotel.Version.Version <= ifVersionGt {
+		response := RoomMappingsResponse{Rooms: []Room{}, NotModified: true}
+		hotel.Version.apply(&response)
+		writeJSON(c, response)
+		return
+	}
+
+	if !fromCache {
+		// Use the shared function to fetch room mappings (tries both hashtagged and non-hashtagged)
+		var res fetchResult
+		if rawNames {
+			res, err = h.fetchRoomsFromHash(ctx, hotelID, rawRoomName)
+		} else {
+			res, err = h.fetchRoomsShared(ctx, hotelID)
+		}
+		if entry != nil && err != nil {
+			entry.Error = err.Error()
+		}
+		switch {
+		case err == nil:
+			hotel.Rooms, hotel.Truncated, hotel.Variant = res.rooms, res.truncated, res.variant
+			if !versionKnown {
+				hotel.Version, _ = h.resultVersion(ctx, hotelID, res)
+			}
+			if !rawNames {
+				hotel.bodies = &renderedBodies{}
+				h.cacheHotel(hotelID, hotel)
+			}
+		default:
+			stale, ok := h.getStaleHotel(hotelID)
+			if !ok || rawNames {
+				slog.ErrorContext(ctx, "Failed to fetch room mappings", "hotel_id", hotelID, "error", err)
+				outcome = analytics.Error
+				respondError(c, errs.Classify("failed to fetch room mappings", err))
+				return
+			}
+			// Turn the Redis blip into slightly stale data rather than an outage
+			slog.WarnContext(ctx, "Serving stale room mappings after Redis error", "hotel_id", hotelID, "error", err)
+			outcome = analytics.Stale
+			hotel = stale
+			markStale(c)
+			h.refreshInBackground(hotelID)
+		}
+	}
+	if entry != nil {
+		entry.KeyVariants = map[string]string{hotelID: hotel.Variant}
+		entry.RoomCounts = map[string]int{hotelID: len(hotel.Rooms)}
+	}
+	if !rawNames && outcome != analytics.Stale && !hotel.Truncated {
+		h.shadow.sample(hotelID, hotel.Rooms, hotel.Version)
+	}
+
+	exists := len(hotel.Rooms) > 0
+	if !exists && h.cfg.MissingHotelNotFound {
+		respondError(c, &errs.Error{Kind: errs.NotFound, Code: errs.CodeHotelNotFound, Msg: "hotel has no room mappings"})
+		return
+	}
+	response := RoomMappingsResponse{Rooms: hotel.Rooms, Truncated: hotel.Truncated, Exists: &exists, fields: fields}
+	hotel.Version.apply(&response)
+	includeAttributes := includes(c, "attributes")
+	includeDeleted := includes(c, "deleted")
+	preEncoded := hotel.bodies != nil && fields == 0 && cursor == 0 && !includes(c, "meta") && !includeAttributes && !includeDeleted
+	if preEncoded && (h.cfg.MaxResponseBytes <= 0 || len(hotel.bodies.plain(response)) <= h.cfg.MaxResponseBytes) {
+		writePreEncoded(c, hotel.bodies, response)
+		return
+	}
+	if includeAttributes {
+		response.Rooms = withAttributes(response.Rooms)
+	}
+	if includes(c, "meta") {
+		meta, err := h.fetchHotelMeta(ctx, hotelID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to fetch metadata", "hotel_id", hotelID, "error", err)
+		}
+		response.Meta = meta
+	}
+	if includeDeleted {
+		deleted, err := h.fetchDeletedRooms(ctx, hotelID, namerFor(rawNames))
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to fetch tombstones", "hotel_id", hotelID, "error", err)
+		}
+		response.Deleted = deleted
+	}
+	if err := h.limitRooms(&response, cursor); err != nil {
+		respondError(c, err)
 		return
 	}
 
-	writeJSONMaybeGzip(c, RoomMappingsResponse{Rooms: rooms})
+	writeJSON(c, response)
 }
 
 // GetRoomMappingsBatch handles batch requests for multiple hotel IDs
 func (h *RoomHandler) GetRoomMappingsBatch(c *gin.Context) {
 	var request struct {
-		HotelIDs []string `json:"hotel_ids" binding:"required"`
+		HotelIDs []string `json:"hotel_ids"`
+		// Hotels is the conditional form: hotels whose version is still
+		// known_version come back as not_modified, without rooms
+		Hotels []struct {
+			HotelID      string `json:"hotel_id"`
+			KnownVersion int64  `json:"known_version"`
+		} `json:"hotels"`
 	}
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: hotel_ids array is required"})
+	if err := bindJSON(c, &request, h.cfg.MaxRequestBodyBytes); err != nil {
+		respondError(c, err)
+		return
+	}
+	var knownVersions map[string]int64
+	switch {
+	case request.HotelIDs != nil && request.Hotels != nil:
+		respondError(c, &errs.Error{Kind: errs.Invalid, Field: "hotels", Msg: "send either hotel_ids or hotels, not both"})
+		return
+	case request.Hotels != nil:
+		knownVersions = make(map[string]int64, len(request.Hotels))
+		request.HotelIDs = make([]string, len(request.Hotels))
+		for i, hotel := range request.Hotels {
+			if hotel.HotelID == "" || hotel.KnownVersion < 0 {
+				respondError(c, &errs.Error{Kind: errs.Invalid, Field: "hotels", Msg: "every hotels entry needs a hotel_id and a non-negative known_version"})
+				return
+			}
+			request.HotelIDs[i] = hotel.HotelID
+			knownVersions[hotel.HotelID] = hotel.KnownVersion
+		}
+	case request.HotelIDs == nil:
+		respondError(c, &errs.Error{Kind: errs.Invalid, Field: "hotel_ids", Msg: `field "hotel_ids" is required`})
+		return
+	}
+	rawNames, err := rawNamesRequested(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	fields, err := roomFieldsRequested(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	ordered, err := queryBool(c, "ordered")
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
-	// Hard caps are essential at 1000 rps
-	if len(request.HotelIDs) == 0 || len(request.HotelIDs) > 100 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "hotel_ids must contain 1..100 items"})
+	// Hard caps are essential at 1000 rps; callers over their soft quota get a smaller cap
+	maxBatch := h.cfg.MaxBatchSize
+	if h.softQuota != nil {
+		limit, degraded := h.softQuota.Observe(callerID(c))
+		maxBatch = limit
+		c.Header("X-Batch-Limit", strconv.Itoa(limit))
+		if degraded {
+			c.Header("X-Batch-Limit-Reason", "soft-quota-exceeded")
+		}
+	}
+	if len(request.HotelIDs) == 0 || len(request.HotelIDs) > maxBatch {
+		e := &errs.Error{Kind: errs.Invalid, Field: "hotel_ids", Msg: fmt.Sprintf("hotel_ids must contain 1..%d items", maxBatch)}
+		if len(request.HotelIDs) > maxBatch {
+			e.Code, e.Details = errs.CodeBatchTooLarge, map[string]any{"max": maxBatch}
+		}
+		respondError(c, e)
 		return
 	}
 
+	var order []string
+	if ordered {
+		order = append([]string(nil), request.HotelIDs...)
+	}
 	// Dedup to avoid duplicate Redis work (common in callers)
-	hotelIDs := dedupStringsInPlace(request.HotelIDs)
+	requested := dedupStringsInPlace(request.HotelIDs)
+	// Keys are read under the tenant's IDs; the response uses the caller's
+	hotelIDs := make([]string, len(requested))
+	for i, id := range requested {
+		if hotelIDs[i], err = h.scopeHotelID(c, id); err != nil {
+			respondError(c, err)
+			return
+		}
+	}
 
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 1500*time.Millisecond)
-	defer cancel()
+	ctx := c.Request.Context()
 
-	// -------- Redis pipelining (no goroutines) --------
+	var entry *journal.Entry
+	if h.journal.Sampled() {
+		start := time.Now()
+		entry = &journal.Entry{
+			Route:       "batch",
+			HotelIDs:    append([]string(nil), hotelIDs...),
+			RoomCounts:  make(map[string]int, len(hotelIDs)),
+			KeyVariants: make(map[string]string, len(hotelIDs)),
+		}
+		defer func() {
+			entry.Time = start
+			entry.Status = c.Writer.Status()
+			entry.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+			entry.ResponseBytes = c.Writer.Size()
+			h.journal.Record(*entry)
+		}()
+	}
+
+	// -------- Redis pipelining (grouped per cluster node) --------
 	// Try primary keys first (as provided), then fallback keys
-	pipe := h.redisClient.Pipeline()
-	primaryCmds := make([]*redisc.MapStringStringCmd, 0, len(hotelIDs))
-	fallbackCmds := make([]*redisc.MapStringStringCmd, 0, len(hotelIDs))
-	keys := make([]string, 0, len(hotelIDs))
+	hashKeys := make([]string, 0, 3*len(hotelIDs))
+	targets := make([]**redis.HashResult, 0, 3*len(hotelIDs))
+	queue := func(key string, target **redis.HashResult) {
+		hashKeys = append(hashKeys, key)
+		targets = append(targets, target)
+	}
 
-	for _, hotelID := range hotelIDs {
-		keys = append(keys, hotelID)
+	includeMeta := includes(c, "meta")
+	// Hotels served from the local cache keep nil command slots
+	primaryCmds := make([]*redis.HashResult, len(hotelIDs))
+	fallbackCmds := make([]*redis.HashResult, len(hotelIDs))
+	versionCmds := make([]*redis.HashResult, len(hotelIDs))
+	var metaCmds []*redis.HashResult
+	if includeMeta {
+		metaCmds = make([]*redis.HashResult, len(hotelIDs))
+	}
+	includeDeleted := includes(c, "deleted") && h.cfg.TombstoneRetention > 0
+	var tombstoneCmds []*redis.HashResult
+	if includeDeleted {
+		tombstoneCmds = make([]*redis.HashResult, len(hotelIDs))
+	}
+	cached := make([]*cachedHotel, len(hotelIDs))
+	// hotelEnds[i] is the end offset of hotel i's keys in hashKeys
+	hotelEnds := make([]int, len(hotelIDs))
+
+	for i, hotelID := range hotelIDs {
+		if rawNames || faults.Active(ctx) {
+			break
+		}
+		if hotel, ok := h.getCachedHotel(hotelID); ok {
+			cached[i] = &hotel
+		}
+	}
+	// Oversized hashes are read with a bounded HSCAN up front instead of HGETALL
+	oversized := h.readOversizedHashes(ctx, hotelIDs, cached, primaryCmds, fallbackCmds)
+
+	for i, hotelID := range hotelIDs {
+		if includeMeta {
+			queue(keys.Meta(hotelID), &metaCmds[i])
+		}
+		if includeDeleted {
+			queue(keys.Tombstones(hotelID), &tombstoneCmds[i])
+		}
+		if cached[i] != nil {
+			hotelEnds[i] = len(hashKeys)
+			continue
+		}
 		// Try with curly braces first, then without
-		primaryCmds = append(primaryCmds, pipe.HGetAll(ctx, fmt.Sprintf("room_map:{%s}", hotelID)))
-		fallbackCmds = append(fallbackCmds, pipe.HGetAll(ctx, fmt.Sprintf("room_map:%s", hotelID)))
+		if !oversized[2*i] {
+			queue(keys.Room(hotelID), &primaryCmds[i])
+		}
+		if h.cfg.RoomKeyFallback && !oversized[2*i+1] {
+			queue(keys.RoomFallback(hotelID), &fallbackCmds[i])
+		}
+		queue(keys.Version(hotelID), &versionCmds[i])
+		hotelEnds[i] = len(hashKeys)
 	}
 
-	_, execErr := pipe.Exec(ctx)
+	execErr := h.execBatchChunks(ctx, hashKeys, targets, hotelEnds)
 	// Exec can return a non-nil error even when some commands succeeded.
 	// We'll treat per-hotel errors individually below via cmd.Err().
-	if execErr != nil && !errors.Is(execErr, redisc.Nil) {
-		log.Printf("ERROR: redis pipeline exec failed: %v", execErr)
+	if execErr != nil && !errors.Is(execErr, redis.Nil) {
+		slog.ErrorContext(ctx, "Redis pipeline exec failed", "error", execErr)
+		if entry != nil {
+			entry.Error = execErr.Error()
+		}
 		// still continue, cmds may contain partial results
 	}
+	if !h.cfg.RoomKeyFallback {
+		// The primary answer stands in for the legacy key, so a failed read
+		// still reports as an error rather than a miss
+		copy(fallbackCmds, primaryCmds)
+	}
+
+	parsed := h.parseBatchRooms(hotelIDs, primaryCmds, fallbackCmds, cached, namerFor(rawNames))
 
 	// -------- Build response --------
 	response := BatchRoomMappingsResponse{
 		Hotels: make(map[string]RoomMappingsResponse, len(hotelIDs)),
+		fields: fields,
 	}
 
 	for i := range hotelIDs {
-		hotelID := keys[i]
+		hotelID := hotelIDs[i]
 		primaryCmd := primaryCmds[i]
 		fallbackCmd := fallbackCmds[i]
 
+		var meta *HotelMeta
+		if includeMeta {
+			meta = metaFromCmd(metaCmds[i])
+		}
+
+		if faults.FailBatchHotel(ctx) {
+			response.Hotels[requested[i]] = RoomMappingsResponse{
+				Rooms: []Room{}, Meta: meta, Status: HotelStatusError,
+				Error: "failed to fetch room mappings", ErrorCode: errs.CodeOf(faults.ErrInjected), ErrorKind: errs.Degraded, Retryable: true,
+			}
+			response.Partial = true
+			continue
+		}
+
+		if hotel := cached[i]; hotel != nil {
+			h.analytics.Record(hotelID, analytics.Hit)
+			h.sampler.served(hotelID)
+			if entry != nil {
+				entry.KeyVariants[hotelID] = hotel.Variant
+				entry.RoomCounts[hotelID] = len(hotel.Rooms)
+			}
+			if !hotel.Truncated {
+				h.shadow.sample(hotelID, hotel.Rooms, hotel.Version)
+			}
+			hotelResp := RoomMappingsResponse{Rooms: hotel.Rooms, Truncated: hotel.Truncated, Meta: meta, Status: HotelStatusOK}
+			switch {
+			case len(hotel.Rooms) == 0:
+				hotelResp.Status = HotelStatusNotFound
+			case hotel.Version.notModifiedSince(knownVersions, requested[i]):
+				hotelResp = RoomMappingsResponse{Rooms: []Room{}, Meta: meta, Status: HotelStatusNotModified}
+			}
+			hotel.Version.apply(&hotelResp)
+			response.Hotels[requested[i]] = hotelResp
+			continue
+		}
+
 		// Try with curly braces first
-		hashData, err := primaryCmd.Result()
-		if err != nil || len(hashData) == 0 {
+		variant := keyVariantHashtag
+		hashData, primaryErr := primaryCmd.Result()
+		if primaryErr != nil || len(hashData) == 0 {
 			// If not found, try without curly braces
-			hashData, err = fallbackCmd.Result()
-			if err != nil || len(hashData) == 0 {
-				// Both failed -> empty
-				response.Hotels[hotelID] = RoomMappingsResponse{Rooms: []Room{}}
+			variant = keyVariantPlain
+			var fallbackErr error
+			hashData, fallbackErr = fallbackCmd.Result()
+			if fallbackErr != nil || len(hashData) == 0 {
+				if entry != nil {
+					entry.KeyVariants[hotelID] = keyVariantNone
+					entry.RoomCounts[hotelID] = 0
+				}
+				hotelResp := RoomMappingsResponse{Rooms: []Room{}, Meta: meta, Status: HotelStatusNotFound}
+				// A key that failed to read may hide the hotel's rooms, so it is
+				// only a miss, and negatively cached, when both were read empty
+				readErr := primaryErr
+				if readErr == nil {
+					readErr = fallbackErr
+				}
+				if readErr != nil {
+					if stale, ok := h.getStaleHotel(hotelID); ok && !rawNames {
+						slog.WarnContext(ctx, "Serving stale room mappings after Redis error", "hotel_id", hotelID, "error", readErr)
+						h.analytics.Record(hotelID, analytics.Stale)
+						hotelResp = RoomMappingsResponse{Rooms: stale.Rooms, Truncated: stale.Truncated, Meta: meta, Status: HotelStatusOK, Stale: true}
+						stale.Version.apply(&hotelResp)
+						response.Hotels[requested[i]] = hotelResp
+						markStale(c)
+						h.refreshInBackground(hotelID)
+						continue
+					}
+					slog.ErrorContext(ctx, "Failed to fetch room mappings", "hotel_id", hotelID, "error", readErr)
+					h.analytics.Record(hotelID, analytics.Error)
+					kind := errs.KindOf(readErr)
+					hotelResp.Status = HotelStatusError
+					hotelResp.Error = "failed to fetch room mappings"
+					hotelResp.ErrorCode = errs.CodeOf(readErr)
+					hotelResp.ErrorKind = kind
+					hotelResp.Retryable = errs.Retryable(kind)
+					response.Partial = true
+				} else {
+					h.analytics.Record(hotelID, analytics.Miss)
+					if !rawNames {
+						h.cacheHotel(hotelID, cachedHotel{Rooms: []Room{}, Variant: keyVariantNone, Version: versionFromCmd(versionCmds[i])})
+					}
+				}
+				response.Hotels[requested[i]] = hotelResp
 				continue
 			}
 		}
 
-		rooms := parseRooms(hashData)
-		response.Hotels[hotelID] = RoomMappingsResponse{Rooms: rooms}
+		h.analytics.Record(hotelID, analytics.Miss)
+		rooms, truncated := parsed[i].rooms, parsed[i].truncated
+		// A bounded HSCAN that filled its limit left fields unread
+		if oversized[2*i] && variant == keyVariantHashtag || oversized[2*i+1] && variant == keyVariantPlain {
+			truncated = truncated || len(hashData) >= h.cfg.LargeHashScanLimit
+		}
+		if entry != nil {
+			entry.KeyVariants[hotelID] = variant
+			entry.RoomCounts[hotelID] = len(rooms)
+		}
+		version := versionFromCmd(versionCmds[i])
+		if !rawNames {
+			h.cacheHotel(hotelID, cachedHotel{Rooms: rooms, Truncated: truncated, Variant: variant, Version: version})
+			if !truncated {
+				h.shadow.sample(hotelID, rooms, version)
+			}
+		}
+		hotelResp := RoomMappingsResponse{Rooms: rooms, Truncated: truncated, Meta: meta, Status: HotelStatusOK}
+		if version.notModifiedSince(knownVersions, requested[i]) {
+			hotelResp = RoomMappingsResponse{Rooms: []Room{}, Meta: meta, Status: HotelStatusNotModified}
+		}
+		version.apply(&hotelResp)
+		response.Hotels[requested[i]] = hotelResp
+	}
+
+	if includes(c, "attributes") {
+		for hotelID, hotelResp := range response.Hotels {
+			hotelResp.Rooms = withAttributes(hotelResp.Rooms)
+			response.Hotels[hotelID] = hotelResp
+		}
+	}
+	if includeDeleted {
+		for i, id := range requested {
+			hashData, err := tombstoneCmds[i].Result()
+			if err != nil {
+				continue
+			}
+			hotelResp := response.Hotels[id]
+			hotelResp.Deleted = deletedRooms(hashData, h.cfg.TombstoneRetention, namerFor(rawNames))
+			response.Hotels[id] = hotelResp
+		}
+	}
+	if ordered {
+		response.orderBy(order)
+	}
+	if err := h.limitBatch(&response); err != nil {
+		respondError(c, err)
+		return
 	}
+	writeJSON(c, response)
+}
 
-	writeJSONMaybeGzip(c, response)
+// parsedRooms is one batch hotel's parseRooms result
+type parsedRooms struct {
+	rooms     []Room
+	truncated bool
+}
+
+// parseBatchRooms parses the room hash each uncached hotel will be served
+// from (primary key, else fallback) on up to BatchParseWorkers goroutines.
+// Hotels with nothing to parse keep a zero entry.
+func (h *RoomHandler) parseBatchRooms(hotelIDs []string, primaryCmds, fallbackCmds []*redis.HashResult, cached []*cachedHotel, names roomNamer) []parsedRooms {
+	hashes := make([]map[string]string, len(cached))
+	pending := 0
+	for i := range cached {
+		if cached[i] != nil {
+			continue
+		}
+		if hashData, err := primaryCmds[i].Result(); err == nil && len(hashData) > 0 {
+			hashes[i] = hashData
+		} else if hashData, err := fallbackCmds[i].Result(); err == nil && len(hashData) > 0 {
+			hashes[i] = hashData
+		} else {
+			continue
+		}
+		pending++
+	}
+
+	parsed := make([]parsedRooms, len(cached))
+	parse := func(i int) {
+		if hashes[i] != nil {
+			parsed[i].rooms, parsed[i].truncated = h.parseRoomsWith(hotelIDs[i], hashes[i], names)
+		}
+	}
+	workers := min(h.cfg.BatchParseWorkers, pending)
+	if workers <= 1 {
+		for i := range hashes {
+			parse(i)
+		}
+		return parsed
+	}
+
+	var (
+		wg   sync.WaitGroup
+		next atomic.Int64
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= len(hashes) {
+					return
+				}
+				parse(i)
+			}
+		}()
+	}
+	wg.Wait()
+	return parsed
+}
+
+// execBatchChunks runs the batch's HGETALLs in chunks of BatchChunkSize
+// hotels, concurrently and each with its own BatchChunkTimeout, so one slow
+// shard only fails the hotels in its chunk. Results are stored via targets.
+func (h *RoomHandler) execBatchChunks(ctx context.Context, hashKeys []string, targets []**redis.HashResult, hotelEnds []int) error {
+	size := h.cfg.BatchChunkSize
+	if size <= 0 || len(hotelEnds) <= size {
+		cmds, err := h.redisClient.HGetAllMulti(ctx, hashKeys)
+		for i, cmd := range cmds {
+			*targets[i] = cmd
+		}
+		return err
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	for first := 0; first < len(hotelEnds); first += size {
+		start := 0
+		if first > 0 {
+			start = hotelEnds[first-1]
+		}
+		end := hotelEnds[min(first+size, len(hotelEnds))-1]
+		if start == end {
+			continue
+		}
+
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			chunkCtx, cancel := context.WithTimeout(ctx, h.cfg.BatchChunkTimeout)
+			defer cancel()
+
+			cmds, err := h.redisClient.HGetAllMulti(chunkCtx, hashKeys[start:end])
+			for i, cmd := range cmds {
+				*targets[start+i] = cmd
+			}
+			if err != nil && !errors.Is(err, redis.Nil) {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}(start, end)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+type fetchResult struct {
+	rooms     []Room
+	variant   string
+	truncated bool
+	// version is the hotel's version, read in the same round trip as the
+	// rooms when versionRead is set
+	version     hotelVersion
+	versionRead bool
+}
+
+// fetchRoomsShared deduplicates concurrent fetches for the same hotel so one
+// Redis round trip serves every waiter. The shared call runs detached from the
+// first caller's cancellation so one impatient client can't fail the others;
+// each caller still stops waiting when its own context ends. Misses go to the
+// upstream API when read-through is on.
+func (h *RoomHandler) fetchRoomsShared(ctx context.Context, hotelID string) (fetchResult, error) {
+	if RedisDegraded() {
+		return fetchResult{variant: keyVariantNone}, errRedisDegraded
+	}
+	if faults.Active(ctx) {
+		return h.fetchRoomsForHotel(ctx, hotelID)
+	}
+	ch := h.fetches.DoChan(hotelID, func() (interface{}, error) {
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		res, err := h.fetchRoomsForHotel(fetchCtx, hotelID)
+		cancel()
+		if err == nil && res.variant == keyVariantNone && h.cfg.UpstreamReadThrough {
+			res = h.readThrough(context.WithoutCancel(ctx), hotelID, res)
+		}
+		return res, err
+	})
+
+	select {
+	case <-ctx.Done():
+		return fetchResult{variant: keyVariantNone}, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return fetchResult{variant: keyVariantNone}, res.Err
+		}
+		return res.Val.(fetchResult), nil
+	}
 }
 
 // fetchRoomsForHotel fetches room mappings for a single hotel
-// Tries with curly braces first, then without curly braces
-func (h *RoomHandler) fetchRoomsForHotel(ctx context.Context, hotelID string) ([]Room, error) {
-	// Try with curly braces first
-	keyWithBraces := fmt.Sprintf("room_map:{%s}", hotelID)
-	hashData, err := h.redisClient.HGetAll(ctx, keyWithBraces)
-	if err == nil && len(hashData) > 0 {
-		return parseRooms(hashData), nil
-	}
-
-	// If not found, try without curly braces
-	keyWithoutBraces := fmt.Sprintf("room_map:%s", hotelID)
-	hashData, err = h.redisClient.HGetAll(ctx, keyWithoutBraces)
+// Tries with curly braces first, then without curly braces, and reports which key variant answered
+func (h *RoomHandler) fetchRoomsForHotel(ctx context.Context, hotelID string) (fetchResult, error) {
+	if !h.cfg.NormalizedRooms {
+		return h.fetchRoomsFromHash(ctx, hotelID, normalizeRoomName)
+	}
+	if res, ok := h.readNormalized(ctx, hotelID); ok {
+		return res, nil
+	}
+	res, err := h.fetchRoomsFromHash(ctx, hotelID, normalizeRoomName)
+	if err == nil && res.variant != keyVariantNone {
+		h.storeNormalizedAsync(ctx, hotelID, res)
+	}
+	return res, err
+}
+
+// fetchRoomsFromHash reads and parses the hotel's room hash, naming rooms
+// with names
+func (h *RoomHandler) fetchRoomsFromHash(ctx context.Context, hotelID string, names roomNamer) (fetchResult, error) {
+	if h.cfg.LargeHashThreshold > 0 {
+		return h.fetchRoomsSizeAware(ctx, hotelID, names)
+	}
+
+	// Read the key variants and the version in one round trip and prefer the
+	// hashtagged variant
+	hashKeys := h.roomHashKeys(hotelID)
+	cmds, err := hedge(ctx, h.cfg.RedisHedgeDelay, func(ctx context.Context) ([]*redis.HashResult, error) {
+		return h.readHashes(ctx, append(hashKeys, keys.Version(hotelID)))
+	})
+	if cmds == nil {
+		return fetchResult{variant: keyVariantNone}, err
+	}
+	versionData, versionErr := cmds[len(hashKeys)].Result()
+	withVersion := func(res fetchResult) fetchResult {
+		res.version, res.versionRead = parseHotelVersion(versionData), versionErr == nil
+		return res
+	}
+
+	hashData, primaryErr := cmds[0].Result()
+	if primaryErr == nil && len(hashData) > 0 {
+		rooms, truncated := h.parseRoomsWith(hotelID, hashData, names)
+		return withVersion(fetchResult{rooms: rooms, variant: keyVariantHashtag, truncated: truncated}), nil
+	}
+	err = primaryErr
+	if len(hashKeys) > 1 {
+		hashData, err = cmds[1].Result()
+	}
 	if err != nil {
-		return nil, err
+		return fetchResult{variant: keyVariantNone}, err
+	}
+	if len(hashData) == 0 {
+		// Not found only if every key was read
+		if primaryErr != nil {
+			return fetchResult{variant: keyVariantNone}, primaryErr
+		}
+		return withVersion(fetchResult{rooms: []Room{}, variant: keyVariantNone}), nil
+	}
+	rooms, truncated := h.parseRoomsWith(hotelID, hashData, names)
+	return withVersion(fetchResult{rooms: rooms, variant: keyVariantPlain, truncated: truncated}), nil
+}
+
+// roomHashKeys returns the keys a hotel's rooms may live under, hashtagged
+// first, leaving out the legacy key when the fallback is disabled
+func (h *RoomHandler) roomHashKeys(hotelID string) []string {
+	if !h.cfg.RoomKeyFallback {
+		return []string{keys.Room(hotelID)}
 	}
-	return parseRooms(hashData), nil
+	return []string{keys.Room(hotelID), keys.RoomFallback(hotelID)}
+}
+
+// parseRooms decodes a room hash, reporting whether it held more rooms than
+// MaxRoomsPerHotel and was cut short
+func (h *RoomHandler) parseRooms(hotelID string, hashData map[string]string) ([]Room, bool) {
+	return h.parseRoomsWith(hotelID, hashData, normalizeRoomName)
 }
 
-// normalizeRoomName normalizes room names for consistent comparison
-func normalizeRoomName(name string) string {
-	s := strings.ToLower(strings.TrimSpace(name))
-	s = punctReplacer.Replace(s)
-	s = wsRe.ReplaceAllString(s, " ")
-	return strings.TrimSpace(s)
+// parseRoomsWith is parseRooms with names derived from the field names by names
+func (h *RoomHandler) parseRoomsWith(hotelID string, hashData map[string]string, names roomNamer) ([]Room, bool) {
+	return h.decodeRooms(hotelID, true, hashData, names)
 }
 
-func parseRooms(hashData map[string]string) []Room {
+// decodeRooms is parseRoomsWith, recording skipped entries and name conflicts
+// only if record is set
+func (h *RoomHandler) decodeRooms(hotelID string, record bool, hashData map[string]string, names roomNamer) ([]Room, bool) {
+	recordID := hotelID
+	if !record {
+		recordID = ""
+	}
 	// Guardrail: cap processed rooms to avoid CPU/memory explosion on huge hashes
-	const maxRoomsToProcess = 2000
-	if len(hashData) > maxRoomsToProcess {
-		log.Printf("WARNING: hotel has %d rooms, truncating processing to %d", len(hashData), maxRoomsToProcess)
+	maxRooms := h.cfg.MaxRoomsPerHotel
+	truncated := len(hashData) > maxRooms
+	if truncated {
+		slog.Warn("Hotel has too many rooms, truncating processing", "rooms", len(hashData), "limit", maxRooms)
 	}
 
-	rooms := make([]Room, 0, len(hashData))
+	rooms := make([]Room, 0, min(len(hashData), maxRooms))
+	rawNames := make([]string, 0, cap(rooms))
 	count := 0
 
 	for roomName, roomJSON := range hashData {
-		if count >= maxRoomsToProcess {
+		if count >= maxRooms {
 			break
 		}
 
-		var rv roomValue
-		// Optimization: could use byte scanning for "id" to avoid allocations,
-		// but Unmarshal is safe and pipeline provides biggest win.
-		if err := json.Unmarshal([]byte(roomJSON), &rv); err != nil {
-			log.Printf("ERROR: Failed to parse room data: %v", err)
+		stored := roomJSON
+		roomJSON, err := valueKeyring.Decrypt(roomJSON, valueAAD(hotelID, roomName))
+		if err != nil {
+			slog.Error("Failed to decrypt room data", "error", err)
+			h.deadLetters.record(recordID, roomName, stored, IssueUndecryptable, err)
 			continue
 		}
 
-		id, err := rv.ID.Int64()
-		if err != nil || id == 0 {
+		id, err := roomID(roomJSON)
+		if err != nil {
+			slog.Error("Failed to parse room data", "error", err)
+			h.deadLetters.record(recordID, roomName, stored, IssueMalformedJSON, err)
+			continue
+		}
+		if id == 0 {
+			h.deadLetters.record(recordID, roomName, stored, IssueZeroID, nil)
 			continue
 		}
 
 		rooms = append(rooms, Room{
-			Name: normalizeRoomName(roomName),
+			Name: names(roomName),
 			ID:   id,
 		})
+		rawNames = append(rawNames, roomName)
 		count++
 	}
 
-	// Stable order for clients & caching
-	sort.Slice(rooms, func(i, j int) bool { return rooms[i].Name < rooms[j].Name })
+	// Stable order for clients & caching; raw names order rooms that
+	// normalize to the same name
+	sort.Sort(roomsByName{rooms, rawNames})
+	rooms = h.resolveNameConflicts(recordID, rooms, rawNames)
 
-	return rooms
+	return rooms, truncated
 }
 
-func writeJSONMaybeGzip(c *gin.Context, v any) {
-	c.Header("Content-Type", "application/json")
+// writePreEncoded serves a cached rendering of v, producing it on first use
+func writePreEncoded(c *gin.Context, bodies *renderedBodies, v any) {
+	_, span := tracer.Start(c.Request.Context(), "encode_response")
+	defer span.End()
 
-	ae := c.GetHeader("Accept-Encoding")
-	if strings.Contains(ae, "gzip") {
-		c.Header("Content-Encoding", "gzip")
-		w := gzipPool.Get().(*gzip.Writer)
-		defer gzipPool.Put(w)
+	enc := negotiateEncoding(c.GetHeader("Accept-Encoding"), len(bodies.plain(v)))
+	setEncodingHeaders(c, enc)
+	_, _ = c.Writer.Write(bodies.get(v, enc))
+}
 
-		w.Reset(c.Writer)
-		defer w.Close()
+// writeJSON encodes v and compresses it with the encoding negotiated from
+// Accept-Encoding
+func writeJSON(c *gin.Context, v any) {
+	_, span := tracer.Start(c.Request.Context(), "encode_response")
+	defer span.End()
 
-		enc := json.NewEncoder(w)
-		_ = enc.Encode(v)
+	buf := getEncodeBuffer()
+	defer putEncodeBuffer(buf)
+	*buf = appendResponseJSON(*buf, v)
+
+	enc := negotiateEncoding(c.GetHeader("Accept-Encoding"), len(*buf))
+	setEncodingHeaders(c, enc)
+	if enc == encodingIdentity {
+		_, _ = c.Writer.Write(*buf)
 		return
 	}
+	cw := getCompressor(enc, c.Writer)
+	_, _ = cw.Write(*buf)
+	_ = cw.Close()
+	putCompressor(enc, cw)
+}
 
-	enc := json.NewEncoder(c.Writer)
-	_ = enc.Encode(v)
+// callerID identifies the calling client for per-caller limits, as the rate
+// limiter does: the principal auth accepted (API key name or token subject),
+// otherwise the client IP. Headers a caller could vary to reset its quota,
+// and raw API keys, are never used.
+func callerID(c *gin.Context) string {
+	if name := c.GetString(logging.APIKeyNameKey); name != "" {
+		return "key:" + name
+	}
+	return "ip:" + c.ClientIP()
 }
 
 func dedupStringsInPlace(in []string) []string {
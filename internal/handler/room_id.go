@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// roomID extracts the top-level "id" of a stored room value, returning 0 when
+// it is missing or not an integer. Well-formed values are scanned in place
+// without allocating; anything the scanner doesn't expect (escaped keys,
+// non-integer ids, malformed input) goes through encoding/json so the result
+// matches a full decode.
+func roomID(value string) (int64, error) {
+	if id, ok := scanRoomID(value); ok {
+		return id, nil
+	}
+	var rv roomValue
+	if err := json.Unmarshal([]byte(value), &rv); err != nil {
+		return 0, err
+	}
+	id, err := rv.ID.Int64()
+	if err != nil {
+		return 0, nil
+	}
+	return id, nil
+}
+
+// scanRoomID walks the keys of a JSON object, skipping every value except
+// "id". A missing id yields 0 and a repeated one keeps the last, as
+// json.Unmarshal does.
+func scanRoomID(s string) (int64, bool) {
+	i := skipSpace(s, 0)
+	if i >= len(s) || s[i] != '{' {
+		return 0, false
+	}
+	i = skipSpace(s, i+1)
+	if i < len(s) && s[i] == '}' {
+		return 0, skipSpace(s, i+1) == len(s)
+	}
+
+	var id int64
+	for {
+		// Key
+		if i >= len(s) || s[i] != '"' {
+			return 0, false
+		}
+		end := i + 1
+		for end < len(s) && s[end] != '"' {
+			if s[end] == '\\' {
+				return 0, false
+			}
+			end++
+		}
+		if end >= len(s) {
+			return 0, false
+		}
+		key := s[i+1 : end]
+		i = skipSpace(s, end+1)
+		if i >= len(s) || s[i] != ':' {
+			return 0, false
+		}
+		i = skipSpace(s, i+1)
+
+		// Value
+		start := i
+		i = skipValue(s, i)
+		if i < 0 {
+			return 0, false
+		}
+		if key == "id" {
+			raw := s[start:i]
+			if len(raw) >= 2 && raw[0] == '"' {
+				raw = raw[1 : len(raw)-1]
+			}
+			n, err := strconv.ParseInt(raw, 10, 64)
+			digits := strings.TrimPrefix(raw, "-")
+			// JSON numbers have no '+' sign or leading zeros
+			if err != nil || raw[0] == '+' || len(digits) > 1 && digits[0] == '0' {
+				return 0, false
+			}
+			id = n
+		}
+
+		i = skipSpace(s, i)
+		if i >= len(s) {
+			return 0, false
+		}
+		switch s[i] {
+		case ',':
+			i = skipSpace(s, i+1)
+		case '}':
+			return id, skipSpace(s, i+1) == len(s)
+		default:
+			return 0, false
+		}
+	}
+}
+
+// skipValue returns the index just past the JSON value starting at i, or -1
+func skipValue(s string, i int) int {
+	if i >= len(s) {
+		return -1
+	}
+	switch s[i] {
+	case '"':
+		return skipString(s, i)
+	case '{', '[':
+		depth := 0
+		for i < len(s) {
+			switch s[i] {
+			case '"':
+				i = skipString(s, i)
+				if i < 0 {
+					return -1
+				}
+				continue
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return i + 1
+				}
+			}
+			i++
+		}
+		return -1
+	default:
+		start := i
+		for i < len(s) && s[i] != ',' && s[i] != '}' && !isSpace(s[i]) {
+			i++
+		}
+		if i == start {
+			return -1
+		}
+		return i
+	}
+}
+
+// skipString returns the index just past the string opening at i, or -1
+func skipString(s string, i int) int {
+	for i++; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+func skipSpace(s string, i int) int {
+	for i < len(s) && isSpace(s[i]) {
+		i++
+	}
+	return i
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
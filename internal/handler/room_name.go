@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+	"golang.org/x/text/width"
+)
+
+var (
+	wsRe          = regexp.MustCompile(`\s+`)
+	punctReplacer = strings.NewReplacer(
+		"-", " ",
+		",", " ",
+		".", " ",
+		"/", " ",
+		"(", " ",
+		")", " ",
+	)
+
+	// unicodeFolding enables foldUnicode in normalizeRoomName
+	unicodeFolding bool
+)
+
+// SetUnicodeFolding makes room name normalization fold accents and
+// full-width forms. Set it before serving.
+func SetUnicodeFolding(enabled bool) {
+	unicodeFolding = enabled
+}
+
+// normalizeRoomName normalizes room names for consistent comparison
+func normalizeRoomName(name string) string {
+	s := strings.ToLower(strings.TrimSpace(foldIfEnabled(transliterate(name))))
+	if rules := activeNameRules.Load(); rules != nil {
+		return rules.apply(s)
+	}
+	s = punctReplacer.Replace(s)
+	s = wsRe.ReplaceAllString(s, " ")
+	return strings.TrimSpace(s)
+}
+
+func foldIfEnabled(s string) string {
+	if unicodeFolding {
+		return foldUnicode(s)
+	}
+	return s
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// isFoldedMark reports whether r is a combining mark to drop. Kana voicing
+// marks are kept: they distinguish letters rather than accent them.
+func isFoldedMark(r rune) bool {
+	return unicode.Is(unicode.Mn, r) && r != '\u3099' && r != '\u309a'
+}
+
+// foldUnicode applies compatibility decomposition (NFKD), which also turns
+// full-width letters, digits and punctuation into ASCII, folds remaining
+// width variants, drops combining marks such as accents and recomposes.
+// ASCII input is returned unchanged.
+func foldUnicode(s string) string {
+	if isASCII(s) {
+		return s
+	}
+	// Transformers keep state, so each call needs its own chain
+	t := transform.Chain(norm.NFKD, width.Fold, runes.Remove(runes.Predicate(isFoldedMark)), norm.NFC)
+	folded, _, err := transform.String(t, s)
+	if err != nil {
+		return s
+	}
+	return folded
+}
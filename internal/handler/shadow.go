@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"math/rand"
+	"time"
+
+	"room-mapping-cache/internal/keys"
+	"room-mapping-cache/internal/metrics"
+	"room-mapping-cache/internal/redis"
+)
+
+const (
+	// shadowReadConcurrency bounds shadow reads in flight; more are dropped
+	shadowReadConcurrency = 32
+	shadowReadTimeout     = 2 * time.Second
+)
+
+// Shadow read outcomes, the room_cache_shadow_reads_total result label
+const (
+	shadowMatch    = "match"
+	shadowMismatch = "mismatch"
+	// shadowMissing: the shadow has no rooms for a hotel that was served some
+	shadowMissing = "missing"
+	// shadowStale: the rooms differ but so does the version, so the shadow
+	// is behind (or ahead of) what was served rather than wrong
+	shadowStale   = "stale"
+	shadowError   = "error"
+	shadowDropped = "dropped"
+)
+
+// shadowReads re-reads a sample of lookups from a second Redis off the
+// request path and counts how its rooms compare with the ones served. A nil
+// *shadowReads does nothing.
+type shadowReads struct {
+	h      *RoomHandler
+	client redis.HashReader
+	rate   float64
+	slots  chan struct{}
+}
+
+// SetShadow mirrors a sample rate of room lookups to client for comparison
+func (h *RoomHandler) SetShadow(client redis.HashReader, rate float64) {
+	h.shadow = &shadowReads{h: h, client: client, rate: rate, slots: make(chan struct{}, shadowReadConcurrency)}
+}
+
+// sample compares a served hotel with the shadow's copy, if sampled
+func (s *shadowReads) sample(hotelID string, served []Room, version hotelVersion) {
+	if s == nil || s.rate <= 0 || rand.Float64() >= s.rate {
+		return
+	}
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		metrics.ShadowReads.WithLabelValues(shadowDropped).Inc()
+		return
+	}
+	go func() {
+		defer func() { <-s.slots }()
+		ctx, cancel := context.WithTimeout(context.Background(), shadowReadTimeout)
+		defer cancel()
+		metrics.ShadowReads.WithLabelValues(s.compare(ctx, hotelID, served, version)).Inc()
+	}()
+}
+
+func (s *shadowReads) compare(ctx context.Context, hotelID string, served []Room, version hotelVersion) string {
+	rooms, shadowVersion, err := s.h.readHotel(ctx, s.client, hotelID)
+	if err != nil {
+		slog.Warn("Shadow read failed", "hotel_id", hotelID, "error", err)
+		return shadowError
+	}
+	switch {
+	case sameRooms(served, rooms):
+		return shadowMatch
+	case len(rooms) == 0:
+		return shadowMissing
+	case shadowVersion.Version != version.Version:
+		return shadowStale
+	}
+	slog.Warn("Shadow read mismatch", "hotel_id", hotelID, "version", version.Version,
+		"served_rooms", len(served), "shadow_rooms", len(rooms))
+	return shadowMismatch
+}
+
+// readHotel reads a hotel's rooms, from either key variant, and its version
+// from client in one round trip. Bad entries are skipped without being
+// recorded, as they were when the hotel was first read.
+func (h *RoomHandler) readHotel(ctx context.Context, client redis.HashReader, hotelID string) ([]Room, hotelVersion, error) {
+	hashKeys := append(h.roomHashKeys(hotelID), keys.Version(hotelID))
+	cmds, err := client.HGetAllMulti(ctx, hashKeys)
+	if len(cmds) < len(hashKeys) {
+		return nil, hotelVersion{}, err
+	}
+	rooms := []Room{}
+	for _, cmd := range cmds[:len(cmds)-1] {
+		hashData, err := cmd.Result()
+		if err != nil {
+			return nil, hotelVersion{}, err
+		}
+		if len(hashData) > 0 {
+			rooms, _ = h.decodeRooms(hotelID, false, hashData, normalizeRoomName)
+			break
+		}
+	}
+	return rooms, versionFromCmd(cmds[len(cmds)-1]), nil
+}
+
+// sameRooms compares two sorted room lists by name and ID
+func sameRooms(a, b []Room) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].ID != b[i].ID {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"room-mapping-cache/internal/errs"
+	"room-mapping-cache/internal/keys"
+	"room-mapping-cache/internal/redis"
+
+	"github.com/gin-gonic/gin"
+)
+
+// saveSnapshot copies the live hash into the snapshot for version atomically,
+// so the snapshot is the hash as it was at one instant, records it in the
+// hotel's snapshot index and drops every indexed snapshot that falls out of
+// the retention window. An empty live hash records nothing. A positive ttl
+// expires the snapshot along with the live hash.
+func saveSnapshot(ctx context.Context, client redis.HashWriter, hotelID string, version int64, keep int, ttl time.Duration) error {
+	if keep <= 0 || version <= 0 {
+		return nil
+	}
+	_, err := client.SnapshotHash(ctx, keys.Room(hotelID), keys.Snapshot(hotelID, version), keys.Snapshots(hotelID), version, keep, ttl)
+	return err
+}
+
+// getRoomMappingsSnapshot serves GET /room-mappings/:hotel_id?version=n
+func (h *RoomHandler) getRoomMappingsSnapshot(c *gin.Context, hotelID, rawVersion string, names roomNamer, fields roomFields) {
+	version, err := strconv.ParseInt(rawVersion, 10, 64)
+	if err != nil || version <= 0 {
+		respondError(c, errs.New(errs.Invalid, "version must be a positive integer"))
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	hashData, err := h.redisClient.HGetAll(ctx, keys.Snapshot(hotelID, version))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to fetch snapshot", "hotel_id", hotelID, "version", version, "error", err)
+		respondError(c, errs.Classify("failed to fetch room mappings", err))
+		return
+	}
+	if len(hashData) == 0 {
+		respondError(c, errs.New(errs.NotFound, "version not found or no longer retained"))
+		return
+	}
+
+	rooms, truncated := h.parseRoomsWith(hotelID, hashData, names)
+	writeJSON(c, RoomMappingsResponse{Rooms: rooms, Version: version, Truncated: truncated, fields: fields})
+}
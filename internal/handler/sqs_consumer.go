@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"room-mapping-cache/internal/errs"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// sqsApplyAttempts is how often a message is applied before it is left for
+// redelivery after the visibility timeout
+const sqsApplyAttempts = 3
+
+// ConsumeSQS long-polls the configured SQS queue for JSON MappingUpdates until
+// ctx is cancelled. Applied messages are deleted; failed ones reappear after
+// the visibility timeout. Once a message has been received SQSMaxReceives
+// times, or at once if it is malformed, it is moved to the dead-letter queue
+// when one is configured and dropped otherwise. Credentials and region come
+// from the standard AWS environment.
+func (h *RoomHandler) ConsumeSQS(ctx context.Context) {
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		slog.Error("Failed to load AWS configuration", "error", err)
+		return
+	}
+	client := sqs.NewFromConfig(awsCfg)
+	slog.Info("Consuming room mapping updates from SQS", "queue", h.cfg.SQSQueueURL, "dead_letter_queue", h.cfg.SQSDeadLetterURL)
+
+	for h.maintenance.WaitWritable(ctx) == nil {
+		out, err := client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:            aws.String(h.cfg.SQSQueueURL),
+			MaxNumberOfMessages: int32(h.cfg.SQSBatchSize),
+			WaitTimeSeconds:     int32(h.cfg.SQSWaitTime / time.Second),
+			VisibilityTimeout:   int32(h.cfg.SQSVisibilityTimeout / time.Second),
+			AttributeNames:      []types.QueueAttributeName{types.QueueAttributeName(types.MessageSystemAttributeNameApproximateReceiveCount)},
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Error("Failed to receive from SQS", "queue", h.cfg.SQSQueueURL, "error", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		for _, msg := range out.Messages {
+			h.handleSQSMessage(ctx, client, msg)
+		}
+	}
+}
+
+func (h *RoomHandler) handleSQSMessage(ctx context.Context, client *sqs.Client, msg types.Message) {
+	id := aws.ToString(msg.MessageId)
+	err := h.applySQSMessage(ctx, aws.ToString(msg.Body))
+	if err == nil {
+		h.deleteSQSMessage(ctx, client, msg)
+		return
+	}
+
+	receives, _ := strconv.Atoi(msg.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)])
+	slog.Error("Failed to apply SQS update", "message_id", id, "receives", receives, "error", err)
+	if errs.KindOf(err) != errs.Invalid && receives < h.cfg.SQSMaxReceives {
+		// Leave it to reappear after the visibility timeout
+		return
+	}
+
+	if h.cfg.SQSDeadLetterURL != "" {
+		_, dlqErr := client.SendMessage(ctx, &sqs.SendMessageInput{
+			QueueUrl:    aws.String(h.cfg.SQSDeadLetterURL),
+			MessageBody: msg.Body,
+			MessageAttributes: map[string]types.MessageAttributeValue{
+				"error": {DataType: aws.String("String"), StringValue: aws.String(err.Error())},
+			},
+		})
+		if dlqErr != nil {
+			slog.Error("Failed to dead-letter SQS update", "message_id", id, "error", dlqErr)
+			return
+		}
+	} else {
+		slog.Warn("Dropping SQS update without a dead-letter queue", "message_id", id)
+	}
+	h.deleteSQSMessage(ctx, client, msg)
+}
+
+// applySQSMessage applies one message, retrying transient failures briefly
+func (h *RoomHandler) applySQSMessage(ctx context.Context, body string) error {
+	var u MappingUpdate
+	if err := json.Unmarshal([]byte(body), &u); err != nil {
+		return errs.Wrap(errs.Invalid, "malformed update message", err)
+	}
+
+	var err error
+	for attempt, backoff := 0, 100*time.Millisecond; attempt < sqsApplyAttempts; attempt, backoff = attempt+1, 2*backoff {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+		}
+		applyCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		err = h.ApplyUpdate(applyCtx, u)
+		cancel()
+		if err == nil || errs.KindOf(err) == errs.Invalid {
+			return err
+		}
+	}
+	return err
+}
+
+func (h *RoomHandler) deleteSQSMessage(ctx context.Context, client *sqs.Client, msg types.Message) {
+	_, err := client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(h.cfg.SQSQueueURL),
+		ReceiptHandle: msg.ReceiptHandle,
+	})
+	if err != nil {
+		slog.Error("Failed to delete SQS message", "message_id", aws.ToString(msg.MessageId), "error", err)
+	}
+}
@@ -0,0 +1,249 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"room-mapping-cache/internal/errs"
+	"room-mapping-cache/internal/hotelid"
+	"room-mapping-cache/internal/keys"
+	"room-mapping-cache/internal/redis"
+)
+
+// Stream update operations. Each entry carries op and hotel_id; "hset" also
+// needs supplier and rooms (a JSON object of room name to value, as in the
+// write endpoint) and "hdel" needs rooms (a JSON array of room names).
+const (
+	streamOpHSet = "hset"
+	streamOpHDel = "hdel"
+	streamOpDel  = "del"
+)
+
+// ConsumeUpdates applies room mapping deltas from the configured Redis Stream
+// using a consumer group, so each entry is applied by one instance, until ctx
+// is cancelled. Failed entries stay pending and are claimed again, by this or
+// another consumer, once idle for UpdatesStreamClaimIdle. After
+// UpdatesStreamMaxDeliveries deliveries, or at once if malformed, they are
+// moved to the dead-letter stream when one is configured and dropped
+// otherwise.
+func (h *RoomHandler) ConsumeUpdates(ctx context.Context, consumer string) {
+	stream, group := h.cfg.UpdatesStream, h.cfg.UpdatesStreamGroup
+	if !h.createStreamGroup(ctx) {
+		return
+	}
+	slog.Info("Consuming room mapping updates", "stream", stream, "group", group, "consumer", consumer, "dead_letter_stream", h.cfg.UpdatesStreamDeadLetter)
+
+	// Drain our own pending entries from a previous run before reading new ones
+	id := "0"
+	var lastClaim time.Time
+	for h.maintenance.WaitWritable(ctx) == nil {
+		if time.Since(lastClaim) >= h.cfg.UpdatesStreamClaimIdle/2 {
+			h.claimStaleUpdates(ctx, consumer)
+			lastClaim = time.Now()
+		}
+
+		msgs, err := h.redisClient.XReadGroup(ctx, stream, group, consumer, id, 100, 5*time.Second)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Error("Failed to read update stream", "stream", stream, "error", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		if id != ">" {
+			if len(msgs) == 0 {
+				id = ">"
+				continue
+			}
+			// Pending history is paged by ID; failures are claimed again later
+			id = msgs[len(msgs)-1].ID
+		}
+
+		for _, msg := range msgs {
+			h.handleStreamMessage(ctx, msg)
+		}
+	}
+}
+
+// createStreamGroup creates the consumer group, retrying with backoff while
+// Redis is unavailable. It returns false once ctx is cancelled.
+func (h *RoomHandler) createStreamGroup(ctx context.Context) bool {
+	stream, group := h.cfg.UpdatesStream, h.cfg.UpdatesStreamGroup
+	for backoff := time.Second; ; backoff = min(2*backoff, 30*time.Second) {
+		err := h.redisClient.XGroupCreate(ctx, stream, group, "$")
+		if err == nil {
+			return true
+		}
+		slog.Error("Failed to create consumer group, retrying", "stream", stream, "group", group, "retry_in", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// claimStaleUpdates takes over entries left pending on any consumer for at
+// least UpdatesStreamClaimIdle, e.g. by an instance that died or an earlier
+// failed attempt, and applies them
+func (h *RoomHandler) claimStaleUpdates(ctx context.Context, consumer string) {
+	stream, group := h.cfg.UpdatesStream, h.cfg.UpdatesStreamGroup
+	start := "0-0"
+	for {
+		msgs, next, err := h.redisClient.XAutoClaim(ctx, stream, group, consumer, h.cfg.UpdatesStreamClaimIdle, start, 100)
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Error("Failed to claim stale stream updates", "stream", stream, "error", err)
+			}
+			return
+		}
+		for _, msg := range msgs {
+			h.handleStreamMessage(ctx, msg)
+		}
+		if next == "0-0" || next == "" {
+			return
+		}
+		start = next
+	}
+}
+
+// handleStreamMessage applies one entry and acks it, leaving it pending on a
+// transient failure until it runs out of deliveries
+func (h *RoomHandler) handleStreamMessage(ctx context.Context, msg redis.StreamEntry) {
+	stream, group := h.cfg.UpdatesStream, h.cfg.UpdatesStreamGroup
+	applyCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	err := h.applyStreamUpdate(applyCtx, msg.Values)
+	cancel()
+	if err != nil {
+		deliveries, countErr := h.redisClient.XDeliveries(ctx, stream, group, msg.ID)
+		if countErr != nil {
+			slog.Error("Failed to read stream update deliveries", "id", msg.ID, "error", countErr)
+		}
+		slog.Error("Failed to apply stream update", "id", msg.ID, "deliveries", deliveries, "error", err)
+		// Malformed entries will never succeed, so don't leave them pending
+		if errs.KindOf(err) != errs.Invalid && (countErr != nil || deliveries < int64(h.cfg.UpdatesStreamMaxDeliveries)) {
+			return
+		}
+		if !h.deadLetterStreamUpdate(ctx, msg, err) {
+			return
+		}
+	}
+	if err := h.redisClient.XAck(ctx, stream, group, msg.ID); err != nil {
+		slog.Error("Failed to ack stream update", "id", msg.ID, "error", err)
+	}
+}
+
+// deadLetterStreamUpdate copies a failed entry, with its ID and error, to the
+// dead-letter stream. It returns false if the entry must stay pending because
+// the copy failed.
+func (h *RoomHandler) deadLetterStreamUpdate(ctx context.Context, msg redis.StreamEntry, cause error) bool {
+	if h.cfg.UpdatesStreamDeadLetter == "" {
+		slog.Warn("Dropping stream update without a dead-letter stream", "id", msg.ID)
+		return true
+	}
+	values := make(map[string]string, len(msg.Values)+2)
+	for k, v := range msg.Values {
+		values[k] = v
+	}
+	values["source_id"] = msg.ID
+	values["error"] = cause.Error()
+	if err := h.redisClient.XAdd(ctx, h.cfg.UpdatesStreamDeadLetter, values); err != nil {
+		slog.Error("Failed to dead-letter stream update", "id", msg.ID, "error", err)
+		return false
+	}
+	return true
+}
+
+// MappingUpdate is one room mapping change from an update feed. Rooms is a
+// JSON object of room name to value for "hset", as in the write endpoint,
+// and a JSON array of room names for "hdel".
+type MappingUpdate struct {
+	Op       string          `json:"op"`
+	HotelID  string          `json:"hotel_id"`
+	Supplier string          `json:"supplier,omitempty"`
+	Rooms    json.RawMessage `json:"rooms,omitempty"`
+}
+
+// applyStreamUpdate applies one stream entry to the hotel's room hash
+func (h *RoomHandler) applyStreamUpdate(ctx context.Context, values map[string]string) error {
+	field := func(name string) string {
+		return strings.TrimSpace(values[name])
+	}
+	return h.ApplyUpdate(ctx, MappingUpdate{
+		Op:       field("op"),
+		HotelID:  field("hotel_id"),
+		Supplier: field("supplier"),
+		Rooms:    json.RawMessage(field("rooms")),
+	})
+}
+
+// ApplyUpdate applies a mapping change to the hotel's room hash and runs the
+// usual post-write bookkeeping. Malformed updates fail with errs.Invalid.
+func (h *RoomHandler) ApplyUpdate(ctx context.Context, u MappingUpdate) error {
+	hotelID, err := hotelid.Canonical(u.HotelID)
+	if err != nil {
+		return err
+	}
+
+	switch op := strings.ToLower(strings.TrimSpace(u.Op)); op {
+	case streamOpHSet:
+		supplier := strings.ToLower(strings.TrimSpace(u.Supplier))
+		var rooms map[string]map[string]interface{}
+		if err := json.Unmarshal(u.Rooms, &rooms); err != nil || supplier == "" || len(rooms) == 0 {
+			return errs.New(errs.Invalid, "hset needs supplier and a rooms object")
+		}
+		fields, err := encodeRoomFields(hotelID, supplier, rooms)
+		if err != nil {
+			return err
+		}
+		if err := h.redisClient.HSet(ctx, keys.Room(hotelID), fields); err != nil {
+			return errs.Classify("failed to write room mappings", err)
+		}
+		h.clearTombstones(ctx, hotelID, fields)
+
+	case streamOpHDel:
+		var names []string
+		if err := json.Unmarshal(u.Rooms, &names); err != nil || len(names) == 0 {
+			return errs.New(errs.Invalid, "hdel needs a rooms array")
+		}
+		if _, err := h.deleteRooms(ctx, hotelID, names); err != nil {
+			return err
+		}
+
+	case streamOpDel:
+		// The two key variants live in different slots, so delete them separately
+		removed := make(map[string]string)
+		for _, key := range []string{keys.RoomFallback(hotelID), keys.Room(hotelID)} {
+			if h.cfg.TombstoneRetention <= 0 {
+				if err := h.redisClient.Del(ctx, key); err != nil {
+					return errs.Classify("failed to delete room mappings", err)
+				}
+				continue
+			}
+			// Read and delete together so rooms written in between get tombstones too
+			hashData, err := h.redisClient.HGetAllDel(ctx, key)
+			if err != nil {
+				return errs.Classify("failed to delete room mappings", err)
+			}
+			for name, stored := range hashData {
+				removed[name] = stored
+			}
+		}
+		h.recordTombstones(ctx, hotelID, removed)
+
+	default:
+		return errs.New(errs.Invalid, fmt.Sprintf("unknown op %q", op))
+	}
+
+	h.afterWrite(ctx, hotelID)
+	return nil
+}
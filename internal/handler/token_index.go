@@ -0,0 +1,270 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"room-mapping-cache/internal/keys"
+	"room-mapping-cache/internal/metrics"
+)
+
+// Token index hash fields: "v" holds the name normalization the index was
+// built with, "t:<token>" the sorted, comma-separated IDs of the rooms whose
+// name has the token and "n:<id>" the JSON array of that ID's room names.
+const (
+	tokenIndexVersionField = "v"
+	tokenIndexTokenPrefix  = "t:"
+	tokenIndexNamePrefix   = "n:"
+)
+
+// nameTokens splits a normalized room name into its distinct words
+func nameTokens(name string) []string {
+	words := strings.Fields(name)
+	seen := make(map[string]bool, len(words))
+	out := words[:0]
+	for _, w := range words {
+		if !seen[w] {
+			seen[w] = true
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// hasAllTokens reports whether name contains every token as a whole word
+func hasAllTokens(name string, tokens []string) bool {
+	words := strings.Fields(name)
+	for _, t := range tokens {
+		found := false
+		for _, w := range words {
+			if w == t {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// RebuildTokenIndex replaces the hotel's token index with one built from its
+// room hash, or removes it when the hotel has no rooms
+func (h *RoomHandler) RebuildTokenIndex(ctx context.Context, hotelID string) error {
+	// Writes go to the primary, which may be the reason reads failed over
+	if h.redisClient.FailedOver() {
+		return nil
+	}
+	res, err := h.fetchRoomsFromHash(ctx, hotelID, normalizeRoomName)
+	if err != nil || len(res.rooms) == 0 {
+		// A stale index is worse than none
+		if delErr := h.redisClient.Del(ctx, keys.TokenIndex(hotelID)); delErr != nil && err == nil {
+			err = delErr
+		}
+		return err
+	}
+
+	var ttl time.Duration
+	roomKey := keys.Room(hotelID)
+	if res.variant == keyVariantPlain {
+		roomKey = keys.RoomFallback(hotelID)
+	}
+	if left, err := h.redisClient.PTTL(ctx, roomKey); err == nil && left > 0 {
+		ttl = left
+	}
+
+	tokens := make(map[string][]int64)
+	names := make(map[int64][]string)
+	for _, r := range res.rooms {
+		// A room listed under several names is indexed under all their tokens
+		for _, t := range nameTokens(r.Name) {
+			tokens[t] = append(tokens[t], r.ID)
+		}
+		names[r.ID] = append(names[r.ID], r.Name)
+	}
+
+	fields := make(map[string]interface{}, 1+len(tokens)+len(names))
+	fields[tokenIndexVersionField] = nameNormalization()
+	add := func(field, value string) error {
+		if valueKeyring != nil {
+			var err error
+			if value, err = valueKeyring.Encrypt(value, valueAAD(hotelID, field)); err != nil {
+				return err
+			}
+		}
+		fields[field] = value
+		return nil
+	}
+	for t, ids := range tokens {
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		parts := make([]string, 0, len(ids))
+		for i, id := range ids {
+			if i > 0 && id == ids[i-1] {
+				continue
+			}
+			parts = append(parts, strconv.FormatInt(id, 10))
+		}
+		if err := add(tokenIndexTokenPrefix+t, strings.Join(parts, ",")); err != nil {
+			return err
+		}
+	}
+	for id, roomNames := range names {
+		raw, _ := json.Marshal(roomNames)
+		if err := add(tokenIndexNamePrefix+strconv.FormatInt(id, 10), string(raw)); err != nil {
+			return err
+		}
+	}
+	// Swapped in whole, so searches never see half of one
+	return h.redisClient.ReplaceHash(ctx, keys.TokenIndex(hotelID), fields, ttl)
+}
+
+// rebuildTokenIndex is RebuildTokenIndex for the write path, which logs
+// rather than fails
+func (h *RoomHandler) rebuildTokenIndex(ctx context.Context, hotelID string) {
+	if err := h.RebuildTokenIndex(ctx, hotelID); err != nil {
+		slog.ErrorContext(ctx, "Failed to rebuild token index", "hotel_id", hotelID, "error", err)
+	}
+}
+
+// searchTokenIndex returns the rooms whose names have every token and contain
+// pattern, sorted by name. It reports false when the hotel has no index built with the current
+// name normalization.
+func (h *RoomHandler) searchTokenIndex(ctx context.Context, hotelID string, tokens []string, pattern string) ([]Room, bool, error) {
+	key := keys.TokenIndex(hotelID)
+	fields := make([]string, 0, 1+len(tokens))
+	fields = append(fields, tokenIndexVersionField)
+	for _, t := range tokens {
+		fields = append(fields, tokenIndexTokenPrefix+t)
+	}
+	values, err := h.redisClient.HMGet(ctx, key, fields...)
+	if err != nil {
+		return nil, false, err
+	}
+	if v, ok := values[0].(string); !ok || v != nameNormalization() {
+		return nil, false, nil
+	}
+
+	// Intersect the ID lists, smallest first
+	lists := make([][]string, 0, len(tokens))
+	for i, raw := range values[1:] {
+		s, ok := raw.(string)
+		if !ok {
+			return []Room{}, true, nil
+		}
+		plain, err := valueKeyring.Decrypt(s, valueAAD(hotelID, fields[1+i]))
+		if err != nil {
+			return nil, false, err
+		}
+		lists = append(lists, strings.Split(plain, ","))
+	}
+	sort.Slice(lists, func(i, j int) bool { return len(lists[i]) < len(lists[j]) })
+	ids := lists[0]
+	for _, list := range lists[1:] {
+		in := make(map[string]bool, len(list))
+		for _, id := range list {
+			in[id] = true
+		}
+		kept := ids[:0:0]
+		for _, id := range ids {
+			if in[id] {
+				kept = append(kept, id)
+			}
+		}
+		ids = kept
+	}
+	if len(ids) == 0 {
+		return []Room{}, true, nil
+	}
+
+	nameFields := make([]string, len(ids))
+	for i, id := range ids {
+		nameFields[i] = tokenIndexNamePrefix + id
+	}
+	values, err = h.redisClient.HMGet(ctx, key, nameFields...)
+	if err != nil {
+		return nil, false, err
+	}
+	rooms := make([]Room, 0, len(ids))
+	for i, raw := range values {
+		s, ok := raw.(string)
+		if !ok {
+			// Rebuilt between the two reads
+			return nil, false, nil
+		}
+		id, _ := strconv.ParseInt(ids[i], 10, 64)
+		plain, err := valueKeyring.Decrypt(s, valueAAD(hotelID, nameFields[i]))
+		var roomNames []string
+		if err == nil {
+			err = json.Unmarshal([]byte(plain), &roomNames)
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		for _, name := range roomNames {
+			// Another name of the room may be the one that matched
+			if hasAllTokens(name, tokens) && strings.Contains(name, pattern) {
+				rooms = append(rooms, Room{Name: name, ID: id})
+			}
+		}
+	}
+	sort.Slice(rooms, func(i, j int) bool {
+		if rooms[i].Name != rooms[j].Name {
+			return rooms[i].Name < rooms[j].Name
+		}
+		return rooms[i].ID < rooms[j].ID
+	})
+	return rooms, true, nil
+}
+
+// searchRooms returns the rooms whose normalized names contain every word of
+// q, and the substring pattern if not empty. It uses the token index when
+// enabled and built, and otherwise scans the hotel's rooms.
+func (h *RoomHandler) searchRooms(ctx context.Context, hotelID, q, pattern string) ([]Room, bool, error) {
+	tokens := nameTokens(normalizeRoomName(q))
+	if len(tokens) == 0 {
+		return []Room{}, false, nil
+	}
+	pattern = normalizeRoomName(pattern)
+	if h.cfg.TokenIndex {
+		rooms, ok, err := h.searchTokenIndex(ctx, hotelID, tokens, pattern)
+		switch {
+		case err != nil:
+			slog.WarnContext(ctx, "Failed to search token index", "hotel_id", hotelID, "error", err)
+			metrics.TokenIndexSearches.WithLabelValues("error").Inc()
+		case ok:
+			metrics.TokenIndexSearches.WithLabelValues("hit").Inc()
+			return rooms, false, nil
+		default:
+			metrics.TokenIndexSearches.WithLabelValues("miss").Inc()
+			h.rebuildTokenIndexAsync(ctx, hotelID)
+		}
+	}
+
+	res, err := h.fetchRoomsShared(ctx, hotelID)
+	if err != nil {
+		return nil, false, err
+	}
+	rooms := make([]Room, 0)
+	for _, r := range res.rooms {
+		if hasAllTokens(r.Name, tokens) && strings.Contains(r.Name, pattern) {
+			rooms = append(rooms, r)
+		}
+	}
+	return rooms, res.truncated, nil
+}
+
+// rebuildTokenIndexAsync builds a missing or outdated index in the
+// background, so the next search can use it
+func (h *RoomHandler) rebuildTokenIndexAsync(ctx context.Context, hotelID string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		h.rebuildTokenIndex(ctx, hotelID)
+	}()
+}
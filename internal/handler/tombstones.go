@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+
+	"room-mapping-cache/internal/errs"
+	"room-mapping-cache/internal/keys"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeletedRoom is the tombstone of a room removed through the write API or an
+// ingestion update, listed with ?include=deleted until TOMBSTONE_RETENTION
+// has passed
+type DeletedRoom struct {
+	Name      string    `json:"name"`
+	ID        int64     `json:"id"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// tombstone is a tombstone hash value; the field is the stored room name
+type tombstone struct {
+	ID        int64 `json:"id"`
+	DeletedAt int64 `json:"deleted_at"`
+}
+
+type RoomMappingsDeleteRequest struct {
+	Rooms []string `json:"rooms" binding:"required"`
+}
+
+type RoomMappingsDeleteResponse struct {
+	HotelID string `json:"hotel_id"`
+	// Deleted counts the named rooms the hotel had
+	Deleted   int    `json:"deleted"`
+	Version   int64  `json:"version"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// DeleteRoomMappings removes the named rooms from the hotel's hash
+func (h *RoomHandler) DeleteRoomMappings(c *gin.Context) {
+	hotelID, err := h.hotelParam(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	var request RoomMappingsDeleteRequest
+	if err := bindJSON(c, &request, h.cfg.MaxRequestBodyBytes); err != nil {
+		respondError(c, err)
+		return
+	}
+	names := dedupStringsInPlace(request.Rooms)
+	if len(names) == 0 {
+		respondError(c, &errs.Error{Kind: errs.Invalid, Field: "rooms", Msg: "at least one room name is required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	deleted, err := h.deleteRooms(ctx, hotelID, names)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to delete rooms", "hotel_id", hotelID, "error", err)
+		respondError(c, err)
+		return
+	}
+	version := h.afterWrite(ctx, hotelID)
+
+	c.JSON(http.StatusOK, RoomMappingsDeleteResponse{
+		HotelID:   c.Param("hotel_id"),
+		Deleted:   deleted,
+		Version:   version.Version,
+		UpdatedAt: version.UpdatedAt.Format(time.RFC3339),
+	})
+}
+
+// deleteRooms removes rooms from the hotel's hash, leaving tombstones for the
+// ones it had, and returns how many that was
+func (h *RoomHandler) deleteRooms(ctx context.Context, hotelID string, names []string) (int, error) {
+	key := keys.Room(hotelID)
+	values, err := h.redisClient.HMGet(ctx, key, names...)
+	if err != nil {
+		return 0, errs.Classify("failed to delete rooms", err)
+	}
+	removed := make(map[string]string, len(names))
+	for i, value := range values {
+		if stored, ok := value.(string); ok {
+			removed[names[i]] = stored
+		}
+	}
+	if err := h.redisClient.HDel(ctx, key, names...); err != nil {
+		return 0, errs.Classify("failed to delete rooms", err)
+	}
+	h.recordTombstones(ctx, hotelID, removed)
+	return len(removed), nil
+}
+
+// recordTombstones stores tombstones for removed (room name to stored value)
+// and drops the hotel's expired ones. Failures are logged: the delete itself
+// already happened.
+func (h *RoomHandler) recordTombstones(ctx context.Context, hotelID string, removed map[string]string) {
+	retention := h.cfg.TombstoneRetention
+	if retention <= 0 || len(removed) == 0 {
+		return
+	}
+	key := keys.Tombstones(hotelID)
+	now := time.Now()
+
+	existing, err := h.redisClient.HGetAll(ctx, key)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to read tombstones", "hotel_id", hotelID, "error", err)
+	}
+	var expired []string
+	for name, raw := range existing {
+		if _, ok := parseTombstone(raw, now, retention); !ok {
+			expired = append(expired, name)
+		}
+	}
+	if len(expired) > 0 {
+		if err := h.redisClient.HDel(ctx, key, expired...); err != nil {
+			slog.ErrorContext(ctx, "Failed to prune tombstones", "hotel_id", hotelID, "error", err)
+		}
+	}
+
+	fields := make(map[string]interface{}, len(removed))
+	for name, stored := range removed {
+		var id int64
+		if plain, err := valueKeyring.Decrypt(stored, valueAAD(hotelID, name)); err == nil {
+			id, _ = roomID(plain)
+		}
+		raw, _ := json.Marshal(tombstone{ID: id, DeletedAt: now.Unix()})
+		fields[name] = string(raw)
+	}
+	if err := h.redisClient.HSet(ctx, key, fields); err != nil {
+		slog.ErrorContext(ctx, "Failed to record tombstones", "hotel_id", hotelID, "error", err)
+		return
+	}
+	if err := h.redisClient.Expire(ctx, key, retention); err != nil {
+		slog.ErrorContext(ctx, "Failed to expire tombstones", "hotel_id", hotelID, "error", err)
+	}
+}
+
+// clearTombstones forgets tombstones of rooms written again
+func (h *RoomHandler) clearTombstones(ctx context.Context, hotelID string, fields map[string]interface{}) {
+	if h.cfg.TombstoneRetention <= 0 {
+		return
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	if err := h.redisClient.HDel(ctx, keys.Tombstones(hotelID), names...); err != nil {
+		slog.ErrorContext(ctx, "Failed to clear tombstones", "hotel_id", hotelID, "error", err)
+	}
+}
+
+// fetchDeletedRooms returns the hotel's live tombstones
+func (h *RoomHandler) fetchDeletedRooms(ctx context.Context, hotelID string, names roomNamer) ([]DeletedRoom, error) {
+	if h.cfg.TombstoneRetention <= 0 {
+		return nil, nil
+	}
+	hashData, err := h.redisClient.HGetAll(ctx, keys.Tombstones(hotelID))
+	if err != nil {
+		return nil, err
+	}
+	return deletedRooms(hashData, h.cfg.TombstoneRetention, names), nil
+}
+
+// deletedRooms lists the unexpired tombstones of a tombstone hash, newest
+// first
+func deletedRooms(hashData map[string]string, retention time.Duration, names roomNamer) []DeletedRoom {
+	now := time.Now()
+	var rooms []DeletedRoom
+	for name, raw := range hashData {
+		t, ok := parseTombstone(raw, now, retention)
+		if !ok {
+			continue
+		}
+		rooms = append(rooms, DeletedRoom{Name: names(name), ID: t.ID, DeletedAt: time.Unix(t.DeletedAt, 0).UTC()})
+	}
+	sort.Slice(rooms, func(i, j int) bool {
+		if !rooms[i].DeletedAt.Equal(rooms[j].DeletedAt) {
+			return rooms[i].DeletedAt.After(rooms[j].DeletedAt)
+		}
+		return rooms[i].Name < rooms[j].Name
+	})
+	return rooms
+}
+
+// parseTombstone decodes a tombstone, reporting false for unreadable or
+// expired ones
+func parseTombstone(raw string, now time.Time, retention time.Duration) (tombstone, bool) {
+	var t tombstone
+	if err := json.Unmarshal([]byte(raw), &t); err != nil {
+		return t, false
+	}
+	return t, now.Sub(time.Unix(t.DeletedAt, 0)) < retention
+}
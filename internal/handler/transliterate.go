@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/mozillazg/go-unidecode"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Transliterator romanizes a run of text written in one script
+type Transliterator func(string) string
+
+// scriptTable describes a script that can be transliterated
+type scriptTable struct {
+	tables         []*unicode.RangeTable
+	transliterator Transliterator
+}
+
+// scripts maps the names accepted by ROOM_NAME_TRANSLITERATION to their
+// Unicode ranges. All default to unidecode's tables; CJK ideographs become
+// Mandarin pinyin without tones.
+var scripts = map[string]*scriptTable{
+	"cyrillic": {tables: []*unicode.RangeTable{unicode.Cyrillic}, transliterator: unidecode.Unidecode},
+	"greek":    {tables: []*unicode.RangeTable{unicode.Greek}, transliterator: unidecode.Unidecode},
+	"cjk":      {tables: []*unicode.RangeTable{unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul, kanaMarks}, transliterator: unidecode.Unidecode},
+}
+
+// kanaMarks are the prolonged sound and half-width voicing marks, which
+// Unicode files under the Common script although they only appear in kana
+var kanaMarks = &unicode.RangeTable{R16: []unicode.Range16{
+	{Lo: 0x30fc, Hi: 0x30fc, Stride: 1},
+	{Lo: 0xff70, Hi: 0xff70, Stride: 1},
+	{Lo: 0xff9e, Hi: 0xff9f, Stride: 1},
+}}
+
+// enabledScripts are consulted in order; nil disables transliteration
+var enabledScripts []*scriptTable
+
+// transliterationID names the enabled scripts for nameNormalization
+var transliterationID string
+
+// SetTransliterator replaces the transliterator of a script, e.g. with a
+// library that reads Japanese kanji as Japanese. Call it before
+// SetTransliteration.
+func SetTransliterator(script string, t Transliterator) error {
+	table, ok := scripts[script]
+	if !ok {
+		return fmt.Errorf("unknown script %q", script)
+	}
+	table.transliterator = t
+	return nil
+}
+
+// SetTransliteration romanizes room names written in the given scripts
+// (cyrillic, greek, cjk) before they are normalized. Set it before serving.
+func SetTransliteration(names []string) error {
+	tables := make([]*scriptTable, 0, len(names))
+	sorted := make([]string, 0, len(names))
+	for _, name := range names {
+		table, ok := scripts[name]
+		if !ok {
+			return fmt.Errorf("unknown script %q", name)
+		}
+		tables = append(tables, table)
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	if len(tables) == 0 {
+		tables = nil
+	}
+	enabledScripts, transliterationID = tables, strings.Join(sorted, "+")
+	return nil
+}
+
+// transliterate romanizes every run of text in an enabled script, separating
+// it from its neighbours with spaces. Other text is returned unchanged.
+func transliterate(s string) string {
+	if enabledScripts == nil || isASCII(s) {
+		return s
+	}
+	var b strings.Builder
+	var run strings.Builder
+	var current *scriptTable
+	flush := func() {
+		if current != nil && run.Len() > 0 {
+			b.WriteByte(' ')
+			// NFKC joins half-width kana with their voicing marks
+			b.WriteString(current.transliterator(norm.NFKC.String(run.String())))
+			b.WriteByte(' ')
+		}
+		run.Reset()
+		current = nil
+	}
+	for _, r := range s {
+		table := scriptOf(r)
+		if table != current {
+			flush()
+			current = table
+		}
+		if table == nil {
+			b.WriteRune(r)
+		} else {
+			run.WriteRune(r)
+		}
+	}
+	flush()
+	return b.String()
+}
+
+func scriptOf(r rune) *scriptTable {
+	if r < 0x370 {
+		return nil
+	}
+	for _, table := range enabledScripts {
+		for _, rt := range table.tables {
+			if unicode.Is(rt, r) {
+				return table
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"room-mapping-cache/internal/errs"
+	"room-mapping-cache/internal/keys"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HotelTTLResponse describes a hotel's room hash expiry
+type HotelTTLResponse struct {
+	HotelID string `json:"hotel_id"`
+	Key     string `json:"key"`
+	// TTLSeconds is the remaining lifetime; -1 means the hash never expires
+	TTLSeconds int64  `json:"ttl_seconds"`
+	ExpiresAt  string `json:"expires_at,omitempty"`
+	// PolicyTTL is the expiry the next write would set ("0s" = none)
+	PolicyTTL string   `json:"policy_ttl"`
+	Suppliers []string `json:"suppliers"`
+}
+
+// HotelTTLRequest changes a hotel's expiry: TTL sets the remaining lifetime
+// ("0" removes the expiry) and ExtendBy adds to it. Exactly one is required.
+type HotelTTLRequest struct {
+	TTL      string `json:"ttl"`
+	ExtendBy string `json:"extend_by"`
+}
+
+// HotelTTL serves GET /admin/hotels/:hotel_id/ttl
+func (h *AdminHandler) HotelTTL(c *gin.Context) {
+	ctx := c.Request.Context()
+	resp, err := h.hotelTTL(ctx, c.Param("hotel_id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// SetHotelTTL serves PUT /admin/hotels/:hotel_id/ttl. The new expiry also
+// applies to the hotel's version key; writes reapply the policy.
+func (h *AdminHandler) SetHotelTTL(c *gin.Context) {
+	var request HotelTTLRequest
+	if err := bindJSON(c, &request, h.roomHandler.cfg.MaxRequestBodyBytes); err != nil {
+		respondError(c, err)
+		return
+	}
+	if (request.TTL == "") == (request.ExtendBy == "") {
+		respondError(c, errs.New(errs.Invalid, "exactly one of ttl and extend_by is required"))
+		return
+	}
+	raw := request.TTL
+	if raw == "" {
+		raw = request.ExtendBy
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 || request.ExtendBy != "" && d == 0 {
+		respondError(c, errs.New(errs.Invalid, "ttl and extend_by must be durations such as 720h"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	hotelID := c.Param("hotel_id")
+	current, err := h.hotelTTL(ctx, hotelID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	ttl := d
+	if request.ExtendBy != "" {
+		if current.TTLSeconds < 0 {
+			respondError(c, errs.New(errs.Invalid, "hotel has no expiry to extend"))
+			return
+		}
+		ttl = time.Duration(current.TTLSeconds)*time.Second + d
+	}
+
+	for _, key := range []string{current.Key, keys.Version(hotelID)} {
+		if ttl > 0 {
+			err = h.redisClient.Expire(ctx, key, ttl)
+		} else {
+			err = h.redisClient.Persist(ctx, key)
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to set hotel TTL", "hotel_id", hotelID, "key", key, "error", err)
+			respondError(c, errs.Classify("failed to set TTL", err))
+			return
+		}
+	}
+	slog.InfoContext(ctx, "Hotel TTL changed", "hotel_id", hotelID, "ttl", ttl.String())
+
+	resp, err := h.hotelTTL(ctx, hotelID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// hotelTTL looks up the expiry of whichever room hash key the hotel uses
+func (h *AdminHandler) hotelTTL(ctx context.Context, hotelID string) (HotelTTLResponse, error) {
+	for _, key := range h.roomHandler.roomHashKeys(hotelID) {
+		pttl, err := h.redisClient.PTTL(ctx, key)
+		if err != nil {
+			return HotelTTLResponse{}, errs.Classify("failed to read TTL", err)
+		}
+		if pttl == -2 {
+			continue
+		}
+		hashData, err := h.redisClient.HGetAll(ctx, key)
+		if err != nil {
+			return HotelTTLResponse{}, errs.Classify("failed to read room mappings", err)
+		}
+
+		suppliers := hashSuppliers(hotelID, hashData)
+		resp := HotelTTLResponse{
+			HotelID:    hotelID,
+			Key:        key,
+			TTLSeconds: -1,
+			PolicyTTL:  h.roomHandler.cfg.HashTTL(suppliers).String(),
+			Suppliers:  suppliers,
+		}
+		if pttl >= 0 {
+			resp.TTLSeconds = int64(pttl / time.Second)
+			resp.ExpiresAt = time.Now().Add(pttl).UTC().Format(time.RFC3339)
+		}
+		return resp, nil
+	}
+	return HotelTTLResponse{}, &errs.Error{Kind: errs.NotFound, Code: errs.CodeHotelNotFound, Msg: "hotel has no room mappings"}
+}
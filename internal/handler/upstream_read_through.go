@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"room-mapping-cache/internal/keys"
+	"room-mapping-cache/internal/loader"
+	"room-mapping-cache/internal/metrics"
+)
+
+// readThrough answers a Redis miss from the upstream mapping API: the hotel is
+// written like an API write, expired within UpstreamReadThroughTTL and read
+// back. Any failure leaves the miss as it was, so the upstream being down
+// never fails a lookup.
+func (h *RoomHandler) readThrough(ctx context.Context, hotelID string, miss fetchResult) fetchResult {
+	if h.maintenance != nil && !h.maintenance.Writable() {
+		return miss
+	}
+	ctx, cancel := context.WithTimeout(ctx, h.cfg.UpstreamTimeout)
+	defer cancel()
+
+	hotels, err := h.fetchUpstreamHotel(ctx, hotelID, h.upstreamHeaders())
+	var status *loader.StatusError
+	if errors.As(err, &status) && status.Status == http.StatusNotFound || err == nil && len(hotels) == 0 {
+		metrics.UpstreamReadThroughs.WithLabelValues("missing").Inc()
+		return miss
+	}
+	if err == nil {
+		err = h.writeReadThrough(ctx, hotelID, hotels)
+	}
+	var res fetchResult
+	if err == nil {
+		res, err = h.fetchRoomsForHotel(ctx, hotelID)
+	}
+	if err != nil {
+		metrics.UpstreamReadThroughs.WithLabelValues("error").Inc()
+		slog.WarnContext(ctx, "Failed to read hotel through from upstream", "hotel_id", hotelID, "error", err)
+		return miss
+	}
+	metrics.UpstreamReadThroughs.WithLabelValues("ok").Inc()
+	slog.DebugContext(ctx, "Read hotel through from upstream", "hotel_id", hotelID, "rooms", len(res.rooms))
+	return res
+}
+
+// writeReadThrough writes fetched hotels and shortens the expiry the usual
+// hash TTL gave them to UpstreamReadThroughTTL
+func (h *RoomHandler) writeReadThrough(ctx context.Context, hotelID string, hotels []HotelRooms) error {
+	suppliers := make([]string, 0, len(hotels))
+	for i, err := range h.WriteHotels(ctx, hotels) {
+		if err != nil {
+			return err
+		}
+		suppliers = append(suppliers, strings.ToLower(strings.TrimSpace(hotels[i].Supplier)))
+	}
+	limit := h.cfg.UpstreamReadThroughTTL
+	if ttl := h.cfg.HashTTL(suppliers); limit <= 0 || ttl > 0 && ttl <= limit {
+		return nil
+	}
+	for _, key := range []string{keys.Room(hotelID), keys.Version(hotelID)} {
+		if err := h.redisClient.Expire(ctx, key, limit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
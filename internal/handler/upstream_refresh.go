@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"room-mapping-cache/internal/loader"
+	"room-mapping-cache/internal/metrics"
+)
+
+// RefreshFromUpstream pulls the configured hotels from the upstream mapping
+// API right away and then every UpstreamRefreshInterval until ctx is
+// cancelled. Fetched rooms are upserted like API writes; rooms the upstream
+// no longer returns are left to the supplier TTLs.
+func (h *RoomHandler) RefreshFromUpstream(ctx context.Context) {
+	headers := h.upstreamHeaders()
+	slog.Info("Refreshing room mappings from upstream", "url", h.cfg.UpstreamURL, "interval", h.cfg.UpstreamRefreshInterval)
+
+	ticker := time.NewTicker(h.cfg.UpstreamRefreshInterval)
+	defer ticker.Stop()
+	for {
+		if h.maintenance == nil || h.maintenance.Writable() {
+			h.refreshUpstream(ctx, headers)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// upstreamHeaders are the headers sent with every upstream request
+func (h *RoomHandler) upstreamHeaders() map[string]string {
+	headers := make(map[string]string, len(h.cfg.UpstreamHeaders)+2)
+	for name, value := range h.cfg.UpstreamHeaders {
+		headers[name] = value
+	}
+	headers["Accept"] = "application/json"
+	if h.cfg.UpstreamToken != "" {
+		headers["Authorization"] = "Bearer " + h.cfg.UpstreamToken
+	}
+	return headers
+}
+
+// refreshUpstream runs one pass over the hotel list
+func (h *RoomHandler) refreshUpstream(ctx context.Context, headers map[string]string) {
+	started := time.Now()
+	ids, err := h.collectHotelIDs(ctx, h.cfg.UpstreamHotelIDs, h.cfg.UpstreamHotelIDsFile, h.cfg.UpstreamHotelIDsSet)
+	if err != nil {
+		slog.Error("Failed to load upstream hotel list", "error", err)
+		return
+	}
+
+	var (
+		wg        sync.WaitGroup
+		next      atomic.Int64
+		refreshed atomic.Int64
+	)
+	for w := 0; w < min(h.cfg.UpstreamConcurrency, len(ids)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				i := int(next.Add(1) - 1)
+				if i >= len(ids) {
+					return
+				}
+				if err := h.refreshHotel(ctx, ids[i], headers); err != nil {
+					metrics.UpstreamRefreshes.WithLabelValues("error").Inc()
+					slog.Warn("Failed to refresh hotel from upstream", "hotel_id", ids[i], "error", err)
+					continue
+				}
+				metrics.UpstreamRefreshes.WithLabelValues("ok").Inc()
+				refreshed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	slog.Info("Upstream refresh finished", "hotels", len(ids), "refreshed", refreshed.Load(),
+		"failed", len(ids)-int(refreshed.Load()), "duration", time.Since(started).Round(time.Millisecond).String())
+}
+
+// refreshHotel fetches one hotel and writes what the upstream returned. A
+// response with any bad record is not written at all.
+func (h *RoomHandler) refreshHotel(ctx context.Context, hotelID string, headers map[string]string) error {
+	ctx, cancel := context.WithTimeout(ctx, h.cfg.UpstreamTimeout)
+	defer cancel()
+
+	hotels, err := h.fetchUpstreamHotel(ctx, hotelID, headers)
+	if err != nil {
+		return err
+	}
+	for _, err := range h.WriteHotels(ctx, hotels) {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetchUpstreamHotel fetches one hotel's records from the upstream API,
+// failing on any bad record
+func (h *RoomHandler) fetchUpstreamHotel(ctx context.Context, hotelID string, headers map[string]string) ([]HotelRooms, error) {
+	source := strings.ReplaceAll(h.cfg.UpstreamURL, "{hotel_id}", url.PathEscape(hotelID))
+	body, err := loader.OpenSource(ctx, source, headers)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var (
+		hotels []HotelRooms
+		bad    error
+	)
+	err = loader.ReadDump(body, false,
+		func(rec loader.Record) error {
+			if rec.HotelID != hotelID {
+				return fmt.Errorf("upstream returned hotel %q", rec.HotelID)
+			}
+			hotels = append(hotels, HotelRooms(rec))
+			return nil
+		},
+		func(e *loader.RecordError) {
+			if bad == nil {
+				bad = e
+			}
+		})
+	if err == nil {
+		err = bad
+	}
+	if err != nil {
+		return nil, fmt.Errorf("bad upstream response: %w", err)
+	}
+	return hotels, nil
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"room-mapping-cache/internal/keys"
+	"room-mapping-cache/internal/redis"
+)
+
+// hotelVersion is the per-hotel write counter stored in room_map_version:{id}.
+// It shares the hotel's hash slot, so room reads pipeline it with the room
+// hash.
+type hotelVersion struct {
+	Version   int64
+	UpdatedAt time.Time
+}
+
+// bumpHotelVersion increments the hotel's version and stamps updated_at
+func bumpHotelVersion(ctx context.Context, client redis.HashWriter, hotelID string) (hotelVersion, error) {
+	now := time.Now().UTC()
+	key := keys.Version(hotelID)
+
+	version, err := client.HIncrByAndSet(ctx, key, "version", 1, map[string]interface{}{"updated_at": now.Format(time.RFC3339)})
+	if err != nil {
+		return hotelVersion{}, err
+	}
+	return hotelVersion{Version: version, UpdatedAt: now}, nil
+}
+
+// resultVersion returns the version read along with res, or reads it on its
+// own when it wasn't. It reports whether the version is known.
+func (h *RoomHandler) resultVersion(ctx context.Context, hotelID string, res fetchResult) (hotelVersion, bool) {
+	if res.versionRead {
+		return res.version, true
+	}
+	version, err := h.fetchHotelVersion(ctx, hotelID)
+	if err != nil && err != errRedisDegraded {
+		slog.ErrorContext(ctx, "Failed to fetch version", "hotel_id", hotelID, "error", err)
+	}
+	return version, err == nil
+}
+
+func (h *RoomHandler) fetchHotelVersion(ctx context.Context, hotelID string) (hotelVersion, error) {
+	if RedisDegraded() {
+		return hotelVersion{}, errRedisDegraded
+	}
+	if h.coalescer != nil {
+		cmds, err := h.readHashes(ctx, []string{keys.Version(hotelID)})
+		if cmds == nil {
+			return hotelVersion{}, err
+		}
+		hashData, err := cmds[0].Result()
+		if err != nil {
+			return hotelVersion{}, err
+		}
+		return parseHotelVersion(hashData), nil
+	}
+	hashData, err := h.redisClient.HGetAll(ctx, keys.Version(hotelID))
+	if err != nil {
+		return hotelVersion{}, err
+	}
+	return parseHotelVersion(hashData), nil
+}
+
+func versionFromCmd(cmd *redis.HashResult) hotelVersion {
+	hashData, err := cmd.Result()
+	if err != nil {
+		return hotelVersion{}
+	}
+	return parseHotelVersion(hashData)
+}
+
+func parseHotelVersion(hashData map[string]string) hotelVersion {
+	var v hotelVersion
+	v.Version, _ = strconv.ParseInt(hashData["version"], 10, 64)
+	v.UpdatedAt, _ = time.Parse(time.RFC3339, hashData["updated_at"])
+	return v
+}
+
+// apply copies the version fields onto a response; unversioned hotels are left blank
+func (v hotelVersion) apply(resp *RoomMappingsResponse) {
+	if v.Version == 0 {
+		return
+	}
+	resp.Version = v.Version
+	resp.UpdatedAt = v.UpdatedAt.Format(time.RFC3339)
+}
+
+// notModifiedSince reports whether a conditional batch lookup already holds
+// this version of hotelID. Unversioned hotels always count as modified.
+func (v hotelVersion) notModifiedSince(knownVersions map[string]int64, hotelID string) bool {
+	known, ok := knownVersions[hotelID]
+	return ok && v.Version > 0 && v.Version <= known
+}
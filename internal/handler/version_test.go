@@ -0,0 +1,41 @@
+package handler_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"room-mapping-cache/testutil"
+)
+
+// A lookup reads the version with the rooms; a delta poll reads it alone
+func TestLookupVersion(t *testing.T) {
+	for _, settings := range [][]string{nil, {"LARGE_HASH_THRESHOLD=100"}} {
+		srv := testutil.NewServer(t, settings...)
+		srv.PutHotel("1001", "acme", map[string]int64{"Double": 1})
+		srv.PutHotel("1001", "acme", map[string]int64{"Twin": 2})
+
+		var body struct {
+			Version     int64           `json:"version"`
+			UpdatedAt   string          `json:"updated_at"`
+			NotModified bool            `json:"not_modified"`
+			Rooms       []testutil.Room `json:"rooms"`
+		}
+		srv.Get("/room-mappings/1001").ExpectStatus(http.StatusOK).JSON(&body)
+		if body.Version != 2 || body.UpdatedAt == "" || len(body.Rooms) != 2 {
+			t.Fatalf("%v: lookup got version %d updated_at %q with %d rooms, want version 2 with 2 rooms", settings, body.Version, body.UpdatedAt, len(body.Rooms))
+		}
+
+		version := body.Version
+		body.Rooms = nil
+		srv.Get("/room-mappings/1001?if_version_gt=" + strconv.FormatInt(version, 10)).ExpectStatus(http.StatusOK).JSON(&body)
+		if !body.NotModified || len(body.Rooms) != 0 {
+			t.Errorf("%v: up-to-date poll got not_modified %v with %d rooms", settings, body.NotModified, len(body.Rooms))
+		}
+		body.NotModified = false
+		srv.Get("/room-mappings/1001?if_version_gt=1").ExpectStatus(http.StatusOK).JSON(&body)
+		if body.NotModified || len(body.Rooms) != 2 {
+			t.Errorf("%v: stale poll got not_modified %v with %d rooms", settings, body.NotModified, len(body.Rooms))
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"room-mapping-cache/internal/keys"
+	"room-mapping-cache/internal/redis"
+)
+
+// warmupChunkSize bounds each warm-up pipeline, matching the batch endpoint cap
+const warmupChunkSize = 100
+
+// selfTestHotelID is looked up when there are no warm-up hotels; a missing
+// hotel exercises the read path just as well
+const selfTestHotelID = "readiness-self-test"
+
+// WarmupHotelIDs collects the configured hot-hotel list from env, file and
+// Redis set sources, deduplicated.
+func (h *RoomHandler) WarmupHotelIDs(ctx context.Context) ([]string, error) {
+	return h.collectHotelIDs(ctx, h.cfg.WarmupHotelIDs, h.cfg.WarmupFile, h.cfg.WarmupRedisSet)
+}
+
+// collectHotelIDs combines a list of hotel IDs, a newline-separated file (with
+// # comments) and a Redis set, deduplicated. Empty sources are skipped.
+func (h *RoomHandler) collectHotelIDs(ctx context.Context, list []string, file, set string) ([]string, error) {
+	ids := append([]string(nil), list...)
+
+	if file != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+				ids = append(ids, line)
+			}
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+	}
+
+	if set != "" {
+		members, err := h.redisClient.SMembers(ctx, set)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read set %s: %w", set, err)
+		}
+		ids = append(ids, members...)
+	}
+
+	return dedupStringsInPlace(ids), nil
+}
+
+// WarmUp loads the given hotels into the local cache in pipelined chunks and
+// returns how many were cached.
+func (h *RoomHandler) WarmUp(ctx context.Context, hotelIDs []string) (int, error) {
+	if h.hotelCache == nil {
+		return 0, nil
+	}
+
+	loaded := 0
+	for start := 0; start < len(hotelIDs); start += warmupChunkSize {
+		end := min(start+warmupChunkSize, len(hotelIDs))
+		chunk := hotelIDs[start:end]
+
+		hashKeys := make([]string, 0, 3*len(chunk))
+		for _, hotelID := range chunk {
+			hashKeys = append(hashKeys, keys.Room(hotelID), keys.Version(hotelID))
+			if h.cfg.RoomKeyFallback {
+				hashKeys = append(hashKeys, keys.RoomFallback(hotelID))
+			}
+		}
+		results, err := h.redisClient.HGetAllMulti(ctx, hashKeys)
+		if err != nil && ctx.Err() != nil {
+			return loaded, err
+		}
+		stride := len(hashKeys) / len(chunk)
+		primaryCmds := make([]*redis.HashResult, len(chunk))
+		fallbackCmds := make([]*redis.HashResult, len(chunk))
+		versionCmds := make([]*redis.HashResult, len(chunk))
+		for i := range chunk {
+			primaryCmds[i], versionCmds[i] = results[stride*i], results[stride*i+1]
+			if h.cfg.RoomKeyFallback {
+				fallbackCmds[i] = results[stride*i+2]
+			}
+		}
+
+		for i, hotelID := range chunk {
+			variant := keyVariantHashtag
+			hashData, err := primaryCmds[i].Result()
+			if err != nil || len(hashData) == 0 {
+				if fallbackCmds[i] == nil {
+					continue
+				}
+				variant = keyVariantPlain
+				if hashData, err = fallbackCmds[i].Result(); err != nil || len(hashData) == 0 {
+					continue
+				}
+			}
+			rooms, truncated := h.parseRooms(hotelID, hashData)
+			h.cacheHotel(hotelID, cachedHotel{
+				Rooms:     rooms,
+				Truncated: truncated,
+				Variant:   variant,
+				Version:   versionFromCmd(versionCmds[i]),
+			})
+			loaded++
+		}
+	}
+	return loaded, nil
+}
+
+// SelfTest runs lookups through the Redis read path, bypassing the local
+// cache and cycling through hotelIDs, until n have succeeded. Failed lookups
+// are retried after a pause until ctx ends.
+func (h *RoomHandler) SelfTest(ctx context.Context, hotelIDs []string, n int) error {
+	if len(hotelIDs) == 0 {
+		hotelIDs = []string{selfTestHotelID}
+	}
+	for ok, i := 0, 0; ok < n; i++ {
+		hotelID := hotelIDs[i%len(hotelIDs)]
+		if _, err := h.fetchRoomsFromHash(ctx, hotelID, normalizeRoomName); err != nil {
+			slog.Warn("Readiness self-test lookup failed", "hotel_id", hotelID, "error", err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(500 * time.Millisecond):
+			}
+			continue
+		}
+		ok++
+	}
+	return nil
+}
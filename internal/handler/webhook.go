@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"room-mapping-cache/internal/errs"
+	"room-mapping-cache/internal/hotelid"
+	"room-mapping-cache/internal/keys"
+	"room-mapping-cache/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Webhook change types and the update ops they map to
+var webhookOps = map[string]string{
+	"rooms.upserted": streamOpHSet,
+	"rooms.removed":  streamOpHDel,
+	"hotel.deleted":  streamOpDel,
+}
+
+// WebhookEvent is a mapping provider change notification. Unknown fields are
+// ignored so the provider can extend its payload.
+type WebhookEvent struct {
+	ID      string          `json:"id"`
+	Changes []WebhookChange `json:"changes"`
+}
+
+// WebhookChange is one change in a WebhookEvent. Rooms is an object of room
+// name to properties for rooms.upserted, an array of room names for
+// rooms.removed, and absent for hotel.deleted.
+type WebhookChange struct {
+	Type     string          `json:"type"`
+	HotelID  string          `json:"hotel_id"`
+	Supplier string          `json:"supplier,omitempty"`
+	Rooms    json.RawMessage `json:"rooms,omitempty"`
+}
+
+// MappingWebhook serves POST /webhooks/mapping-updates. The signature is
+// checked by middleware. Each event ID is applied once; redeliveries are
+// acknowledged without applying again. If a change fails to apply the event
+// is forgotten, so the provider's retry applies it in full.
+func (h *RoomHandler) MappingWebhook(c *gin.Context) {
+	ctx := c.Request.Context()
+	var event WebhookEvent
+	if err := json.NewDecoder(c.Request.Body).Decode(&event); err != nil {
+		metrics.WebhookEvents.WithLabelValues("invalid").Inc()
+		respondError(c, errs.Wrap(errs.Invalid, "invalid webhook payload", err))
+		return
+	}
+	updates, err := webhookUpdates(event)
+	if err != nil {
+		metrics.WebhookEvents.WithLabelValues("invalid").Inc()
+		respondError(c, err)
+		return
+	}
+
+	eventKey := keys.WebhookEvent(event.ID)
+	first, err := h.redisClient.SetNX(ctx, eventKey, "1", h.cfg.WebhookDedupeTTL)
+	if err != nil {
+		metrics.WebhookEvents.WithLabelValues("error").Inc()
+		respondError(c, errs.Classify("failed to record webhook event", err))
+		return
+	}
+	if !first {
+		metrics.WebhookEvents.WithLabelValues("duplicate").Inc()
+		slog.InfoContext(ctx, "Dropping redelivered webhook event", "event_id", event.ID)
+		c.JSON(http.StatusOK, gin.H{"event_id": event.ID, "status": "duplicate"})
+		return
+	}
+
+	for i, u := range updates {
+		if err := h.ApplyUpdate(ctx, u); err != nil {
+			outcome := "error"
+			if errs.KindOf(err) == errs.Invalid {
+				outcome = "invalid"
+			}
+			if delErr := h.redisClient.Del(ctx, eventKey); delErr != nil {
+				slog.ErrorContext(ctx, "Failed to forget webhook event", "event_id", event.ID, "error", delErr)
+			}
+			metrics.WebhookEvents.WithLabelValues(outcome).Inc()
+			slog.ErrorContext(ctx, "Failed to apply webhook change", "event_id", event.ID, "change", i,
+				"hotel_id", u.HotelID, "error", err)
+			respondError(c, err)
+			return
+		}
+	}
+
+	metrics.WebhookEvents.WithLabelValues("applied").Inc()
+	slog.InfoContext(ctx, "Applied webhook event", "event_id", event.ID, "changes", len(updates))
+	c.JSON(http.StatusOK, gin.H{"event_id": event.ID, "status": "applied", "changes": len(updates)})
+}
+
+// webhookUpdates translates an event into updates, rejecting the whole event
+// if any change has an unknown type or no hotel
+func webhookUpdates(event WebhookEvent) ([]MappingUpdate, error) {
+	if strings.TrimSpace(event.ID) == "" {
+		return nil, &errs.Error{Kind: errs.Invalid, Field: "id", Msg: "event id is required"}
+	}
+	if len(event.Changes) == 0 {
+		return nil, &errs.Error{Kind: errs.Invalid, Field: "changes", Msg: "event has no changes"}
+	}
+	updates := make([]MappingUpdate, len(event.Changes))
+	for i, change := range event.Changes {
+		op, ok := webhookOps[change.Type]
+		if !ok {
+			return nil, errs.New(errs.Invalid, fmt.Sprintf("change %d: unknown type %q", i, change.Type))
+		}
+		if _, err := hotelid.Canonical(change.HotelID); err != nil {
+			return nil, &errs.Error{Kind: errs.Invalid, Code: errs.CodeOf(err), Field: "hotel_id", Msg: fmt.Sprintf("change %d: %v", i, err)}
+		}
+		updates[i] = MappingUpdate{Op: op, HotelID: change.HotelID, Supplier: change.Supplier, Rooms: change.Rooms}
+	}
+	return updates, nil
+}
@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"room-mapping-cache/internal/errs"
+	"room-mapping-cache/internal/keys"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RoomMappingsWriteRequest upserts room entries for one supplier. Room values
+// are stored as-is apart from the supplier and updated_at bookkeeping fields.
+type RoomMappingsWriteRequest struct {
+	Supplier string                            `json:"supplier" binding:"required"`
+	Rooms    map[string]map[string]interface{} `json:"rooms" binding:"required"`
+}
+
+type RoomMappingsWriteResponse struct {
+	HotelID   string `json:"hotel_id"`
+	Written   int    `json:"written"`
+	Version   int64  `json:"version"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// PutRoomMappings upserts supplier room entries into the hotel's hash and
+// applies the supplier TTL policy to the key.
+func (h *RoomHandler) PutRoomMappings(c *gin.Context) {
+	hotelID, err := h.hotelParam(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	var request RoomMappingsWriteRequest
+	if err := bindJSON(c, &request, h.cfg.MaxRequestBodyBytes); err != nil {
+		respondError(c, err)
+		return
+	}
+	supplier := strings.ToLower(strings.TrimSpace(request.Supplier))
+	if supplier == "" || len(request.Rooms) == 0 {
+		respondError(c, errs.New(errs.Invalid, "supplier and at least one room are required"))
+		return
+	}
+
+	fields, err := encodeRoomFields(hotelID, supplier, request.Rooms)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	if err := h.redisClient.HSet(ctx, keys.Room(hotelID), fields); err != nil {
+		slog.ErrorContext(ctx, "Failed to write room mappings", "hotel_id", hotelID, "error", err)
+		respondError(c, errs.Classify("failed to write room mappings", err))
+		return
+	}
+	h.clearTombstones(ctx, hotelID, fields)
+	version := h.afterWrite(ctx, hotelID)
+
+	c.JSON(http.StatusOK, RoomMappingsWriteResponse{
+		HotelID:   c.Param("hotel_id"),
+		Written:   len(fields),
+		Version:   version.Version,
+		UpdatedAt: version.UpdatedAt.Format(time.RFC3339),
+	})
+}
+
+// encodeRoomFields stamps each room with the supplier and write time and
+// serializes (and encrypts, if configured) it as a field value of the hotel's
+// room hash.
+func encodeRoomFields(hotelID, supplier string, rooms map[string]map[string]interface{}) (map[string]interface{}, error) {
+	now := time.Now().Unix()
+	fields := make(map[string]interface{}, len(rooms))
+	for name, value := range rooms {
+		if strings.TrimSpace(name) == "" {
+			return nil, errs.New(errs.Invalid, "room names must not be empty")
+		}
+		if value == nil {
+			value = map[string]interface{}{}
+		}
+		value["supplier"] = supplier
+		value["updated_at"] = now
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, errs.New(errs.Invalid, fmt.Sprintf("invalid room %q", name))
+		}
+		stored := string(raw)
+		if valueKeyring != nil {
+			if stored, err = valueKeyring.Encrypt(stored, valueAAD(hotelID, name)); err != nil {
+				return nil, errs.Wrap(errs.Internal, "failed to encrypt room value", err)
+			}
+		}
+		fields[name] = stored
+	}
+	return fields, nil
+}
+
+// AfterWrite runs afterWrite for a hotel changed outside the handler, such
+// as by the supplier expiry job
+func (h *RoomHandler) AfterWrite(ctx context.Context, hotelID string) {
+	h.afterWrite(ctx, hotelID)
+}
+
+// afterWrite runs the bookkeeping every write to a hotel's room hash needs:
+// TTL, version bump, snapshot, normalized copy and cache invalidation.
+// Failures are logged rather than returned since the write itself already
+// succeeded.
+func (h *RoomHandler) afterWrite(ctx context.Context, hotelID string) hotelVersion {
+	ttl, err := h.applyHashTTL(ctx, keys.Room(hotelID))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to apply hash TTL", "hotel_id", hotelID, "error", err)
+	}
+
+	version, err := bumpHotelVersion(ctx, h.redisClient, hotelID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to bump version", "hotel_id", hotelID, "error", err)
+	} else {
+		if err := saveSnapshot(ctx, h.redisClient, hotelID, version.Version, h.cfg.SnapshotVersions, ttl); err != nil {
+			slog.ErrorContext(ctx, "Failed to snapshot version", "hotel_id", hotelID, "version", version.Version, "error", err)
+		}
+		// The version goes with the hotel rather than outliving it
+		if ttl > 0 {
+			if err := h.redisClient.Expire(ctx, keys.Version(hotelID), ttl); err != nil {
+				slog.ErrorContext(ctx, "Failed to expire version", "hotel_id", hotelID, "error", err)
+			}
+		}
+	}
+	if h.cfg.NormalizedRooms {
+		h.rebuildNormalized(ctx, hotelID)
+	}
+	if h.cfg.TokenIndex {
+		h.rebuildTokenIndex(ctx, hotelID)
+	}
+	h.invalidateEverywhere(ctx, hotelID)
+	return version
+}
+
+// applyHashTTL sets the key expiry from the suppliers present in the hash
+// (see config.HashTTL), or persists it, and returns the TTL applied. Individual
+// stale rooms are removed by the supplier expiry job.
+func (h *RoomHandler) applyHashTTL(ctx context.Context, key string) (time.Duration, error) {
+	hashData, err := h.redisClient.HGetAll(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	if len(hashData) == 0 {
+		return 0, nil
+	}
+
+	hotelID, _ := keys.HotelID(key)
+	ttl := h.cfg.HashTTL(hashSuppliers(hotelID, hashData))
+	if ttl <= 0 {
+		return 0, h.redisClient.Persist(ctx, key)
+	}
+	return ttl, h.redisClient.Expire(ctx, key, ttl)
+}
+
+// hashSuppliers lists the distinct suppliers of a room hash's values.
+// Unreadable values count as an unknown ("") supplier.
+func hashSuppliers(hotelID string, hashData map[string]string) []string {
+	seen := make(map[string]bool)
+	var suppliers []string
+	for name, raw := range hashData {
+		var meta struct {
+			Supplier string `json:"supplier"`
+		}
+		if plain, err := valueKeyring.Decrypt(raw, valueAAD(hotelID, name)); err == nil {
+			_ = json.Unmarshal([]byte(plain), &meta)
+		}
+		if !seen[meta.Supplier] {
+			seen[meta.Supplier] = true
+			suppliers = append(suppliers, meta.Supplier)
+		}
+	}
+	return suppliers
+}
@@ -0,0 +1,107 @@
+// Package hotelid validates and canonicalizes hotel IDs where they enter the
+// service (path parameters, batch bodies, webhooks and the update stream), so
+// " 1001", "#1001" and "1001" can name the same hotel and malformed IDs are
+// refused before they reach a Redis key.
+package hotelid
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"room-mapping-cache/internal/errs"
+)
+
+// HOTEL_ID_CASE values
+const (
+	CasePreserve = "preserve"
+	CaseLower    = "lower"
+	CaseUpper    = "upper"
+)
+
+// Rules configures validation and canonicalization
+type Rules struct {
+	// Numeric accepts only IDs made of ASCII digits
+	Numeric bool
+	// MaxLength is the longest ID accepted, in bytes; 0 means no limit
+	MaxLength int
+	// AllowedChars is the body of a regexp character class, e.g. "A-Za-z0-9_-",
+	// that every character must match; empty accepts any printable character
+	// except whitespace and the hash tag braces
+	AllowedChars string
+	// StripHash removes leading "#" characters, as in "#1001"
+	StripHash bool
+	// Case folds IDs to CaseLower or CaseUpper; CasePreserve keeps them
+	Case string
+}
+
+type compiled struct {
+	Rules
+	allowed *regexp.Regexp
+}
+
+var current = compiled{Rules: Rules{Case: CasePreserve}}
+
+// Configure sets the rules Canonical applies. It must be called before
+// serving traffic.
+func Configure(r Rules) error {
+	c := compiled{Rules: r}
+	switch r.Case {
+	case "":
+		c.Case = CasePreserve
+	case CasePreserve, CaseLower, CaseUpper:
+	default:
+		return fmt.Errorf("case must be preserve, lower or upper, got %q", r.Case)
+	}
+	if r.AllowedChars != "" {
+		re, err := regexp.Compile("^[" + r.AllowedChars + "]+$")
+		if err != nil {
+			return fmt.Errorf("allowed characters %q: %w", r.AllowedChars, err)
+		}
+		c.allowed = re
+	}
+	current = c
+	return nil
+}
+
+// Canonical returns the canonical form of a hotel ID sent by a caller, or an
+// errs.Invalid error saying why it is not acceptable
+func Canonical(raw string) (string, error) {
+	r := current
+	id := strings.TrimSpace(raw)
+	if r.StripHash {
+		id = strings.TrimSpace(strings.TrimLeft(id, "#"))
+	}
+	switch r.Case {
+	case CaseLower:
+		id = strings.ToLower(id)
+	case CaseUpper:
+		id = strings.ToUpper(id)
+	}
+
+	switch {
+	case id == "":
+		return "", invalid(raw, "hotel_id is required")
+	case r.MaxLength > 0 && len(id) > r.MaxLength:
+		return "", invalid(raw, fmt.Sprintf("hotel_id must be at most %d characters", r.MaxLength))
+	case r.Numeric && strings.Trim(id, "0123456789") != "":
+		return "", invalid(raw, "hotel_id must be numeric")
+	case r.allowed != nil && !r.allowed.MatchString(id):
+		return "", invalid(raw, fmt.Sprintf("hotel_id may only contain [%s]", r.AllowedChars))
+	case r.allowed == nil && strings.IndexFunc(id, unacceptable) >= 0:
+		return "", invalid(raw, "hotel_id must not contain whitespace, control characters or braces")
+	}
+	return id, nil
+}
+
+// unacceptable reports runes refused without AllowedChars: braces would break
+// the key's hash tag
+func unacceptable(r rune) bool {
+	return r == '{' || r == '}' || unicode.IsSpace(r) || !unicode.IsPrint(r)
+}
+
+func invalid(raw, msg string) error {
+	return &errs.Error{Kind: errs.Invalid, Code: errs.CodeInvalidHotelID, Field: "hotel_id", Msg: msg,
+		Details: map[string]any{"hotel_id": raw}}
+}
@@ -0,0 +1,236 @@
+// Package idempotency lets callers retry writes safely. A request carrying an
+// Idempotency-Key is applied once; retries with the same key and payload get
+// the stored response back instead of being applied again.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"room-mapping-cache/internal/audit"
+	"room-mapping-cache/internal/errs"
+	"room-mapping-cache/internal/keys"
+	"room-mapping-cache/internal/logging"
+	"room-mapping-cache/internal/metrics"
+	"room-mapping-cache/internal/redis"
+	"room-mapping-cache/internal/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Header carries the caller's key for a write
+const Header = "Idempotency-Key"
+
+// ReplayedHeader is set on responses served from a stored result
+const ReplayedHeader = "Idempotent-Replayed"
+
+// maxKeyLen bounds Idempotency-Key; UUIDs and ULIDs fit comfortably
+const maxKeyLen = 255
+
+// storeTimeout bounds saving the response, which happens after the request
+// deadline may have passed
+const storeTimeout = time.Second
+
+// record is what is kept under a key: the request fingerprint, and once the
+// request completed, its response
+type record struct {
+	Fingerprint     string `json:"fingerprint"`
+	Done            bool   `json:"done,omitempty"`
+	Status          int    `json:"status,omitempty"`
+	ContentType     string `json:"content_type,omitempty"`
+	ContentEncoding string `json:"content_encoding,omitempty"`
+	Body            []byte `json:"body,omitempty"`
+}
+
+// Store keeps responses to keyed writes in Redis
+type Store struct {
+	redis redis.KeyValue
+	// ttl is how long completed responses are replayed
+	ttl time.Duration
+	// pendingTTL releases the key of a request that never completed, e.g.
+	// because the process died while applying it
+	pendingTTL time.Duration
+	// maxBody bounds how much of a request body is fingerprinted
+	maxBody int64
+}
+
+func NewStore(redisClient redis.KeyValue, ttl, pendingTTL time.Duration, maxBody int64) *Store {
+	return &Store{redis: redisClient, ttl: ttl, pendingTTL: pendingTTL, maxBody: maxBody}
+}
+
+// Middleware applies each keyed request once per caller. A retry while the
+// first attempt is still running gets 409; reusing a key for a different
+// request gets 400. Requests without the header pass through untouched.
+// Responses that invite a retry (5xx, 408, 429) are not stored, so the retry
+// is applied afresh.
+func (s *Store) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(Header)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if !validKey(key) {
+			reject(c, &errs.Error{Kind: errs.Invalid, Field: Header, Msg: Header + " must be 1-255 letters, digits or -_.:"})
+			return
+		}
+		ctx := c.Request.Context()
+
+		fingerprint, err := s.fingerprint(c)
+		if err != nil {
+			metrics.IdempotentRequests.WithLabelValues("error").Inc()
+			reject(c, errs.Wrap(errs.Invalid, "failed to read request body", err))
+			return
+		}
+		redisKey := keys.Idempotency(caller(c), key)
+		pending, _ := json.Marshal(record{Fingerprint: fingerprint})
+		claimed, err := s.redis.SetNX(ctx, redisKey, string(pending), s.pendingTTL)
+		if err != nil {
+			metrics.IdempotentRequests.WithLabelValues("error").Inc()
+			reject(c, errs.Classify("failed to check idempotency key", err))
+			return
+		}
+		if !claimed {
+			s.replay(c, redisKey, fingerprint)
+			return
+		}
+
+		rec := &recorder{ResponseWriter: c.Writer}
+		c.Writer = rec
+		c.Next()
+
+		storeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), storeTimeout)
+		defer cancel()
+		status := c.Writer.Status()
+		if status >= 500 || status == http.StatusRequestTimeout || status == http.StatusTooManyRequests {
+			metrics.IdempotentRequests.WithLabelValues("released").Inc()
+			if err := s.redis.Del(storeCtx, redisKey); err != nil {
+				slog.ErrorContext(ctx, "Failed to release idempotency key", "error", err)
+			}
+			return
+		}
+		metrics.IdempotentRequests.WithLabelValues("stored").Inc()
+		done, _ := json.Marshal(record{
+			Fingerprint:     fingerprint,
+			Done:            true,
+			Status:          status,
+			ContentType:     c.Writer.Header().Get("Content-Type"),
+			ContentEncoding: c.Writer.Header().Get("Content-Encoding"),
+			Body:            rec.body.Bytes(),
+		})
+		if err := s.redis.Set(storeCtx, redisKey, string(done), s.ttl); err != nil {
+			// The pending record expires, after which a retry applies again
+			slog.ErrorContext(ctx, "Failed to store idempotent response", "error", err)
+		}
+	}
+}
+
+// replay answers a request whose key was already claimed
+func (s *Store) replay(c *gin.Context, redisKey, fingerprint string) {
+	raw, err := s.redis.Get(c.Request.Context(), redisKey)
+	if errors.Is(err, redis.Nil) {
+		// Released between the two calls, or not yet on the replica read
+		raw, err = "", nil
+	}
+	if err != nil {
+		metrics.IdempotentRequests.WithLabelValues("error").Inc()
+		reject(c, errs.Classify("failed to check idempotency key", err))
+		return
+	}
+	var rec record
+	if raw != "" {
+		if err := json.Unmarshal([]byte(raw), &rec); err != nil {
+			metrics.IdempotentRequests.WithLabelValues("error").Inc()
+			reject(c, errs.Wrap(errs.Internal, "corrupt idempotency record", err))
+			return
+		}
+	}
+	switch {
+	case raw != "" && rec.Fingerprint != fingerprint:
+		metrics.IdempotentRequests.WithLabelValues("mismatch").Inc()
+		reject(c, &errs.Error{Kind: errs.Invalid, Field: Header, Msg: Header + " was already used for a different request"})
+	case !rec.Done:
+		metrics.IdempotentRequests.WithLabelValues("in_progress").Inc()
+		c.Header("Retry-After", "1")
+		reject(c, errs.New(errs.Conflict, "a request with this "+Header+" is in progress"))
+	default:
+		metrics.IdempotentRequests.WithLabelValues("replayed").Inc()
+		c.Header(ReplayedHeader, "true")
+		if rec.ContentEncoding != "" {
+			c.Header("Content-Encoding", rec.ContentEncoding)
+		}
+		c.Data(rec.Status, rec.ContentType, rec.Body)
+		c.Abort()
+	}
+}
+
+// fingerprint hashes the method, path and body, restoring the body for the
+// handler
+func (s *Store) fingerprint(c *gin.Context) (string, error) {
+	h := sha256.New()
+	io.WriteString(h, c.Request.Method+" "+c.Request.URL.Path+"\n")
+	if c.Request.Body != nil && c.Request.ContentLength != 0 {
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, s.maxBody))
+		if err != nil {
+			return "", err
+		}
+		h.Write(body)
+		c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), c.Request.Body))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// caller scopes keys to the tenant and authenticated caller, so one caller's
+// key cannot replay another's response
+func caller(c *gin.Context) string {
+	if t := tenant.From(c); t != "" {
+		return t + "/" + audit.Subject(c)
+	}
+	return audit.Subject(c)
+}
+
+func validKey(key string) bool {
+	if len(key) > maxKeyLen {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		switch b := key[i]; {
+		case b >= 'a' && b <= 'z', b >= 'A' && b <= 'Z', b >= '0' && b <= '9':
+		case b == '-', b == '_', b == '.', b == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func reject(c *gin.Context, e *errs.Error) {
+	if e.Err != nil {
+		slog.WarnContext(c.Request.Context(), "Idempotency check failed", "reason", e.Msg, "error", e.Err)
+	}
+	logging.AbortWithError(c, e)
+}
+
+// recorder keeps a copy of the response body
+type recorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *recorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
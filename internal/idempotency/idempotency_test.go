@@ -0,0 +1,50 @@
+package idempotency
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"room-mapping-cache/internal/redis"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := redis.NewFake()
+	applied := 0
+	r := gin.New()
+	r.PUT("/room-mappings/:hotel_id", NewStore(store, time.Hour, time.Minute, 1<<20).Middleware(), func(c *gin.Context) {
+		applied++
+		c.JSON(http.StatusOK, gin.H{"applied": applied})
+	})
+
+	send := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/room-mappings/1001", strings.NewReader(body))
+		req.Header.Set(Header, key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	first := send("k1", `{"rooms":{}}`)
+	if first.Code != http.StatusOK || applied != 1 {
+		t.Fatalf("first request: status %d, applied %d times", first.Code, applied)
+	}
+	retry := send("k1", `{"rooms":{}}`)
+	if retry.Code != http.StatusOK || retry.Header().Get(ReplayedHeader) != "true" || retry.Body.String() != first.Body.String() || applied != 1 {
+		t.Errorf("retry: status %d replayed %q body %s, applied %d times", retry.Code, retry.Header().Get(ReplayedHeader), retry.Body, applied)
+	}
+	if w := send("k1", `{"rooms":{"Twin":{}}}`); w.Code != http.StatusBadRequest {
+		t.Errorf("key reused for another body: status %d, want 400", w.Code)
+	}
+
+	store.SetError(errors.New("connection refused"))
+	if w := send("k2", `{}`); w.Code < 500 || applied != 1 {
+		t.Errorf("store down: status %d, applied %d times", w.Code, applied)
+	}
+}
@@ -0,0 +1,288 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"room-mapping-cache/internal/config"
+	"room-mapping-cache/internal/encryption"
+	"room-mapping-cache/internal/errs"
+	"room-mapping-cache/internal/keys"
+	"room-mapping-cache/internal/limits"
+	"room-mapping-cache/internal/redis"
+	"room-mapping-cache/internal/tenant"
+)
+
+// SupplierExpiryReport summarizes one sweep of the supplier expiry job
+type SupplierExpiryReport struct {
+	StartedAt     time.Time      `json:"started_at"`
+	FinishedAt    time.Time      `json:"finished_at"`
+	HotelsScanned int            `json:"hotels_scanned"`
+	RoomsExpired  int            `json:"rooms_expired"`
+	BySupplier    map[string]int `json:"expired_by_supplier"`
+	// With HOTEL_TTL set: hashes that had no expiry and were given one, and
+	// those already past it and deleted
+	ExpiriesAssigned int       `json:"expiries_assigned,omitempty"`
+	HotelsExpired    int       `json:"hotels_expired,omitempty"`
+	Error            string    `json:"error,omitempty"`
+	ErrorKind        errs.Kind `json:"error_kind,omitempty"`
+}
+
+// SupplierExpiry periodically removes rooms whose supplier data is older than
+// the supplier's configured TTL.
+type SupplierExpiry struct {
+	redisClient ExpiryStore
+	cfg         *config.Config
+	keyring     *encryption.Keyring
+
+	maintenance *limits.Maintenance
+	afterWrite  func(ctx context.Context, hotelID string)
+
+	mu         sync.RWMutex
+	lastReport *SupplierExpiryReport
+}
+
+// ExpiryStore is the part of the store SupplierExpiry scans and trims
+type ExpiryStore interface {
+	redis.KeyValue
+	redis.HashReader
+	redis.HashWriter
+	redis.Scanner
+	FailedOver() bool
+}
+
+// NewSupplierExpiry creates the job. keyring may be nil when values are stored unencrypted.
+func NewSupplierExpiry(redisClient ExpiryStore, cfg *config.Config, keyring *encryption.Keyring) *SupplierExpiry {
+	return &SupplierExpiry{
+		redisClient: redisClient,
+		cfg:         cfg,
+		keyring:     keyring,
+	}
+}
+
+// SetMaintenance skips scheduled sweeps while m freezes mutations
+func (j *SupplierExpiry) SetMaintenance(m *limits.Maintenance) {
+	j.maintenance = m
+}
+
+// SetAfterWrite runs fn, the write path's bookkeeping (version bump, derived
+// indexes, cache invalidation), for every hotel a sweep changes
+func (j *SupplierExpiry) SetAfterWrite(fn func(ctx context.Context, hotelID string)) {
+	j.afterWrite = fn
+}
+
+// Run sweeps on the configured interval until ctx is cancelled
+func (j *SupplierExpiry) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.cfg.SupplierSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if j.maintenance != nil && !j.maintenance.Writable() {
+				slog.Info("Skipping supplier expiry sweep during maintenance")
+				continue
+			}
+			report := j.Sweep(ctx)
+			slog.Info("Supplier expiry sweep finished", "hotels_scanned", report.HotelsScanned,
+				"rooms_expired", report.RoomsExpired, "by_supplier", report.BySupplier,
+				"expiries_assigned", report.ExpiriesAssigned, "hotels_expired", report.HotelsExpired)
+		}
+	}
+}
+
+// LastReport returns the most recent sweep report, or nil if none has run
+func (j *SupplierExpiry) LastReport() *SupplierExpiryReport {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.lastReport
+}
+
+// Sweep performs a single pass over all room hash keys
+func (j *SupplierExpiry) Sweep(ctx context.Context) *SupplierExpiryReport {
+	report := &SupplierExpiryReport{
+		StartedAt:  time.Now(),
+		BySupplier: make(map[string]int),
+	}
+	defer func() {
+		report.FinishedAt = time.Now()
+		j.mu.Lock()
+		j.lastReport = report
+		j.mu.Unlock()
+	}()
+
+	// Deletes go to the primary, so don't sweep what we can't clean up
+	if j.redisClient.FailedOver() {
+		report.Error = "skipped: reads are failed over to the secondary Redis"
+		report.ErrorKind = errs.Degraded
+		return report
+	}
+
+	now := time.Now()
+	cursor := ""
+	for {
+		found, next, err := j.redisClient.ScanKeys(ctx, cursor, keys.RoomScanPattern(), 500)
+		if err != nil {
+			slog.Error("Supplier expiry scan failed", "error", err)
+			report.Error = err.Error()
+			report.ErrorKind = errs.KindOf(err)
+			return report
+		}
+
+		for _, key := range found {
+			// Version snapshots are historical copies
+			if keys.IsSnapshot(key) {
+				continue
+			}
+			report.HotelsScanned++
+			j.sweepKey(ctx, key, now, report)
+		}
+
+		if next == "" {
+			return report
+		}
+		cursor = next
+	}
+}
+
+func (j *SupplierExpiry) sweepKey(ctx context.Context, key string, now time.Time, report *SupplierExpiryReport) {
+	hashData, err := j.redisClient.HGetAll(ctx, key)
+	if err != nil {
+		slog.Error("Supplier expiry failed to read hash", "key", key, "error", err)
+		return
+	}
+
+	var (
+		stale     = make(map[string]string)
+		supplier  = make(map[string]string)
+		suppliers []string
+		newest    int64
+	)
+	seen := make(map[string]bool)
+	hotelID, _ := keys.HotelID(key)
+	tenantName, id := tenant.Split(hotelID)
+	for name, raw := range hashData {
+		var rv struct {
+			Supplier  string `json:"supplier"`
+			UpdatedAt int64  `json:"updated_at"`
+		}
+		if plain, err := j.keyring.Decrypt(raw, encryption.AAD{Tenant: tenantName, HotelID: id, Field: name}); err == nil {
+			_ = json.Unmarshal([]byte(plain), &rv)
+		}
+		if !seen[rv.Supplier] {
+			seen[rv.Supplier] = true
+			suppliers = append(suppliers, rv.Supplier)
+		}
+		if rv.UpdatedAt == 0 {
+			// Entries not written through the write path carry no freshness info
+			continue
+		}
+		newest = max(newest, rv.UpdatedAt)
+		ttl := j.cfg.SupplierTTL(rv.Supplier)
+		if ttl <= 0 || now.Sub(time.Unix(rv.UpdatedAt, 0)) < ttl {
+			continue
+		}
+		stale[name], supplier[name] = raw, rv.Supplier
+	}
+
+	if j.cfg.HotelTTL > 0 && j.assignExpiry(ctx, key, hashData, suppliers, newest, now, report) {
+		return
+	}
+	if len(stale) == 0 {
+		return
+	}
+	// Rooms rewritten since they were read are fresh again and stay
+	removed, err := j.redisClient.HDelIfEqual(ctx, key, stale)
+	if err != nil {
+		slog.Error("Supplier expiry failed to remove stale rooms", "key", key, "error", err)
+		return
+	}
+	if len(removed) == 0 {
+		return
+	}
+	j.changed(ctx, key)
+	report.RoomsExpired += len(removed)
+	for _, name := range removed {
+		report.BySupplier[supplier[name]]++
+	}
+}
+
+// changed runs the write path's bookkeeping for the hotel of a swept key.
+// Without it, the normalized copy and token index are dropped for the next
+// read or search to rebuild from the hash.
+func (j *SupplierExpiry) changed(ctx context.Context, key string) {
+	hotelID, ok := keys.HotelID(key)
+	if !ok {
+		return
+	}
+	if j.afterWrite != nil {
+		j.afterWrite(ctx, hotelID)
+		return
+	}
+	if j.cfg.NormalizedRooms {
+		if err := j.redisClient.Del(ctx, keys.Normalized(hotelID)); err != nil {
+			slog.Error("Supplier expiry failed to drop normalized rooms", "key", key, "error", err)
+		}
+	}
+	if j.cfg.TokenIndex {
+		if err := j.redisClient.Del(ctx, keys.TokenIndex(hotelID)); err != nil {
+			slog.Error("Supplier expiry failed to drop token index", "key", key, "error", err)
+		}
+	}
+}
+
+// assignExpiry gives a hash without an expiry the one a write would have set,
+// counted from its newest room write (or from now if none is recorded), and
+// deletes it if that has already passed and it is unchanged since hashData
+// was read. Reports whether it deleted the hash.
+func (j *SupplierExpiry) assignExpiry(ctx context.Context, key string, hashData map[string]string, suppliers []string, newest int64, now time.Time, report *SupplierExpiryReport) bool {
+	pttl, err := j.redisClient.PTTL(ctx, key)
+	if err != nil || pttl != -1 {
+		return false
+	}
+	ttl := j.cfg.HashTTL(suppliers)
+	if ttl <= 0 {
+		return false
+	}
+	if newest > 0 {
+		ttl -= now.Sub(time.Unix(newest, 0))
+	}
+
+	if ttl <= 0 {
+		deleted, err := j.redisClient.DelIfEqual(ctx, key, hashData)
+		if err != nil {
+			slog.Error("Failed to delete expired hotel", "key", key, "error", err)
+			return false
+		}
+		if !deleted {
+			// Written or given an expiry since it was read
+			return true
+		}
+		report.HotelsExpired++
+		j.changed(ctx, key)
+		return true
+	}
+	if err := j.redisClient.Expire(ctx, key, ttl); err != nil {
+		slog.Error("Failed to assign hotel expiry", "key", key, "error", err)
+		return false
+	}
+	report.ExpiriesAssigned++
+	j.expireTokenIndex(ctx, key, ttl)
+	return false
+}
+
+// expireTokenIndex gives the hotel's token index the hash's new expiry
+func (j *SupplierExpiry) expireTokenIndex(ctx context.Context, key string, ttl time.Duration) {
+	hotelID, ok := keys.HotelID(key)
+	if !ok || !j.cfg.TokenIndex {
+		return
+	}
+	if err := j.redisClient.Expire(ctx, keys.TokenIndex(hotelID), ttl); err != nil {
+		slog.Error("Supplier expiry failed to update token index", "key", key, "error", err)
+	}
+}
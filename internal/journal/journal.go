@@ -0,0 +1,122 @@
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+)
+
+// Entry is one journaled request/response pair
+type Entry struct {
+	Time          time.Time         `json:"time"`
+	Route         string            `json:"route"`
+	HotelIDs      []string          `json:"hotel_ids"`
+	Status        int               `json:"status"`
+	DurationMs    float64           `json:"duration_ms"`
+	ResponseBytes int               `json:"response_bytes"`
+	RoomCounts    map[string]int    `json:"room_counts,omitempty"`
+	KeyVariants   map[string]string `json:"key_variants,omitempty"`
+	Error         string            `json:"error,omitempty"`
+}
+
+// Journal keeps the most recent sampled entries in a ring buffer and
+// optionally appends every sampled entry to a JSON-lines file.
+type Journal struct {
+	sampleRate float64
+
+	mu      sync.Mutex
+	entries []Entry
+	next    int
+	full    bool
+	file    *os.File
+	enc     *json.Encoder
+}
+
+// New creates a journal holding up to size entries. path may be empty to keep
+// entries in memory only.
+func New(size int, sampleRate float64, path string) (*Journal, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("journal size must be positive")
+	}
+	j := &Journal{
+		sampleRate: sampleRate,
+		entries:    make([]Entry, size),
+	}
+	if path != "" {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open journal file: %w", err)
+		}
+		j.file = f
+		j.enc = json.NewEncoder(f)
+	}
+	return j, nil
+}
+
+// Sampled reports whether the current request should be journaled. A nil
+// journal never samples, so callers don't need to check whether it's enabled.
+func (j *Journal) Sampled() bool {
+	if j == nil {
+		return false
+	}
+	return j.sampleRate >= 1 || rand.Float64() < j.sampleRate
+}
+
+// Record stores an entry
+func (j *Journal) Record(e Entry) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.entries[j.next] = e
+	j.next = (j.next + 1) % len(j.entries)
+	if j.next == 0 {
+		j.full = true
+	}
+
+	if j.enc != nil {
+		if err := j.enc.Encode(e); err != nil {
+			slog.Error("Failed to write journal entry", "error", err)
+		}
+	}
+}
+
+// Recent returns up to limit of the newest entries, oldest first
+func (j *Journal) Recent(limit int) []Entry {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	n := j.next
+	if j.full {
+		n = len(j.entries)
+	}
+	if limit <= 0 || limit > n {
+		limit = n
+	}
+
+	out := make([]Entry, 0, limit)
+	for i := n - limit; i < n; i++ {
+		idx := i
+		if j.full {
+			idx = (j.next + i) % len(j.entries)
+		}
+		out = append(out, j.entries[idx])
+	}
+	return out
+}
+
+func (j *Journal) Close() error {
+	if j == nil || j.file == nil {
+		return nil
+	}
+	return j.file.Close()
+}
@@ -0,0 +1,216 @@
+// Package keys centralizes Redis key construction. Room hash keys follow a
+// configurable template (ROOM_KEY_TEMPLATE) with {hotel} and optional
+// {supplier} placeholders; {hotel} expands to a {id} hash tag so all of a
+// hotel's keys share a cluster slot.
+package keys
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const DefaultTemplate = "room_map:{hotel}"
+
+var current = mustSchema(DefaultTemplate, "")
+
+type schema struct {
+	template string
+	supplier string
+	hotelRe  *regexp.Regexp
+	snapRe   *regexp.Regexp
+}
+
+// Configure sets the room key template and the supplier substituted for
+// {supplier}. It must be called before serving traffic.
+func Configure(template, supplier string) error {
+	s, err := newSchema(template, supplier)
+	if err != nil {
+		return err
+	}
+	current = s
+	return nil
+}
+
+func newSchema(template, supplier string) (*schema, error) {
+	if strings.Count(template, "{hotel}") != 1 {
+		return nil, fmt.Errorf("key template %q must contain {hotel} exactly once", template)
+	}
+	if strings.Contains(template, "{supplier}") && supplier == "" {
+		return nil, fmt.Errorf("key template %q uses {supplier} but no supplier is configured", template)
+	}
+
+	// Build a matcher for live room keys, accepting both hash-tagged and plain hotel IDs
+	var b strings.Builder
+	for _, part := range splitTemplate(template) {
+		switch part {
+		case "{hotel}":
+			b.WriteString(`(?:\{([^{}]+)\}|([^{}:]+))`)
+		case "{supplier}":
+			b.WriteString(regexp.QuoteMeta(supplier))
+		default:
+			b.WriteString(regexp.QuoteMeta(part))
+		}
+	}
+	pattern := b.String()
+
+	return &schema{
+		template: template,
+		supplier: supplier,
+		hotelRe:  regexp.MustCompile("^" + pattern + "$"),
+		snapRe:   regexp.MustCompile("^" + pattern + `:v\d+$`),
+	}, nil
+}
+
+func mustSchema(template, supplier string) *schema {
+	s, err := newSchema(template, supplier)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// splitTemplate splits a template into literal parts and placeholders
+func splitTemplate(template string) []string {
+	var parts []string
+	for template != "" {
+		i := strings.IndexAny(template, "{")
+		if i < 0 {
+			parts = append(parts, template)
+			break
+		}
+		if i > 0 {
+			parts = append(parts, template[:i])
+		}
+		matched := false
+		for _, ph := range []string{"{hotel}", "{supplier}"} {
+			if strings.HasPrefix(template[i:], ph) {
+				parts = append(parts, ph)
+				template = template[i+len(ph):]
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			parts = append(parts, template[i:i+1])
+			template = template[i+1:]
+		}
+	}
+	return parts
+}
+
+func (s *schema) expand(hotel string) string {
+	r := strings.NewReplacer("{hotel}", hotel, "{supplier}", s.supplier)
+	return r.Replace(s.template)
+}
+
+// Room returns the canonical (hash-tagged) room hash key for a hotel
+func Room(hotelID string) string {
+	return current.expand("{" + hotelID + "}")
+}
+
+// RoomFallback returns the legacy non-hash-tagged room hash key
+func RoomFallback(hotelID string) string {
+	return current.expand(hotelID)
+}
+
+// Snapshot returns the key of a hotel's room hash copy at a given version
+func Snapshot(hotelID string, version int64) string {
+	return fmt.Sprintf("%s:v%d", Room(hotelID), version)
+}
+
+// Snapshots returns the key of the index of a hotel's retained snapshots
+func Snapshots(hotelID string) string {
+	return fmt.Sprintf("room_map_snapshots:{%s}", hotelID)
+}
+
+// Version returns the key of the hotel's version/updated_at hash
+func Version(hotelID string) string {
+	return fmt.Sprintf("room_map_version:{%s}", hotelID)
+}
+
+// Normalized returns the key of the hotel's pre-parsed, sorted room list
+func Normalized(hotelID string) string {
+	return fmt.Sprintf("room_map_norm:{%s}", hotelID)
+}
+
+// TokenIndex returns the key of the hotel's room name token index
+func TokenIndex(hotelID string) string {
+	return fmt.Sprintf("room_tokens:{%s}", hotelID)
+}
+
+// Meta returns the key of the hotel metadata hash
+func Meta(hotelID string) string {
+	return fmt.Sprintf("hotel_meta:{%s}", hotelID)
+}
+
+// ConsistencyReport returns the key of the latest consistency check report
+func ConsistencyReport() string {
+	return "room_map_consistency_report"
+}
+
+// DeadLetters returns the key of the hash of a hotel's skipped room entries
+func DeadLetters(hotelID string) string {
+	return fmt.Sprintf("dead_letters:{%s}", hotelID)
+}
+
+// DeadLetterScanPattern is a SCAN MATCH pattern covering all dead-letter hashes
+func DeadLetterScanPattern() string {
+	return "dead_letters:*"
+}
+
+// Tombstones returns the key of the hash of a hotel's recently deleted rooms
+func Tombstones(hotelID string) string {
+	return fmt.Sprintf("room_tombstones:{%s}", hotelID)
+}
+
+// WebhookEvent returns the key marking a mapping provider webhook event as
+// received, used to drop redeliveries
+func WebhookEvent(eventID string) string {
+	return fmt.Sprintf("webhook_event:{%s}", eventID)
+}
+
+// Idempotency returns the key of a caller's stored response to the write
+// made with an Idempotency-Key
+func Idempotency(caller, key string) string {
+	return fmt.Sprintf("idempotency:{%s}:%s", caller, key)
+}
+
+// RateLimit returns the key of a caller's token bucket for a route group
+func RateLimit(group, caller string) string {
+	return fmt.Sprintf("rate_limit:%s:{%s}", group, caller)
+}
+
+// Quota returns the key of a quota subject's request counter for a period,
+// e.g. "d20261017" or "m202610"
+func Quota(subject, period string) string {
+	return fmt.Sprintf("quota:{%s}:%s", subject, period)
+}
+
+// RoomScanPattern is a SCAN MATCH pattern covering all room hash keys. It
+// also matches snapshots, which callers filter with IsSnapshot.
+func RoomScanPattern() string {
+	return current.expand("*")
+}
+
+// VersionScanPattern is a SCAN MATCH pattern covering all version hashes
+func VersionScanPattern() string {
+	return "room_map_version:*"
+}
+
+// IsSnapshot reports whether key is a version snapshot of a room hash
+func IsSnapshot(key string) bool {
+	return current.snapRe.MatchString(key)
+}
+
+// HotelID extracts the hotel ID from a live room hash key in either form
+func HotelID(key string) (string, bool) {
+	m := current.hotelRe.FindStringSubmatch(key)
+	if m == nil {
+		return "", false
+	}
+	if m[1] != "" {
+		return m[1], true
+	}
+	return m[2], m[2] != ""
+}
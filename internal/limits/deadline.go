@@ -0,0 +1,91 @@
+package limits
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"room-mapping-cache/internal/errs"
+	"room-mapping-cache/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Headers a caller passes its remaining latency budget in: milliseconds, or
+// the gRPC form such as "250m" or "2S"
+const (
+	DeadlineHeader    = "X-Deadline-Ms"
+	GRPCTimeoutHeader = "Grpc-Timeout"
+)
+
+// Deadline bounds the request context to d, so handlers and the Redis calls
+// they make share the route's latency budget. A shorter budget sent by the
+// caller tightens it; one already spent is answered with TIMEOUT unrun.
+func Deadline(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		budget, ok, err := callerBudget(c.Request.Header)
+		if err != nil {
+			logging.AbortWithError(c, err)
+			return
+		}
+		timeout := d
+		if ok {
+			if budget <= 0 {
+				logging.AbortWithError(c, errs.New(errs.Timeout, "caller deadline already passed"))
+				return
+			}
+			timeout = min(d, budget)
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// callerBudget reads the budget from X-Deadline-Ms, else grpc-timeout
+func callerBudget(h http.Header) (time.Duration, bool, error) {
+	if raw := h.Get(DeadlineHeader); raw != "" {
+		ms, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || ms < 0 {
+			return 0, false, &errs.Error{Kind: errs.Invalid, Field: DeadlineHeader, Msg: DeadlineHeader + " must be a non-negative integer"}
+		}
+		if ms > int64(math.MaxInt64/time.Millisecond) {
+			return math.MaxInt64, true, nil
+		}
+		return time.Duration(ms) * time.Millisecond, true, nil
+	}
+	if raw := h.Get(GRPCTimeoutHeader); raw != "" {
+		budget, ok := parseGRPCTimeout(raw)
+		if !ok {
+			return 0, false, &errs.Error{Kind: errs.Invalid, Field: GRPCTimeoutHeader, Msg: GRPCTimeoutHeader + ` must be up to 8 digits and a unit (H, M, S, m, u or n)`}
+		}
+		return budget, true, nil
+	}
+	return 0, false, nil
+}
+
+// parseGRPCTimeout parses a gRPC-over-HTTP/2 Timeout value
+func parseGRPCTimeout(raw string) (time.Duration, bool) {
+	if len(raw) < 2 || len(raw) > 9 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(raw[:len(raw)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	units := map[byte]time.Duration{
+		'H': time.Hour, 'M': time.Minute, 'S': time.Second,
+		'm': time.Millisecond, 'u': time.Microsecond, 'n': time.Nanosecond,
+	}
+	unit, ok := units[raw[len(raw)-1]]
+	if !ok {
+		return 0, false
+	}
+	if n > int64(math.MaxInt64/unit) {
+		return math.MaxInt64, true
+	}
+	return time.Duration(n) * unit, true
+}
@@ -0,0 +1,63 @@
+package limits
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"room-mapping-cache/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Drain tracks requests in flight so shutdown can wait for them before
+// closing the listener
+type Drain struct {
+	active   atomic.Int64
+	draining atomic.Bool
+}
+
+func NewDrain() *Drain {
+	return &Drain{}
+}
+
+// Middleware counts the request while it is served. Once draining, responses
+// carry Connection: close so keep-alive clients reconnect to another instance.
+func (d *Drain) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		d.active.Add(1)
+		metrics.ActiveRequests.Inc()
+		defer func() {
+			metrics.ActiveRequests.Dec()
+			d.active.Add(-1)
+		}()
+		if d.draining.Load() {
+			c.Header("Connection", "close")
+		}
+		c.Next()
+	}
+}
+
+// Start begins draining
+func (d *Drain) Start() {
+	d.draining.Store(true)
+}
+
+// Active returns the number of requests being served
+func (d *Drain) Active() int64 {
+	return d.active.Load()
+}
+
+// Wait returns once no request is in flight, or with ctx's error
+func (d *Drain) Wait(ctx context.Context) error {
+	ticker := time.NewTicker(25 * time.Millisecond)
+	defer ticker.Stop()
+	for d.active.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
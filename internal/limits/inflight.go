@@ -0,0 +1,48 @@
+package limits
+
+import (
+	"net/http"
+
+	"room-mapping-cache/internal/errs"
+	"room-mapping-cache/internal/logging"
+	"room-mapping-cache/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InFlight caps concurrently served requests. Requests over the cap are shed
+// immediately with 503 rather than queued, so a slow Redis does not pile up
+// requests until they all time out.
+type InFlight struct {
+	slots chan struct{}
+}
+
+// NewInFlight allows up to max concurrent requests
+func NewInFlight(max int) *InFlight {
+	metrics.InFlightLimit.Set(float64(max))
+	return &InFlight{slots: make(chan struct{}, max)}
+}
+
+// Middleware sheds requests while the limit is reached
+func (l *InFlight) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		select {
+		case l.slots <- struct{}{}:
+		default:
+			metrics.ShedRequests.WithLabelValues(c.FullPath()).Inc()
+			c.Header("Retry-After", "1")
+			// 503 rather than the 429 of Overloaded: the instance, not the
+			// caller, is over its limit
+			_, body := errs.NewResponse(errs.New(errs.Overloaded, "server overloaded"), logging.RequestID(c.Request.Context()))
+			c.Set(logging.ErrorCodeKey, string(body.Code))
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, body)
+			return
+		}
+		metrics.InFlightRequests.Inc()
+		defer func() {
+			metrics.InFlightRequests.Dec()
+			<-l.slots
+		}()
+		c.Next()
+	}
+}
@@ -0,0 +1,211 @@
+package limits
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"room-mapping-cache/internal/config"
+	"room-mapping-cache/internal/errs"
+	"room-mapping-cache/internal/keys"
+	"room-mapping-cache/internal/logging"
+	"room-mapping-cache/internal/metrics"
+	"room-mapping-cache/internal/redis"
+
+	"github.com/gin-gonic/gin"
+)
+
+// quotaTimeout bounds the Redis round trip; slower checks fail open
+const quotaTimeout = 50 * time.Millisecond
+
+// Quota periods
+const (
+	PeriodDaily   = "daily"
+	PeriodMonthly = "monthly"
+)
+
+// QuotaPeriod is a subject's usage in the current day or month
+type QuotaPeriod struct {
+	Used int64 `json:"used"`
+	// Limit is 0 when the period is unlimited
+	Limit    int       `json:"limit"`
+	ResetsAt time.Time `json:"resets_at"`
+}
+
+func (p QuotaPeriod) remaining() int64 {
+	return max(int64(p.Limit)-p.Used, 0)
+}
+
+func (p QuotaPeriod) exhausted() bool {
+	return p.Limit > 0 && p.Used >= int64(p.Limit)
+}
+
+// QuotaUsage is a subject's usage of its quotas
+type QuotaUsage struct {
+	Subject string      `json:"subject"`
+	Daily   QuotaPeriod `json:"daily"`
+	Monthly QuotaPeriod `json:"monthly"`
+}
+
+// Quotas are daily and monthly request quotas per API key ("key:<name>")
+// and tenant ("tenant:<name>"), counted in Redis over UTC calendar periods.
+// Unlike the rate limiter they are meant for billing external callers, not
+// protecting Redis, but they likewise fail open.
+type Quotas struct {
+	redis     QuotaStore
+	defaults  config.QuotaLimits
+	overrides map[string]config.QuotaLimits
+}
+
+// QuotaStore is where Quotas keeps its counters
+type QuotaStore interface {
+	redis.KeyValue
+	redis.Limiter
+}
+
+// NewQuotas applies defaults to every subject without an override
+func NewQuotas(redisClient QuotaStore, defaults config.QuotaLimits, overrides map[string]config.QuotaLimits) *Quotas {
+	return &Quotas{redis: redisClient, defaults: defaults, overrides: overrides}
+}
+
+// Limits returns the quotas of a subject
+func (q *Quotas) Limits(subject string) config.QuotaLimits {
+	if l, ok := q.overrides[subject]; ok {
+		return l
+	}
+	return q.defaults
+}
+
+// periodKeys returns the counter keys of the day and month containing now,
+// and when each ends
+func periodKeys(subject string, now time.Time) (day, month string, dayEnd, monthEnd time.Time) {
+	now = now.UTC()
+	y, m, d := now.Date()
+	dayEnd = time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+	monthEnd = time.Date(y, m+1, 1, 0, 0, 0, 0, time.UTC)
+	return keys.Quota(subject, now.Format("d20060102")), keys.Quota(subject, now.Format("m200601")), dayEnd, monthEnd
+}
+
+// Middleware counts each request against the caller's API key and tenant
+// quotas, rejecting it with 429 once either is used up. The most constrained
+// quota is reported in X-Quota-Limit, X-Quota-Remaining and X-Quota-Reset.
+// Anonymous requests without a tenant are not counted.
+func (q *Quotas) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var subjects []string
+		if name := c.GetString(logging.APIKeyNameKey); name != "" {
+			subjects = append(subjects, "key:"+name)
+		}
+		if tenant := c.GetString(logging.TenantKey); tenant != "" {
+			subjects = append(subjects, "tenant:"+tenant)
+		}
+
+		var tightest *QuotaPeriod
+		note := func(p *QuotaPeriod) {
+			if p.Limit > 0 && (tightest == nil || p.remaining() < tightest.remaining()) {
+				tightest = p
+			}
+		}
+		for _, subject := range subjects {
+			limits := q.Limits(subject)
+			if limits.Daily <= 0 && limits.Monthly <= 0 {
+				continue
+			}
+			usage, allowed, err := q.take(c.Request.Context(), subject, limits)
+			if err != nil {
+				metrics.QuotaErrors.Inc()
+				slog.WarnContext(c.Request.Context(), "Quota check failed, allowing request", "subject", subject, "error", err)
+				continue
+			}
+			if !allowed {
+				period, exhausted := PeriodMonthly, &usage.Monthly
+				if usage.Daily.exhausted() {
+					period, exhausted = PeriodDaily, &usage.Daily
+				}
+				metrics.QuotaRejected.WithLabelValues(strings.SplitN(subject, ":", 2)[0], period).Inc()
+				setQuotaHeaders(c, exhausted)
+				c.Header("Retry-After", strconv.FormatInt(int64(time.Until(exhausted.ResetsAt).Seconds())+1, 10))
+				logging.AbortWithError(c, &errs.Error{
+					Kind: errs.Overloaded, Code: errs.CodeQuotaExceeded, Msg: period + " quota exceeded",
+					Details: map[string]any{"period": period, "resets_at": exhausted.ResetsAt},
+				})
+				return
+			}
+			note(&usage.Daily)
+			note(&usage.Monthly)
+		}
+		if tightest != nil {
+			setQuotaHeaders(c, tightest)
+		}
+		c.Next()
+	}
+}
+
+func setQuotaHeaders(c *gin.Context, p *QuotaPeriod) {
+	c.Header("X-Quota-Limit", strconv.Itoa(p.Limit))
+	c.Header("X-Quota-Remaining", strconv.FormatInt(p.remaining(), 10))
+	c.Header("X-Quota-Reset", strconv.FormatInt(p.ResetsAt.Unix(), 10))
+}
+
+func (q *Quotas) take(ctx context.Context, subject string, limits config.QuotaLimits) (QuotaUsage, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, quotaTimeout)
+	defer cancel()
+
+	day, month, dayEnd, monthEnd := periodKeys(subject, time.Now())
+	// Counts a request on both periods unless either is at its limit
+	counts, allowed, err := q.redis.IncrWithinLimits(ctx,
+		redis.Counter{Key: day, Limit: int64(limits.Daily), ExpireAt: dayEnd},
+		redis.Counter{Key: month, Limit: int64(limits.Monthly), ExpireAt: monthEnd})
+	if err != nil {
+		return QuotaUsage{}, false, err
+	}
+	return QuotaUsage{
+		Subject: subject,
+		Daily:   QuotaPeriod{Used: counts[0], Limit: limits.Daily, ResetsAt: dayEnd},
+		Monthly: QuotaPeriod{Used: counts[1], Limit: limits.Monthly, ResetsAt: monthEnd},
+	}, allowed, nil
+}
+
+// Usage returns a subject's usage in the current day and month
+func (q *Quotas) Usage(ctx context.Context, subject string) (QuotaUsage, error) {
+	limits := q.Limits(subject)
+	day, month, dayEnd, monthEnd := periodKeys(subject, time.Now())
+	usage := QuotaUsage{
+		Subject: subject,
+		Daily:   QuotaPeriod{Limit: limits.Daily, ResetsAt: dayEnd},
+		Monthly: QuotaPeriod{Limit: limits.Monthly, ResetsAt: monthEnd},
+	}
+	for _, p := range []struct {
+		key  string
+		used *int64
+	}{{day, &usage.Daily.Used}, {month, &usage.Monthly.Used}} {
+		raw, err := q.redis.Get(ctx, p.key)
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return QuotaUsage{}, err
+		}
+		if *p.used, err = strconv.ParseInt(raw, 10, 64); err != nil {
+			return QuotaUsage{}, err
+		}
+	}
+	return usage, nil
+}
+
+// Reset clears a subject's usage for period (daily, monthly, or "" for both)
+func (q *Quotas) Reset(ctx context.Context, subject, period string) error {
+	day, month, _, _ := periodKeys(subject, time.Now())
+	switch period {
+	case PeriodDaily:
+		return q.redis.Del(ctx, day)
+	case PeriodMonthly:
+		return q.redis.Del(ctx, month)
+	case "":
+		return q.redis.Del(ctx, day, month)
+	}
+	return &errs.Error{Kind: errs.Invalid, Field: "period", Msg: "period must be daily or monthly"}
+}
@@ -0,0 +1,72 @@
+package limits
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+
+	"room-mapping-cache/internal/errs"
+	"room-mapping-cache/internal/keys"
+	"room-mapping-cache/internal/logging"
+	"room-mapping-cache/internal/metrics"
+	"room-mapping-cache/internal/redis"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rateLimitTimeout bounds the Redis round trip; slower checks fail open
+const rateLimitTimeout = 50 * time.Millisecond
+
+// RateLimiter is a per-caller token bucket stored in Redis, so the limit
+// holds across replicas. Callers are identified by API key name when
+// authenticated, otherwise by client IP.
+type RateLimiter struct {
+	redis redis.Limiter
+	group string
+	rate  float64
+	burst int
+}
+
+// NewRateLimiter allows rate requests per second per caller on the route
+// group, with bursts of up to burst requests
+func NewRateLimiter(redisClient redis.Limiter, group string, rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = int(math.Max(1, math.Ceil(rate)))
+	}
+	return &RateLimiter{redis: redisClient, group: group, rate: rate, burst: burst}
+}
+
+// Middleware rejects callers over their limit with 429 and Retry-After. It
+// fails open when Redis is unavailable, since it only protects Redis itself.
+func (l *RateLimiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		caller := "ip:" + c.ClientIP()
+		if name := c.GetString(logging.APIKeyNameKey); name != "" {
+			caller = "key:" + name
+		}
+
+		allowed, retryAfter, err := l.take(c.Request.Context(), caller)
+		if err != nil {
+			metrics.RateLimitErrors.WithLabelValues(l.group).Inc()
+			slog.WarnContext(c.Request.Context(), "Rate limit check failed, allowing request", "group", l.group, "error", err)
+			c.Next()
+			return
+		}
+		if !allowed {
+			metrics.RateLimited.WithLabelValues(l.group).Inc()
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			logging.AbortWithError(c, &errs.Error{Kind: errs.Overloaded, Code: errs.CodeRateLimited, Msg: "rate limit exceeded"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func (l *RateLimiter) take(ctx context.Context, caller string) (bool, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, rateLimitTimeout)
+	defer cancel()
+
+	return l.redis.TakeToken(ctx, keys.RateLimit(l.group, caller), l.rate, l.burst)
+}
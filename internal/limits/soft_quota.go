@@ -0,0 +1,66 @@
+package limits
+
+import (
+	"sync"
+	"time"
+)
+
+// SoftQuota counts requests per caller in fixed one-minute windows and
+// derives a reduced batch size for callers over their quota, so heavy callers
+// degrade gradually instead of being rejected outright.
+type SoftQuota struct {
+	perMinute int
+	maxBatch  int
+	minBatch  int
+
+	mu     sync.Mutex
+	window time.Time
+	counts map[string]int
+}
+
+func NewSoftQuota(perMinute, maxBatch, minBatch int) *SoftQuota {
+	if minBatch < 1 {
+		minBatch = 1
+	}
+	if minBatch > maxBatch {
+		minBatch = maxBatch
+	}
+	return &SoftQuota{
+		perMinute: perMinute,
+		maxBatch:  maxBatch,
+		minBatch:  minBatch,
+		counts:    make(map[string]int),
+	}
+}
+
+// Observe records a request from caller and returns the batch size limit that
+// applies to it and whether the caller is currently degraded.
+func (q *SoftQuota) Observe(caller string) (limit int, degraded bool) {
+	if q.perMinute <= 0 {
+		return q.maxBatch, false
+	}
+
+	now := time.Now().Truncate(time.Minute)
+	q.mu.Lock()
+	if !now.Equal(q.window) {
+		q.window = now
+		q.counts = make(map[string]int, len(q.counts))
+	}
+	q.counts[caller]++
+	count := q.counts[caller]
+	q.mu.Unlock()
+
+	if count <= q.perMinute {
+		return q.maxBatch, false
+	}
+
+	// Halve the allowance for every multiple of the quota the caller is over
+	limit = q.maxBatch
+	for over := count / q.perMinute; over > 0 && limit > q.minBatch; over-- {
+		limit /= 2
+	}
+	if limit < q.minBatch {
+		limit = q.minBatch
+	}
+	return limit, true
+}
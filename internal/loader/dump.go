@@ -0,0 +1,205 @@
+package loader
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Record is one hotel's rooms for one supplier, as the write endpoint takes
+// them: room name to room value.
+type Record struct {
+	HotelID  string                            `json:"hotel_id"`
+	Supplier string                            `json:"supplier"`
+	Rooms    map[string]map[string]interface{} `json:"rooms"`
+}
+
+// Validate reports why a record cannot be written, if it can't
+func (r Record) Validate() error {
+	switch {
+	case strings.TrimSpace(r.HotelID) == "":
+		return errors.New("hotel_id is required")
+	case strings.TrimSpace(r.Supplier) == "":
+		return errors.New("supplier is required")
+	case len(r.Rooms) == 0:
+		return errors.New("at least one room is required")
+	}
+	for name := range r.Rooms {
+		if strings.TrimSpace(name) == "" {
+			return errors.New("room names must not be empty")
+		}
+	}
+	return nil
+}
+
+// RecordError is a record that could not be parsed or validated. Reading
+// continues past it.
+type RecordError struct {
+	Pos int // record number in JSON dumps, line number in CSV dumps
+	Err error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("record %d: %v", e.Pos, e.Err)
+}
+
+func (e *RecordError) Unwrap() error { return e.Err }
+
+// ReadDump streams the records of a JSON or CSV dump to fn. A *RecordError for
+// a bad record is passed to onBad and reading goes on; any other error, or an
+// error from fn, stops the read and is returned.
+//
+// JSON dumps are an array of records or a stream of records, one after the
+// other (e.g. JSON lines). CSV dumps have a header row with hotel_id, supplier
+// and room columns; every other non-empty column becomes a string property of
+// the room. Consecutive rows for the same hotel and supplier form one record.
+func ReadDump(r io.Reader, isCSV bool, fn func(Record) error, onBad func(*RecordError)) error {
+	if isCSV {
+		return readCSV(r, fn, onBad)
+	}
+	return readJSON(r, fn, onBad)
+}
+
+func readJSON(r io.Reader, fn func(Record) error, onBad func(*RecordError)) error {
+	br := bufio.NewReader(r)
+	dec := json.NewDecoder(br)
+
+	// Peek past whitespace to tell an array from a stream of objects
+	inArray := false
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if b == ' ' || b == '\t' || b == '\r' || b == '\n' {
+			continue
+		}
+		_ = br.UnreadByte()
+		if b == '[' {
+			inArray = true
+			if _, err := dec.Token(); err != nil {
+				return err
+			}
+		}
+		break
+	}
+
+	for pos := 1; !inArray || dec.More(); pos++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF && !inArray {
+			return nil
+		} else if err != nil {
+			// Syntax errors leave the decoder unusable, so they end the read
+			return fmt.Errorf("record %d: %w", pos, err)
+		}
+		var rec Record
+		err := json.Unmarshal(raw, &rec)
+		if err == nil {
+			err = rec.Validate()
+		}
+		if err != nil {
+			onBad(&RecordError{Pos: pos, Err: err})
+			continue
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	_, err := dec.Token() // closing bracket
+	return err
+}
+
+func readCSV(r io.Reader, fn func(Record) error, onBad func(*RecordError)) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	columns := make([]string, len(header))
+	hotelCol, supplierCol, roomCol := -1, -1, -1
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[i] = name
+		switch name {
+		case "hotel_id":
+			hotelCol = i
+		case "supplier":
+			supplierCol = i
+		case "room", "room_name":
+			roomCol = i
+		}
+	}
+	if hotelCol < 0 || supplierCol < 0 || roomCol < 0 {
+		return errors.New("CSV header needs hotel_id, supplier and room columns")
+	}
+
+	var (
+		cur      Record
+		curStart int
+	)
+	flush := func() error {
+		if cur.Rooms == nil {
+			return nil
+		}
+		rec := cur
+		cur = Record{}
+		if err := rec.Validate(); err != nil {
+			onBad(&RecordError{Pos: curStart, Err: err})
+			return nil
+		}
+		return fn(rec)
+	}
+
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return flush()
+		}
+		if err != nil {
+			// The reader resumes at the next line after a malformed one
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				onBad(&RecordError{Pos: parseErr.StartLine, Err: parseErr.Err})
+				continue
+			}
+			return err
+		}
+		line, _ := cr.FieldPos(0)
+		if len(row) < len(columns) {
+			onBad(&RecordError{Pos: line, Err: fmt.Errorf("expected %d columns, got %d", len(columns), len(row))})
+			continue
+		}
+
+		hotelID := strings.TrimSpace(row[hotelCol])
+		supplier := strings.TrimSpace(row[supplierCol])
+		if cur.Rooms == nil || hotelID != cur.HotelID || supplier != cur.Supplier {
+			if err := flush(); err != nil {
+				return err
+			}
+			cur = Record{HotelID: hotelID, Supplier: supplier, Rooms: map[string]map[string]interface{}{}}
+			curStart = line
+		}
+
+		value := map[string]interface{}{}
+		for i, name := range columns {
+			if i == hotelCol || i == supplierCol || i == roomCol || name == "" || row[i] == "" {
+				continue
+			}
+			value[name] = row[i]
+		}
+		cur.Rooms[row[roomCol]] = value
+	}
+}
@@ -0,0 +1,51 @@
+package loader
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ReadFixtures streams the hotels of every *.json file in dir to fn, files
+// and hotels in name order so seeding is reproducible. A fixture file is an
+// object of hotel ID to rooms (room name to room value); each hotel becomes a
+// Record for supplier. Invalid hotels are passed to onBad with their position
+// in the file; unreadable files and errors from fn stop the read.
+func ReadFixtures(dir, supplier string, fn func(Record) error, onBad func(*RecordError)) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no *.json fixtures in %s", dir)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		raw, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		var hotels map[string]map[string]map[string]interface{}
+		if err := json.Unmarshal(raw, &hotels); err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(file), err)
+		}
+		ids := make([]string, 0, len(hotels))
+		for id := range hotels {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for i, id := range ids {
+			rec := Record{HotelID: id, Supplier: supplier, Rooms: hotels[id]}
+			if err := rec.Validate(); err != nil {
+				onBad(&RecordError{Pos: i + 1, Err: fmt.Errorf("%s: hotel %s: %w", filepath.Base(file), id, err)})
+				continue
+			}
+			if err := fn(rec); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
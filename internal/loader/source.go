@@ -0,0 +1,123 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// googleSheetRe matches interactive Google Sheets URLs so they can be rewritten
+// to their CSV export form.
+var googleSheetRe = regexp.MustCompile(`^https://docs\.google\.com/spreadsheets/d/([A-Za-z0-9_-]+)`)
+
+var httpClient = &http.Client{Timeout: 60 * time.Second}
+
+// OpenSource opens a dump for reading. source may be a local file path, an
+// s3://bucket/key URL or an HTTPS URL; headers are sent with HTTP requests
+// (e.g. Authorization).
+func OpenSource(ctx context.Context, source string, headers map[string]string) (io.ReadCloser, error) {
+	if strings.HasPrefix(source, "s3://") {
+		return openS3(ctx, source)
+	}
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		f, err := os.Open(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open source file: %w", err)
+		}
+		return f, nil
+	}
+
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source URL: %w", err)
+	}
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("refusing to load from non-HTTPS URL %s", u.Redacted())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, GoogleSheetExportURL(source), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build source request: %w", err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch source: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &StatusError{Status: resp.StatusCode}
+	}
+	return resp.Body, nil
+}
+
+// StatusError is returned by OpenSource when an HTTP source answers with
+// anything but 200
+type StatusError struct {
+	Status int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("source returned status %d", e.Status)
+}
+
+// openS3 streams an S3 object, with credentials and region from the standard
+// AWS environment
+func openS3(ctx context.Context, source string) (io.ReadCloser, error) {
+	u, err := url.Parse(source)
+	if err != nil || u.Host == "" || strings.TrimPrefix(u.Path, "/") == "" {
+		return nil, fmt.Errorf("invalid S3 URL %q, expected s3://bucket/key", source)
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	bucket, key := u.Host, strings.TrimPrefix(u.Path, "/")
+	out, err := s3.NewFromConfig(awsCfg).GetObject(ctx, &s3.GetObjectInput{Bucket: &bucket, Key: &key})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch source: %w", err)
+	}
+	return out.Body, nil
+}
+
+// GoogleSheetExportURL rewrites a Google Sheets URL to its CSV export URL,
+// preserving the selected sheet (gid). Other URLs are returned unchanged.
+func GoogleSheetExportURL(source string) string {
+	m := googleSheetRe.FindStringSubmatch(source)
+	if m == nil || strings.Contains(source, "/export?") {
+		return source
+	}
+
+	gid := "0"
+	if u, err := url.Parse(source); err == nil {
+		if g := u.Query().Get("gid"); g != "" {
+			gid = g
+		} else if g, ok := strings.CutPrefix(u.Fragment, "gid="); ok && g != "" {
+			gid = g
+		}
+	}
+	return fmt.Sprintf("https://docs.google.com/spreadsheets/d/%s/export?format=csv&gid=%s", m[1], url.QueryEscape(gid))
+}
+
+// IsCSV reports whether a source should be parsed as CSV rather than JSON.
+func IsCSV(source string) bool {
+	if googleSheetRe.MatchString(source) || strings.Contains(source, "format=csv") {
+		return true
+	}
+	if u, err := url.Parse(source); err == nil && u.Path != "" {
+		source = u.Path
+	}
+	return strings.HasSuffix(strings.ToLower(source), ".csv")
+}
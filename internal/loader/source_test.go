@@ -0,0 +1,92 @@
+package loader
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGoogleSheetExportURL(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"https://docs.google.com/spreadsheets/d/abc_1-2/edit", "https://docs.google.com/spreadsheets/d/abc_1-2/export?format=csv&gid=0"},
+		{"https://docs.google.com/spreadsheets/d/abc/edit?gid=42", "https://docs.google.com/spreadsheets/d/abc/export?format=csv&gid=42"},
+		{"https://docs.google.com/spreadsheets/d/abc/edit#gid=7", "https://docs.google.com/spreadsheets/d/abc/export?format=csv&gid=7"},
+		{"https://docs.google.com/spreadsheets/d/abc/export?format=csv&gid=3", "https://docs.google.com/spreadsheets/d/abc/export?format=csv&gid=3"},
+		{"https://example.com/dump.json", "https://example.com/dump.json"},
+	}
+	for _, tt := range tests {
+		if got := GoogleSheetExportURL(tt.source); got != tt.want {
+			t.Errorf("GoogleSheetExportURL(%q) = %q, want %q", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestIsCSV(t *testing.T) {
+	tests := []struct {
+		source string
+		want   bool
+	}{
+		{"dump.csv", true},
+		{"DUMP.CSV", true},
+		{"dump.json", false},
+		{"https://example.com/dump.csv?sig=x", true},
+		{"https://example.com/export?format=csv", true},
+		{"https://docs.google.com/spreadsheets/d/abc/edit", true},
+		{"https://example.com/dump.json", false},
+	}
+	for _, tt := range tests {
+		if got := IsCSV(tt.source); got != tt.want {
+			t.Errorf("IsCSV(%q) = %v, want %v", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestOpenSource(t *testing.T) {
+	ctx := context.Background()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		io.WriteString(w, "hotel_id,supplier\n")
+	}))
+	defer srv.Close()
+	defer func(c *http.Client) { httpClient = c }(httpClient)
+	httpClient = srv.Client()
+
+	read := func(source string, headers map[string]string) (string, error) {
+		t.Helper()
+		body, err := OpenSource(ctx, source, headers)
+		if err != nil {
+			return "", err
+		}
+		defer body.Close()
+		b, err := io.ReadAll(body)
+		return string(b), err
+	}
+
+	if got, err := read(srv.URL+"/dump.csv", map[string]string{"Authorization": "Bearer token"}); err != nil || got != "hotel_id,supplier\n" {
+		t.Errorf("HTTPS source: %q, %v", got, err)
+	}
+	if _, err := read(srv.URL+"/dump.csv", nil); err == nil {
+		t.Error("HTTPS source answering 401: no error")
+	}
+	if _, err := read("http://example.com/dump.csv", nil); err == nil {
+		t.Error("plain HTTP source: no error")
+	}
+
+	path := filepath.Join(t.TempDir(), "dump.json")
+	if err := os.WriteFile(path, []byte("[]"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := read(path, nil); err != nil || got != "[]" {
+		t.Errorf("file source: %q, %v", got, err)
+	}
+}
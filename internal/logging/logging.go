@@ -0,0 +1,191 @@
+// Package logging configures structured (slog) logging and the per-request
+// access log with request ID correlation.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	mathrand "math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"room-mapping-cache/internal/errs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader is read from incoming requests and echoed on responses
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// Setup installs the default slog logger. format is "json" (default) or
+// "text"; level is debug, info, warn or error. Records logged with a request
+// context carry its request_id. Errors caused by the client cancelling its
+// request are logged at info level with canceled=true, so they don't count
+// as failures.
+func Setup(level, format string) {
+	opts := &slog.HandlerOptions{Level: parseLevel(level)}
+	var h slog.Handler
+	if strings.EqualFold(format, "text") {
+		h = slog.NewTextHandler(os.Stderr, opts)
+	} else {
+		h = slog.NewJSONHandler(os.Stderr, opts)
+	}
+	slog.SetDefault(slog.New(contextHandler{h}))
+}
+
+func parseLevel(level string) slog.Level {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return slog.LevelInfo
+	}
+	return l
+}
+
+// contextHandler adds the request ID stored in the record's context and
+// downgrades errors that only report a cancelled request
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= slog.LevelError && canceledError(r) {
+		r.Level = slog.LevelInfo
+		if !h.Handler.Enabled(ctx, r.Level) {
+			return nil
+		}
+		r.AddAttrs(slog.Bool("canceled", true))
+	}
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// canceledError reports whether the record's error attribute is a context
+// cancellation
+func canceledError(r slog.Record) bool {
+	canceled := false
+	r.Attrs(func(a slog.Attr) bool {
+		if err, ok := a.Value.Any().(error); ok && errors.Is(err, context.Canceled) {
+			canceled = true
+			return false
+		}
+		return true
+	})
+	return canceled
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// APIKeyNameKey is the gin context key auth middleware sets to the caller's
+// API key name, logged as api_key
+const APIKeyNameKey = "api_key_name"
+
+// TenantKey is the gin context key set to the request's tenant, logged as
+// tenant
+const TenantKey = "tenant"
+
+// ErrorCodeKey is the gin context key set to the error code a request was
+// answered with, logged as code
+const ErrorCodeKey = "error_code"
+
+// AbortWithError answers the request with the error envelope for err and
+// records its code for the access log and error metrics
+func AbortWithError(c *gin.Context, err error) {
+	status, body := errs.NewResponse(err, RequestID(c.Request.Context()))
+	c.Set(ErrorCodeKey, string(body.Code))
+	c.AbortWithStatusJSON(status, body)
+}
+
+// RequestID returns the request ID carried by ctx, if any
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Middleware assigns each request an ID (reusing X-Request-ID when the caller
+// sent a valid one), stores it in the request context and writes one access log line
+// per request once it completes. Only successSampleRate of requests below 400
+// are logged; errors always are.
+func Middleware(successSampleRate float64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), requestIDKey{}, id))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		if status < 400 && successSampleRate < 1 && mathrand.Float64() >= successSampleRate {
+			return
+		}
+		attrs := []any{
+			"method", c.Request.Method,
+			"route", c.FullPath(),
+			"path", c.Request.URL.Path,
+			"status", status,
+			"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"bytes", c.Writer.Size(),
+			"client_ip", c.ClientIP(),
+		}
+		if hotelID := c.Param("hotel_id"); hotelID != "" {
+			attrs = append(attrs, "hotel_id", hotelID)
+		}
+		if name := c.GetString(APIKeyNameKey); name != "" {
+			attrs = append(attrs, "api_key", name)
+		}
+		if tenant := c.GetString(TenantKey); tenant != "" {
+			attrs = append(attrs, "tenant", tenant)
+		}
+		if code := c.GetString(ErrorCodeKey); code != "" {
+			attrs = append(attrs, "code", code)
+		}
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+		}
+		slog.Log(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// validRequestID accepts caller IDs that are safe to echo in headers and log
+// lines: up to 128 letters, digits and "-_.:"
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		switch b := id[i]; {
+		case b >= 'a' && b <= 'z', b >= 'A' && b <= 'Z', b >= '0' && b <= '9':
+		case b == '-', b == '_', b == '.', b == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var b [12]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,444 @@
+// Package metrics owns the service's Prometheus registry and collectors.
+package metrics
+
+import (
+	"net/http"
+
+	"room-mapping-cache/internal/cache"
+	"room-mapping-cache/internal/redis"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds every metric exported on /metrics
+var Registry = prometheus.NewRegistry()
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves the registry in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// poolCollector reads Redis pool counters at scrape time
+type poolCollector struct {
+	stats func() *redis.PoolStats
+
+	hits, misses, timeouts *prometheus.Desc
+	total, idle, stale     *prometheus.Desc
+}
+
+// RegisterRedisPool exports the pool counters returned by stats, labelled
+// with endpoint (e.g. "primary" or "secondary").
+func RegisterRedisPool(endpoint string, stats func() *redis.PoolStats) {
+	labels := prometheus.Labels{"endpoint": endpoint}
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc("room_cache_redis_pool_"+name, help, nil, labels)
+	}
+	Registry.MustRegister(&poolCollector{
+		stats:    stats,
+		hits:     desc("hits_total", "Times a free connection was found in the pool."),
+		misses:   desc("misses_total", "Times a free connection was not found in the pool."),
+		timeouts: desc("timeouts_total", "Times a wait for a pool connection timed out."),
+		total:    desc("conns", "Connections currently in the pool."),
+		idle:     desc("idle_conns", "Idle connections currently in the pool."),
+		stale:    desc("stale_conns_total", "Stale connections removed from the pool."),
+	})
+}
+
+func (p *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{p.hits, p.misses, p.timeouts, p.total, p.idle, p.stale} {
+		ch <- d
+	}
+}
+
+func (p *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := p.stats()
+	if s == nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(p.hits, prometheus.CounterValue, float64(s.Hits))
+	ch <- prometheus.MustNewConstMetric(p.misses, prometheus.CounterValue, float64(s.Misses))
+	ch <- prometheus.MustNewConstMetric(p.timeouts, prometheus.CounterValue, float64(s.Timeouts))
+	ch <- prometheus.MustNewConstMetric(p.total, prometheus.GaugeValue, float64(s.TotalConns))
+	ch <- prometheus.MustNewConstMetric(p.idle, prometheus.GaugeValue, float64(s.IdleConns))
+	ch <- prometheus.MustNewConstMetric(p.stale, prometheus.CounterValue, float64(s.StaleConns))
+}
+
+// RegisterCache exports local cache counters read from stats at scrape time
+func RegisterCache(stats func() cache.Stats) {
+	counter := func(name, help string, value func(cache.Stats) float64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{Name: "room_cache_local_" + name, Help: help},
+			func() float64 { return value(stats()) })
+	}
+	gauge := func(name, help string, value func(cache.Stats) float64) prometheus.Collector {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: "room_cache_local_" + name, Help: help},
+			func() float64 { return value(stats()) })
+	}
+	Registry.MustRegister(
+		counter("hits_total", "Local cache hits.", func(s cache.Stats) float64 { return float64(s.Hits) }),
+		counter("misses_total", "Local cache misses.", func(s cache.Stats) float64 { return float64(s.Misses) }),
+		counter("evictions_total", "Local cache evictions.", func(s cache.Stats) float64 { return float64(s.Evictions) }),
+		gauge("entries", "Hotels in the local cache.", func(s cache.Stats) float64 { return float64(s.Entries) }),
+		gauge("bytes", "Estimated local cache size in bytes.", func(s cache.Stats) float64 { return float64(s.Bytes) }),
+	)
+}
+
+// GzipWriters counts gzip writers taken from the pool and the subset that had
+// to be newly allocated; their ratio is the pool's miss rate.
+var GzipWriters = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "room_cache_gzip_writers_total",
+	Help: "Gzip writers taken from the pool (state=acquired) and newly allocated (state=created).",
+}, []string{"state"})
+
+func init() {
+	Registry.MustRegister(GzipWriters)
+}
+
+// NormalizedReads counts lookups of stored normalized room lists by result
+var NormalizedReads = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "room_cache_normalized_reads_total",
+	Help: "Reads of pre-normalized room lists (result=hit|miss).",
+}, []string{"result"})
+
+func init() {
+	Registry.MustRegister(NormalizedReads)
+}
+
+// HedgedRequests counts hedged single-hotel Redis reads that were sent and
+// those whose answer beat the original request
+var HedgedRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "room_cache_redis_hedged_requests_total",
+	Help: "Hedged Redis reads for single lookups (outcome=sent|won).",
+}, []string{"outcome"})
+
+func init() {
+	Registry.MustRegister(HedgedRequests)
+}
+
+// CoalescedReads observes how many single-hotel reads shared each coalesced
+// pipeline
+var CoalescedReads = prometheus.NewHistogram(prometheus.HistogramOpts{
+	Name:    "room_cache_coalesced_reads",
+	Help:    "Single-hotel reads sent together in one coalesced Redis pipeline.",
+	Buckets: []float64{1, 2, 4, 8, 16, 32, 64, 128, 256},
+})
+
+func init() {
+	Registry.MustRegister(CoalescedReads)
+}
+
+// RefreshAheads counts hot cache entries refreshed before they expired
+var RefreshAheads = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "room_cache_refresh_ahead_total",
+	Help: "Hot local cache entries re-read from Redis shortly before expiring.",
+})
+
+func init() {
+	Registry.MustRegister(RefreshAheads)
+}
+
+// ErrorResponses counts error responses by route and error code; Panics
+// counts handler panics by route
+var (
+	ErrorResponses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "room_cache_error_responses_total",
+		Help: "Error responses by route and error code.",
+	}, []string{"route", "code"})
+	Panics = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "room_cache_panics_total",
+		Help: "Requests whose handler panicked, by route.",
+	}, []string{"route"})
+)
+
+func init() {
+	Registry.MustRegister(ErrorResponses, Panics)
+}
+
+// ResponseLimits counts room lookups over MAX_RESPONSE_BYTES by action
+// (truncated or rejected)
+var ResponseLimits = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "room_cache_response_limits_total",
+	Help: "Room lookups over the response size limit, by action.",
+}, []string{"action"})
+
+func init() {
+	Registry.MustRegister(ResponseLimits)
+}
+
+// UpstreamRefreshes counts hotels pulled from the upstream mapping API
+var UpstreamRefreshes = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "room_cache_upstream_refreshes_total",
+	Help: "Hotels refreshed from the upstream mapping API (outcome=ok|error).",
+}, []string{"outcome"})
+
+func init() {
+	Registry.MustRegister(UpstreamRefreshes)
+}
+
+// UpstreamReadThroughs counts Redis misses looked up in the upstream mapping API
+var UpstreamReadThroughs = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "room_cache_upstream_read_throughs_total",
+	Help: "Redis misses fetched from the upstream mapping API (outcome=ok|missing|error).",
+}, []string{"outcome"})
+
+func init() {
+	Registry.MustRegister(UpstreamReadThroughs)
+}
+
+// ConsistencyIssues is the number of room hash issues of each kind found by
+// the latest consistency check
+var ConsistencyIssues = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "room_cache_consistency_issues",
+	Help: "Room data issues found by the latest consistency check, by kind.",
+}, []string{"kind"})
+
+func init() {
+	Registry.MustRegister(ConsistencyIssues)
+}
+
+// DeadLetters counts skipped room entries sent to the dead-letter store
+var DeadLetters = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "room_cache_dead_letters_total",
+	Help: "Skipped room entries by dead-letter outcome (recorded|full|dropped|error).",
+}, []string{"outcome"})
+
+func init() {
+	Registry.MustRegister(DeadLetters)
+}
+
+// NameConflicts counts normalized room names found shared by different IDs
+var NameConflicts = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "room_cache_name_conflicts_total",
+	Help: "Normalized room names shared by rooms with different IDs, counted per parsed hash.",
+})
+
+func init() {
+	Registry.MustRegister(NameConflicts)
+}
+
+// WebhookEvents counts mapping provider webhook deliveries by outcome
+var WebhookEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "room_cache_webhook_events_total",
+	Help: "Mapping provider webhook events (outcome=applied|duplicate|invalid|error).",
+}, []string{"outcome"})
+
+func init() {
+	Registry.MustRegister(WebhookEvents)
+}
+
+// ResponseEncodings counts JSON responses by negotiated content encoding
+var ResponseEncodings = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "room_cache_response_encodings_total",
+	Help: "JSON responses by content encoding (identity, zstd, br, gzip).",
+}, []string{"encoding"})
+
+func init() {
+	Registry.MustRegister(ResponseEncodings)
+}
+
+// AuthRequests counts authentication outcomes by API key name; rejected
+// requests have an empty key label.
+var AuthRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "room_cache_auth_requests_total",
+	Help: "Authenticated requests by API key name and result (ok, missing, invalid).",
+}, []string{"key", "result"})
+
+func init() {
+	Registry.MustRegister(AuthRequests)
+}
+
+// RateLimited counts requests rejected by the rate limiter per route group;
+// RateLimitErrors counts checks that failed open because Redis was unavailable.
+var (
+	RateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "room_cache_rate_limited_total",
+		Help: "Requests rejected with 429 by route group.",
+	}, []string{"group"})
+
+	RateLimitErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "room_cache_rate_limit_errors_total",
+		Help: "Rate limit checks that failed and let the request through, by route group.",
+	}, []string{"group"})
+)
+
+func init() {
+	Registry.MustRegister(RateLimited, RateLimitErrors)
+}
+
+// In-flight request limiting: current and maximum concurrent requests, and
+// requests shed with 503 because the limit was reached
+var (
+	InFlightRequests = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "room_cache_inflight_requests",
+		Help: "Requests currently being served under the in-flight limit.",
+	})
+
+	InFlightLimit = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "room_cache_inflight_limit",
+		Help: "Configured maximum in-flight requests.",
+	})
+
+	ShedRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "room_cache_shed_requests_total",
+		Help: "Requests rejected with 503 because the in-flight limit was reached, by route.",
+	}, []string{"route"})
+)
+
+func init() {
+	Registry.MustRegister(InFlightRequests, InFlightLimit, ShedRequests)
+}
+
+// ActiveRequests is the number of requests being served on the public
+// listener, which shutdown waits to reach zero
+var ActiveRequests = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "room_cache_active_requests",
+	Help: "Requests currently being served on the public listener.",
+})
+
+func init() {
+	Registry.MustRegister(ActiveRequests)
+}
+
+// MaintenanceMode is 1 for the current maintenance mode and 0 for the others;
+// MaintenanceRejected counts requests refused by it
+var (
+	MaintenanceMode = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "room_cache_maintenance_mode",
+		Help: "1 for the current maintenance mode (off, read_only or full), 0 otherwise.",
+	}, []string{"mode"})
+
+	MaintenanceRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "room_cache_maintenance_rejected_total",
+		Help: "Requests rejected with 503 by maintenance mode, by mode.",
+	}, []string{"mode"})
+)
+
+func init() {
+	Registry.MustRegister(MaintenanceMode, MaintenanceRejected)
+}
+
+// ShadowReads counts sampled lookups re-read from the shadow Redis, by
+// result (match, mismatch, missing, stale, error or dropped)
+var ShadowReads = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "room_cache_shadow_reads_total",
+	Help: "Sampled room lookups compared against the shadow Redis, by result.",
+}, []string{"result"})
+
+func init() {
+	Registry.MustRegister(ShadowReads)
+}
+
+// CacheSamples counts cached hotels re-read from Redis by the cache sampler,
+// by result (consistent, stale, divergent, evicted or error); CacheSampleLag
+// is how far behind Redis the stale ones were and CacheConsistency the share
+// of compared hotels that were consistent in the last round
+var (
+	CacheSamples = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "room_cache_cache_samples_total",
+		Help: "Cached hotels compared with Redis by the cache sampler, by result.",
+	}, []string{"result"})
+
+	CacheSampleLag = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "room_cache_cache_sample_lag_seconds",
+		Help:    "How much older than Redis's copy stale cached hotels were, by updated_at.",
+		Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600},
+	})
+
+	CacheConsistency = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "room_cache_cache_consistency_ratio",
+		Help: "Share of sampled cached hotels matching Redis in the last sampler round.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(CacheSamples, CacheSampleLag, CacheConsistency)
+}
+
+// SignatureChecks counts HMAC request signature verifications by result
+var SignatureChecks = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "room_cache_signature_checks_total",
+	Help: "Request signature checks on write endpoints by result (ok, missing, expired, invalid).",
+}, []string{"result"})
+
+func init() {
+	Registry.MustRegister(SignatureChecks)
+}
+
+// TokenIndexSearches counts ?q= searches by how the token index served them
+var TokenIndexSearches = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "room_cache_token_index_searches_total",
+	Help: "Room name searches against the token index (result=hit|miss|error).",
+}, []string{"result"})
+
+func init() {
+	Registry.MustRegister(TokenIndexSearches)
+}
+
+// TenantRequests and TenantRequestDuration break room and meta traffic down
+// by X-Tenant ("default" without one)
+var (
+	TenantRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "room_cache_tenant_requests_total",
+		Help: "Room and meta requests by tenant and status class.",
+	}, []string{"tenant", "status"})
+
+	TenantRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "room_cache_tenant_request_duration_seconds",
+		Help:    "Room and meta request latency by tenant.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"tenant"})
+)
+
+func init() {
+	Registry.MustRegister(TenantRequests, TenantRequestDuration)
+}
+
+// QuotaRejected counts requests refused because a quota was used up, by
+// subject kind (key or tenant) and period; QuotaErrors counts checks that
+// failed open
+var (
+	QuotaRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "room_cache_quota_rejected_total",
+		Help: "Requests rejected with 429 because a daily or monthly quota was used up, by subject kind and period.",
+	}, []string{"kind", "period"})
+
+	QuotaErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "room_cache_quota_errors_total",
+		Help: "Quota checks that failed and let the request through.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(QuotaRejected, QuotaErrors)
+}
+
+// IdempotentRequests counts writes carrying an Idempotency-Key by outcome:
+// stored, released (not stored so a retry applies again), replayed,
+// in_progress, mismatch or error
+var IdempotentRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "room_cache_idempotent_requests_total",
+	Help: "Write requests with an Idempotency-Key by outcome.",
+}, []string{"outcome"})
+
+func init() {
+	Registry.MustRegister(IdempotentRequests)
+}
+
+// FaultsInjected counts artificial faults by type (latency, redis_error,
+// batch_failure); it stays at zero unless fault injection is enabled
+var FaultsInjected = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "room_cache_faults_injected_total",
+	Help: "Artificial faults injected for resilience testing, by type.",
+}, []string{"fault"})
+
+func init() {
+	Registry.MustRegister(FaultsInjected)
+}
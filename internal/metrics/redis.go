@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	redisDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "room_cache_redis_command_duration_seconds",
+		Help:    "Redis command latency; pipelines are recorded as command \"pipeline\".",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"endpoint", "command"})
+
+	redisErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "room_cache_redis_errors_total",
+		Help: "Failed Redis commands, excluding nil replies and cancellations.",
+	}, []string{"endpoint", "command"})
+
+	redisCanceled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "room_cache_redis_canceled_total",
+		Help: "Redis commands abandoned because the caller cancelled its request.",
+	}, []string{"endpoint", "command"})
+
+	redisPipelineSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "room_cache_redis_pipeline_commands",
+		Help:    "Commands per executed pipeline.",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 10),
+	}, []string{"endpoint"})
+)
+
+func init() {
+	Registry.MustRegister(redisDuration, redisErrors, redisCanceled, redisPipelineSize)
+}
+
+// RedisHook is a go-redis hook recording command latency, errors and
+// pipeline sizes for one endpoint (e.g. "primary" or "secondary").
+type RedisHook struct {
+	Endpoint string
+}
+
+func (h RedisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h RedisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		h.observe(ctx, cmd.Name(), start, err)
+		return err
+	}
+}
+
+func (h RedisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		redisPipelineSize.WithLabelValues(h.Endpoint).Observe(float64(len(cmds)))
+		h.observe(ctx, "pipeline", start, err)
+		return err
+	}
+}
+
+func (h RedisHook) observe(ctx context.Context, command string, start time.Time, err error) {
+	elapsed := time.Since(start)
+	canceled := errors.Is(err, context.Canceled)
+	failed := err != nil && !errors.Is(err, redis.Nil) && !canceled
+	redisDuration.WithLabelValues(h.Endpoint, command).Observe(elapsed.Seconds())
+	switch {
+	case canceled:
+		redisCanceled.WithLabelValues(h.Endpoint, command).Inc()
+	case failed:
+		redisErrors.WithLabelValues(h.Endpoint, command).Inc()
+	}
+	recordRedisCall(ctx, command, elapsed, failed)
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"room-mapping-cache/internal/errs"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// SLI names, the sli label of the SLI counters
+const (
+	// SLIAvailability: good requests were answered without a 5xx
+	SLIAvailability = "availability"
+	// SLILatency: good requests were answered without a 5xx within the
+	// route's latency threshold
+	SLILatency = "latency"
+)
+
+// SLI counters, so burn rates are plain ratios such as
+// 1 - rate(room_cache_sli_good_total[1h]) / rate(room_cache_sli_requests_total[1h])
+var (
+	sliRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "room_cache_sli_requests_total",
+		Help: "Requests counted towards each SLI, by route and SLI (availability or latency).",
+	}, []string{"route", "sli"})
+
+	sliGood = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "room_cache_sli_good_total",
+		Help: "Requests meeting each SLI, by route and SLI (availability or latency).",
+	}, []string{"route", "sli"})
+
+	sliLatencyThreshold = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "room_cache_sli_latency_threshold_seconds",
+		Help: "Latency a request must be answered within to count as good, by route.",
+	}, []string{"route"})
+)
+
+func init() {
+	Registry.MustRegister(sliRequests, sliGood, sliLatencyThreshold)
+}
+
+// SLI counts requests towards the availability and latency SLIs. Requests
+// must be answered within threshold, or within routes[route] for the route
+// templates listed there. Unmatched routes and requests the client cancelled
+// are not counted.
+func SLI(threshold time.Duration, routes map[string]time.Duration) gin.HandlerFunc {
+	for route, d := range routes {
+		sliLatencyThreshold.WithLabelValues(route).Set(d.Seconds())
+	}
+	sliLatencyThreshold.WithLabelValues("default").Set(threshold.Seconds())
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		status := c.Writer.Status()
+		if route == "" || status == errs.StatusClientClosedRequest {
+			return
+		}
+		limit, ok := routes[route]
+		if !ok {
+			limit = threshold
+		}
+		available := status < http.StatusInternalServerError
+
+		sliRequests.WithLabelValues(route, SLIAvailability).Inc()
+		sliRequests.WithLabelValues(route, SLILatency).Inc()
+		if available {
+			sliGood.WithLabelValues(route, SLIAvailability).Inc()
+			if time.Since(start) <= limit {
+				sliGood.WithLabelValues(route, SLILatency).Inc()
+			}
+		}
+	}
+}
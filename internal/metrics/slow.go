@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var slowRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "room_cache_slow_requests_total",
+	Help: "Requests over the latency (reason=latency) or response size (reason=size) thresholds.",
+}, []string{"route", "reason"})
+
+func init() {
+	Registry.MustRegister(slowRequests)
+}
+
+type redisCallsKey struct{}
+
+// redisCalls accumulates Redis time per command for one request
+type redisCalls struct {
+	mu        sync.Mutex
+	byCommand map[string]*RedisCallStat
+}
+
+// RedisCallStat is the Redis time one request spent on one command
+type RedisCallStat struct {
+	Command  string  `json:"command"`
+	Calls    int     `json:"calls"`
+	TotalMs  float64 `json:"total_ms"`
+	Failures int     `json:"failures,omitempty"`
+}
+
+// recordRedisCall is called by RedisHook for commands run under a request context
+func recordRedisCall(ctx context.Context, command string, d time.Duration, failed bool) {
+	calls, ok := ctx.Value(redisCallsKey{}).(*redisCalls)
+	if !ok {
+		return
+	}
+	calls.mu.Lock()
+	defer calls.mu.Unlock()
+	stat := calls.byCommand[command]
+	if stat == nil {
+		stat = &RedisCallStat{Command: command}
+		calls.byCommand[command] = stat
+	}
+	stat.Calls++
+	stat.TotalMs += float64(d.Microseconds()) / 1000
+	if failed {
+		stat.Failures++
+	}
+}
+
+// top returns the n commands with the most total time
+func (r *redisCalls) top(n int) []RedisCallStat {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]RedisCallStat, 0, len(r.byCommand))
+	for _, stat := range r.byCommand {
+		out = append(out, *stat)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].TotalMs > out[j].TotalMs })
+	if len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+// SlowRequests logs and counts requests slower than latency or with responses
+// larger than size bytes (zero disables either check), along with the Redis
+// commands that took the most time while serving them.
+func SlowRequests(latency time.Duration, size int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if latency <= 0 && size <= 0 {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		calls := &redisCalls{byCommand: make(map[string]*RedisCallStat)}
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), redisCallsKey{}, calls))
+
+		c.Next()
+
+		elapsed := time.Since(start)
+		slow := latency > 0 && elapsed >= latency
+		big := size > 0 && c.Writer.Size() >= size
+		if !slow && !big {
+			return
+		}
+
+		route := c.FullPath()
+		if slow {
+			slowRequests.WithLabelValues(route, "latency").Inc()
+		}
+		if big {
+			slowRequests.WithLabelValues(route, "size").Inc()
+		}
+		slog.WarnContext(c.Request.Context(), "Slow or large request",
+			"route", route,
+			"status", c.Writer.Status(),
+			"latency_ms", float64(elapsed.Microseconds())/1000,
+			"bytes", c.Writer.Size(),
+			"redis_top", calls.top(3),
+		)
+	}
+}
@@ -0,0 +1,324 @@
+package redis
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"room-mapping-cache/internal/errs"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// hdelIfEqualScript removes the ARGV field, value pairs whose field still
+// holds that value and returns those fields
+var hdelIfEqualScript = redis.NewScript(`
+local removed = {}
+for i = 1, #ARGV, 2 do
+	if redis.call('HGET', KEYS[1], ARGV[i]) == ARGV[i + 1] then
+		redis.call('HDEL', KEYS[1], ARGV[i])
+		removed[#removed + 1] = ARGV[i]
+	end
+end
+return removed
+`)
+
+// delIfEqualScript deletes the hash only if it holds exactly the ARGV field,
+// value pairs and has no expiry, returning 1 if it did
+var delIfEqualScript = redis.NewScript(`
+if redis.call('PTTL', KEYS[1]) ~= -1 or redis.call('HLEN', KEYS[1]) * 2 ~= #ARGV then
+	return 0
+end
+for i = 1, #ARGV, 2 do
+	if redis.call('HGET', KEYS[1], ARGV[i]) ~= ARGV[i + 1] then
+		return 0
+	end
+end
+return redis.call('DEL', KEYS[1])
+`)
+
+// copyHashScript replaces KEYS[2] with a copy of the hash KEYS[1], expiring
+// after ARGV[1] milliseconds if positive. It returns 0, copying nothing, if
+// KEYS[1] is empty. HSET is chunked to stay within Lua's unpack limit.
+var copyHashScript = redis.NewScript(`
+local data = redis.call('HGETALL', KEYS[1])
+if #data == 0 then
+	return 0
+end
+redis.call('DEL', KEYS[2])
+for i = 1, #data, 1000 do
+	redis.call('HSET', KEYS[2], unpack(data, i, math.min(i + 999, #data)))
+end
+if tonumber(ARGV[1]) > 0 then
+	redis.call('PEXPIRE', KEYS[2], ARGV[1])
+end
+return 1
+`)
+
+// CopyHash atomically replaces dst with a copy of the hash src, expiring
+// after ttl if positive. Both keys must share a hash slot. It reports false,
+// leaving dst alone, if src is empty.
+func (c *Client) CopyHash(ctx context.Context, src, dst string, ttl time.Duration) (bool, error) {
+	n, err := c.runWriteScript(ctx, copyHashScript, []string{src, dst}, ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
+// snapshotHashScript copies the hash KEYS[1] to KEYS[2] like copyHashScript,
+// then records KEYS[2] in the sorted set KEYS[3] scored by version ARGV[1]
+// and deletes every recorded copy scored at or below ARGV[1] - ARGV[2]. The
+// index gets the copy's expiry ARGV[3], or none. Nothing is recorded or
+// pruned if KEYS[1] is empty. The pruned keys are read from the index rather
+// than declared, so they must share the index's hash slot.
+var snapshotHashScript = redis.NewScript(`
+local data = redis.call('HGETALL', KEYS[1])
+if #data == 0 then
+	return 0
+end
+redis.call('DEL', KEYS[2])
+for i = 1, #data, 1000 do
+	redis.call('HSET', KEYS[2], unpack(data, i, math.min(i + 999, #data)))
+end
+local ttl = tonumber(ARGV[3])
+if ttl > 0 then
+	redis.call('PEXPIRE', KEYS[2], ttl)
+end
+redis.call('ZADD', KEYS[3], ARGV[1], KEYS[2])
+local cutoff = tonumber(ARGV[1]) - tonumber(ARGV[2])
+local old = redis.call('ZRANGEBYSCORE', KEYS[3], '-inf', cutoff)
+for _, key in ipairs(old) do
+	redis.call('DEL', key)
+end
+redis.call('ZREMRANGEBYSCORE', KEYS[3], '-inf', cutoff)
+if ttl > 0 then
+	redis.call('PEXPIRE', KEYS[3], ttl)
+else
+	redis.call('PERSIST', KEYS[3])
+end
+return 1
+`)
+
+// SnapshotHash atomically copies the hash src to dst as the copy at version,
+// records it in the index, and deletes the indexed copies more than keep
+// versions older, so none outlive the retention window even without a ttl.
+// All keys, including every indexed copy, must share a hash slot. It reports
+// false, leaving dst and the index alone, if src is empty.
+func (c *Client) SnapshotHash(ctx context.Context, src, dst, index string, version int64, keep int, ttl time.Duration) (bool, error) {
+	n, err := c.runWriteScript(ctx, snapshotHashScript, []string{src, dst, index}, version, keep, ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
+// HDelIfEqual removes the fields of a hash that still hold the given values,
+// atomically, so a field rewritten since it was read is kept. It returns the
+// fields it removed.
+func (c *Client) HDelIfEqual(ctx context.Context, key string, fields map[string]string) ([]string, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return c.runWriteScript(ctx, hdelIfEqualScript, []string{key}, pairs(fields)...).StringSlice()
+}
+
+// DelIfEqual deletes a hash that has no expiry, atomically and only if it
+// still holds exactly hash. It reports whether it deleted it.
+func (c *Client) DelIfEqual(ctx context.Context, key string, hash map[string]string) (bool, error) {
+	n, err := c.runWriteScript(ctx, delIfEqualScript, []string{key}, pairs(hash)...).Int()
+	return n == 1, err
+}
+
+// pairs flattens a hash into field, value script arguments
+func pairs(hash map[string]string) []interface{} {
+	args := make([]interface{}, 0, 2*len(hash))
+	for field, value := range hash {
+		args = append(args, field, value)
+	}
+	return args
+}
+
+// hsetMissingScript copies field/value pairs into a hash without overwriting
+// fields it already has, returning how many it copied
+var hsetMissingScript = redis.NewScript(`
+local copied = 0
+for i = 1, #ARGV, 2 do
+	copied = copied + redis.call('HSETNX', KEYS[1], ARGV[i], ARGV[i + 1])
+end
+return copied
+`)
+
+// hsetCappedScript sets a field unless the hash is full and doesn't have it
+// yet, and refreshes the expiry. ARGV is field, value, max fields, TTL in ms.
+var hsetCappedScript = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 0 and redis.call('HLEN', KEYS[1]) >= tonumber(ARGV[3]) then
+	return 0
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+return 1
+`)
+
+// roomIDsScript returns only the name and "id" of each room in a hash, so
+// large hashes never cross the wire. It walks the hash with HSCAN and stops
+// after ARGV[1] rooms; the reply is a truncated flag followed by name, id
+// pairs.
+var roomIDsScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local out = {0}
+local seen = {}
+local n = 0
+local cursor = "0"
+repeat
+	local page = redis.call("HSCAN", KEYS[1], cursor, "COUNT", 500)
+	cursor = page[1]
+	local h = page[2]
+	for i = 1, #h, 2 do
+		local name = h[i]
+		if not seen[name] then
+			seen[name] = true
+			local id = string.match(h[i + 1], '"id"%s*:%s*"?(%d+)')
+			if id and id ~= "0" then
+				if n >= limit then
+					out[1] = 1
+					return out
+				end
+				n = n + 1
+				out[#out + 1] = name
+				out[#out + 1] = id
+			end
+		end
+	end
+until cursor == "0"
+return out
+`)
+
+// tokenBucketScript takes one token from the bucket at KEYS[1], refilled at
+// ARGV[1] tokens per second up to ARGV[2], using Redis time so every replica
+// shares one clock. Returns {allowed, retry after ms}.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local state = redis.call('HMGET', KEYS[1], 't', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+local allowed, retry = 0, 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) * 1000 / rate)
+end
+redis.call('HSET', KEYS[1], 't', tokens, 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return {allowed, retry}
+`)
+
+// incrWithinLimitsScript increments every KEYS counter unless one is at its
+// limit (ARGV[i]; 0 is unlimited), setting each to expire at the Unix time
+// ARGV[#KEYS + i]. Returns {allowed, counts...}.
+var incrWithinLimitsScript = redis.NewScript(`
+local n = #KEYS
+local out = {1}
+for i = 1, n do
+	local count = tonumber(redis.call('GET', KEYS[i]) or '0')
+	local limit = tonumber(ARGV[i])
+	if limit > 0 and count >= limit then
+		out[1] = 0
+	end
+	out[i + 1] = count
+end
+if out[1] == 1 then
+	for i = 1, n do
+		out[i + 1] = redis.call('INCR', KEYS[i])
+		redis.call('EXPIREAT', KEYS[i], ARGV[n + i])
+	end
+end
+return out
+`)
+
+// HIncrByAndSet increments field of a hash by incr and sets values on it in
+// one round trip, returning the new count. The pipeline is never re-sent, so
+// the increment applies at most once.
+func (c *Client) HIncrByAndSet(ctx context.Context, key, field string, incr int64, values map[string]interface{}) (int64, error) {
+	pipe := c.pipeline(false)
+	n := pipe.HIncrBy(ctx, key, field, incr)
+	if len(values) > 0 {
+		pipe.HSet(ctx, key, values)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return n.Val(), nil
+}
+
+// HSetMissing copies values into a hash without overwriting the fields it
+// already has, returning how many it copied
+func (c *Client) HSetMissing(ctx context.Context, key string, values map[string]string) (int, error) {
+	if len(values) == 0 {
+		return 0, nil
+	}
+	return c.runWriteScript(ctx, hsetMissingScript, []string{key}, pairs(values)...).Int()
+}
+
+// HSetCapped sets field of a hash unless the hash already has maxFields
+// other fields, and then expires the hash after ttl. It reports whether it
+// set the field.
+func (c *Client) HSetCapped(ctx context.Context, key, field, value string, maxFields int, ttl time.Duration) (bool, error) {
+	n, err := c.runWriteScript(ctx, hsetCappedScript, []string{key}, field, value, maxFields, ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
+// RoomIDs returns the fields of a room hash with the id in their JSON value,
+// skipping those without one, in Redis so the values never cross the wire. It
+// stops after limit rooms and reports whether the hash had more.
+func (c *Client) RoomIDs(ctx context.Context, key string, limit int) ([]RoomID, bool, error) {
+	res, err := c.runScript(ctx, roomIDsScript, []string{key}, limit).Slice()
+	if err != nil {
+		return nil, false, err
+	}
+	truncated := len(res) > 0 && res[0] == int64(1)
+	ids := make([]RoomID, 0, len(res)/2)
+	for i := 1; i+1 < len(res); i += 2 {
+		name, _ := res[i].(string)
+		raw, _ := res[i+1].(string)
+		if id, err := strconv.ParseInt(raw, 10, 64); err == nil && id != 0 {
+			ids = append(ids, RoomID{Name: name, ID: id})
+		}
+	}
+	return ids, truncated, nil
+}
+
+// TakeToken takes one token from the bucket at key, refilled at rate tokens
+// per second up to burst. It reports whether a token was available and, if
+// not, how long until one is. Redis time is used so every replica shares one
+// clock.
+func (c *Client) TakeToken(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error) {
+	res, err := c.runWriteScript(ctx, tokenBucketScript, []string{key}, rate, burst).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	if len(res) != 2 {
+		return false, 0, errs.New(errs.Internal, "unexpected token bucket script result")
+	}
+	return res[0] == 1, time.Duration(res[1]) * time.Millisecond, nil
+}
+
+// IncrWithinLimits increments every counter unless one is already at its
+// limit, in which case none is. It returns the counts, after incrementing if
+// it did, and whether it did. All keys must share a hash slot.
+func (c *Client) IncrWithinLimits(ctx context.Context, counters ...Counter) ([]int64, bool, error) {
+	keys := make([]string, len(counters))
+	args := make([]interface{}, 2*len(counters))
+	for i, counter := range counters {
+		keys[i] = counter.Key
+		args[i] = counter.Limit
+		args[len(counters)+i] = counter.ExpireAt.Unix()
+	}
+	res, err := c.runWriteScript(ctx, incrWithinLimitsScript, keys, args...).Int64Slice()
+	if err != nil {
+		return nil, false, err
+	}
+	if len(res) != 1+len(counters) {
+		return nil, false, errs.New(errs.Internal, "unexpected counter script result")
+	}
+	return res[1:], res[0] == 1, nil
+}
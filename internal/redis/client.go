@@ -3,8 +3,15 @@ package redis
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"room-mapping-cache/internal/errs"
+
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -12,46 +19,131 @@ type Client struct {
 	clusterClient *redis.ClusterClient
 	client        *redis.Client
 	isCluster     bool
+	retry         RetryPolicy
+	failover      *failover
+	keyPrefix     string
+}
+
+// Options configures the client
+type Options struct {
+	Addrs      []string
+	Password   string
+	UseCluster bool
+
+	// DB selects the logical database (single instance only) and KeyPrefix is
+	// prepended to every key, so environments can share one Redis
+	DB        int
+	KeyPrefix string
+
+	// Network is "tcp" (default) or "unix", in which case Addrs holds the
+	// socket path. Unix sockets are single instance only.
+	Network string
+
+	// Cluster read routing. ReadOnly sends reads to replicas; RouteByLatency
+	// and RouteRandomly pick among primary and replicas (both imply ReadOnly).
+	ReadOnly       bool
+	RouteByLatency bool
+	RouteRandomly  bool
+
+	// Pool and timeout settings; zero values fall back to the defaults below.
+	// MinIdleConns is a pointer so an explicit 0 (no idle connections) can be
+	// told apart from unset.
+	PoolSize     int
+	MinIdleConns *int
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	PoolTimeout  time.Duration
+	MaxRetries   int
+
+	// Retry is applied on top of go-redis' own retries to reads and pipelines
+	Retry RetryPolicy
+}
+
+// withDefaults fills unset pool and timeout settings
+func (o Options) withDefaults() Options {
+	if o.PoolSize == 0 {
+		o.PoolSize = 100
+	}
+	if o.MinIdleConns == nil {
+		n := 10
+		o.MinIdleConns = &n
+	}
+	if o.DialTimeout == 0 {
+		o.DialTimeout = 5 * time.Second
+	}
+	if o.ReadTimeout == 0 {
+		o.ReadTimeout = 3 * time.Second
+	}
+	if o.WriteTimeout == 0 {
+		o.WriteTimeout = 3 * time.Second
+	}
+	if o.PoolTimeout == 0 {
+		o.PoolTimeout = 4 * time.Second
+	}
+	if o.MaxRetries == 0 {
+		o.MaxRetries = 3
+	}
+	return o
 }
 
-func NewClient(addrs []string, password string, useCluster bool) (*Client, error) {
-	if len(addrs) == 0 {
+func NewClient(opts Options) (*Client, error) {
+	if len(opts.Addrs) == 0 {
 		return nil, fmt.Errorf("no Redis addresses provided")
 	}
+	opts = opts.withDefaults()
 
-	if useCluster {
+	if opts.UseCluster {
+		if opts.DB != 0 {
+			return nil, fmt.Errorf("redis cluster only supports DB 0")
+		}
+		if opts.Network == "unix" {
+			return nil, fmt.Errorf("unix socket connections are not supported in cluster mode")
+		}
 		rdb := redis.NewClusterClient(&redis.ClusterOptions{
-			Addrs:        addrs,
-			Password:     password,
-			PoolSize:     100,
-			MinIdleConns: 10,
-			DialTimeout:  5 * time.Second,
-			ReadTimeout:  3 * time.Second,
-			WriteTimeout: 3 * time.Second,
-			PoolTimeout:  4 * time.Second,
-			MaxRetries:   3,
+			Addrs:          opts.Addrs,
+			Password:       opts.Password,
+			ReadOnly:       opts.ReadOnly,
+			RouteByLatency: opts.RouteByLatency,
+			RouteRandomly:  opts.RouteRandomly,
+			PoolSize:       opts.PoolSize,
+			MinIdleConns:   *opts.MinIdleConns,
+			DialTimeout:    opts.DialTimeout,
+			ReadTimeout:    opts.ReadTimeout,
+			WriteTimeout:   opts.WriteTimeout,
+			PoolTimeout:    opts.PoolTimeout,
+			MaxRetries:     opts.MaxRetries,
 		})
+		if opts.KeyPrefix != "" {
+			rdb.AddHook(prefixHook{prefix: opts.KeyPrefix})
+		}
 
-		return &Client{clusterClient: rdb, isCluster: true}, nil
+		return &Client{clusterClient: rdb, isCluster: true, retry: opts.Retry, keyPrefix: opts.KeyPrefix}, nil
 	}
 
 	// Single Redis instance mode
-	if len(addrs) > 1 {
+	if len(opts.Addrs) > 1 {
 		return nil, fmt.Errorf("multiple addresses provided but cluster mode is disabled")
 	}
 
 	rdb := redis.NewClient(&redis.Options{
-		Addr:         addrs[0],
-		Password:     password,
-		PoolSize:     100,
-		MinIdleConns: 10,
-		DialTimeout:  5 * time.Second,
-		ReadTimeout:  3 * time.Second,
-		WriteTimeout: 3 * time.Second,
-		PoolTimeout:  4 * time.Second,
+		Network:      opts.Network,
+		Addr:         opts.Addrs[0],
+		Password:     opts.Password,
+		DB:           opts.DB,
+		PoolSize:     opts.PoolSize,
+		MinIdleConns: *opts.MinIdleConns,
+		DialTimeout:  opts.DialTimeout,
+		ReadTimeout:  opts.ReadTimeout,
+		WriteTimeout: opts.WriteTimeout,
+		PoolTimeout:  opts.PoolTimeout,
+		MaxRetries:   opts.MaxRetries,
 	})
+	if opts.KeyPrefix != "" {
+		rdb.AddHook(prefixHook{prefix: opts.KeyPrefix})
+	}
 
-	return &Client{client: rdb, isCluster: false}, nil
+	return &Client{client: rdb, isCluster: false, retry: opts.Retry, keyPrefix: opts.KeyPrefix}, nil
 }
 
 // Ping checks if Redis is accessible
@@ -66,56 +158,619 @@ func (c *Client) Ping(ctx context.Context) error {
 func (c *Client) HealthCheck(ctx context.Context) error {
 	// First, try a simple ping
 	if err := c.Ping(ctx); err != nil {
-		return fmt.Errorf("redis ping failed: %w", err)
+		return errs.Wrap(errs.Degraded, "redis ping failed", err)
 	}
 
 	if c.isCluster {
 		// Try to get cluster info to verify cluster connectivity
 		info, err := c.clusterClient.ClusterInfo(ctx).Result()
 		if err != nil {
-			return fmt.Errorf("redis cluster info failed: %w", err)
+			return errs.Wrap(errs.Degraded, "redis cluster info failed", err)
 		}
 
 		// Verify we got a response (basic validation)
 		if info == "" {
-			return fmt.Errorf("redis cluster info returned empty response")
+			return errs.New(errs.Degraded, "redis cluster info returned empty response")
 		}
 	} else {
-		// For single instance, just verify we can get info
-		info, err := c.client.Info(ctx, "server").Result()
+		// For single instance, just verify we can get info. The small clients
+		// section is one the in-process dev Redis also serves.
+		info, err := c.client.Info(ctx, "clients").Result()
 		if err != nil {
-			return fmt.Errorf("redis info failed: %w", err)
+			return errs.Wrap(errs.Degraded, "redis info failed", err)
 		}
 
 		if info == "" {
-			return fmt.Errorf("redis info returned empty response")
+			return errs.New(errs.Degraded, "redis info returned empty response")
 		}
 	}
 
 	return nil
 }
 
+// IsCluster reports whether the client talks to a Redis Cluster
+func (c *Client) IsCluster() bool {
+	return c.isCluster
+}
+
+// ClusterInfo returns the CLUSTER INFO fields of the endpoint serving reads,
+// or nil for a single instance
+func (c *Client) ClusterInfo(ctx context.Context) (map[string]string, error) {
+	if r := c.reader(); r != c {
+		return r.ClusterInfo(ctx)
+	}
+	if !c.isCluster {
+		return nil, nil
+	}
+	raw, err := c.clusterClient.ClusterInfo(ctx).Result()
+	if err != nil {
+		return nil, err
+	}
+	info := make(map[string]string)
+	for _, line := range strings.Split(raw, "\n") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(line), ":"); ok {
+			info[k] = v
+		}
+	}
+	return info, nil
+}
+
 func (c *Client) Get(ctx context.Context, key string) (string, error) {
+	if r := c.reader(); r != c {
+		return r.Get(ctx, key)
+	}
 	if c.isCluster {
 		return c.clusterClient.Get(ctx, key).Result()
 	}
 	return c.client.Get(ctx, key).Result()
 }
 
-// HGetAll retrieves all fields and values from a Redis hash
+// Set stores a string value with a time to live (0 keeps it forever)
+func (c *Client) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	if c.isCluster {
+		return c.clusterClient.Set(ctx, key, value, ttl).Err()
+	}
+	return c.client.Set(ctx, key, value, ttl).Err()
+}
+
+// SetNX sets key only if it does not exist, reporting whether it was set
+func (c *Client) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	if c.isCluster {
+		return c.clusterClient.SetNX(ctx, key, value, ttl).Result()
+	}
+	return c.client.SetNX(ctx, key, value, ttl).Result()
+}
+
+// PTTL returns a key's remaining time to live; negative values mean no expiry
+// (-1) or no key (-2), as in Redis
+func (c *Client) PTTL(ctx context.Context, key string) (time.Duration, error) {
+	if c.isCluster {
+		return c.clusterClient.PTTL(ctx, key).Result()
+	}
+	return c.client.PTTL(ctx, key).Result()
+}
+
+// HGetAll retrieves all fields and values from a Redis hash, retrying transient failures
 func (c *Client) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	if r := c.reader(); r != c {
+		return r.HGetAll(ctx, key)
+	}
+	var result map[string]string
+	err := c.withRetry(ctx, func() error {
+		var err error
+		if c.isCluster {
+			result, err = c.clusterClient.HGetAll(ctx, key).Result()
+		} else {
+			result, err = c.client.HGetAll(ctx, key).Result()
+		}
+		return err
+	})
+	return result, err
+}
+
+// HMGet reads fields of a Redis hash, retrying transient failures. Missing
+// fields are nil.
+func (c *Client) HMGet(ctx context.Context, key string, fields ...string) ([]interface{}, error) {
+	if r := c.reader(); r != c {
+		return r.HMGet(ctx, key, fields...)
+	}
+	var result []interface{}
+	err := c.withRetry(ctx, func() error {
+		var err error
+		if c.isCluster {
+			result, err = c.clusterClient.HMGet(ctx, key, fields...).Result()
+		} else {
+			result, err = c.client.HMGet(ctx, key, fields...).Result()
+		}
+		return err
+	})
+	return result, err
+}
+
+// HSet sets the given fields on a Redis hash
+func (c *Client) HSet(ctx context.Context, key string, values map[string]interface{}) error {
+	if c.isCluster {
+		return c.clusterClient.HSet(ctx, key, values).Err()
+	}
+	return c.client.HSet(ctx, key, values).Err()
+}
+
+// ReplaceHash replaces a hash's fields with values in one MULTI, so readers
+// never see it deleted or half written. It expires after ttl if positive.
+func (c *Client) ReplaceHash(ctx context.Context, key string, values map[string]interface{}, ttl time.Duration) error {
+	var pipe redis.Pipeliner
+	if c.isCluster {
+		pipe = c.clusterClient.TxPipeline()
+	} else {
+		pipe = c.client.TxPipeline()
+	}
+	pipe.Del(ctx, key)
+	pipe.HSet(ctx, key, values)
+	if ttl > 0 {
+		pipe.PExpire(ctx, key, ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Del deletes the given keys. In cluster mode all keys must hash to the same slot.
+func (c *Client) Del(ctx context.Context, keys ...string) error {
 	if c.isCluster {
-		return c.clusterClient.HGetAll(ctx, key).Result()
+		return c.clusterClient.Del(ctx, keys...).Err()
 	}
-	return c.client.HGetAll(ctx, key).Result()
+	return c.client.Del(ctx, keys...).Err()
 }
 
-// Pipeline returns a new Pipeliner
-func (c *Client) Pipeline() redis.Pipeliner {
+// HGetAllDel deletes a hash in one MULTI with reading it, like GETDEL, and
+// returns the fields it held, so none written in between go unseen
+func (c *Client) HGetAllDel(ctx context.Context, key string) (map[string]string, error) {
+	var pipe redis.Pipeliner
 	if c.isCluster {
-		return c.clusterClient.Pipeline()
+		pipe = c.clusterClient.TxPipeline()
+	} else {
+		pipe = c.client.TxPipeline()
+	}
+	hash := pipe.HGetAll(ctx, key)
+	pipe.Del(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	return hash.Val(), nil
+}
+
+// HDel removes fields from a Redis hash
+func (c *Client) HDel(ctx context.Context, key string, fields ...string) error {
+	if c.isCluster {
+		return c.clusterClient.HDel(ctx, key, fields...).Err()
+	}
+	return c.client.HDel(ctx, key, fields...).Err()
+}
+
+// Expire sets a key's time to live
+func (c *Client) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	if c.isCluster {
+		return c.clusterClient.Expire(ctx, key, ttl).Err()
+	}
+	return c.client.Expire(ctx, key, ttl).Err()
+}
+
+// Persist removes a key's time to live
+func (c *Client) Persist(ctx context.Context, key string) error {
+	if c.isCluster {
+		return c.clusterClient.Persist(ctx, key).Err()
+	}
+	return c.client.Persist(ctx, key).Err()
+}
+
+// SMembers returns all members of a Redis set
+func (c *Client) SMembers(ctx context.Context, key string) ([]string, error) {
+	if r := c.reader(); r != c {
+		return r.SMembers(ctx, key)
+	}
+	if c.isCluster {
+		return c.clusterClient.SMembers(ctx, key).Result()
+	}
+	return c.client.SMembers(ctx, key).Result()
+}
+
+// Publish posts a message to a pub/sub channel
+func (c *Client) Publish(ctx context.Context, channel, message string) error {
+	if c.isCluster {
+		return c.clusterClient.Publish(ctx, channel, message).Err()
+	}
+	return c.client.Publish(ctx, channel, message).Err()
+}
+
+// Subscribe subscribes to pub/sub channels; the caller must Close the
+// subscription. go-redis re-subscribes automatically after connection loss.
+func (c *Client) Subscribe(ctx context.Context, channels ...string) Subscription {
+	if c.keyPrefix != "" {
+		prefixed := make([]string, len(channels))
+		for i, ch := range channels {
+			prefixed[i] = c.keyPrefix + ch
+		}
+		channels = prefixed
+	}
+	if c.isCluster {
+		return newSubscription(c.clusterClient.Subscribe(ctx, channels...))
+	}
+	return newSubscription(c.client.Subscribe(ctx, channels...))
+}
+
+// subscription relays a go-redis PubSub's messages as Messages
+type subscription struct {
+	pubsub *redis.PubSub
+	ch     chan Message
+	done   chan struct{}
+	once   sync.Once
+}
+
+func newSubscription(pubsub *redis.PubSub) *subscription {
+	s := &subscription{pubsub: pubsub, ch: make(chan Message, 100), done: make(chan struct{})}
+	go s.relay(pubsub.Channel())
+	return s
+}
+
+func (s *subscription) relay(in <-chan *redis.Message) {
+	defer close(s.ch)
+	for msg := range in {
+		select {
+		case s.ch <- Message{Channel: msg.Channel, Payload: msg.Payload}:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *subscription) Channel() <-chan Message {
+	return s.ch
+}
+
+func (s *subscription) Close() error {
+	s.once.Do(func() { close(s.done) })
+	return s.pubsub.Close()
+}
+
+// XGroupCreate creates a consumer group starting at start, creating the stream
+// if needed. An already existing group is not an error.
+func (c *Client) XGroupCreate(ctx context.Context, stream, group, start string) error {
+	var err error
+	if c.isCluster {
+		err = c.clusterClient.XGroupCreateMkStream(ctx, stream, group, start).Err()
+	} else {
+		err = c.client.XGroupCreateMkStream(ctx, stream, group, start).Err()
+	}
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+	return err
+}
+
+// XReadGroup reads up to count entries for consumer, blocking up to block.
+// Pass ">" as id for new entries or "0" to re-read the consumer's pending ones.
+func (c *Client) XReadGroup(ctx context.Context, stream, group, consumer, id string, count int64, block time.Duration) ([]StreamEntry, error) {
+	args := &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  []string{stream, id},
+		Count:    count,
+		Block:    block,
+	}
+	var (
+		streams []redis.XStream
+		err     error
+	)
+	if c.isCluster {
+		streams, err = c.clusterClient.XReadGroup(ctx, args).Result()
+	} else {
+		streams, err = c.client.XReadGroup(ctx, args).Result()
+	}
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil || len(streams) == 0 {
+		return nil, err
+	}
+	return streamEntries(streams[0].Messages), nil
+}
+
+// XAck acknowledges processed stream entries
+func (c *Client) XAck(ctx context.Context, stream, group string, ids ...string) error {
+	if c.isCluster {
+		return c.clusterClient.XAck(ctx, stream, group, ids...).Err()
+	}
+	return c.client.XAck(ctx, stream, group, ids...).Err()
+}
+
+// XAutoClaim transfers to consumer up to count pending entries idle for at
+// least minIdle, scanning from start. It returns the claimed entries and the
+// cursor for the next call, which is "0-0" once the pending list is covered.
+func (c *Client) XAutoClaim(ctx context.Context, stream, group, consumer string, minIdle time.Duration, start string, count int64) ([]StreamEntry, string, error) {
+	args := &redis.XAutoClaimArgs{
+		Stream:   stream,
+		Group:    group,
+		Consumer: consumer,
+		MinIdle:  minIdle,
+		Start:    start,
+		Count:    count,
+	}
+	var (
+		msgs []redis.XMessage
+		next string
+		err  error
+	)
+	if c.isCluster {
+		msgs, next, err = c.clusterClient.XAutoClaim(ctx, args).Result()
+	} else {
+		msgs, next, err = c.client.XAutoClaim(ctx, args).Result()
+	}
+	return streamEntries(msgs), next, err
+}
+
+// streamEntries converts go-redis stream messages. Values are always strings
+// on the wire.
+func streamEntries(msgs []redis.XMessage) []StreamEntry {
+	if len(msgs) == 0 {
+		return nil
+	}
+	entries := make([]StreamEntry, len(msgs))
+	for i, msg := range msgs {
+		values := make(map[string]string, len(msg.Values))
+		for k, v := range msg.Values {
+			values[k], _ = v.(string)
+		}
+		entries[i] = StreamEntry{ID: msg.ID, Values: values}
 	}
-	return c.client.Pipeline()
+	return entries
+}
+
+// XDeliveries returns how many times a pending entry has been delivered, or 0
+// if it is no longer pending
+func (c *Client) XDeliveries(ctx context.Context, stream, group, id string) (int64, error) {
+	args := &redis.XPendingExtArgs{Stream: stream, Group: group, Start: id, End: id, Count: 1}
+	var (
+		pending []redis.XPendingExt
+		err     error
+	)
+	if c.isCluster {
+		pending, err = c.clusterClient.XPendingExt(ctx, args).Result()
+	} else {
+		pending, err = c.client.XPendingExt(ctx, args).Result()
+	}
+	if err != nil || len(pending) == 0 {
+		return 0, err
+	}
+	return pending[0].RetryCount, nil
+}
+
+// XAdd appends an entry to a stream
+func (c *Client) XAdd(ctx context.Context, stream string, values map[string]string) error {
+	args := &redis.XAddArgs{Stream: stream, Values: values}
+	if c.isCluster {
+		return c.clusterClient.XAdd(ctx, args).Err()
+	}
+	return c.client.XAdd(ctx, args).Err()
+}
+
+// AddHook installs a go-redis hook, e.g. for instrumentation. Hooks added
+// here run after the key prefix has been applied.
+func (c *Client) AddHook(hook redis.Hook) {
+	if c.isCluster {
+		c.clusterClient.AddHook(hook)
+		return
+	}
+	c.client.AddHook(hook)
+}
+
+// InstrumentTracing adds an OpenTelemetry span to every command and pipeline,
+// including those sent to the secondary, tagged with the request ID.
+// Statements are not recorded since HSET values carry room data.
+func (c *Client) InstrumentTracing() error {
+	if c.failover != nil {
+		if err := c.failover.secondary.InstrumentTracing(); err != nil {
+			return err
+		}
+	}
+	var err error
+	if c.isCluster {
+		err = redisotel.InstrumentTracing(c.clusterClient, redisotel.WithDBStatement(false))
+	} else {
+		err = redisotel.InstrumentTracing(c.client, redisotel.WithDBStatement(false))
+	}
+	if err != nil {
+		return err
+	}
+	c.AddHook(requestIDHook{})
+	return nil
+}
+
+// KeyPrefix returns the global key prefix, for callers that build raw channel
+// names such as keyspace notification patterns
+func (c *Client) KeyPrefix() string {
+	return c.keyPrefix
+}
+
+// PSubscribeAllNodes pattern-subscribes on every master. Keyspace notifications
+// are only delivered by the node that owns the key, so a single cluster
+// subscription would miss most events. The caller must Close each one.
+func (c *Client) PSubscribeAllNodes(ctx context.Context, patterns ...string) ([]Subscription, error) {
+	if !c.isCluster {
+		return []Subscription{newSubscription(c.client.PSubscribe(ctx, patterns...))}, nil
+	}
+	masters, err := c.masters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	subs := make([]Subscription, 0, len(masters))
+	for _, master := range masters {
+		subs = append(subs, newSubscription(master.PSubscribe(ctx, patterns...)))
+	}
+	return subs, nil
+}
+
+// runScript runs a read-only Lua script via EVALSHA, loading it on first use
+func (c *Client) runScript(ctx context.Context, script *redis.Script, keys []string, args ...interface{}) *redis.Cmd {
+	if r := c.reader(); r != c {
+		return r.runScript(ctx, script, keys, args...)
+	}
+	if c.isCluster {
+		return script.Run(ctx, c.clusterClient, keys, args...)
+	}
+	return script.Run(ctx, c.client, keys, args...)
+}
+
+// runWriteScript runs a Lua script that writes via EVALSHA on the primary,
+// loading it on first use
+func (c *Client) runWriteScript(ctx context.Context, script *redis.Script, keys []string, args ...interface{}) *redis.Cmd {
+	if c.isCluster {
+		return script.Run(ctx, c.clusterClient, keys, args...)
+	}
+	return script.Run(ctx, c.client, keys, args...)
+}
+
+// pipeline returns a new Pipeliner on this client, retrying per c.retry only
+// when retry is set. Its Exec otherwise fails every command when the
+// connection failed but never re-sends them: a write may have been applied
+// before the failure and INCR or HINCRBY would then apply twice.
+func (c *Client) pipeline(retry bool) redis.Pipeliner {
+	var pipe redis.Pipeliner
+	if c.isCluster {
+		pipe = c.clusterClient.Pipeline()
+	} else {
+		pipe = c.client.Pipeline()
+	}
+	var policy RetryPolicy
+	if retry {
+		policy = c.retry
+	}
+	return &retryPipeline{Pipeliner: pipe, policy: policy}
+}
+
+// ScanKeys runs one SCAN step over the keyspace. In cluster mode the scan walks
+// every master in turn; the returned cursor is opaque and encodes both the node
+// index and the node-local cursor. An empty next cursor means the scan is done.
+func (c *Client) ScanKeys(ctx context.Context, cursor, match string, count int64) ([]string, string, error) {
+	if r := c.reader(); r != c {
+		return r.ScanKeys(ctx, cursor, match, count)
+	}
+	if c.keyPrefix != "" {
+		found, next, err := c.scanKeys(ctx, cursor, c.keyPrefix+match, count)
+		for i, key := range found {
+			found[i] = strings.TrimPrefix(key, c.keyPrefix)
+		}
+		return found, next, err
+	}
+	return c.scanKeys(ctx, cursor, match, count)
+}
+
+func (c *Client) scanKeys(ctx context.Context, cursor, match string, count int64) ([]string, string, error) {
+	if !c.isCluster {
+		cur, err := parseScanCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		keys, next, err := c.client.Scan(ctx, cur, match, count).Result()
+		if err != nil {
+			return nil, "", err
+		}
+		if next == 0 {
+			return keys, "", nil
+		}
+		return keys, strconv.FormatUint(next, 10), nil
+	}
+
+	node, cur := 0, uint64(0)
+	if cursor != "" {
+		nodePart, curPart, ok := strings.Cut(cursor, "-")
+		if !ok {
+			return nil, "", errs.New(errs.Invalid, "invalid scan cursor")
+		}
+		n, err := strconv.Atoi(nodePart)
+		if err != nil || n < 0 {
+			return nil, "", errs.New(errs.Invalid, "invalid scan cursor")
+		}
+		if cur, err = parseScanCursor(curPart); err != nil {
+			return nil, "", err
+		}
+		node = n
+	}
+
+	masters, err := c.masters(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	if node >= len(masters) {
+		return nil, "", nil
+	}
+
+	keys, next, err := masters[node].Scan(ctx, cur, match, count).Result()
+	if err != nil {
+		return nil, "", err
+	}
+	if next == 0 {
+		node++
+		if node >= len(masters) {
+			return keys, "", nil
+		}
+	}
+	return keys, fmt.Sprintf("%d-%d", node, next), nil
+}
+
+// masters returns the cluster master clients in a stable (address) order so
+// that node-indexed scan cursors stay valid between calls.
+func (c *Client) masters(ctx context.Context) ([]*redis.Client, error) {
+	var (
+		mu      sync.Mutex
+		clients []*redis.Client
+	)
+	err := c.clusterClient.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+		mu.Lock()
+		clients = append(clients, client)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, errs.Classify("failed to list cluster masters", err)
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].Options().Addr < clients[j].Options().Addr })
+	return clients, nil
+}
+
+func parseScanCursor(cursor string) (uint64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	cur, err := strconv.ParseUint(cursor, 10, 64)
+	if err != nil {
+		return 0, errs.New(errs.Invalid, "invalid scan cursor")
+	}
+	return cur, nil
+}
+
+// PoolStats returns connection pool counters, summed over all nodes in cluster mode
+func (c *Client) PoolStats() *PoolStats {
+	var s *redis.PoolStats
+	if c.isCluster {
+		s = c.clusterClient.PoolStats()
+	} else {
+		s = c.client.PoolStats()
+	}
+	return &PoolStats{
+		Hits:       s.Hits,
+		Misses:     s.Misses,
+		Timeouts:   s.Timeouts,
+		TotalConns: s.TotalConns,
+		IdleConns:  s.IdleConns,
+		StaleConns: s.StaleConns,
+	}
+}
+
+// SecondaryPoolStats returns the DR endpoint's pool counters, or nil if none is configured
+func (c *Client) SecondaryPoolStats() *PoolStats {
+	if c.failover == nil {
+		return nil
+	}
+	return c.failover.secondary.PoolStats()
 }
 
 func (c *Client) Close() error {
@@ -124,3 +779,174 @@ func (c *Client) Close() error {
 	}
 	return c.client.Close()
 }
+
+// HGetAllMulti fetches many hashes in pipelines. In cluster mode keys are
+// grouped by owning node and each node's pipeline runs concurrently, so a slow
+// or failing node only affects its own keys. The returned commands are aligned
+// with keys; the error is the first pipeline failure, if any.
+func (c *Client) HGetAllMulti(ctx context.Context, keys []string) ([]*HashResult, error) {
+	if r := c.reader(); r != c {
+		return r.HGetAllMulti(ctx, keys)
+	}
+	cmds := make([]*redis.MapStringStringCmd, len(keys))
+	err := c.pipelineByNode(ctx, keys, true, func(pipe redis.Pipeliner, i int) {
+		cmds[i] = pipe.HGetAll(ctx, keys[i])
+	})
+	results := make([]*HashResult, len(keys))
+	for i, cmd := range cmds {
+		results[i] = NewHashResult(cmd.Result())
+	}
+	return results, err
+}
+
+// HSetMulti writes fields into many hashes, pipelined by node as in
+// HGetAllMulti. values is aligned with keys. The error is the first pipeline
+// failure, if any; per-key errors are returned aligned with keys.
+func (c *Client) HSetMulti(ctx context.Context, keys []string, values []map[string]interface{}) ([]error, error) {
+	cmds := make([]*redis.IntCmd, len(keys))
+	err := c.pipelineByNode(ctx, keys, false, func(pipe redis.Pipeliner, i int) {
+		cmds[i] = pipe.HSet(ctx, keys[i], values[i])
+	})
+	keyErrs := make([]error, len(keys))
+	for i, cmd := range cmds {
+		keyErrs[i] = cmd.Err()
+	}
+	return keyErrs, err
+}
+
+// PTTLMulti returns the remaining time to live of many keys, pipelined by node
+// as in HGetAllMulti. Values follow PTTL; the commands are aligned with keys.
+func (c *Client) PTTLMulti(ctx context.Context, keys []string) ([]*redis.DurationCmd, error) {
+	if r := c.reader(); r != c {
+		return r.PTTLMulti(ctx, keys)
+	}
+	cmds := make([]*redis.DurationCmd, len(keys))
+	err := c.pipelineByNode(ctx, keys, true, func(pipe redis.Pipeliner, i int) {
+		cmds[i] = pipe.PTTL(ctx, keys[i])
+	})
+	return cmds, err
+}
+
+// RestoreHashes writes whole hashes in pipelines grouped by node. Each key is
+// deleted first when replace is set, and expires after its ttl if positive.
+// The returned HSET commands are aligned with keys.
+func (c *Client) RestoreHashes(ctx context.Context, keys []string, values []map[string]interface{}, ttls []time.Duration, replace bool) ([]*redis.IntCmd, error) {
+	cmds := make([]*redis.IntCmd, len(keys))
+	err := c.pipelineByNode(ctx, keys, false, func(pipe redis.Pipeliner, i int) {
+		if replace {
+			pipe.Del(ctx, keys[i])
+		}
+		cmds[i] = pipe.HSet(ctx, keys[i], values[i])
+		if ttls[i] > 0 {
+			pipe.PExpire(ctx, keys[i], ttls[i])
+		}
+	})
+	return cmds, err
+}
+
+// HLenMulti returns the field count of each hash, aligned with keys. Keys whose
+// lookup failed report -1.
+func (c *Client) HLenMulti(ctx context.Context, keys []string) ([]int64, error) {
+	if r := c.reader(); r != c {
+		return r.HLenMulti(ctx, keys)
+	}
+	cmds := make([]*redis.IntCmd, len(keys))
+	err := c.pipelineByNode(ctx, keys, true, func(pipe redis.Pipeliner, i int) {
+		cmds[i] = pipe.HLen(ctx, keys[i])
+	})
+	lens := make([]int64, len(keys))
+	for i, cmd := range cmds {
+		if cmd == nil || cmd.Err() != nil {
+			lens[i] = -1
+			continue
+		}
+		lens[i] = cmd.Val()
+	}
+	return lens, err
+}
+
+// HScanLimited reads a hash incrementally with HSCAN, stopping once limit
+// fields have been collected. Used instead of HGETALL for oversized hashes.
+func (c *Client) HScanLimited(ctx context.Context, key string, limit int) (map[string]string, error) {
+	if r := c.reader(); r != c {
+		return r.HScanLimited(ctx, key, limit)
+	}
+	result := make(map[string]string, limit)
+	var cursor uint64
+	for {
+		var (
+			kvs []string
+			err error
+		)
+		if c.isCluster {
+			kvs, cursor, err = c.clusterClient.HScan(ctx, key, cursor, "", 500).Result()
+		} else {
+			kvs, cursor, err = c.client.HScan(ctx, key, cursor, "", 500).Result()
+		}
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i+1 < len(kvs); i += 2 {
+			result[kvs[i]] = kvs[i+1]
+			if len(result) >= limit {
+				return result, nil
+			}
+		}
+		if cursor == 0 {
+			return result, nil
+		}
+	}
+}
+
+// pipelineByNode queues one command per key via queue and executes them. In
+// cluster mode there is one pipeline per owning node, run concurrently. Only
+// set retry when every queued command is safe to send twice.
+func (c *Client) pipelineByNode(ctx context.Context, keys []string, retry bool, queue func(pipe redis.Pipeliner, i int)) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	if !c.isCluster {
+		pipe := c.pipeline(retry)
+		for i := range keys {
+			queue(pipe, i)
+		}
+		_, err := pipe.Exec(ctx)
+		return err
+	}
+
+	// Group key indexes by the node that owns their slot
+	groups := make(map[string][]int)
+	for i, key := range keys {
+		node := ""
+		if master, err := c.clusterClient.MasterForKey(ctx, c.keyPrefix+key); err == nil {
+			node = master.Options().Addr
+		}
+		groups[node] = append(groups[node], i)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	for _, idxs := range groups {
+		pipe := c.pipeline(retry)
+		for _, i := range idxs {
+			queue(pipe, i)
+		}
+		wg.Add(1)
+		go func(pipe redis.Pipeliner) {
+			defer wg.Done()
+			if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}(pipe)
+	}
+	wg.Wait()
+	return firstErr
+}
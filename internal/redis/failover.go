@@ -0,0 +1,134 @@
+package redis
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// failover holds the optional DR endpoint that serves reads while the primary
+// is unhealthy. Writes always go to the primary.
+type failover struct {
+	secondary *Client
+	active    atomic.Bool // true while reads are served by the secondary
+	switches  atomic.Int64
+
+	// Reads switch after failAfter consecutive failed primary checks and back
+	// after recoverAfter consecutive good ones, so a flapping primary does
+	// not bounce reads between endpoints
+	failAfter    int
+	recoverAfter int
+
+	mu        sync.Mutex
+	checked   bool // a check has run; the first one decides alone
+	failures  int
+	successes int
+}
+
+// SetSecondary configures a DR endpoint for reads, switched to after
+// failAfter consecutive failed primary checks and back after recoverAfter
+// consecutive good ones (values below 1 mean 1). Call before serving traffic.
+func (c *Client) SetSecondary(secondary *Client, failAfter, recoverAfter int) {
+	c.failover = &failover{
+		secondary:    secondary,
+		failAfter:    max(failAfter, 1),
+		recoverAfter: max(recoverAfter, 1),
+	}
+}
+
+// FailedOver reports whether reads are currently served by the secondary
+func (c *Client) FailedOver() bool {
+	return c.failover != nil && c.failover.active.Load()
+}
+
+// FailoverSwitches returns how many times reads have switched endpoints
+func (c *Client) FailoverSwitches() int64 {
+	if c.failover == nil {
+		return 0
+	}
+	return c.failover.switches.Load()
+}
+
+// reader returns the client that should serve reads
+func (c *Client) reader() *Client {
+	if c.FailedOver() {
+		return c.failover.secondary
+	}
+	return c
+}
+
+// ActiveHealthCheck checks the endpoint currently serving reads
+func (c *Client) ActiveHealthCheck(ctx context.Context) error {
+	return c.reader().HealthCheck(ctx)
+}
+
+// MonitorFailover health-checks the primary every interval, moving reads to
+// the secondary while it fails and back once it recovers. It is a no-op
+// without a secondary and returns when ctx is cancelled.
+func (c *Client) MonitorFailover(ctx context.Context, interval time.Duration) {
+	if c.failover == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			checkCtx, cancel := context.WithTimeout(ctx, interval)
+			c.CheckFailover(checkCtx)
+			cancel()
+		}
+	}
+}
+
+// CheckFailover runs one primary health check and switches the read endpoint
+// once enough consecutive checks agree. The first check switches on its own,
+// so a service started while the primary is down reads from the secondary at
+// once. It is a no-op without a secondary.
+func (c *Client) CheckFailover(ctx context.Context) {
+	f := c.failover
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	primaryErr := c.HealthCheck(ctx)
+	if primaryErr != nil {
+		f.failures++
+		f.successes = 0
+	} else {
+		f.successes++
+		f.failures = 0
+	}
+	first := !f.checked
+	f.checked = true
+
+	switch {
+	case primaryErr == nil && f.active.Load():
+		if f.successes < f.recoverAfter {
+			slog.Info("Redis primary healthy, waiting before switching reads back", "consecutive", f.successes, "threshold", f.recoverAfter)
+			return
+		}
+		f.active.Store(false)
+		f.switches.Add(1)
+		slog.Info("Redis primary recovered, switching reads back to primary")
+	case primaryErr != nil && !f.active.Load():
+		if f.failures < f.failAfter && !first {
+			slog.Warn("Redis primary health check failed", "error", primaryErr, "consecutive", f.failures, "threshold", f.failAfter)
+			return
+		}
+		if err := f.secondary.HealthCheck(ctx); err != nil {
+			slog.Error("Redis primary unhealthy and secondary unavailable", "primary_error", primaryErr, "error", err)
+			return
+		}
+		f.active.Store(true)
+		f.switches.Add(1)
+		slog.Warn("Redis primary unhealthy, failing reads over to secondary", "error", primaryErr)
+	}
+}
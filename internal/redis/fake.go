@@ -0,0 +1,377 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Fake is a test double for the KeyValue, HashReader and Status parts of the
+// store, for components that depend on no more than those (idempotency keys,
+// API keys, room reads, health). It keeps plain maps and can fail every call
+// or report a failover on demand. Components that need more of the store can
+// be tested against Memory.
+type Fake struct {
+	mu      sync.Mutex
+	strings map[string]string
+	hashes  map[string]map[string]string
+	expires map[string]time.Time
+
+	err        atomic.Pointer[error]
+	failedOver atomic.Bool
+}
+
+var (
+	_ KeyValue   = (*Fake)(nil)
+	_ HashReader = (*Fake)(nil)
+	_ Status     = (*Fake)(nil)
+)
+
+var errWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+// roomIDRe finds the id in a room value, as the RoomIDs script does
+var roomIDRe = regexp.MustCompile(`"id"\s*:\s*"?(\d+)`)
+
+// NewFake returns an empty Fake
+func NewFake() *Fake {
+	return &Fake{
+		strings: make(map[string]string),
+		hashes:  make(map[string]map[string]string),
+		expires: make(map[string]time.Time),
+	}
+}
+
+// SetHash seeds the hash at key with fields, replacing whatever was there
+func (f *Fake) SetHash(key string, fields map[string]string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.drop(key)
+	hash := make(map[string]string, len(fields))
+	for field, value := range fields {
+		hash[field] = value
+	}
+	f.hashes[key] = hash
+}
+
+// SetError makes every following call, health checks included, fail with err
+// until it is cleared with nil
+func (f *Fake) SetError(err error) {
+	if err == nil {
+		f.err.Store(nil)
+		return
+	}
+	f.err.Store(&err)
+}
+
+// SetFailedOver makes FailedOver report reads as served by the secondary
+func (f *Fake) SetFailedOver(v bool) {
+	f.failedOver.Store(v)
+}
+
+// check returns the error a call fails with, if any
+func (f *Fake) check(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := f.err.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+// live drops key if it has expired and reports whether it exists
+func (f *Fake) live(key string) bool {
+	if at, ok := f.expires[key]; ok && !time.Now().Before(at) {
+		f.drop(key)
+	}
+	_, isString := f.strings[key]
+	_, isHash := f.hashes[key]
+	return isString || isHash
+}
+
+func (f *Fake) drop(key string) {
+	delete(f.strings, key)
+	delete(f.hashes, key)
+	delete(f.expires, key)
+}
+
+// hash returns the live hash at key, nil if there is none
+func (f *Fake) hash(key string) (map[string]string, error) {
+	if !f.live(key) {
+		return nil, nil
+	}
+	hash, ok := f.hashes[key]
+	if !ok {
+		return nil, errWrongType
+	}
+	return hash, nil
+}
+
+func (f *Fake) Get(ctx context.Context, key string) (string, error) {
+	if err := f.check(ctx); err != nil {
+		return "", err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.live(key) {
+		return "", Nil
+	}
+	value, ok := f.strings[key]
+	if !ok {
+		return "", errWrongType
+	}
+	return value, nil
+}
+
+func (f *Fake) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	if err := f.check(ctx); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.set(key, value, ttl)
+	return nil
+}
+
+func (f *Fake) set(key, value string, ttl time.Duration) {
+	f.drop(key)
+	f.strings[key] = value
+	if ttl > 0 {
+		f.expires[key] = time.Now().Add(ttl)
+	}
+}
+
+func (f *Fake) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	if err := f.check(ctx); err != nil {
+		return false, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.live(key) {
+		return false, nil
+	}
+	f.set(key, value, ttl)
+	return true, nil
+}
+
+func (f *Fake) Del(ctx context.Context, keys ...string) error {
+	if err := f.check(ctx); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, key := range keys {
+		f.drop(key)
+	}
+	return nil
+}
+
+// PTTL returns -2 for a missing key and -1 for one without expiry, as Redis
+// does
+func (f *Fake) PTTL(ctx context.Context, key string) (time.Duration, error) {
+	if err := f.check(ctx); err != nil {
+		return 0, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.live(key) {
+		return -2, nil
+	}
+	at, ok := f.expires[key]
+	if !ok {
+		return -1, nil
+	}
+	return time.Until(at).Truncate(time.Millisecond), nil
+}
+
+func (f *Fake) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	if err := f.check(ctx); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case !f.live(key):
+	case ttl <= 0:
+		f.drop(key)
+	default:
+		f.expires[key] = time.Now().Add(ttl)
+	}
+	return nil
+}
+
+func (f *Fake) Persist(ctx context.Context, key string) error {
+	if err := f.check(ctx); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.expires, key)
+	return nil
+}
+
+func (f *Fake) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	if err := f.check(ctx); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	hash, err := f.hash(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(hash))
+	for field, value := range hash {
+		out[field] = value
+	}
+	return out, nil
+}
+
+func (f *Fake) HMGet(ctx context.Context, key string, fields ...string) ([]interface{}, error) {
+	if err := f.check(ctx); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	hash, err := f.hash(key)
+	if err != nil {
+		return nil, err
+	}
+	vals := make([]interface{}, len(fields))
+	for i, field := range fields {
+		if value, ok := hash[field]; ok {
+			vals[i] = value
+		}
+	}
+	return vals, nil
+}
+
+// HScanLimited returns the first limit fields in name order
+func (f *Fake) HScanLimited(ctx context.Context, key string, limit int) (map[string]string, error) {
+	if err := f.check(ctx); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	hash, err := f.hash(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, min(limit, len(hash)))
+	for _, field := range sortedFields(hash) {
+		if len(out) >= limit {
+			break
+		}
+		out[field] = hash[field]
+	}
+	return out, nil
+}
+
+func (f *Fake) HGetAllMulti(ctx context.Context, keys []string) ([]*HashResult, error) {
+	results := make([]*HashResult, len(keys))
+	if err := f.check(ctx); err != nil {
+		for i := range results {
+			results[i] = NewHashResult(nil, err)
+		}
+		return results, err
+	}
+	for i, key := range keys {
+		results[i] = NewHashResult(f.HGetAll(ctx, key))
+	}
+	return results, nil
+}
+
+func (f *Fake) HLenMulti(ctx context.Context, keys []string) ([]int64, error) {
+	lens := make([]int64, len(keys))
+	err := f.check(ctx)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, key := range keys {
+		hash, keyErr := f.hash(key)
+		if err != nil || keyErr != nil {
+			lens[i] = -1
+			continue
+		}
+		lens[i] = int64(len(hash))
+	}
+	return lens, err
+}
+
+// RoomIDs returns the rooms in name order, as far as limit
+func (f *Fake) RoomIDs(ctx context.Context, key string, limit int) ([]RoomID, bool, error) {
+	if err := f.check(ctx); err != nil {
+		return nil, false, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	hash, err := f.hash(key)
+	if err != nil {
+		return nil, false, err
+	}
+	var ids []RoomID
+	for _, name := range sortedFields(hash) {
+		m := roomIDRe.FindStringSubmatch(hash[name])
+		if m == nil {
+			continue
+		}
+		id, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil || id == 0 {
+			continue
+		}
+		if len(ids) >= limit {
+			return ids, true, nil
+		}
+		ids = append(ids, RoomID{Name: name, ID: id})
+	}
+	return ids, false, nil
+}
+
+func sortedFields(hash map[string]string) []string {
+	fields := make([]string, 0, len(hash))
+	for field := range hash {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func (f *Fake) HealthCheck(ctx context.Context) error {
+	return f.check(ctx)
+}
+
+func (f *Fake) ActiveHealthCheck(ctx context.Context) error {
+	return f.check(ctx)
+}
+
+func (f *Fake) IsCluster() bool {
+	return false
+}
+
+func (f *Fake) ClusterInfo(ctx context.Context) (map[string]string, error) {
+	return nil, f.check(ctx)
+}
+
+func (f *Fake) KeyPrefix() string {
+	return ""
+}
+
+func (f *Fake) FailedOver() bool {
+	return f.failedOver.Load()
+}
+
+func (f *Fake) FailoverSwitches() int64 {
+	return 0
+}
+
+func (f *Fake) PoolStats() *PoolStats {
+	return nil
+}
+
+func (f *Fake) SecondaryPoolStats() *PoolStats {
+	return nil
+}
@@ -0,0 +1,147 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeAndStore is the part of the store the Fake stands in for
+type fakeAndStore interface {
+	KeyValue
+	HashReader
+	Status
+}
+
+// The Fake must answer like the store it stands in for
+func TestFakeMatchesStore(t *testing.T) {
+	stores := map[string]func(t *testing.T) (fakeAndStore, func(key string, fields map[string]string)){
+		"fake": func(t *testing.T) (fakeAndStore, func(string, map[string]string)) {
+			f := NewFake()
+			return f, f.SetHash
+		},
+		"memory": func(t *testing.T) (fakeAndStore, func(string, map[string]string)) {
+			s := newTestStore(t)
+			return s, func(key string, fields map[string]string) {
+				values := make(map[string]interface{}, len(fields))
+				for field, value := range fields {
+					values[field] = value
+				}
+				must(t, s.ReplaceHash(context.Background(), key, values, 0))
+			}
+		},
+	}
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			s, setHash := open(t)
+			ctx := context.Background()
+
+			if _, err := s.Get(ctx, "k"); !errors.Is(err, Nil) {
+				t.Errorf("get missing: err %v, want Nil", err)
+			}
+			if ok, err := s.SetNX(ctx, "k", "v", time.Minute); !ok || err != nil {
+				t.Errorf("setnx: %v %v", ok, err)
+			}
+			if ok, _ := s.SetNX(ctx, "k", "w", 0); ok {
+				t.Error("setnx overwrote an existing key")
+			}
+			if ttl, _ := s.PTTL(ctx, "k"); ttl <= 0 || ttl > time.Minute {
+				t.Errorf("pttl: %v", ttl)
+			}
+			must(t, s.Persist(ctx, "k"))
+			if ttl, _ := s.PTTL(ctx, "k"); ttl != -1 {
+				t.Errorf("pttl after persist: %v, want -1", ttl)
+			}
+			if _, err := s.HGetAll(ctx, "k"); err == nil {
+				t.Error("hgetall on a string: no error")
+			}
+			must(t, s.Del(ctx, "k"))
+
+			setHash("rooms", map[string]string{"Twin": `{"id":2}`, "Double": `{"id": "1"}`, "Broken": `{}`})
+			if got, _ := s.HGetAll(ctx, "rooms"); len(got) != 3 || got["Twin"] != `{"id":2}` {
+				t.Errorf("hgetall: %v", got)
+			}
+			if got, _ := s.HGetAll(ctx, "missing"); got == nil || len(got) != 0 {
+				t.Errorf("hgetall missing: %#v, want empty", got)
+			}
+			if vals, _ := s.HMGet(ctx, "rooms", "Twin", "Suite"); len(vals) != 2 || vals[0] != `{"id":2}` || vals[1] != nil {
+				t.Errorf("hmget: %v", vals)
+			}
+			if part, _ := s.HScanLimited(ctx, "rooms", 2); len(part) != 2 {
+				t.Errorf("hscan limited: %d fields, want 2", len(part))
+			}
+			results, err := s.HGetAllMulti(ctx, []string{"rooms", "missing"})
+			if err != nil || len(results) != 2 || len(results[0].Val()) != 3 || len(results[1].Val()) != 0 {
+				t.Errorf("hgetall multi: %v", err)
+			}
+			if lens, _ := s.HLenMulti(ctx, []string{"rooms", "missing"}); !reflect.DeepEqual(lens, []int64{3, 0}) {
+				t.Errorf("hlen multi: %v", lens)
+			}
+			ids, truncated, err := s.RoomIDs(ctx, "rooms", 2)
+			if err != nil || truncated || len(ids) != 2 {
+				t.Errorf("room ids: %v %v %v", ids, truncated, err)
+			}
+			if ids, truncated, _ := s.RoomIDs(ctx, "rooms", 1); len(ids) != 1 || !truncated {
+				t.Errorf("room ids over the limit: %v, truncated %v", ids, truncated)
+			}
+
+			if err := s.ActiveHealthCheck(ctx); err != nil || s.IsCluster() || s.FailedOver() {
+				t.Errorf("status: health %v, cluster %v, failed over %v", err, s.IsCluster(), s.FailedOver())
+			}
+		})
+	}
+}
+
+func TestFakeExpiry(t *testing.T) {
+	ctx := context.Background()
+	f := NewFake()
+	must(t, f.Set(ctx, "k", "v", time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	if _, err := f.Get(ctx, "k"); !errors.Is(err, Nil) {
+		t.Errorf("get expired: err %v, want Nil", err)
+	}
+	if ttl, _ := f.PTTL(ctx, "k"); ttl != -2 {
+		t.Errorf("pttl of an expired key: %v, want -2", ttl)
+	}
+}
+
+func TestFakeSetError(t *testing.T) {
+	ctx := context.Background()
+	f := NewFake()
+	f.SetHash("room:{1001}", map[string]string{"Twin": `{"id":1}`})
+
+	outage := errors.New("connection refused")
+	f.SetError(outage)
+	if _, err := f.HGetAll(ctx, "room:{1001}"); !errors.Is(err, outage) {
+		t.Errorf("read during outage: err %v", err)
+	}
+	results, err := f.HGetAllMulti(ctx, []string{"room:{1001}"})
+	if !errors.Is(err, outage) || !errors.Is(results[0].Err(), outage) {
+		t.Errorf("batch read during outage: err %v, key err %v", err, results[0].Err())
+	}
+	if lens, _ := f.HLenMulti(ctx, []string{"room:{1001}"}); lens[0] != -1 {
+		t.Errorf("hlen during outage: %v, want -1", lens)
+	}
+	if err := f.HealthCheck(ctx); !errors.Is(err, outage) {
+		t.Errorf("health check during outage: err %v", err)
+	}
+
+	f.SetError(nil)
+	if got, err := f.HGetAll(ctx, "room:{1001}"); err != nil || got["Twin"] == "" {
+		t.Errorf("read after outage: %v, %v", got, err)
+	}
+}
+
+func TestFakeFailedOver(t *testing.T) {
+	f := NewFake()
+	var s Status = f
+	if s.FailedOver() {
+		t.Fatal("new fake reports failover")
+	}
+	f.SetFailedOver(true)
+	if !s.FailedOver() {
+		t.Error("failover not reported through Status")
+	}
+}
@@ -0,0 +1,39 @@
+package redis
+
+import (
+	"github.com/alicebob/miniredis/v2"
+)
+
+// Memory is a RoomStore held in this process, for environments that can't
+// run Redis: an embedded Redis-compatible server answers the same commands,
+// pipelines and Lua scripts as a single instance would. Data lasts as long
+// as the process and is not shared between replicas.
+type Memory struct {
+	*Client
+	// Server is the embedded store, for seeding and inspecting keys
+	Server *miniredis.Miniredis
+}
+
+// NewMemory starts an empty in-process store. opts' key prefix, pool and
+// timeout settings apply; its addresses, credentials and topology don't.
+func NewMemory(opts Options) (*Memory, error) {
+	server, err := miniredis.Run()
+	if err != nil {
+		return nil, err
+	}
+	opts.Addrs, opts.Network, opts.Password, opts.DB = []string{server.Addr()}, "tcp", "", 0
+	opts.UseCluster, opts.ReadOnly, opts.RouteByLatency, opts.RouteRandomly = false, false, false, false
+	client, err := NewClient(opts)
+	if err != nil {
+		server.Close()
+		return nil, err
+	}
+	return &Memory{Client: client, Server: server}, nil
+}
+
+// Close disconnects and stops the embedded store, dropping its data
+func (m *Memory) Close() error {
+	err := m.Client.Close()
+	m.Server.Close()
+	return err
+}
@@ -0,0 +1,132 @@
+package redis
+
+import (
+	"context"
+	"strings"
+
+	"room-mapping-cache/internal/errs"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// prefixHook prepends a global prefix to the key arguments of every command,
+// so several environments can share one Redis without colliding. Pub/sub
+// channels are prefixed too since they are not scoped by DB. SCAN is handled
+// in ScanKeys, which also strips the prefix from the returned keys.
+type prefixHook struct {
+	prefix string
+}
+
+func (h prefixHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h prefixHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if err := h.apply(cmd); err != nil {
+			cmd.SetErr(err)
+			return err
+		}
+		return next(ctx, cmd)
+	}
+}
+
+func (h prefixHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			if err := h.apply(cmd); err != nil {
+				cmd.SetErr(err)
+				return err
+			}
+		}
+		return next(ctx, cmds)
+	}
+}
+
+// Where a command takes its keys
+type keyPositions int
+
+const (
+	noKeys     keyPositions = iota
+	firstKey                // CMD key ...
+	allKeys                 // CMD key [key ...]
+	secondKey               // XGROUP CREATE stream ...
+	scriptKeys              // EVALSHA sha numkeys key [key ...] arg [arg ...]
+	streamKeys              // ... STREAMS key [key ...] id [id ...]
+)
+
+// commandKeys lists every command the client sends. A command missing here
+// is refused rather than sent with its keys unprefixed, where it would read
+// or write another environment's data.
+var commandKeys = map[string]keyPositions{
+	// SCAN's MATCH pattern is prefixed by ScanKeys
+	"ping": noKeys, "info": noKeys, "cluster": noKeys, "scan": noKeys,
+	"multi": noKeys, "exec": noKeys, "script": noKeys,
+
+	"get": firstKey, "set": firstKey, "setnx": firstKey, "type": firstKey,
+	"expire": firstKey, "pexpire": firstKey, "persist": firstKey, "ttl": firstKey, "pttl": firstKey,
+	"hget": firstKey, "hmget": firstKey, "hgetall": firstKey, "hset": firstKey, "hdel": firstKey,
+	"hlen": firstKey, "hscan": firstKey, "hincrby": firstKey,
+	"smembers": firstKey, "sadd": firstKey,
+	"xadd": firstKey, "xack": firstKey, "xlen": firstKey, "xpending": firstKey, "xautoclaim": firstKey,
+	"publish": firstKey,
+
+	"del": allKeys, "unlink": allKeys, "exists": allKeys,
+
+	"xgroup": secondKey,
+
+	"evalsha": scriptKeys, "eval": scriptKeys, "evalsha_ro": scriptKeys, "eval_ro": scriptKeys,
+
+	"xreadgroup": streamKeys,
+}
+
+// apply rewrites the key positions of cmd in place, failing for commands
+// not in commandKeys. Retried commands are re-processed, so an already
+// prefixed key is left alone.
+func (h prefixHook) apply(cmd redis.Cmder) error {
+	name := strings.ToLower(cmd.Name())
+	positions, ok := commandKeys[name]
+	if !ok {
+		return errs.New(errs.Internal, "key prefix: no key positions known for "+strings.ToUpper(name))
+	}
+
+	args := cmd.Args()
+	switch positions {
+	case firstKey:
+		h.prefixAt(args, 1)
+	case allKeys:
+		for i := 1; i < len(args); i++ {
+			h.prefixAt(args, i)
+		}
+	case secondKey:
+		h.prefixAt(args, 2)
+	case scriptKeys:
+		if len(args) > 2 {
+			if n, ok := args[2].(int); ok {
+				for i := 3; i < 3+n && i < len(args); i++ {
+					h.prefixAt(args, i)
+				}
+			}
+		}
+	case streamKeys:
+		for i, arg := range args {
+			if s, ok := arg.(string); ok && strings.EqualFold(s, "streams") {
+				n := (len(args) - i - 1) / 2
+				for j := i + 1; j <= i+n; j++ {
+					h.prefixAt(args, j)
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (h prefixHook) prefixAt(args []interface{}, i int) {
+	if i >= len(args) {
+		return
+	}
+	if key, ok := args[i].(string); ok && !strings.HasPrefix(key, h.prefix) {
+		args[i] = h.prefix + key
+	}
+}
@@ -0,0 +1,135 @@
+package redis
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+const testPrefix = "env1:"
+
+func newPrefixedClient(t *testing.T) (*Client, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	c, err := NewClient(Options{Addrs: []string{mr.Addr()}, KeyPrefix: testPrefix})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c, mr
+}
+
+var echoKeysScript = redis.NewScript(`return redis.call("HLEN", KEYS[1])`)
+
+// Every command the client issues goes through the hook, must be known to it
+// and must only touch prefixed keys.
+func TestPrefixHookCoversEveryCommand(t *testing.T) {
+	c, mr := newPrefixedClient(t)
+	ctx := context.Background()
+	check := func(what string, err error) {
+		t.Helper()
+		if err != nil && err != redis.Nil {
+			t.Fatalf("%s: %v", what, err)
+		}
+	}
+
+	check("ping", c.Ping(ctx))
+	check("health", c.HealthCheck(ctx))
+	check("set", c.Set(ctx, "s", "v", time.Minute))
+	_, err := c.SetNX(ctx, "nx", "v", time.Minute)
+	check("setnx", err)
+	_, err = c.Get(ctx, "s")
+	check("get", err)
+	_, err = c.PTTL(ctx, "s")
+	check("pttl", err)
+
+	check("hset", c.HSet(ctx, "h", map[string]interface{}{"a": "1", "b": "2"}))
+	_, err = c.HGetAll(ctx, "h")
+	check("hgetall", err)
+	_, err = c.HMGet(ctx, "h", "a", "b")
+	check("hmget", err)
+	check("expire", c.Expire(ctx, "h", time.Minute))
+	check("persist", c.Persist(ctx, "h"))
+	check("hdel", c.HDel(ctx, "h", "b"))
+	_, err = c.HGetAllDel(ctx, "gone")
+	check("hgetall del", err)
+	check("replace", c.ReplaceHash(ctx, "r", map[string]interface{}{"a": "1"}, time.Minute))
+	_, err = c.CopyHash(ctx, "r", "copy", time.Minute)
+	check("copy", err)
+	_, err = c.SnapshotHash(ctx, "r", "r:v2", "snaps", 2, 1, time.Minute)
+	check("snapshot", err)
+	_, err = c.HDelIfEqual(ctx, "copy", map[string]string{"a": "1"})
+	check("hdel if equal", err)
+	_, err = c.DelIfEqual(ctx, "r", map[string]string{"a": "1"})
+	check("del if equal", err)
+	_, err = c.HScanLimited(ctx, "h", 10)
+	check("hscan", err)
+	check("run script", c.runScript(ctx, echoKeysScript, []string{"h"}).Err())
+	_, err = c.SMembers(ctx, "set")
+	check("smembers", err)
+	check("publish", c.Publish(ctx, "events", "x"))
+
+	multi := []string{"m1", "m2"}
+	_, err = c.HSetMulti(ctx, multi, []map[string]interface{}{{"a": "1"}, {"a": "2"}})
+	check("hset multi", err)
+	_, err = c.HGetAllMulti(ctx, multi)
+	check("hgetall multi", err)
+	_, err = c.PTTLMulti(ctx, multi)
+	check("pttl multi", err)
+	_, err = c.HLenMulti(ctx, multi)
+	check("hlen multi", err)
+	_, err = c.RestoreHashes(ctx, multi, []map[string]interface{}{{"a": "1"}, {"a": "2"}}, []time.Duration{time.Minute, 0}, true)
+	check("restore", err)
+
+	_, err = c.HIncrByAndSet(ctx, "v", "version", 1, map[string]interface{}{"updated_at": "now"})
+	check("hincrby and set", err)
+	_, err = c.HSetMissing(ctx, "h", map[string]string{"c": "3"})
+	check("hset missing", err)
+	_, err = c.HSetCapped(ctx, "dl", "room", "entry", 10, time.Minute)
+	check("hset capped", err)
+	_, _, err = c.RoomIDs(ctx, "h", 10)
+	check("room ids", err)
+	_, _, err = c.TakeToken(ctx, "bucket", 10, 5)
+	check("take token", err)
+	_, _, err = c.IncrWithinLimits(ctx, Counter{Key: "{q}d", Limit: 5, ExpireAt: time.Now().Add(time.Hour)}, Counter{Key: "{q}m", ExpireAt: time.Now().Add(time.Hour)})
+	check("incr within limits", err)
+
+	check("xgroup", c.XGroupCreate(ctx, "stream", "g", "$"))
+	check("xadd", c.XAdd(ctx, "stream", map[string]string{"op": "del"}))
+	msgs, err := c.XReadGroup(ctx, "stream", "g", "c1", ">", 10, 0)
+	check("xreadgroup", err)
+	if len(msgs) != 1 {
+		t.Fatalf("xreadgroup: %d entries, want 1", len(msgs))
+	}
+	_, err = c.XDeliveries(ctx, "stream", "g", msgs[0].ID)
+	check("xpending", err)
+	_, _, err = c.XAutoClaim(ctx, "stream", "g", "c2", 0, "0-0", 10)
+	check("xautoclaim", err)
+	check("xack", c.XAck(ctx, "stream", "g", msgs[0].ID))
+	_, _, err = c.ScanKeys(ctx, "", "*", 100)
+	check("scan", err)
+	check("del", c.Del(ctx, "s", "nx"))
+
+	for _, key := range mr.Keys() {
+		if !strings.HasPrefix(key, testPrefix) {
+			t.Errorf("key %q written without the prefix", key)
+		}
+	}
+}
+
+func TestPrefixHookRefusesUnknownCommands(t *testing.T) {
+	c, mr := newPrefixedClient(t)
+	mr.Set(testPrefix+"a", "1")
+
+	err := c.client.Rename(context.Background(), "a", "b").Err()
+	if err == nil || !strings.Contains(err.Error(), "RENAME") {
+		t.Fatalf("rename: err %v, want a refusal", err)
+	}
+	if !mr.Exists(testPrefix + "a") {
+		t.Fatal("rename reached Redis")
+	}
+}
@@ -0,0 +1,43 @@
+package redis
+
+import (
+	"context"
+
+	"room-mapping-cache/internal/logging"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// requestIDKey is the span attribute carrying the ID of the HTTP request that
+// issued a command, so slow Redis spans can be matched to access log lines
+const requestIDKey = attribute.Key("request.id")
+
+// requestIDHook tags the span of each command and pipeline with the request
+// ID. It must be added after the tracing hook, so it runs inside its span.
+type requestIDHook struct{}
+
+func (requestIDHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (requestIDHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		annotate(ctx)
+		return next(ctx, cmd)
+	}
+}
+
+func (requestIDHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		annotate(ctx)
+		return next(ctx, cmds)
+	}
+}
+
+func annotate(ctx context.Context) {
+	if id := logging.RequestID(ctx); id != "" {
+		trace.SpanFromContext(ctx).SetAttributes(requestIDKey.String(id))
+	}
+}
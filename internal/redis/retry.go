@@ -0,0 +1,114 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"room-mapping-cache/internal/errs"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RetryPolicy retries transient failures (timeouts, connection resets,
+// MOVED/ASK, pool exhaustion) with exponential backoff and full jitter.
+type RetryPolicy struct {
+	// Attempts is the total number of tries; values below 2 disable retries
+	Attempts  int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+func isTransient(err error) bool {
+	return err != nil && errs.Retryable(errs.KindOf(err))
+}
+
+// backoff sleeps before retry number attempt (1-based), returning false if
+// ctx ends first.
+func (p RetryPolicy) backoff(ctx context.Context, attempt int) bool {
+	delay := p.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay > 0 {
+		delay = time.Duration(rand.Int63n(int64(delay)) + 1)
+	}
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// withRetry runs fn until it succeeds, fails permanently, or attempts run out
+func (c *Client) withRetry(ctx context.Context, fn func() error) error {
+	err := fn()
+	for attempt := 1; attempt < c.retry.Attempts && isTransient(err); attempt++ {
+		if !c.retry.backoff(ctx, attempt) {
+			return err
+		}
+		err = fn()
+	}
+	return err
+}
+
+// retryPipeline re-sends only the commands that failed transiently, reusing
+// the same Cmd objects so callers' references stay valid. A zero policy
+// never re-sends.
+type retryPipeline struct {
+	redis.Pipeliner
+	policy RetryPolicy
+}
+
+func (p *retryPipeline) Exec(ctx context.Context) ([]redis.Cmder, error) {
+	cmds, err := p.Pipeliner.Exec(ctx)
+	failUnanswered(cmds, err)
+	for attempt := 1; attempt < p.policy.Attempts && err != nil; attempt++ {
+		var failed []redis.Cmder
+		for _, cmd := range cmds {
+			if isTransient(cmd.Err()) {
+				failed = append(failed, cmd)
+			}
+		}
+		if len(failed) == 0 || !p.policy.backoff(ctx, attempt) {
+			break
+		}
+
+		for _, cmd := range failed {
+			cmd.SetErr(nil)
+			_ = p.Pipeliner.Process(ctx, cmd)
+		}
+		_, execErr := p.Pipeliner.Exec(ctx)
+		failUnanswered(failed, execErr)
+
+		err = nil
+		for _, cmd := range cmds {
+			if cmdErr := cmd.Err(); cmdErr != nil {
+				err = cmdErr
+				break
+			}
+		}
+	}
+	return cmds, err
+}
+
+// failUnanswered gives the pipeline's error to every command without one
+// when the pipeline failed on the connection rather than on a command.
+// go-redis leaves the commands untouched when no connection could be had,
+// and those after a broken read, and they would pass for empty replies.
+func failUnanswered(cmds []redis.Cmder, err error) {
+	var redisErr redis.Error
+	if err == nil || err == redis.Nil || errors.As(err, &redisErr) {
+		return
+	}
+	for _, cmd := range cmds {
+		if cmd.Err() == nil {
+			cmd.SetErr(err)
+		}
+	}
+}
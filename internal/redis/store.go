@@ -0,0 +1,182 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Nil is the error Get returns for a missing key
+var Nil = redis.Nil
+
+// The store's operations are grouped by what they are used for, so a
+// component can depend on, and a test double implement, only the groups it
+// needs. RoomStore is all of them.
+
+// KeyValue reads and writes plain keys and their expiry
+type KeyValue interface {
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+	Del(ctx context.Context, keys ...string) error
+	PTTL(ctx context.Context, key string) (time.Duration, error)
+	Expire(ctx context.Context, key string, ttl time.Duration) error
+	Persist(ctx context.Context, key string) error
+}
+
+// HashReader reads hashes such as the room hashes, one at a time or in
+// batches read in as few round trips as the topology allows
+type HashReader interface {
+	HGetAll(ctx context.Context, key string) (map[string]string, error)
+	HMGet(ctx context.Context, key string, fields ...string) ([]interface{}, error)
+	HScanLimited(ctx context.Context, key string, limit int) (map[string]string, error)
+	HGetAllMulti(ctx context.Context, keys []string) ([]*HashResult, error)
+	HLenMulti(ctx context.Context, keys []string) ([]int64, error)
+	RoomIDs(ctx context.Context, key string, limit int) ([]RoomID, bool, error)
+}
+
+// HashWriter writes hashes, including the atomic read-modify-write operations
+type HashWriter interface {
+	HSet(ctx context.Context, key string, values map[string]interface{}) error
+	HDel(ctx context.Context, key string, fields ...string) error
+	HSetMulti(ctx context.Context, keys []string, values []map[string]interface{}) ([]error, error)
+	ReplaceHash(ctx context.Context, key string, values map[string]interface{}, ttl time.Duration) error
+	HGetAllDel(ctx context.Context, key string) (map[string]string, error)
+	CopyHash(ctx context.Context, src, dst string, ttl time.Duration) (bool, error)
+	SnapshotHash(ctx context.Context, src, dst, index string, version int64, keep int, ttl time.Duration) (bool, error)
+	HDelIfEqual(ctx context.Context, key string, fields map[string]string) ([]string, error)
+	DelIfEqual(ctx context.Context, key string, hash map[string]string) (bool, error)
+	HIncrByAndSet(ctx context.Context, key, field string, incr int64, values map[string]interface{}) (int64, error)
+	HSetMissing(ctx context.Context, key string, values map[string]string) (int, error)
+	HSetCapped(ctx context.Context, key, field, value string, maxFields int, ttl time.Duration) (bool, error)
+}
+
+// Scanner lists keys and set members
+type Scanner interface {
+	SMembers(ctx context.Context, key string) ([]string, error)
+	ScanKeys(ctx context.Context, cursor, match string, count int64) ([]string, string, error)
+}
+
+// Limiter keeps rate limit and quota counters
+type Limiter interface {
+	TakeToken(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error)
+	IncrWithinLimits(ctx context.Context, counters ...Counter) ([]int64, bool, error)
+}
+
+// PubSub publishes and receives invalidation messages
+type PubSub interface {
+	Publish(ctx context.Context, channel, message string) error
+	Subscribe(ctx context.Context, channels ...string) Subscription
+	PSubscribeAllNodes(ctx context.Context, patterns ...string) ([]Subscription, error)
+}
+
+// Streams consumes and appends to streams through consumer groups
+type Streams interface {
+	XGroupCreate(ctx context.Context, stream, group, start string) error
+	XReadGroup(ctx context.Context, stream, group, consumer, id string, count int64, block time.Duration) ([]StreamEntry, error)
+	XAck(ctx context.Context, stream, group string, ids ...string) error
+	XAutoClaim(ctx context.Context, stream, group, consumer string, minIdle time.Duration, start string, count int64) ([]StreamEntry, string, error)
+	XDeliveries(ctx context.Context, stream, group, id string) (int64, error)
+	XAdd(ctx context.Context, stream string, values map[string]string) error
+}
+
+// Status reports the store's health and topology
+type Status interface {
+	HealthCheck(ctx context.Context) error
+	ActiveHealthCheck(ctx context.Context) error
+	IsCluster() bool
+	ClusterInfo(ctx context.Context) (map[string]string, error)
+	KeyPrefix() string
+	FailedOver() bool
+	FailoverSwitches() int64
+	PoolStats() *PoolStats
+	SecondaryPoolStats() *PoolStats
+}
+
+// RoomStore is the whole store as the room handler uses it. Client
+// implements it against Redis and Memory in process.
+type RoomStore interface {
+	KeyValue
+	HashReader
+	HashWriter
+	Scanner
+	Limiter
+	PubSub
+	Streams
+	Status
+}
+
+var (
+	_ RoomStore = (*Client)(nil)
+	_ RoomStore = (*Memory)(nil)
+)
+
+// HashResult is one hash of a batch read: its fields, or why it couldn't be
+// read. A missing hash reads as empty, not as an error.
+type HashResult struct {
+	val map[string]string
+	err error
+}
+
+// NewHashResult returns a result holding val and err, e.g. for a hash read
+// outside a batch
+func NewHashResult(val map[string]string, err error) *HashResult {
+	return &HashResult{val: val, err: err}
+}
+
+func (r *HashResult) Result() (map[string]string, error) {
+	return r.val, r.err
+}
+
+func (r *HashResult) Val() map[string]string {
+	return r.val
+}
+
+func (r *HashResult) Err() error {
+	return r.err
+}
+
+// RoomID is a room name with the id stored in its value
+type RoomID struct {
+	Name string
+	ID   int64
+}
+
+// Counter is one counter of IncrWithinLimits. Limit 0 is unlimited; the
+// counter expires at ExpireAt.
+type Counter struct {
+	Key      string
+	Limit    int64
+	ExpireAt time.Time
+}
+
+// Message is a message received on a subscription
+type Message struct {
+	// Channel is the channel it was published on, including any key prefix
+	Channel string
+	Payload string
+}
+
+// Subscription delivers pub/sub messages until closed
+type Subscription interface {
+	// Channel returns the messages; it is closed when the subscription is
+	Channel() <-chan Message
+	Close() error
+}
+
+// StreamEntry is one entry of a stream
+type StreamEntry struct {
+	ID     string
+	Values map[string]string
+}
+
+// PoolStats are connection pool counters
+type PoolStats struct {
+	Hits       uint32 // times a free connection was found in the pool
+	Misses     uint32 // times a free connection was not found in the pool
+	Timeouts   uint32 // times a wait timeout occurred
+	TotalConns uint32 // connections in the pool
+	IdleConns  uint32 // idle connections in the pool
+	StaleConns uint32 // stale connections removed from the pool
+}
@@ -0,0 +1,325 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newTestStore returns an empty in-process store, closed when the test ends
+func newTestStore(t *testing.T) RoomStore {
+	m, err := NewMemory(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreStrings(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	if _, err := s.Get(ctx, "k"); !errors.Is(err, Nil) {
+		t.Fatalf("get missing: err %v, want Nil", err)
+	}
+	if ttl, _ := s.PTTL(ctx, "k"); ttl != -2 {
+		t.Errorf("pttl missing: %v, want -2", ttl)
+	}
+	must(t, s.Set(ctx, "k", "v", 0))
+	if ok, _ := s.SetNX(ctx, "k", "w", 0); ok {
+		t.Error("setnx overwrote an existing key")
+	}
+	if v, err := s.Get(ctx, "k"); err != nil || v != "v" {
+		t.Errorf("get: %q, %v", v, err)
+	}
+	if ttl, _ := s.PTTL(ctx, "k"); ttl != -1 {
+		t.Errorf("pttl without expiry: %v, want -1", ttl)
+	}
+	must(t, s.Expire(ctx, "k", time.Minute))
+	if ttl, _ := s.PTTL(ctx, "k"); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("pttl after expire: %v", ttl)
+	}
+	must(t, s.Persist(ctx, "k"))
+	if ttl, _ := s.PTTL(ctx, "k"); ttl != -1 {
+		t.Errorf("pttl after persist: %v, want -1", ttl)
+	}
+	if _, err := s.HGetAll(ctx, "k"); err == nil {
+		t.Error("hgetall on a string: no error")
+	}
+	must(t, s.Del(ctx, "k"))
+	if _, err := s.Get(ctx, "k"); !errors.Is(err, Nil) {
+		t.Errorf("get after del: err %v, want Nil", err)
+	}
+}
+
+func TestStoreHashes(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	must(t, s.HSet(ctx, "h", map[string]interface{}{"a": "1", "b": 2, "c": 1.5}))
+	got, err := s.HGetAll(ctx, "h")
+	if want := map[string]string{"a": "1", "b": "2", "c": "1.5"}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("hgetall: %v, %v", got, err)
+	}
+	vals, _ := s.HMGet(ctx, "h", "a", "x")
+	if len(vals) != 2 || vals[0] != "1" || vals[1] != nil {
+		t.Errorf("hmget: %v", vals)
+	}
+	if part, _ := s.HScanLimited(ctx, "h", 2); len(part) != 2 {
+		t.Errorf("hscan limited: %d fields, want 2", len(part))
+	}
+	must(t, s.HDel(ctx, "h", "a", "b", "c"))
+	if ttl, _ := s.PTTL(ctx, "h"); ttl != -2 {
+		t.Errorf("emptied hash still exists")
+	}
+
+	results, err := s.HGetAllMulti(ctx, []string{"m1", "missing"})
+	if err != nil || len(results) != 2 || len(results[1].Val()) != 0 {
+		t.Fatalf("hgetall multi of missing keys: %v", err)
+	}
+	keyErrs, err := s.HSetMulti(ctx, []string{"m1", "m2"}, []map[string]interface{}{{"a": "1"}, {"a": "2", "b": "3"}})
+	if err != nil || keyErrs[0] != nil || keyErrs[1] != nil {
+		t.Fatalf("hset multi: %v %v", keyErrs, err)
+	}
+	results, _ = s.HGetAllMulti(ctx, []string{"m1", "m2"})
+	if results[0].Val()["a"] != "1" || results[1].Val()["b"] != "3" {
+		t.Errorf("hgetall multi: %v %v", results[0].Val(), results[1].Val())
+	}
+	if lens, _ := s.HLenMulti(ctx, []string{"m1", "m2", "missing"}); !reflect.DeepEqual(lens, []int64{1, 2, 0}) {
+		t.Errorf("hlen multi: %v", lens)
+	}
+}
+
+func TestStoreAtomicOperations(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	must(t, s.HSet(ctx, "r", map[string]interface{}{"old": "x"}))
+	must(t, s.ReplaceHash(ctx, "r", map[string]interface{}{"a": "1", "b": "2"}, time.Minute))
+	if got, _ := s.HGetAll(ctx, "r"); !reflect.DeepEqual(got, map[string]string{"a": "1", "b": "2"}) {
+		t.Errorf("replace hash: %v", got)
+	}
+	if ttl, _ := s.PTTL(ctx, "r"); ttl <= 0 {
+		t.Errorf("replace hash ttl: %v", ttl)
+	}
+
+	if ok, _ := s.CopyHash(ctx, "missing", "copy", 0); ok {
+		t.Error("copied an empty hash")
+	}
+	if ok, err := s.CopyHash(ctx, "r", "copy", 0); !ok || err != nil {
+		t.Errorf("copy hash: %v %v", ok, err)
+	}
+	for version := int64(1); version <= 4; version++ {
+		if version == 3 {
+			continue // a write whose snapshot failed
+		}
+		dst := "r:v" + strconv.FormatInt(version, 10)
+		if ok, err := s.SnapshotHash(ctx, "r", dst, "snaps", version, 2, 0); !ok || err != nil {
+			t.Errorf("snapshot %s: %v %v", dst, ok, err)
+		}
+	}
+	for dst, want := range map[string]int{"r:v1": 0, "r:v2": 0, "r:v4": 2} {
+		if got, _ := s.HGetAll(ctx, dst); len(got) != want {
+			t.Errorf("after pruning %s has %d fields, want %d", dst, len(got), want)
+		}
+	}
+	if ok, _ := s.SnapshotHash(ctx, "missing", "missing:v9", "snaps", 9, 2, 0); ok {
+		t.Error("snapshotted an empty hash")
+	}
+	if got, _ := s.HGetAll(ctx, "r:v4"); len(got) != 2 {
+		t.Error("snapshotting an empty hash pruned the retained ones")
+	}
+
+	must(t, s.HSet(ctx, "gone", map[string]interface{}{"a": "1"}))
+	if got, err := s.HGetAllDel(ctx, "gone"); err != nil || !reflect.DeepEqual(got, map[string]string{"a": "1"}) {
+		t.Errorf("hgetall del: %v %v", got, err)
+	}
+	if got, _ := s.HGetAll(ctx, "gone"); len(got) != 0 {
+		t.Errorf("hgetall del left %v", got)
+	}
+
+	removed, _ := s.HDelIfEqual(ctx, "copy", map[string]string{"a": "1", "b": "changed"})
+	if !reflect.DeepEqual(removed, []string{"a"}) {
+		t.Errorf("hdel if equal removed %v, want [a]", removed)
+	}
+	if ok, _ := s.DelIfEqual(ctx, "copy", map[string]string{"b": "other"}); ok {
+		t.Error("del if equal deleted a changed hash")
+	}
+	if ok, _ := s.DelIfEqual(ctx, "copy", map[string]string{"b": "2"}); !ok {
+		t.Error("del if equal kept an unchanged hash")
+	}
+
+	for want := int64(1); want <= 2; want++ {
+		n, err := s.HIncrByAndSet(ctx, "v", "version", 1, map[string]interface{}{"updated_at": "t"})
+		if err != nil || n != want {
+			t.Errorf("hincrby and set: %d, %v; want %d", n, err, want)
+		}
+	}
+
+	n, _ := s.HSetMissing(ctx, "r", map[string]string{"a": "new", "c": "3"})
+	if got, _ := s.HGetAll(ctx, "r"); n != 1 || got["a"] != "1" || got["c"] != "3" {
+		t.Errorf("hset missing copied %d: %v", n, got)
+	}
+
+	for i, field := range []string{"x", "y", "x", "z"} {
+		ok, err := s.HSetCapped(ctx, "capped", field, "v", 2, time.Minute)
+		if want := i < 3; ok != want || err != nil {
+			t.Errorf("hset capped %s: %v %v, want %v", field, ok, err, want)
+		}
+	}
+}
+
+func TestStoreRoomIDs(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	must(t, s.HSet(ctx, "rooms", map[string]interface{}{
+		"Twin":   `{"id":4,"supplier":"a"}`,
+		"Suite":  `{"id": "3"}`,
+		"NoID":   `{"supplier":"a"}`,
+		"ZeroID": `{"id":0}`,
+	}))
+	ids, truncated, err := s.RoomIDs(ctx, "rooms", 10)
+	sort.Slice(ids, func(i, j int) bool { return ids[i].Name < ids[j].Name })
+	if want := []RoomID{{Name: "Suite", ID: 3}, {Name: "Twin", ID: 4}}; err != nil || truncated || !reflect.DeepEqual(ids, want) {
+		t.Errorf("room ids: %v %v %v", ids, truncated, err)
+	}
+	if ids, truncated, _ := s.RoomIDs(ctx, "rooms", 1); len(ids) != 1 || !truncated {
+		t.Errorf("room ids over the limit: %v, truncated %v", ids, truncated)
+	}
+}
+
+func TestStoreLimits(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if ok, _, err := s.TakeToken(ctx, "bucket", 1, 2); !ok || err != nil {
+			t.Fatalf("token %d: %v %v", i, ok, err)
+		}
+	}
+	if ok, retry, _ := s.TakeToken(ctx, "bucket", 1, 2); ok || retry <= 0 || retry > time.Second {
+		t.Errorf("empty bucket: allowed %v, retry %v", ok, retry)
+	}
+
+	end := time.Now().Add(time.Hour)
+	day := Counter{Key: "{q}:d", Limit: 2, ExpireAt: end}
+	month := Counter{Key: "{q}:m", ExpireAt: end}
+	for want := int64(1); want <= 2; want++ {
+		counts, ok, err := s.IncrWithinLimits(ctx, day, month)
+		if !ok || err != nil || !reflect.DeepEqual(counts, []int64{want, want}) {
+			t.Fatalf("counters: %v %v %v", counts, ok, err)
+		}
+	}
+	if counts, ok, _ := s.IncrWithinLimits(ctx, day, month); ok || !reflect.DeepEqual(counts, []int64{2, 2}) {
+		t.Errorf("counter at its limit: %v, allowed %v", counts, ok)
+	}
+	if ttl, _ := s.PTTL(ctx, day.Key); ttl <= 0 {
+		t.Errorf("counter ttl: %v", ttl)
+	}
+}
+
+func TestStoreScanKeys(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	want := make([]string, 0, 25)
+	for i := 0; i < 25; i++ {
+		key := "room:{" + string(rune('a'+i)) + "}"
+		want = append(want, key)
+		must(t, s.Set(ctx, key, "v", 0))
+	}
+	must(t, s.Set(ctx, "other", "v", 0))
+
+	var found []string
+	cursor := ""
+	for {
+		page, next, err := s.ScanKeys(ctx, cursor, "room:{*}", 7)
+		must(t, err)
+		found = append(found, page...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	sort.Strings(found)
+	if !reflect.DeepEqual(found, want) {
+		t.Errorf("scan found %v", found)
+	}
+}
+
+func TestStorePubSub(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	sub := s.Subscribe(ctx, "events")
+	defer sub.Close()
+	subs, err := s.PSubscribeAllNodes(ctx, "ev*")
+	must(t, err)
+	defer subs[0].Close()
+	// Subscriptions are set up asynchronously by go-redis
+	time.Sleep(50 * time.Millisecond)
+
+	must(t, s.Publish(ctx, "events", "1001"))
+	for _, ch := range []<-chan Message{sub.Channel(), subs[0].Channel()} {
+		select {
+		case msg := <-ch:
+			if msg.Channel != "events" || msg.Payload != "1001" {
+				t.Errorf("message %+v", msg)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("no message")
+		}
+	}
+}
+
+func TestStoreStreams(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	if _, err := s.XReadGroup(ctx, "updates", "g", "c1", ">", 10, -1); err == nil {
+		t.Error("read from a missing group: no error")
+	}
+	must(t, s.XGroupCreate(ctx, "updates", "g", "$"))
+	must(t, s.XGroupCreate(ctx, "updates", "g", "$"))
+	must(t, s.XAdd(ctx, "updates", map[string]string{"op": "del", "hotel_id": "1001"}))
+
+	msgs, err := s.XReadGroup(ctx, "updates", "g", "c1", ">", 10, -1)
+	if err != nil || len(msgs) != 1 || msgs[0].Values["hotel_id"] != "1001" {
+		t.Fatalf("read new: %v %v", msgs, err)
+	}
+	id := msgs[0].ID
+	deliveries := func(want int64) {
+		t.Helper()
+		if n, err := s.XDeliveries(ctx, "updates", "g", id); n != want || err != nil {
+			t.Errorf("deliveries %d, %v; want %d", n, err, want)
+		}
+	}
+	deliveries(1)
+
+	// Nothing new arrives within the block
+	if msgs, err := s.XReadGroup(ctx, "updates", "g", "c1", ">", 10, 50*time.Millisecond); len(msgs) != 0 || err != nil {
+		t.Errorf("blocking read: %v %v", msgs, err)
+	}
+
+	if msgs, _ := s.XReadGroup(ctx, "updates", "g", "c1", "0", 10, -1); len(msgs) != 1 || msgs[0].ID != id {
+		t.Errorf("re-read pending: %v", msgs)
+	}
+	deliveries(2)
+
+	claimed, next, err := s.XAutoClaim(ctx, "updates", "g", "c2", 0, "0-0", 10)
+	if err != nil || len(claimed) != 1 || next != "0-0" {
+		t.Errorf("autoclaim: %v %q %v", claimed, next, err)
+	}
+	deliveries(3)
+	if msgs, _ := s.XReadGroup(ctx, "updates", "g", "c1", "0", 10, -1); len(msgs) != 0 {
+		t.Errorf("claimed entry still pending on c1: %v", msgs)
+	}
+
+	must(t, s.XAck(ctx, "updates", "g", id))
+	deliveries(0)
+}
@@ -0,0 +1,93 @@
+// Package tenant lets several brands share one deployment. The X-Tenant
+// header selects a tenant, whose hotels are stored under scoped hotel IDs
+// ("tenant:hotel"), so every key derived from a hotel ID is namespaced.
+package tenant
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"room-mapping-cache/internal/errs"
+	"room-mapping-cache/internal/logging"
+	"room-mapping-cache/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Header selects the tenant of a request
+const Header = "X-Tenant"
+
+// Separator joins a tenant and a hotel ID
+const Separator = ":"
+
+// defaultLabel is the metrics label of requests without a tenant
+const defaultLabel = "default"
+
+// Middleware checks X-Tenant against tenants and records the request in the
+// per-tenant metrics. With required set every request must name a tenant.
+func Middleware(tenants []string, required bool) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(tenants))
+	for _, t := range tenants {
+		allowed[t] = true
+	}
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", Header)
+		name := strings.TrimSpace(c.GetHeader(Header))
+		switch {
+		case name == "" && required:
+			reject(c, errs.Invalid, Header+" is required")
+			return
+		case name != "" && !allowed[name]:
+			reject(c, errs.Forbidden, "unknown tenant")
+			return
+		}
+		if name != "" {
+			c.Set(logging.TenantKey, name)
+		}
+
+		start := time.Now()
+		c.Next()
+
+		label := name
+		if label == "" {
+			label = defaultLabel
+		}
+		metrics.TenantRequests.WithLabelValues(label, statusClass(c.Writer.Status())).Inc()
+		metrics.TenantRequestDuration.WithLabelValues(label).Observe(time.Since(start).Seconds())
+	}
+}
+
+// From returns the request's tenant, or "" for the untenanted keys
+func From(c *gin.Context) string {
+	return c.GetString(logging.TenantKey)
+}
+
+// Scope returns the hotel ID the tenant's hotel is stored under. Hotel IDs
+// containing the separator are refused, since they could name another
+// tenant's hotel.
+func Scope(tenant, hotelID string) (string, error) {
+	if strings.Contains(hotelID, Separator) {
+		return "", &errs.Error{Kind: errs.Invalid, Field: "hotel_id", Msg: "hotel_id must not contain " + Separator}
+	}
+	if tenant == "" {
+		return hotelID, nil
+	}
+	return tenant + Separator + hotelID, nil
+}
+
+// Split returns the tenant and hotel ID of a hotel ID returned by Scope
+func Split(scoped string) (tenant, hotelID string) {
+	if t, id, ok := strings.Cut(scoped, Separator); ok {
+		return t, id
+	}
+	return "", scoped
+}
+
+func statusClass(status int) string {
+	return strconv.Itoa(status/100) + "xx"
+}
+
+func reject(c *gin.Context, kind errs.Kind, msg string) {
+	logging.AbortWithError(c, &errs.Error{Kind: kind, Msg: msg, Field: Header})
+}
@@ -0,0 +1,61 @@
+// Package tracing configures OpenTelemetry tracing with an OTLP/HTTP exporter.
+// The exporter endpoint and headers come from the standard OTEL_EXPORTER_OTLP_*
+// environment variables.
+package tracing
+
+import (
+	"context"
+
+	"room-mapping-cache/internal/logging"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Setup installs a global tracer provider sampling sampleRatio of new traces
+// (incoming sampled parents are always honored) and W3C trace context
+// propagation. The returned function flushes and stops the exporter.
+func Setup(ctx context.Context, serviceName string, sampleRatio float64) (func(context.Context) error, error) {
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	return provider.Shutdown, nil
+}
+
+// RequestID tags the server span with the request ID, so a trace can be
+// found from the X-Request-ID a caller reports. It must run after otelgin.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if id := logging.RequestID(c.Request.Context()); id != "" {
+			trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.String("request.id", id))
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"room-mapping-cache/internal/config"
+	"room-mapping-cache/internal/handler"
+	"room-mapping-cache/internal/loader"
+)
+
+// loadStats counts what a load did, for progress lines and the summary
+type loadStats struct {
+	records, hotels, rooms, invalid, failed int
+}
+
+// runLoad implements the "load" subcommand: it reads a JSON or CSV dump from
+// a file, HTTPS or S3 URL and upserts every hotel into Redis in pipelined
+// batches, as if each had been written through the API. Exits non-zero if any
+// record was invalid or failed to write.
+func runLoad(args []string) int {
+	fs := flag.NewFlagSet("load", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s load [flags] <file | https://... | s3://bucket/key>\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	configPath := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML config file; environment variables and flags override its values")
+	format := fs.String("format", "auto", "dump format: json, csv, or auto to go by the source name")
+	batchSize := fs.Int("batch", 500, "records per Redis pipeline")
+	dryRun := fs.Bool("dry-run", false, "parse and validate the dump without writing to Redis")
+	progressEvery := fs.Duration("progress", 5*time.Second, "interval between progress lines")
+	config.RegisterFlags(fs)
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 || *batchSize < 1 {
+		fs.Usage()
+		return 2
+	}
+	source := fs.Arg(0)
+
+	var isCSV bool
+	switch strings.ToLower(*format) {
+	case "auto":
+		isCSV = loader.IsCSV(source)
+	case "csv":
+		isCSV = true
+	case "json":
+	default:
+		fatal("Invalid -format", fmt.Errorf("unknown format %q", *format))
+	}
+
+	cfg := loadConfig(*configPath)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var roomHandler *handler.RoomHandler
+	if !*dryRun {
+		redisClient := connectRedis(ctx, cfg)
+		defer redisClient.Close()
+		setupKeyring(cfg)
+		setupNameRules(cfg)
+		roomHandler = handler.NewRoomHandler(redisClient, cfg, nil)
+	}
+
+	body, err := loader.OpenSource(ctx, source, cfg.LoaderHTTPHeaders)
+	if err != nil {
+		fatal("Failed to open source", err, "source", source)
+	}
+	defer body.Close()
+	slog.Info("Loading room mappings", "source", source, "csv", isCSV, "batch", *batchSize, "dry_run", *dryRun)
+
+	var (
+		stats     loadStats
+		batch     = make([]handler.HotelRooms, 0, *batchSize)
+		started   = time.Now()
+		lastPrint = started
+	)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if roomHandler != nil {
+			for i, err := range roomHandler.WriteHotels(ctx, batch) {
+				if err != nil {
+					stats.failed++
+					slog.Error("Failed to load hotel", "hotel_id", batch[i].HotelID, "supplier", batch[i].Supplier, "error", err)
+					continue
+				}
+				stats.hotels++
+				stats.rooms += len(batch[i].Rooms)
+			}
+		} else {
+			for _, hotel := range batch {
+				stats.hotels++
+				stats.rooms += len(hotel.Rooms)
+			}
+		}
+		batch = batch[:0]
+
+		if time.Since(lastPrint) >= *progressEvery {
+			lastPrint = time.Now()
+			elapsed := time.Since(started)
+			slog.Info("Load progress", "records", stats.records, "hotels", stats.hotels, "rooms", stats.rooms,
+				"invalid", stats.invalid, "failed", stats.failed,
+				"records_per_sec", fmt.Sprintf("%.0f", float64(stats.records)/elapsed.Seconds()))
+		}
+	}
+
+	err = loader.ReadDump(body, isCSV,
+		func(rec loader.Record) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			stats.records++
+			batch = append(batch, handler.HotelRooms(rec))
+			if len(batch) == *batchSize {
+				flush()
+			}
+			return nil
+		},
+		func(bad *loader.RecordError) {
+			stats.invalid++
+			slog.Warn("Skipping invalid record", "position", bad.Pos, "error", bad.Err)
+		})
+	if err == nil {
+		flush()
+	}
+
+	elapsed := time.Since(started)
+	summary := []any{
+		"records", stats.records, "hotels", stats.hotels, "rooms", stats.rooms,
+		"invalid", stats.invalid, "failed", stats.failed,
+		"duration", elapsed.Round(time.Millisecond).String(), "dry_run", *dryRun,
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		slog.Warn("Load interrupted", summary...)
+		return 1
+	case err != nil:
+		slog.Error("Load stopped early", append([]any{"error", err}, summary...)...)
+		return 1
+	case stats.invalid > 0 || stats.failed > 0:
+		slog.Warn("Load finished with errors", summary...)
+		return 1
+	}
+	slog.Info("Load finished", summary...)
+	return 0
+}
@@ -2,114 +2,792 @@ package main
 
 import (
 	"context"
-	"log"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
 	"net/http"
+	"net/http/pprof"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"room-mapping-cache/internal/audit"
+	"room-mapping-cache/internal/auth"
+	"room-mapping-cache/internal/buildinfo"
+	"room-mapping-cache/internal/cache"
 	"room-mapping-cache/internal/config"
+	"room-mapping-cache/internal/encryption"
+	"room-mapping-cache/internal/faults"
 	"room-mapping-cache/internal/handler"
+	"room-mapping-cache/internal/hotelid"
+	"room-mapping-cache/internal/idempotency"
+	"room-mapping-cache/internal/jobs"
+	"room-mapping-cache/internal/journal"
+	"room-mapping-cache/internal/keys"
+	"room-mapping-cache/internal/limits"
+	"room-mapping-cache/internal/logging"
+	"room-mapping-cache/internal/metrics"
 	"room-mapping-cache/internal/redis"
+	"room-mapping-cache/internal/tenant"
+	"room-mapping-cache/internal/tracing"
 
+	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 func main() {
-	cfg := config.Load()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "load":
+			os.Exit(runLoad(os.Args[2:]))
+		case "backup":
+			os.Exit(runBackup(os.Args[2:]))
+		case "restore":
+			os.Exit(runRestore(os.Args[2:]))
+		case "reindex":
+			os.Exit(runReindex(os.Args[2:]))
+		case "seed":
+			os.Exit(runSeed(os.Args[2:]))
+		case "healthcheck":
+			os.Exit(runHealthcheck(os.Args[2:]))
+		}
+	}
 
-	redisMode := "single instance"
-	if cfg.UseCluster {
-		redisMode = "cluster"
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "YAML config file; environment variables and flags override its values")
+	dev := flag.Bool("dev", false, "run against an in-process Redis instead of REDIS_ADDR (not in production)")
+	devSeed := flag.String("dev-seed", "", `with -dev, hotels to start with: "sample", a fixtures directory or a load dump (file, https:// or s3:// URL)`)
+	config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	if *devSeed != "" && !*dev {
+		fatal("Invalid -dev-seed", fmt.Errorf("-dev-seed requires -dev"))
 	}
-	log.Printf("Initializing Redis %s client with addresses: %v", redisMode, cfg.RedisAddrs)
+	// -dev settings apply before validation, e.g. so STORE_SEED passes
+	cfg := loadConfig(*configPath, func(cfg *config.Config) {
+		if *dev {
+			startDevMode(cfg)
+			if *devSeed != "" {
+				cfg.StoreSeed = *devSeed
+			}
+		}
+	})
 
-	// Initialize Redis client (cluster or single instance based on config)
-	redisClient, err := redis.NewClient(cfg.RedisAddrs, cfg.RedisPassword, cfg.UseCluster)
-	if err != nil {
-		log.Fatalf("Failed to initialize Redis client: %v", err)
+	// Initialize the store: Redis (cluster or single instance based on
+	// config), or the in-process memory store
+	redisOpts := redisOptions(cfg)
+	var (
+		redisClient *redis.Client
+		redisMode   string
+		err         error
+	)
+	if cfg.StoreBackend == config.StoreBackendMemory {
+		redisMode = "memory"
+		memory, err := redis.NewMemory(redisOpts)
+		if err != nil {
+			fatal("Failed to start in-memory store", err)
+		}
+		defer memory.Close()
+		redisClient = memory.Client
+		slog.Warn("Using the in-memory store; data is lost on exit and not shared between replicas")
+	} else {
+		redisMode = "single instance"
+		if cfg.UseCluster {
+			redisMode = "cluster"
+		}
+		slog.Info("Initializing Redis client", "mode", redisMode, "mode_source", cfg.UseClusterSource, "addrs", cfg.RedisAddrs)
+		if redisClient, err = redis.NewClient(redisOpts); err != nil {
+			fatal("Failed to initialize Redis client", err)
+		}
+		defer redisClient.Close()
+	}
+	redisClient.AddHook(metrics.RedisHook{Endpoint: "primary"})
+
+	// Optional DR endpoint that takes over reads while the primary is
+	// unhealthy. Validation keeps it away from the memory store.
+	if len(cfg.RedisSecondaryAddrs) > 0 {
+		secondaryOpts := redisOpts
+		secondaryOpts.Addrs = cfg.RedisSecondaryAddrs
+		secondaryOpts.Password = cfg.RedisSecondaryPassword
+		secondaryOpts.Network = "tcp" // a DR endpoint is never co-located
+		secondary, err := redis.NewClient(secondaryOpts)
+		if err != nil {
+			fatal("Failed to initialize secondary Redis client", err)
+		}
+		defer secondary.Close()
+		secondary.AddHook(metrics.RedisHook{Endpoint: "secondary"})
+		redisClient.SetSecondary(secondary, cfg.RedisFailoverAfter, cfg.RedisFailbackAfter)
+		slog.Info("Secondary Redis configured for read failover", "addrs", cfg.RedisSecondaryAddrs)
 	}
-	defer redisClient.Close()
 
 	// Perform thorough Redis connection check on startup
-	log.Printf("Checking Redis %s connectivity...", redisMode)
+	slog.Info("Checking Redis connectivity", "mode", redisMode)
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	if err := redisClient.HealthCheck(ctx); err != nil {
-		log.Fatalf("CRITICAL: Failed to connect to Redis %s: %v. Service will not start.", redisMode, err)
+	redisClient.CheckFailover(ctx)
+	if err := redisClient.ActiveHealthCheck(ctx); err != nil {
+		fatal("Failed to connect to Redis, service will not start", err, "mode", redisMode)
 	}
-	log.Printf("Redis %s connection verified successfully", redisMode)
+	slog.Info("Redis connection verified", "mode", redisMode)
+
+	// Optional encryption at rest for room values
+	keyring := setupKeyring(cfg)
+
+	// Background jobs stop once the server has shut down, so the cache keeps
+	// getting invalidations while the last requests are served
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
 
-	// Start background health check goroutine that will crash the service if Redis becomes unavailable
-	go monitorRedisHealth(redisClient)
+	go redisClient.MonitorFailover(jobsCtx, cfg.RedisFailoverCheckInterval)
+	go monitorRedisHealth(jobsCtx, redisClient, cfg.RedisHealthInterval)
+
+	// Optional API key authentication for room and admin routes
+	var apiKeys *auth.Keys
+	if cfg.AuthEnabled {
+		static, err := auth.ParseKeys(cfg.APIKeys)
+		if err != nil {
+			fatal("Invalid API_KEYS", err)
+		}
+		apiKeys = auth.New(static, cfg.APIKeysFile, redisClient, cfg.APIKeysRedisKey)
+		if err := apiKeys.Reload(ctx); err != nil {
+			fatal("Failed to load API keys", err)
+		}
+		if apiKeys.Len() == 0 {
+			slog.Warn("Authentication enabled but no API keys configured; all requests will be rejected")
+		}
+		go apiKeys.Refresh(jobsCtx, cfg.APIKeysRefreshInterval)
+		slog.Info("API key authentication enabled", "keys", apiKeys.Len())
+	}
+
+	// Optional JWT bearer tokens, as an alternative to API keys
+	var tokens *auth.JWTValidator
+	if cfg.JWTEnabled {
+		tokens = auth.NewJWTValidator(cfg.JWTIssuer, cfg.JWTAudience, cfg.JWTJWKSURL)
+		if err := tokens.Refresh(ctx); err != nil {
+			fatal("Failed to fetch JWKS", err)
+		}
+		go tokens.Run(jobsCtx, cfg.JWTJWKSRefreshInterval)
+		slog.Info("JWT authentication enabled", "issuer", cfg.JWTIssuer, "audience", cfg.JWTAudience)
+	}
+
+	var inFlight *limits.InFlight
+	if cfg.MaxInFlightRequests > 0 {
+		inFlight = limits.NewInFlight(cfg.MaxInFlightRequests)
+	}
+
+	// guard sheds load, authenticates and rate limits a route group, as configured
+	guard := func(group, scope string, rate float64) []gin.HandlerFunc {
+		var mw []gin.HandlerFunc
+		if inFlight != nil {
+			mw = append(mw, inFlight.Middleware())
+		}
+		if apiKeys != nil || tokens != nil {
+			mw = append(mw, auth.Middleware(apiKeys, tokens, scope))
+		}
+		if rate > 0 {
+			burst := int(math.Ceil(rate * cfg.RateLimitBurstSeconds))
+			mw = append(mw, limits.NewRateLimiter(redisClient, group, rate, burst).Middleware())
+		}
+		return mw
+	}
+
+	// Maintenance mode freezes mutations, or all room traffic, e.g. while
+	// Redis is resharded
+	maintenance := limits.NewMaintenance(cfg.MaintenanceMode, cfg.MaintenanceRetryAfter)
+	if cfg.MaintenanceMode != limits.MaintenanceOff {
+		slog.Warn("Starting in maintenance mode", "mode", cfg.MaintenanceMode)
+	}
+
+	supplierExpiry := jobs.NewSupplierExpiry(redisClient, cfg, keyring)
+	supplierExpiry.SetMaintenance(maintenance)
 
 	// Set up router
-	if cfg.Environment == "production" {
-		gin.SetMode(gin.ReleaseMode)
+	gin.SetMode(cfg.GinMode)
+	router := newRouter(cfg)
+	drain := limits.NewDrain()
+	router.Use(drain.Middleware())
+	router.Use(logging.Middleware(cfg.AccessLogSampleRate))
+	router.Use(handler.Recovery())
+	router.Use(metrics.Middleware())
+	router.Use(metrics.SlowRequests(cfg.SlowRequestThreshold, cfg.LargeResponseBytes))
+	router.Use(metrics.SLI(cfg.SLOLatencyThreshold, cfg.SLORouteLatencyThresholds))
+
+	// CORS runs before auth so browser preflights are answered without credentials
+	if len(cfg.CORSAllowedOrigins) > 0 {
+		corsCfg := cors.Config{
+			AllowMethods:     cfg.CORSAllowedMethods,
+			AllowHeaders:     cfg.CORSAllowedHeaders,
+			ExposeHeaders:    []string{logging.RequestIDHeader, "Retry-After", "X-Quota-Limit", "X-Quota-Remaining", "X-Quota-Reset", idempotency.ReplayedHeader},
+			AllowCredentials: cfg.CORSAllowCredentials,
+			MaxAge:           cfg.CORSMaxAge,
+		}
+		if len(cfg.CORSAllowedOrigins) == 1 && cfg.CORSAllowedOrigins[0] == "*" {
+			corsCfg.AllowAllOrigins = true
+		} else {
+			corsCfg.AllowOrigins = cfg.CORSAllowedOrigins
+		}
+		if err := corsCfg.Validate(); err != nil {
+			fatal("Invalid CORS configuration", err)
+		}
+		router.Use(cors.New(corsCfg))
+		slog.Info("CORS enabled", "origins", cfg.CORSAllowedOrigins)
 	}
-	router := gin.New()
-	router.Use(gin.Logger())
-	router.Use(gin.Recovery())
+
+	// Optional distributed tracing of handlers and Redis commands
+	shutdownTracing := func(context.Context) error { return nil }
+	if cfg.TracingEnabled {
+		shutdownTracing, err = tracing.Setup(context.Background(), cfg.TracingServiceName, cfg.TracingSampleRatio)
+		if err != nil {
+			fatal("Failed to initialize tracing", err)
+		}
+		if err := redisClient.InstrumentTracing(); err != nil {
+			fatal("Failed to instrument Redis tracing", err)
+		}
+		router.Use(otelgin.Middleware(cfg.TracingServiceName), tracing.RequestID())
+		slog.Info("Tracing enabled", "service", cfg.TracingServiceName, "sample_ratio", cfg.TracingSampleRatio)
+	}
+
+	// Optional fault injection for client resilience testing
+	var faultInjector *faults.Injector
+	if cfg.FaultInjection {
+		faultInjector = faults.New()
+		redisClient.AddHook(faults.Hook())
+		router.Use(faultInjector.Middleware())
+		slog.Warn("Fault injection enabled; requests may carry X-Fault-* headers", "environment", cfg.Environment)
+	}
+
+	// Optional request journal for replay debugging
+	var requestJournal *journal.Journal
+	if cfg.JournalEnabled {
+		requestJournal, err = journal.New(cfg.JournalSize, cfg.JournalSampleRate, cfg.JournalFile)
+		if err != nil {
+			fatal("Failed to initialize request journal", err)
+		}
+		defer requestJournal.Close()
+		slog.Info("Request journal enabled", "size", cfg.JournalSize, "sample_rate", cfg.JournalSampleRate)
+	}
+
+	// Audit trail for writes and admin calls
+	auditLog, err := audit.New(cfg.AuditLogFile, cfg.MaxRequestBodyBytes)
+	if err != nil {
+		fatal("Failed to initialize audit log", err)
+	}
+	defer auditLog.Close()
 
 	// Initialize handler
-	roomHandler := handler.NewRoomHandler(redisClient)
+	roomHandler := handler.NewRoomHandler(redisClient, cfg, requestJournal)
+	roomHandler.SetMaintenance(maintenance)
+	supplierExpiry.SetAfterWrite(roomHandler.AfterWrite)
+	if len(cfg.SupplierTTLs) > 0 || cfg.DefaultSupplierTTL > 0 || cfg.HotelTTL > 0 {
+		go supplierExpiry.Run(jobsCtx)
+	}
+	// Optional second Redis that a sample of lookups is compared against
+	if len(cfg.RedisShadowAddrs) > 0 {
+		shadowOpts := redisOptions(cfg)
+		shadowOpts.Addrs = cfg.RedisShadowAddrs
+		shadowOpts.Password = cfg.RedisShadowPassword
+		shadowOpts.UseCluster = cfg.RedisShadowUseCluster
+		shadowOpts.Network = "tcp"
+		shadow, err := redis.NewClient(shadowOpts)
+		if err != nil {
+			fatal("Failed to initialize shadow Redis client", err)
+		}
+		defer shadow.Close()
+		shadow.AddHook(metrics.RedisHook{Endpoint: "shadow"})
+		metrics.RegisterRedisPool("shadow", shadow.PoolStats)
+		roomHandler.SetShadow(shadow, cfg.ShadowReadSampleRate)
+		slog.Info("Shadow Redis configured for read comparison", "addrs", cfg.RedisShadowAddrs, "sample_rate", cfg.ShadowReadSampleRate)
+	}
+	if cfg.StoreSeed != "" {
+		if err := seedStore(jobsCtx, cfg, roomHandler, cfg.StoreSeed); err != nil {
+			fatal("Failed to seed the in-memory store", err)
+		}
+	}
+	if cfg.CacheEnabled && cfg.CacheSampleInterval > 0 {
+		roomHandler.SetCacheSampler(cfg.CacheSampleSize)
+		go roomHandler.RunCacheSampler(jobsCtx, cfg.CacheSampleInterval)
+	}
+	consistency := handler.NewConsistencyChecker(redisClient, cfg.ConsistencyInterval, cfg.ConsistencyMaxIssues)
+	if cfg.ConsistencyInterval > 0 {
+		go consistency.Run(jobsCtx)
+	}
+	keyMigration := handler.NewKeyMigration(jobsCtx, roomHandler)
+	var quotas *limits.Quotas
+	if cfg.QuotaDaily > 0 || cfg.QuotaMonthly > 0 || len(cfg.QuotaOverrides) > 0 {
+		quotas = limits.NewQuotas(redisClient, config.QuotaLimits{Daily: cfg.QuotaDaily, Monthly: cfg.QuotaMonthly}, cfg.QuotaOverrides)
+	}
+	adminHandler := handler.NewAdminHandler(redisClient, roomHandler, supplierExpiry, consistency, keyMigration, requestJournal, quotas)
+	if faultInjector != nil {
+		adminHandler.SetFaults(faultInjector)
+	}
 	handler.SetRedisClient(redisClient)
+	handler.SetRetryAfter(cfg.RetryAfter, cfg.RedisHealthInterval)
+	handler.SetCompression(cfg.CompressionEncodings, cfg.CompressionMinBytes, cfg.CompressionGzipLevel)
+	setupNameRules(cfg)
+	go handler.WatchNameRules(jobsCtx, cfg.RoomNameRulesFile, cfg.RoomNameRulesReloadInterval)
+	metrics.RegisterRedisPool("primary", redisClient.PoolStats)
+	if len(cfg.RedisSecondaryAddrs) > 0 {
+		metrics.RegisterRedisPool("secondary", redisClient.SecondaryPoolStats)
+	}
+	if cfg.CacheEnabled {
+		metrics.RegisterCache(func() cache.Stats { return roomHandler.CacheStats().Stats })
+	}
+
+	// Keep local caches coherent across replicas
+	go roomHandler.ListenInvalidations(jobsCtx)
+	if cfg.KeyspaceEventsEnabled {
+		go roomHandler.ListenKeyspaceEvents(jobsCtx)
+	}
+
+	if cfg.UpdatesStreamEnabled {
+		consumer, err := os.Hostname()
+		if err != nil || consumer == "" {
+			consumer = fmt.Sprintf("consumer-%d", os.Getpid())
+		}
+		go roomHandler.ConsumeUpdates(jobsCtx, consumer)
+	}
+	if cfg.KafkaEnabled {
+		go roomHandler.ConsumeKafka(jobsCtx)
+	}
+	if cfg.SQSEnabled {
+		go roomHandler.ConsumeSQS(jobsCtx)
+	}
+	if cfg.UpstreamRefresh {
+		go roomHandler.RefreshFromUpstream(jobsCtx)
+	}
+
+	// Operational routes move to the admin listener when one is configured
+	opsRouter := router
+	if cfg.AdminAddr != "" {
+		opsRouter = newRouter(cfg)
+		opsRouter.Use(logging.Middleware(cfg.AccessLogSampleRate), handler.Recovery(), metrics.Middleware())
+	}
+	var opsGuard []gin.HandlerFunc
+	if len(cfg.AdminAllowedCIDRs) > 0 {
+		allowlist, err := auth.IPAllowlist(cfg.AdminAllowedCIDRs)
+		if err != nil {
+			fatal("Invalid ADMIN_ALLOWED_CIDRS", err)
+		}
+		opsGuard = append(opsGuard, allowlist)
+	}
+	ops := opsRouter.Group("", opsGuard...)
 
 	// Routes
 	router.GET("/health", handler.HealthCheck)
-	router.GET("/room-mappings/:hotel_id", roomHandler.GetRoomMappings)
-	router.POST("/room-mappings/batch", roomHandler.GetRoomMappingsBatch)
+	router.GET("/version", handler.Version)
+	router.GET("/ready", handler.Ready)
+	ops.GET("/health/detail", adminHandler.HealthDetail)
+	ops.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	lookupDeadline := limits.Deadline(cfg.LookupTimeout)
+	writeDeadline := limits.Deadline(cfg.WriteTimeout)
+
+	// Room and meta routes use the X-Tenant tenant's keys and quotas
+	var callerChain []gin.HandlerFunc
+	if len(cfg.Tenants) > 0 {
+		callerChain = append(callerChain, tenant.Middleware(cfg.Tenants, cfg.TenantRequired))
+	}
+	// Quotas count per API key and tenant, so they run after both are known
+	if quotas != nil {
+		callerChain = append(callerChain, quotas.Middleware())
+	}
+
+	// Room and admin routes require an API key or token when auth is enabled
+	reads := router.Group("", maintenance.Middleware(false))
+	reads.Use(append(guard("read", cfg.JWTScopeRead, cfg.RateLimitRead), callerChain...)...)
+	reads.GET("/room-mappings/:hotel_id", lookupDeadline, roomHandler.GetRoomMappings)
+	reads.POST("/room-mappings/batch", limits.Deadline(cfg.BatchTimeout), roomHandler.GetRoomMappingsBatch)
+	reads.GET("/room-mappings/:hotel_id/diff", lookupDeadline, roomHandler.GetRoomMappingsDiff)
+	reads.GET("/room-mappings/:hotel_id/filter", lookupDeadline, roomHandler.FilterRoomMappings)
+	reads.GET("/room-mappings/:hotel_id/count", lookupDeadline, roomHandler.CountRoomMappings)
+	reads.GET("/hotels/:hotel_id/meta", lookupDeadline, roomHandler.GetHotelMeta)
+	reads.POST("/match/batch", limits.Deadline(cfg.BatchTimeout), roomHandler.MatchBatch)
+
+	// Only the mapping pipeline may write when signing secrets are configured
+	writeChain := append([]gin.HandlerFunc{maintenance.Middleware(true)}, guard("write", cfg.JWTScopeWrite, cfg.RateLimitWrite)...)
+	if len(cfg.WriteSigningSecrets) > 0 {
+		writeChain = append(writeChain, auth.RequireSignature(cfg.WriteSigningSecrets, cfg.SignatureMaxSkew, cfg.MaxRequestBodyBytes))
+	}
+	writeChain = append(append(writeChain, callerChain...), auditLog.Middleware(), writeDeadline)
+	// Keyed writes are applied once per caller; pending keys are released once
+	// the write could no longer be running
+	if cfg.IdempotencyTTL > 0 {
+		writeChain = append(writeChain, idempotency.NewStore(redisClient, cfg.IdempotencyTTL, 2*cfg.WriteTimeout, cfg.MaxRequestBodyBytes).Middleware())
+	}
+	writes := router.Group("", writeChain...)
+	writes.PUT("/room-mappings/:hotel_id", roomHandler.PutRoomMappings)
+	writes.DELETE("/room-mappings/:hotel_id/rooms", roomHandler.DeleteRoomMappings)
+	writes.PUT("/hotels/:hotel_id/meta", roomHandler.PutHotelMeta)
+
+	// Mapping provider push updates authenticate by signature alone
+	if len(cfg.WebhookSecrets) > 0 {
+		router.POST("/webhooks/mapping-updates", maintenance.Middleware(true),
+			auth.RequireSignature(cfg.WebhookSecrets, cfg.SignatureMaxSkew, cfg.MaxRequestBodyBytes),
+			auditLog.Middleware(), writeDeadline, roomHandler.MappingWebhook)
+	}
+
+	// Admin routes; an on-demand supplier expiry sweep sets its own longer deadline
+	adminDeadline := limits.Deadline(cfg.AdminTimeout)
+	adminChain := append(guard("admin", cfg.JWTScopeAdmin, cfg.RateLimitAdmin), auditLog.Middleware())
+	admin := ops.Group("/admin", adminChain...)
+	// Profiles on the admin listener need the admin allowlist and credentials
+	if cfg.AdminAddr != "" && cfg.PprofEnabled {
+		ops.Group("/debug/pprof", adminChain...).Any("/*profile", gin.WrapH(pprofMux()))
+	}
+	admin.GET("/hotels", adminDeadline, adminHandler.ListHotels)
+	admin.GET("/hotels/:hotel_id/ttl", adminDeadline, adminHandler.HotelTTL)
+	admin.PUT("/hotels/:hotel_id/ttl", adminDeadline, adminHandler.SetHotelTTL)
+	admin.GET("/room-mappings/:hotel_id/raw", adminDeadline, adminHandler.RawRoomMappings)
+	admin.GET("/jobs/supplier-expiry", adminHandler.SupplierExpiryReport)
+	admin.GET("/validate", adminHandler.ConsistencyReport)
+	admin.GET("/dead-letters", adminDeadline, adminHandler.DeadLetters)
+	admin.POST("/keys/migrate", adminHandler.StartKeyMigration)
+	admin.GET("/keys/migrate", adminHandler.KeyMigrationReport)
+	admin.GET("/journal", adminDeadline, adminHandler.Journal)
+	admin.GET("/cache/stats", adminDeadline, adminHandler.CacheStats)
+	admin.GET("/analytics/top-hotels", adminDeadline, adminHandler.TopHotels)
+	admin.GET("/quotas/:subject", adminDeadline, adminHandler.QuotaUsage)
+	admin.DELETE("/quotas/:subject", adminDeadline, adminHandler.ResetQuota)
+	admin.GET("/maintenance", adminHandler.Maintenance)
+	admin.PUT("/maintenance", adminHandler.SetMaintenance)
+	if faultInjector != nil {
+		admin.GET("/faults", adminHandler.Faults)
+		admin.PUT("/faults", adminHandler.SetFaultsDefault)
+		admin.DELETE("/faults", adminHandler.ClearFaults)
+	}
 
 	// Start server
+	// h2c serves cleartext HTTP/2 to in-mesh callers (prior knowledge or
+	// Upgrade: h2c); HTTP/1.1 clients are unaffected
+	var mainHandler http.Handler = router
+	if cfg.H2CEnabled {
+		mainHandler = h2c.NewHandler(router, &http2.Server{
+			MaxConcurrentStreams: uint32(cfg.HTTP2MaxConcurrentStreams),
+			IdleTimeout:          cfg.ServerIdleTimeout,
+		})
+	}
 	srv := &http.Server{
 		Addr:         cfg.Addr,
-		Handler:      router,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  120 * time.Second,
+		Handler:      mainHandler,
+		ReadTimeout:  cfg.ServerReadTimeout,
+		WriteTimeout: cfg.ServerWriteTimeout,
+		IdleTimeout:  cfg.ServerIdleTimeout,
 	}
 
 	// Graceful shutdown
 	go func() {
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server failed to start: %v", err)
+			fatal("Server failed to start", err)
 		}
 	}()
 
-	log.Printf("Server started on %s", cfg.Addr)
+	build := buildinfo.Get()
+	slog.Info("Server started", "addr", cfg.Addr, "commit", build.Commit, "build_time", build.BuildTime)
+
+	// /ready stays 503 until the cache is warm and lookups work, so new
+	// instances don't take cold traffic
+	go func() {
+		warmUp(jobsCtx, roomHandler, cfg)
+		handler.MarkWarmedUp()
+		slog.Info("Ready to serve traffic")
+	}()
+
+	// Admin listener; CPU profiles and traces run for ?seconds=N, so no write timeout
+	var adminSrv *http.Server
+	if cfg.AdminAddr != "" {
+		adminSrv = &http.Server{
+			Addr:              cfg.AdminAddr,
+			Handler:           opsRouter,
+			ReadHeaderTimeout: 10 * time.Second,
+			IdleTimeout:       120 * time.Second,
+		}
+		go func() {
+			if err := adminSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				fatal("Admin server failed to start", err)
+			}
+		}()
+		slog.Info("Admin server started", "addr", cfg.AdminAddr)
+	}
+
+	// Profiling lives on its own listener so it is never exposed with the API,
+	// unless the admin listener already serves it
+	var debugSrv *http.Server
+	if cfg.PprofEnabled && cfg.AdminAddr == "" {
+		debugSrv = newDebugServer(cfg.DebugAddr)
+		go func() {
+			if err := debugSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				slog.Error("Debug server failed", "error", err)
+			}
+		}()
+		slog.Info("Debug server started", "addr", cfg.DebugAddr)
+	}
 
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("Shutting down server...")
+	// Fail readiness first and keep serving until the load balancer notices
+	handler.SetDraining()
+	drain.Start()
+	slog.Info("Draining", "delay", cfg.ShutdownDrainDelay, "active_requests", drain.Active())
+	select {
+	case <-time.After(cfg.ShutdownDrainDelay):
+	case <-quit:
+		slog.Warn("Second signal, skipping drain delay")
+	}
 
-	ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+	slog.Info("Shutting down server", "active_requests", drain.Active())
+	ctx, cancel = context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
 
+	if err := drain.Wait(ctx); err != nil {
+		slog.Warn("Requests still in flight at shutdown timeout", "active_requests", drain.Active())
+	}
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		fatal("Server forced to shutdown", err)
+	}
+	if adminSrv != nil {
+		_ = adminSrv.Shutdown(ctx)
 	}
+	if debugSrv != nil {
+		_ = debugSrv.Shutdown(ctx)
+	}
+	stopJobs()
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Warn("Failed to flush traces", "error", err)
+	}
+
+	slog.Info("Server exited")
+}
+
+// loadConfig loads, validates and applies the process-wide settings: logging
+// and the key schema. overrides adjust the loaded settings before validation.
+func loadConfig(configPath string, overrides ...func(*config.Config)) *config.Config {
+	var cfg *config.Config
+	if configPath != "" {
+		var err error
+		if cfg, err = config.LoadFile(configPath); err != nil {
+			fatal("Failed to load config file", err)
+		}
+	} else {
+		cfg = config.Load()
+	}
+	for _, override := range overrides {
+		override(cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		fatal("Invalid configuration", err)
+	}
+	logging.Setup(cfg.LogLevel, cfg.LogFormat)
+	if err := keys.Configure(cfg.RoomKeyTemplate, cfg.RoomKeySupplier); err != nil {
+		fatal("Invalid ROOM_KEY_TEMPLATE", err)
+	}
+	if err := hotelid.Configure(hotelIDRules(cfg)); err != nil {
+		fatal("Invalid hotel ID rules", err)
+	}
+	return cfg
+}
+
+// hotelIDRules maps the HOTEL_ID_* settings onto hotel ID rules
+func hotelIDRules(cfg *config.Config) hotelid.Rules {
+	return hotelid.Rules{
+		Numeric:      cfg.HotelIDNumeric,
+		MaxLength:    cfg.HotelIDMaxLength,
+		AllowedChars: cfg.HotelIDAllowedChars,
+		StripHash:    cfg.HotelIDStripHash,
+		Case:         cfg.HotelIDCase,
+	}
+}
 
-	log.Println("Server exited")
+// redisOptions maps the Redis settings onto client options
+func redisOptions(cfg *config.Config) redis.Options {
+	return redis.Options{
+		Addrs:          cfg.RedisAddrs,
+		Password:       cfg.RedisPassword,
+		UseCluster:     cfg.UseCluster,
+		DB:             cfg.RedisDB,
+		KeyPrefix:      cfg.RedisKeyPrefix,
+		Network:        cfg.RedisNetwork,
+		ReadOnly:       cfg.RedisReadOnly,
+		RouteByLatency: cfg.RedisRouteByLatency,
+		RouteRandomly:  cfg.RedisRouteRandomly,
+		PoolSize:       cfg.RedisPoolSize,
+		MinIdleConns:   &cfg.RedisMinIdleConns,
+		DialTimeout:    cfg.RedisDialTimeout,
+		ReadTimeout:    cfg.RedisReadTimeout,
+		WriteTimeout:   cfg.RedisWriteTimeout,
+		PoolTimeout:    cfg.RedisPoolTimeout,
+		MaxRetries:     cfg.RedisMaxRetries,
+		Retry: redis.RetryPolicy{
+			Attempts:  cfg.RedisRetryAttempts,
+			BaseDelay: cfg.RedisRetryBaseDelay,
+			MaxDelay:  cfg.RedisRetryMaxDelay,
+		},
+	}
 }
 
-// monitorRedisHealth periodically checks Redis connectivity and crashes the service if it fails
-func monitorRedisHealth(redisClient *redis.Client) {
-	ticker := time.NewTicker(30 * time.Second)
+// setupNameRules configures room name normalization
+func setupNameRules(cfg *config.Config) {
+	handler.SetUnicodeFolding(cfg.RoomNameUnicodeFolding)
+	if err := handler.SetTransliteration(cfg.RoomNameTransliteration); err != nil {
+		fatal("Invalid ROOM_NAME_TRANSLITERATION", err)
+	}
+	if cfg.RoomNameRulesFile == "" {
+		return
+	}
+	if err := handler.LoadNameRules(cfg.RoomNameRulesFile); err != nil {
+		fatal("Failed to load room name rules", err)
+	}
+	slog.Info("Room name rules loaded", "path", cfg.RoomNameRulesFile)
+}
+
+// connectRedis opens the primary Redis for a subcommand and checks that it
+// answers
+func connectRedis(ctx context.Context, cfg *config.Config) *redis.Client {
+	if cfg.StoreBackend == config.StoreBackendMemory {
+		fatal("Subcommands need a shared store", fmt.Errorf("STORE_BACKEND is memory"))
+	}
+	redisClient, err := redis.NewClient(redisOptions(cfg))
+	if err != nil {
+		fatal("Failed to initialize Redis client", err)
+	}
+	checkCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := redisClient.ActiveHealthCheck(checkCtx); err != nil {
+		fatal("Failed to connect to Redis", err)
+	}
+	return redisClient
+}
+
+// setupKeyring enables encryption at rest for room values if keys are
+// configured, returning nil otherwise
+func setupKeyring(cfg *config.Config) *encryption.Keyring {
+	if cfg.EncryptionKeys == "" {
+		return nil
+	}
+	encKeys, err := encryption.ParseKeys(cfg.EncryptionKeys)
+	if err != nil {
+		fatal("Invalid ENCRYPTION_KEYS", err)
+	}
+	keyring, err := encryption.NewKeyring(encKeys, cfg.EncryptionActiveKeyID)
+	if err != nil {
+		fatal("Failed to initialize encryption keyring", err)
+	}
+	handler.SetValueKeyring(keyring)
+	slog.Info("Encryption at rest enabled", "active_key", cfg.EncryptionActiveKeyID, "keys", len(encKeys))
+	return keyring
+}
+
+// newDebugServer serves the net/http/pprof endpoints
+func newDebugServer(addr string) *http.Server {
+	// CPU profiles and traces run for ?seconds=N, so no write timeout here
+	return &http.Server{
+		Addr:              addr,
+		Handler:           pprofMux(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
+
+// pprofMux routes the net/http/pprof handlers under /debug/pprof/
+func pprofMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+// newRouter creates a router that reads client IPs as configured
+func newRouter(cfg *config.Config) *gin.Engine {
+	router := gin.New()
+	switch proxies := cfg.TrustedProxies; {
+	case len(proxies) == 1 && proxies[0] == "none":
+		router.SetTrustedProxies(nil)
+	case len(proxies) > 0:
+		if err := router.SetTrustedProxies(proxies); err != nil {
+			fatal("Invalid TRUSTED_PROXIES", err)
+		}
+	case len(cfg.AdminAllowedCIDRs) > 0:
+		// Trusting every proxy would let any client pass the admin
+		// allowlist with X-Forwarded-For
+		router.SetTrustedProxies(nil)
+	}
+	router.RemoteIPHeaders = cfg.ClientIPHeaders
+	switch strings.ToLower(cfg.TrustedPlatform) {
+	case "":
+	case "cloudflare":
+		router.TrustedPlatform = gin.PlatformCloudflare
+	case "google":
+		router.TrustedPlatform = gin.PlatformGoogleAppEngine
+	default:
+		router.TrustedPlatform = cfg.TrustedPlatform
+	}
+	return router
+}
+
+// warmUp loads the hot hotels into the local cache, avoiding a post-deploy
+// thundering herd, then runs the readiness self-test lookups
+func warmUp(ctx context.Context, roomHandler *handler.RoomHandler, cfg *config.Config) {
+	warmIDs, err := roomHandler.WarmupHotelIDs(ctx)
+	if err != nil {
+		slog.Warn("Failed to load cache warm-up list", "error", err)
+	} else if len(warmIDs) > 0 {
+		warmCtx, warmCancel := context.WithTimeout(ctx, 60*time.Second)
+		loaded, err := roomHandler.WarmUp(warmCtx, warmIDs)
+		warmCancel()
+		if err != nil {
+			slog.Warn("Cache warm-up stopped early", "error", err)
+		}
+		slog.Info("Cache warm-up finished", "loaded", loaded, "requested", len(warmIDs))
+	}
+
+	if n := cfg.ReadinessSelfTestLookups; n > 0 {
+		if err := roomHandler.SelfTest(ctx, warmIDs, n); err != nil {
+			return
+		}
+		slog.Info("Readiness self-test passed", "lookups", n)
+	}
+}
+
+// monitorRedisHealth periodically checks Redis connectivity and flips the
+// service in and out of degraded mode. While degraded, /ready returns 503 and
+// reads are served from the local cache.
+func monitorRedisHealth(ctx context.Context, redisClient redis.Status, interval time.Duration) {
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		err := redisClient.HealthCheck(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		checkCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		err := redisClient.ActiveHealthCheck(checkCtx)
 		cancel()
 
-		if err != nil {
-			log.Fatalf("CRITICAL: Redis health check failed: %v. Service is crashing.", err)
+		wasDegraded := handler.SetRedisDegraded(err != nil)
+		switch {
+		case err != nil && !wasDegraded:
+			slog.Error("Redis health check failed, entering degraded mode", "error", err)
+		case err != nil:
+			slog.Warn("Redis still unavailable", "error", err)
+		case wasDegraded:
+			slog.Info("Redis health check passed, leaving degraded mode")
 		}
-		log.Println("Redis health check passed")
 	}
 }
+
+// fatal logs an unrecoverable startup or shutdown error and exits
+func fatal(msg string, err error, args ...any) {
+	slog.Error(msg, append([]any{"error", err}, args...)...)
+	os.Exit(1)
+}
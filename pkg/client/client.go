@@ -0,0 +1,436 @@
+// Package client is the Go client for the room mapping service:
+//
+//	c := client.New("http://room-mapping-cache:8080", client.WithAPIKey(key))
+//	hotel, err := c.GetRoomMappings(ctx, "1001")
+//	if err != nil {
+//		return err
+//	}
+//	if !hotel.Exists {
+//		// the service has no mappings for this hotel
+//	}
+//
+// Reads are retried with backoff on network errors and retryable error
+// responses, honouring Retry-After, until the context ends. Responses are
+// requested gzip-compressed. Error responses are returned as *Error.
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client calls one room mapping service. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	header     http.Header
+	retries    int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient replaces the default client, which has a 10s timeout
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithAPIKey authenticates with an API key
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.header.Set("X-API-Key", key) }
+}
+
+// WithBearerToken authenticates with a JWT
+func WithBearerToken(token string) Option {
+	return func(c *Client) { c.header.Set("Authorization", "Bearer "+token) }
+}
+
+// WithTenant reads the given tenant's hotels
+func WithTenant(tenant string) Option {
+	return func(c *Client) { c.header.Set("X-Tenant", tenant) }
+}
+
+// WithClientID identifies the caller for per-client limits and logs
+func WithClientID(id string) Option {
+	return func(c *Client) { c.header.Set("X-Client-ID", id) }
+}
+
+// WithRetries sets how many times a failed read is retried (default 2) and
+// the first backoff delay (default 100ms), doubled on every retry up to 5s.
+// A Retry-After from the server takes precedence over the backoff.
+func WithRetries(retries int, baseDelay time.Duration) Option {
+	return func(c *Client) { c.retries, c.baseDelay = retries, baseDelay }
+}
+
+// New returns a client for the service at baseURL, e.g.
+// "http://room-mapping-cache:8080"
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		header:     http.Header{},
+		retries:    2,
+		baseDelay:  100 * time.Millisecond,
+		maxDelay:   5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Room is one mapped room
+type Room struct {
+	// Name is the normalized room name
+	Name string `json:"name"`
+	ID   int64  `json:"id"`
+	// Conflict marks rooms sharing a normalized name with a room of another ID
+	Conflict bool `json:"conflict,omitempty"`
+}
+
+// Hotel is the room mappings of one hotel
+type Hotel struct {
+	Rooms []Room `json:"rooms"`
+	// Exists is false when the service has no mappings for the hotel
+	Exists bool `json:"exists"`
+	// Version counts writes to the hotel; 0 if unknown
+	Version   int64     `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Truncated is set when the hotel has more rooms than the service decodes
+	// or than fit in one response. NextCursor, when set, fetches the rest
+	// with GetRoomMappingsFrom.
+	Truncated  bool   `json:"truncated"`
+	NextCursor string `json:"next_cursor"`
+	// Stale is set when the service answered from its cache during a Redis
+	// failure
+	Stale bool `json:"stale"`
+}
+
+// BatchHotel is one hotel of a batch lookup. Err is set when the service
+// could not read the hotel; retrying just those hotels may succeed.
+type BatchHotel struct {
+	Hotel
+	HotelID string
+	// NotModified is set by GetBatchChanged for hotels still at the known
+	// version; Rooms is then empty and Version is the current version
+	NotModified bool
+	Err         *Error
+}
+
+// GetRoomMappings returns a hotel's rooms. A hotel without mappings is not
+// an error: it comes back with Exists false.
+func (c *Client) GetRoomMappings(ctx context.Context, hotelID string) (*Hotel, error) {
+	return c.GetRoomMappingsFrom(ctx, hotelID, "")
+}
+
+// GetRoomMappingsFrom is GetRoomMappings continuing at a NextCursor of an
+// earlier answer; an empty cursor starts at the first room
+func (c *Client) GetRoomMappingsFrom(ctx context.Context, hotelID, cursor string) (*Hotel, error) {
+	var body struct {
+		Hotel
+		Exists *bool `json:"exists"`
+	}
+	path := "/room-mappings/" + url.PathEscape(hotelID)
+	if cursor != "" {
+		path += "?cursor=" + url.QueryEscape(cursor)
+	}
+	err := c.do(ctx, http.MethodGet, path, nil, &body)
+	if ErrorCode(err) == CodeHotelNotFound {
+		return &Hotel{Rooms: []Room{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	hotel := body.Hotel
+	// Older services leave out exists; they answer missing hotels with no rooms
+	hotel.Exists = len(hotel.Rooms) > 0
+	if body.Exists != nil {
+		hotel.Exists = *body.Exists
+	}
+	return &hotel, nil
+}
+
+// GetBatch looks up several hotels in one request, keyed by hotel ID. The
+// service limits how many hotels one batch may hold (BATCH_TOO_LARGE).
+func (c *Client) GetBatch(ctx context.Context, hotelIDs []string) (map[string]BatchHotel, error) {
+	return c.batch(ctx, map[string][]string{"hotel_ids": hotelIDs})
+}
+
+// GetBatchChanged is GetBatch for pollers: known maps hotel IDs to the
+// version the caller already holds (0 if none), and hotels still at that
+// version come back NotModified, without rooms.
+func (c *Client) GetBatchChanged(ctx context.Context, known map[string]int64) (map[string]BatchHotel, error) {
+	type entry struct {
+		HotelID      string `json:"hotel_id"`
+		KnownVersion int64  `json:"known_version"`
+	}
+	entries := make([]entry, 0, len(known))
+	for id, version := range known {
+		entries = append(entries, entry{HotelID: id, KnownVersion: version})
+	}
+	return c.batch(ctx, map[string][]entry{"hotels": entries})
+}
+
+// GetBatchOrdered is GetBatch answering in request order: one entry per
+// hotel ID, duplicates included, for callers that join by position.
+func (c *Client) GetBatchOrdered(ctx context.Context, hotelIDs []string) ([]BatchHotel, error) {
+	var body struct {
+		Results []batchHotel `json:"results"`
+	}
+	err := c.do(ctx, http.MethodPost, "/room-mappings/batch?ordered=true", map[string][]string{"hotel_ids": hotelIDs}, &body)
+	if err != nil {
+		return nil, err
+	}
+	hotels := make([]BatchHotel, len(body.Results))
+	for i, h := range body.Results {
+		hotels[i] = h.toBatchHotel(h.HotelID)
+	}
+	return hotels, nil
+}
+
+func (c *Client) batch(ctx context.Context, req any) (map[string]BatchHotel, error) {
+	var body struct {
+		Hotels map[string]batchHotel `json:"hotels"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/room-mappings/batch", req, &body); err != nil {
+		return nil, err
+	}
+	hotels := make(map[string]BatchHotel, len(body.Hotels))
+	for id, h := range body.Hotels {
+		hotels[id] = h.toBatchHotel(id)
+	}
+	return hotels, nil
+}
+
+// batchHotel is a batch entry as the service sends it
+type batchHotel struct {
+	Hotel
+	HotelID   string `json:"hotel_id"`
+	Status    string `json:"status"`
+	Error     string `json:"error"`
+	Code      string `json:"code"`
+	Retryable bool   `json:"retryable"`
+}
+
+func (h batchHotel) toBatchHotel(id string) BatchHotel {
+	hotel := BatchHotel{Hotel: h.Hotel, HotelID: id}
+	switch h.Status {
+	case "ok":
+		hotel.Exists = true
+	case "not_modified":
+		hotel.Exists, hotel.NotModified = true, true
+	case "error":
+		hotel.Err = &Error{Code: h.Code, Message: h.Error, Retryable: h.Retryable}
+	}
+	return hotel
+}
+
+// Match returns the rooms of a hotel whose normalized name contains every
+// word of query, e.g. "deluxe king"
+func (c *Client) Match(ctx context.Context, hotelID, query string) ([]Room, error) {
+	var body struct {
+		Rooms []Room `json:"rooms"`
+	}
+	path := "/room-mappings/" + url.PathEscape(hotelID) + "/filter?q=" + url.QueryEscape(query)
+	if err := c.do(ctx, http.MethodGet, path, nil, &body); err != nil {
+		return nil, err
+	}
+	return body.Rooms, nil
+}
+
+// MatchRequest names supplier rooms to match against one hotel's mappings
+type MatchRequest struct {
+	HotelID   string   `json:"hotel_id"`
+	RoomNames []string `json:"room_names"`
+}
+
+// HotelMatches is the answer for one MatchRequest. Err is set when the
+// service could not read the hotel.
+type HotelMatches struct {
+	HotelID string
+	Exists  bool
+	// Matches holds one entry per requested room name, in order
+	Matches []NameMatch
+	Err     *Error
+}
+
+// NameMatch lists the rooms matching a room name: the rooms with the same
+// normalized name if any (Exact), otherwise those containing all its words
+type NameMatch struct {
+	Name  string `json:"name"`
+	Exact bool   `json:"exact"`
+	Rooms []Room `json:"rooms"`
+}
+
+// MatchBatch matches room names against several hotels in one request,
+// answering in request order. The service limits the hotels and names per
+// request (BATCH_TOO_LARGE, INVALID_REQUEST).
+func (c *Client) MatchBatch(ctx context.Context, hotels []MatchRequest) ([]HotelMatches, error) {
+	var body struct {
+		Hotels []struct {
+			HotelID   string      `json:"hotel_id"`
+			Status    string      `json:"status"`
+			Matches   []NameMatch `json:"matches"`
+			Error     string      `json:"error"`
+			Code      string      `json:"code"`
+			Retryable bool        `json:"retryable"`
+		} `json:"hotels"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/match/batch", map[string][]MatchRequest{"hotels": hotels}, &body); err != nil {
+		return nil, err
+	}
+	out := make([]HotelMatches, len(body.Hotels))
+	for i, h := range body.Hotels {
+		out[i] = HotelMatches{HotelID: h.HotelID, Exists: h.Status == "ok", Matches: h.Matches}
+		if h.Status == "error" {
+			out[i].Err = &Error{Code: h.Code, Message: h.Error, Retryable: h.Retryable}
+		}
+	}
+	return out, nil
+}
+
+// do sends a read and decodes its JSON answer into out, retrying as
+// configured
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	var payload []byte
+	if in != nil {
+		var err error
+		if payload, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+	for attempt := 0; ; attempt++ {
+		err := c.once(ctx, method, path, payload, out)
+		if err == nil || attempt >= c.retries || !c.shouldRetry(ctx, err) {
+			return err
+		}
+		t := time.NewTimer(c.delay(attempt, err))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
+
+func (c *Client) once(ctx context.Context, method, path string, payload []byte, out any) error {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	for name, values := range c.header {
+		req.Header[name] = values
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	// Set explicitly, so responses are decoded here whatever the transport
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var reader io.Reader = resp.Body
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return fmt.Errorf("room mapping service: decode gzip response: %w", err)
+		}
+		defer zr.Close()
+		reader = zr
+	}
+
+	if resp.StatusCode >= 300 {
+		return decodeError(resp, reader)
+	}
+	if err := json.NewDecoder(reader).Decode(out); err != nil {
+		return fmt.Errorf("room mapping service: decode response: %w", err)
+	}
+	return nil
+}
+
+func decodeError(resp *http.Response, body io.Reader) error {
+	raw, _ := io.ReadAll(io.LimitReader(body, 64<<10))
+	var eb errorBody
+	_ = json.Unmarshal(raw, &eb)
+	e := &Error{
+		Status:    resp.StatusCode,
+		Code:      eb.Code,
+		Message:   eb.Message,
+		Retryable: eb.Retryable,
+		RequestID: eb.RequestID,
+		Details:   eb.Details,
+	}
+	if e.Message == "" {
+		e.Message = eb.Error
+	}
+	if e.Message == "" {
+		e.Message = strings.TrimSpace(string(raw))
+	}
+	if e.RequestID == "" {
+		e.RequestID = resp.Header.Get("X-Request-ID")
+	}
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+		e.RetryAfter = time.Duration(secs) * time.Second
+	}
+	if e.Code == "" {
+		// Proxies and load balancers answer without the envelope
+		switch resp.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			e.Code, e.Retryable = CodeRedisUnavailable, true
+		case http.StatusTooManyRequests:
+			e.Code, e.Retryable = CodeOverloaded, true
+		default:
+			e.Code = CodeInternal
+		}
+	}
+	return e
+}
+
+// shouldRetry retries retryable error responses and network errors, but not
+// the caller's own cancellation
+func (c *Client) shouldRetry(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Retryable
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// delay is the server's Retry-After, or exponential backoff with full jitter
+func (c *Client) delay(attempt int, err error) time.Duration {
+	var e *Error
+	if errors.As(err, &e) && e.RetryAfter > 0 {
+		return e.RetryAfter
+	}
+	d := c.baseDelay << attempt
+	if d <= 0 || d > c.maxDelay {
+		d = c.maxDelay
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
@@ -0,0 +1,80 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Error codes returned by the service; see the service's internal/errs for
+// the full list. Codes are stable, messages are not.
+const (
+	CodeInvalidRequest   = "INVALID_REQUEST"
+	CodeInvalidHotelID   = "INVALID_HOTEL_ID"
+	CodeBatchTooLarge    = "BATCH_TOO_LARGE"
+	CodeResponseTooLarge = "RESPONSE_TOO_LARGE"
+	CodeHotelNotFound    = "HOTEL_NOT_FOUND"
+	CodeNotFound         = "NOT_FOUND"
+	CodeRedisUnavailable = "REDIS_UNAVAILABLE"
+	CodeMaintenance      = "MAINTENANCE"
+	CodeTimeout          = "TIMEOUT"
+	CodeOverloaded       = "OVERLOADED"
+	CodeRateLimited      = "RATE_LIMITED"
+	CodeQuotaExceeded    = "QUOTA_EXCEEDED"
+	CodeUnauthorized     = "UNAUTHORIZED"
+	CodeForbidden        = "FORBIDDEN"
+	CodeInternal         = "INTERNAL"
+)
+
+// Error is an error response from the service
+type Error struct {
+	// Status is the HTTP status; 0 for errors reported per hotel in a batch
+	Status  int
+	Code    string
+	Message string
+	// Retryable is the service's hint that the same request may succeed later
+	Retryable bool
+	// RetryAfter is the server's Retry-After, if it sent one
+	RetryAfter time.Duration
+	RequestID  string
+	Details    map[string]any
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("room mapping service: %s: %s", e.Code, e.Message)
+	if e.Status != 0 {
+		msg += fmt.Sprintf(" (status %d)", e.Status)
+	}
+	if e.RequestID != "" {
+		msg += " (request " + e.RequestID + ")"
+	}
+	return msg
+}
+
+// ErrorCode returns the service error code of err, or "" if err is not an
+// error response
+func ErrorCode(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return ""
+}
+
+// IsRetryable reports whether err is a service error marked retryable. The
+// client has already retried it as configured.
+func IsRetryable(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Retryable
+}
+
+// errorBody is the service's error envelope
+type errorBody struct {
+	Code      string         `json:"code"`
+	Message   string         `json:"message"`
+	Retryable bool           `json:"retryable"`
+	RequestID string         `json:"request_id"`
+	Details   map[string]any `json:"details"`
+	// Error is the message field of services predating codes
+	Error string `json:"error"`
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"room-mapping-cache/internal/config"
+	"room-mapping-cache/internal/handler"
+	"room-mapping-cache/internal/keys"
+)
+
+// runReindex implements the "reindex" subcommand: it rebuilds the room name
+// token index of every hotel found by scanning the room hashes, for hotels
+// written before TOKEN_INDEX_ENABLED was turned on or after the name rules
+// changed. Exits non-zero if any hotel failed.
+func runReindex(args []string) int {
+	fs := flag.NewFlagSet("reindex", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s reindex [flags]\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	configPath := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML config file; environment variables and flags override its values")
+	concurrency := fs.Int("concurrency", 8, "hotels indexed in parallel")
+	batchSize := fs.Int("batch", 500, "keys per SCAN page")
+	progressEvery := fs.Duration("progress", 5*time.Second, "interval between progress lines")
+	config.RegisterFlags(fs)
+	_ = fs.Parse(args)
+	if fs.NArg() != 0 || *concurrency < 1 || *batchSize < 1 {
+		fs.Usage()
+		return 2
+	}
+
+	cfg := loadConfig(*configPath)
+	if !cfg.TokenIndex {
+		slog.Warn("TOKEN_INDEX_ENABLED is off; the index will be built but not used or kept up to date")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	redisClient := connectRedis(ctx, cfg)
+	defer redisClient.Close()
+	setupKeyring(cfg)
+	setupNameRules(cfg)
+	roomHandler := handler.NewRoomHandler(redisClient, cfg, nil)
+
+	var (
+		indexed, failed atomic.Int64
+		started         = time.Now()
+		lastPrint       = started
+		hotels          = make(chan string)
+		wg              sync.WaitGroup
+	)
+	for i := 0; i < *concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for hotelID := range hotels {
+				if err := roomHandler.RebuildTokenIndex(ctx, hotelID); err != nil {
+					failed.Add(1)
+					slog.Error("Failed to index hotel", "hotel_id", hotelID, "error", err)
+					continue
+				}
+				indexed.Add(1)
+			}
+		}()
+	}
+
+	// Hotels with both a hashtagged and a legacy key show up twice
+	seen := make(map[string]bool)
+	var err error
+	cursor := ""
+scan:
+	for {
+		var found []string
+		found, cursor, err = redisClient.ScanKeys(ctx, cursor, keys.RoomScanPattern(), int64(*batchSize))
+		if err != nil {
+			break
+		}
+		for _, key := range found {
+			hotelID, ok := keys.HotelID(key)
+			if !ok || keys.IsSnapshot(key) || seen[hotelID] {
+				continue
+			}
+			seen[hotelID] = true
+			select {
+			case hotels <- hotelID:
+			case <-ctx.Done():
+				err = ctx.Err()
+				break scan
+			}
+		}
+		if time.Since(lastPrint) >= *progressEvery {
+			lastPrint = time.Now()
+			slog.Info("Reindex progress", "hotels", len(seen), "indexed", indexed.Load(), "failed", failed.Load())
+		}
+		if cursor == "" {
+			break
+		}
+	}
+	close(hotels)
+	wg.Wait()
+
+	summary := []any{
+		"hotels", len(seen), "indexed", indexed.Load(), "failed", failed.Load(),
+		"duration", time.Since(started).Round(time.Millisecond).String(),
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		slog.Warn("Reindex interrupted", summary...)
+		return 1
+	case err != nil:
+		slog.Error("Reindex stopped early", append([]any{"error", err}, summary...)...)
+		return 1
+	case failed.Load() > 0:
+		slog.Warn("Reindex finished with errors", summary...)
+		return 1
+	}
+	slog.Info("Reindex finished", summary...)
+	return 0
+}
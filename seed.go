@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"room-mapping-cache/internal/config"
+	"room-mapping-cache/internal/handler"
+	"room-mapping-cache/internal/loader"
+)
+
+// runSeed implements the "seed" subcommand: it writes a directory of JSON
+// fixture files into the configured Redis, for reproducible local, staging
+// and demo environments. With -replace each fixture hotel is deleted first,
+// so rooms left over from earlier runs disappear. Exits non-zero if any hotel
+// was invalid or failed to write.
+func runSeed(args []string) int {
+	fs := flag.NewFlagSet("seed", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s seed [flags] <fixtures dir>\n\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "Each *.json file holds an object of hotel ID to rooms (room name to room value).\n\n")
+		fs.PrintDefaults()
+	}
+	configPath := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML config file; environment variables and flags override its values")
+	supplier := fs.String("supplier", "fixtures", "supplier the fixture rooms are written for")
+	replace := fs.Bool("replace", false, "delete each fixture hotel's existing rooms before writing")
+	batchSize := fs.Int("batch", 500, "hotels per Redis pipeline")
+	config.RegisterFlags(fs)
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 || *batchSize < 1 || *supplier == "" {
+		fs.Usage()
+		return 2
+	}
+	dir := fs.Arg(0)
+
+	cfg := loadConfig(*configPath)
+	if cfg.Environment == "production" {
+		slog.Warn("Seeding fixtures into a production environment", "dir", dir)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	redisClient := connectRedis(ctx, cfg)
+	defer redisClient.Close()
+	setupKeyring(cfg)
+	setupNameRules(cfg)
+	roomHandler := handler.NewRoomHandler(redisClient, cfg, nil)
+
+	var (
+		stats   loadStats
+		batch   = make([]handler.HotelRooms, 0, *batchSize)
+		started = time.Now()
+	)
+	flush := func() {
+		if *replace {
+			for i := 0; i < len(batch); i++ {
+				if err := roomHandler.ApplyUpdate(ctx, handler.MappingUpdate{Op: "del", HotelID: batch[i].HotelID}); err != nil {
+					stats.failed++
+					slog.Error("Failed to clear hotel", "hotel_id", batch[i].HotelID, "error", err)
+					batch = append(batch[:i], batch[i+1:]...)
+					i--
+				}
+			}
+		}
+		for i, err := range roomHandler.WriteHotels(ctx, batch) {
+			if err != nil {
+				stats.failed++
+				slog.Error("Failed to seed hotel", "hotel_id", batch[i].HotelID, "error", err)
+				continue
+			}
+			stats.hotels++
+			stats.rooms += len(batch[i].Rooms)
+		}
+		batch = batch[:0]
+	}
+
+	slog.Info("Seeding fixtures", "dir", dir, "supplier", *supplier, "replace", *replace)
+	err := loader.ReadFixtures(dir, *supplier,
+		func(rec loader.Record) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			stats.records++
+			batch = append(batch, handler.HotelRooms(rec))
+			if len(batch) == *batchSize {
+				flush()
+			}
+			return nil
+		},
+		func(bad *loader.RecordError) {
+			stats.invalid++
+			slog.Warn("Skipping invalid fixture", "error", bad.Err)
+		})
+	if err == nil {
+		flush()
+	}
+
+	summary := []any{
+		"hotels", stats.hotels, "rooms", stats.rooms, "invalid", stats.invalid, "failed", stats.failed,
+		"duration", time.Since(started).Round(time.Millisecond).String(),
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		slog.Warn("Seed interrupted", summary...)
+		return 1
+	case err != nil:
+		slog.Error("Seed stopped early", append([]any{"error", err}, summary...)...)
+		return 1
+	case stats.invalid > 0 || stats.failed > 0:
+		slog.Warn("Seed finished with errors", summary...)
+		return 1
+	}
+	slog.Info("Seed finished", summary...)
+	return 0
+}
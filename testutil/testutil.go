@@ -0,0 +1,292 @@
+// Package testutil runs the room mapping service in-process for integration
+// tests, here and in client teams' repositories:
+//
+//	srv := testutil.NewServer(t, "CACHE_ENABLED=false")
+//	srv.PutHotel("1001", "expedia", map[string]int64{"Deluxe King Room": 1})
+//	srv.Get("/room-mappings/1001").ExpectStatus(http.StatusOK).ExpectRooms(
+//		testutil.Room{Name: "deluxe king room", ID: 1})
+//
+// Redis is an in-process miniredis unless TEST_REDIS_ADDR names a real one
+// (e.g. started by testcontainers or docker compose); each server then uses
+// its own key prefix, so tests sharing that Redis do not see each other's
+// hotels. Settings are applied with t.Setenv, so servers cannot be started
+// from parallel tests.
+package testutil
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"room-mapping-cache/internal/config"
+	"room-mapping-cache/internal/handler"
+	"room-mapping-cache/internal/hotelid"
+	"room-mapping-cache/internal/keys"
+	"room-mapping-cache/internal/limits"
+	"room-mapping-cache/internal/logging"
+	"room-mapping-cache/internal/redis"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+)
+
+// RedisAddrEnv names an external Redis to test against instead of miniredis
+const RedisAddrEnv = "TEST_REDIS_ADDR"
+
+// Server is a running service backed by a test Redis
+type Server struct {
+	// URL is the base URL of the service, e.g. http://127.0.0.1:38421
+	URL string
+	// Redis is the in-process Redis, nil when RedisAddrEnv is set. Tests can
+	// use it to inspect keys or simulate failures (SetError, FastForward).
+	Redis *miniredis.Miniredis
+	// RedisAddr is the address of the Redis the service uses
+	RedisAddr string
+	// KeyPrefix is prepended to every key the service writes
+	KeyPrefix string
+
+	t      testing.TB
+	client *http.Client
+}
+
+// Room is one room of a lookup response
+type Room struct {
+	Name string `json:"name"`
+	ID   int64  `json:"id"`
+}
+
+// NewServer starts the service with its room routes, /health and /ready.
+// settings are NAME=value environment settings applied on top of the
+// defaults, as in .env.example. The server and Redis stop when the test ends.
+func NewServer(t testing.TB, settings ...string) *Server {
+	t.Helper()
+	for _, s := range settings {
+		name, value, ok := strings.Cut(s, "=")
+		if !ok {
+			t.Fatalf("testutil: setting %q is not NAME=value", s)
+		}
+		t.Setenv(name, value)
+	}
+
+	s := &Server{t: t, client: &http.Client{Timeout: 30 * time.Second}}
+	if addr := strings.TrimSpace(os.Getenv(RedisAddrEnv)); addr != "" {
+		s.RedisAddr = addr
+		s.KeyPrefix = "test-" + randomHex(6) + ":"
+	} else {
+		mr := miniredis.NewMiniRedis()
+		if err := mr.Start(); err != nil {
+			t.Fatalf("testutil: start miniredis: %v", err)
+		}
+		t.Cleanup(mr.Close)
+		s.Redis, s.RedisAddr = mr, mr.Addr()
+	}
+
+	cfg := config.Load()
+	cfg.RedisAddrs = []string{s.RedisAddr}
+	cfg.RedisNetwork = "tcp"
+	cfg.UseCluster, cfg.UseClusterSource = false, "testutil"
+	cfg.RedisDB = 0
+	cfg.RedisSecondaryAddrs = nil
+	cfg.RedisKeyPrefix = s.KeyPrefix + cfg.RedisKeyPrefix
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("testutil: invalid configuration: %v", err)
+	}
+	if err := keys.Configure(cfg.RoomKeyTemplate, cfg.RoomKeySupplier); err != nil {
+		t.Fatalf("testutil: invalid ROOM_KEY_TEMPLATE: %v", err)
+	}
+	if err := hotelid.Configure(hotelid.Rules{
+		Numeric:      cfg.HotelIDNumeric,
+		MaxLength:    cfg.HotelIDMaxLength,
+		AllowedChars: cfg.HotelIDAllowedChars,
+		StripHash:    cfg.HotelIDStripHash,
+		Case:         cfg.HotelIDCase,
+	}); err != nil {
+		t.Fatalf("testutil: invalid hotel ID rules: %v", err)
+	}
+	handler.SetUnicodeFolding(cfg.RoomNameUnicodeFolding)
+	if err := handler.SetTransliteration(cfg.RoomNameTransliteration); err != nil {
+		t.Fatalf("testutil: invalid ROOM_NAME_TRANSLITERATION: %v", err)
+	}
+
+	redisClient, err := redis.NewClient(redis.Options{
+		Addrs:        cfg.RedisAddrs,
+		Password:     cfg.RedisPassword,
+		KeyPrefix:    cfg.RedisKeyPrefix,
+		Network:      cfg.RedisNetwork,
+		DialTimeout:  cfg.RedisDialTimeout,
+		ReadTimeout:  cfg.RedisReadTimeout,
+		WriteTimeout: cfg.RedisWriteTimeout,
+	})
+	if err != nil {
+		t.Fatalf("testutil: create Redis client: %v", err)
+	}
+	t.Cleanup(func() { redisClient.Close() })
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := redisClient.ActiveHealthCheck(ctx); err != nil {
+		t.Fatalf("testutil: connect to Redis at %s: %v", s.RedisAddr, err)
+	}
+
+	handler.SetRedisClient(redisClient)
+	handler.SetCompression(cfg.CompressionEncodings, cfg.CompressionMinBytes, cfg.CompressionGzipLevel)
+	roomHandler := handler.NewRoomHandler(redisClient, cfg, nil)
+	handler.MarkWarmedUp()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(logging.Middleware(0))
+	router.Use(handler.Recovery())
+	router.GET("/health", handler.HealthCheck)
+	router.GET("/ready", handler.Ready)
+
+	lookupDeadline := limits.Deadline(cfg.LookupTimeout)
+	router.GET("/room-mappings/:hotel_id", lookupDeadline, roomHandler.GetRoomMappings)
+	router.POST("/room-mappings/batch", limits.Deadline(cfg.BatchTimeout), roomHandler.GetRoomMappingsBatch)
+	router.GET("/room-mappings/:hotel_id/diff", lookupDeadline, roomHandler.GetRoomMappingsDiff)
+	router.GET("/room-mappings/:hotel_id/filter", lookupDeadline, roomHandler.FilterRoomMappings)
+	router.GET("/room-mappings/:hotel_id/count", lookupDeadline, roomHandler.CountRoomMappings)
+	router.GET("/hotels/:hotel_id/meta", lookupDeadline, roomHandler.GetHotelMeta)
+	router.POST("/match/batch", limits.Deadline(cfg.BatchTimeout), roomHandler.MatchBatch)
+	router.PUT("/room-mappings/:hotel_id", roomHandler.PutRoomMappings)
+	router.DELETE("/room-mappings/:hotel_id/rooms", roomHandler.DeleteRoomMappings)
+	router.PUT("/hotels/:hotel_id/meta", roomHandler.PutHotelMeta)
+
+	ts := httptest.NewServer(router)
+	t.Cleanup(ts.Close)
+	s.URL = ts.URL
+	return s
+}
+
+// PutHotel writes a supplier's rooms for a hotel through the API and fails
+// the test unless it is accepted. rooms maps room names to room IDs.
+func (s *Server) PutHotel(hotelID, supplier string, rooms map[string]int64) {
+	s.t.Helper()
+	values := make(map[string]map[string]int64, len(rooms))
+	for name, id := range rooms {
+		values[name] = map[string]int64{"id": id}
+	}
+	s.Do(http.MethodPut, "/room-mappings/"+hotelID, map[string]any{
+		"supplier": supplier,
+		"rooms":    values,
+	}, nil).ExpectStatus(http.StatusOK)
+}
+
+// Get sends a GET request to path, which may carry a query string
+func (s *Server) Get(path string) *Response {
+	s.t.Helper()
+	return s.Do(http.MethodGet, path, nil, nil)
+}
+
+// Do sends a request to path. A non-nil body is sent as JSON unless it is
+// already a []byte or string.
+func (s *Server) Do(method, path string, body any, header http.Header) *Response {
+	s.t.Helper()
+	var r io.Reader
+	switch b := body.(type) {
+	case nil:
+	case []byte:
+		r = bytes.NewReader(b)
+	case string:
+		r = strings.NewReader(b)
+	default:
+		raw, err := json.Marshal(b)
+		if err != nil {
+			s.t.Fatalf("testutil: encode %s %s body: %v", method, path, err)
+		}
+		r = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequest(method, s.URL+path, r)
+	if err != nil {
+		s.t.Fatalf("testutil: build %s %s: %v", method, path, err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if r != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.t.Fatalf("testutil: %s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.t.Fatalf("testutil: read %s %s response: %v", method, path, err)
+	}
+	return &Response{Status: resp.StatusCode, Header: resp.Header, Body: raw, t: s.t, what: method + " " + path}
+}
+
+// Response is a completed request, with assertion helpers that fail the test
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+
+	t    testing.TB
+	what string
+}
+
+// ExpectStatus fails the test unless the response has status want
+func (r *Response) ExpectStatus(want int) *Response {
+	r.t.Helper()
+	if r.Status != want {
+		r.t.Fatalf("%s: status %d, want %d; body: %s", r.what, r.Status, want, r.Body)
+	}
+	return r
+}
+
+// JSON decodes the body into v, failing the test if it is not valid JSON
+func (r *Response) JSON(v any) {
+	r.t.Helper()
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		r.t.Fatalf("%s: decode body: %v; body: %s", r.what, err, r.Body)
+	}
+}
+
+// ExpectRooms fails the test unless the body is a lookup response holding
+// exactly want, in order
+func (r *Response) ExpectRooms(want ...Room) *Response {
+	r.t.Helper()
+	var body struct {
+		Rooms []Room `json:"rooms"`
+	}
+	r.JSON(&body)
+	if len(body.Rooms) == 0 && len(want) == 0 {
+		return r
+	}
+	if !reflect.DeepEqual(body.Rooms, want) {
+		r.t.Fatalf("%s: rooms %+v, want %+v", r.what, body.Rooms, want)
+	}
+	return r
+}
+
+// ExpectError fails the test unless the body is an error response with
+// code, e.g. "HOTEL_NOT_FOUND" or "BATCH_TOO_LARGE"
+func (r *Response) ExpectError(code string) *Response {
+	r.t.Helper()
+	var body struct {
+		Code string `json:"code"`
+	}
+	r.JSON(&body)
+	if body.Code != code {
+		r.t.Fatalf("%s: error code %q, want %q; body: %s", r.what, body.Code, code, r.Body)
+	}
+	return r
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,152 @@
+package testutil_test
+
+import (
+	"net/http"
+	"testing"
+
+	"room-mapping-cache/testutil"
+)
+
+func TestLookupNormalizesNames(t *testing.T) {
+	srv := testutil.NewServer(t, "CACHE_ENABLED=false")
+	srv.PutHotel("1001", "expedia", map[string]int64{
+		"Deluxe King Room":       1,
+		"  SUPERIOR double-room": 2,
+	})
+
+	srv.Get("/room-mappings/1001").ExpectStatus(http.StatusOK).ExpectRooms(
+		testutil.Room{Name: "deluxe king room", ID: 1},
+		testutil.Room{Name: "superior double room", ID: 2},
+	)
+}
+
+// expectMissing fails the test unless the lookup says the hotel has no rooms
+func expectMissing(t *testing.T, srv *testutil.Server, hotelID string) {
+	t.Helper()
+	var body struct {
+		Exists *bool `json:"exists"`
+	}
+	srv.Get("/room-mappings/" + hotelID).ExpectStatus(http.StatusOK).ExpectRooms().JSON(&body)
+	if body.Exists == nil || *body.Exists {
+		t.Fatalf("hotel %s: exists %v, want false", hotelID, body.Exists)
+	}
+}
+
+func TestLookupUnknownHotel(t *testing.T) {
+	srv := testutil.NewServer(t)
+	expectMissing(t, srv, "424242")
+
+	strict := testutil.NewServer(t, "MISSING_HOTEL_NOT_FOUND=true")
+	strict.Get("/room-mappings/424242").ExpectStatus(http.StatusNotFound).ExpectError("HOTEL_NOT_FOUND")
+}
+
+func TestWritesMergeAcrossSuppliers(t *testing.T) {
+	srv := testutil.NewServer(t, "CACHE_ENABLED=false")
+	srv.PutHotel("1001", "expedia", map[string]int64{"Twin Room": 4})
+	srv.PutHotel("1001", "hotelbeds", map[string]int64{"Junior Suite": 3})
+
+	srv.Get("/room-mappings/1001").ExpectStatus(http.StatusOK).ExpectRooms(
+		testutil.Room{Name: "junior suite", ID: 3},
+		testutil.Room{Name: "twin room", ID: 4},
+	)
+}
+
+func TestDeleteRooms(t *testing.T) {
+	srv := testutil.NewServer(t, "CACHE_ENABLED=false")
+	srv.PutHotel("1001", "expedia", map[string]int64{"Twin Room": 4, "Junior Suite": 3})
+
+	srv.Do(http.MethodDelete, "/room-mappings/1001/rooms", map[string]any{
+		"rooms": []string{"Twin Room"},
+	}, nil).ExpectStatus(http.StatusOK)
+
+	srv.Get("/room-mappings/1001").ExpectStatus(http.StatusOK).ExpectRooms(
+		testutil.Room{Name: "junior suite", ID: 3},
+	)
+}
+
+func TestBatchReportsEachHotel(t *testing.T) {
+	srv := testutil.NewServer(t)
+	srv.PutHotel("1001", "expedia", map[string]int64{"Twin Room": 4})
+
+	resp := srv.Do(http.MethodPost, "/room-mappings/batch", map[string]any{
+		"hotel_ids": []string{"1001", "1002"},
+	}, nil).ExpectStatus(http.StatusOK)
+
+	var body struct {
+		Hotels map[string]struct {
+			Status string          `json:"status"`
+			Rooms  []testutil.Room `json:"rooms"`
+		} `json:"hotels"`
+	}
+	resp.JSON(&body)
+	if got := body.Hotels["1001"]; got.Status != "ok" || len(got.Rooms) != 1 || got.Rooms[0].ID != 4 {
+		t.Errorf("hotel 1001: %+v", got)
+	}
+	if got := body.Hotels["1002"]; got.Status != "not_found" {
+		t.Errorf("hotel 1002: status %q, want not_found", got.Status)
+	}
+}
+
+func TestFilterAndCount(t *testing.T) {
+	srv := testutil.NewServer(t, "CACHE_ENABLED=false")
+	srv.PutHotel("1001", "expedia", map[string]int64{
+		"Deluxe King Room":  1,
+		"Deluxe Twin Room":  2,
+		"Superior Double":   3,
+		"Deluxe-King Suite": 5,
+	})
+
+	srv.Get("/room-mappings/1001/filter?name=deluxe%20king").ExpectStatus(http.StatusOK).ExpectRooms(
+		testutil.Room{Name: "deluxe king room", ID: 1},
+		testutil.Room{Name: "deluxe king suite", ID: 5},
+	)
+
+	var count struct {
+		Count int64 `json:"count"`
+	}
+	srv.Get("/room-mappings/1001/count?name=deluxe").ExpectStatus(http.StatusOK).JSON(&count)
+	if count.Count != 3 {
+		t.Errorf("count %d, want 3", count.Count)
+	}
+}
+
+func TestHotelMeta(t *testing.T) {
+	srv := testutil.NewServer(t)
+	srv.PutHotel("1001", "expedia", map[string]int64{"Twin Room": 4})
+	srv.Do(http.MethodPut, "/hotels/1001/meta", map[string]any{
+		"name": " Grand Hotel ",
+		"city": "Lisbon",
+	}, nil).ExpectStatus(http.StatusOK)
+
+	var meta struct {
+		Name string `json:"name"`
+		City string `json:"city"`
+	}
+	srv.Get("/hotels/1001/meta").ExpectStatus(http.StatusOK).JSON(&meta)
+	if meta.Name != "Grand Hotel" || meta.City != "Lisbon" {
+		t.Errorf("meta %+v", meta)
+	}
+}
+
+func TestInvalidWriteIsRejected(t *testing.T) {
+	srv := testutil.NewServer(t)
+
+	srv.Do(http.MethodPut, "/room-mappings/1001", `{"supplier":"expedia"`, nil).ExpectStatus(http.StatusBadRequest)
+	expectMissing(t, srv, "1001")
+}
+
+func TestRedisFailureIsNotAMiss(t *testing.T) {
+	srv := testutil.NewServer(t, "CACHE_ENABLED=false")
+	if srv.Redis == nil {
+		t.Skip("needs the in-process Redis to inject failures")
+	}
+	srv.PutHotel("1001", "expedia", map[string]int64{"Twin Room": 4})
+
+	srv.Redis.SetError("LOADING Redis is loading the dataset in memory")
+	resp := srv.Get("/room-mappings/1001")
+	srv.Redis.SetError("")
+	if resp.Status < 500 {
+		t.Fatalf("status %d during a Redis failure, want 5xx; body: %s", resp.Status, resp.Body)
+	}
+	srv.Get("/room-mappings/1001").ExpectStatus(http.StatusOK)
+}